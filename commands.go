@@ -0,0 +1,559 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gorm.io/gorm/logger"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/database"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/repository/repotest"
+	"go_pco_arrivals/internal/services"
+	"go_pco_arrivals/internal/utils"
+)
+
+// runCommand executes a maintenance subcommand such as "reencrypt-tokens"
+func runCommand(name string, args []string) error {
+	switch name {
+	case "reencrypt-tokens":
+		return runReencryptTokens()
+	case "check-storage":
+		return runCheckStorage(args)
+	case "migrate":
+		return runMigrate(args)
+	case "migrate-data":
+		return runMigrateData(args)
+	case "backup":
+		return runBackup(args)
+	case "restore":
+		return runRestore(args)
+	case "rollups":
+		return runRollups(args)
+	case "generate-encryption-key":
+		key, err := utils.GenerateEncryptionKey()
+		if err != nil {
+			return fmt.Errorf("failed to generate key: %w", err)
+		}
+		fmt.Println(key)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q (available: reencrypt-tokens, check-storage, migrate, migrate-data, backup, restore, rollups, generate-encryption-key)", name)
+	}
+}
+
+// configureTokenEncryption installs the cipher used for PCO tokens at rest
+func configureTokenEncryption(cfg *config.Config, logger *utils.Logger) error {
+	cipher, err := utils.NewTokenCipher(cfg.Auth.TokenEncryptionKeys, cfg.Auth.TokenEncryptionKeyID)
+	if err != nil {
+		return err
+	}
+
+	if !cipher.Enabled() {
+		logger.Warn("TOKEN_ENCRYPTION_KEYS is not set, PCO tokens will be stored in plaintext")
+	}
+
+	database.SetTokenCipher(cipher)
+	return nil
+}
+
+// runReencryptTokens rewrites stored PCO tokens with the active encryption key
+func runReencryptTokens() error {
+	logger := utils.NewLogger().WithComponent("reencrypt_tokens")
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if err := configureTokenEncryption(cfg, logger); err != nil {
+		return fmt.Errorf("failed to configure token encryption: %w", err)
+	}
+
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	store, err := repository.New(db)
+	if err != nil {
+		return err
+	}
+
+	updated, err := store.Users.ReencryptTokens()
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Re-encrypted user tokens", "users_updated", updated, "active_key_id", database.TokenCipher().ActiveKeyID())
+	return nil
+}
+
+// runMigrate inspects or moves the schema version:
+//
+//	migrate status | up | down | to N
+func runMigrate(args []string) error {
+	usage := fmt.Errorf("usage: migrate status|up|down|to N")
+	if len(args) == 0 {
+		return usage
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	// Only report migration progress, not every statement
+	quietSQL(db)
+
+	switch args[0] {
+	case "status":
+	case "up":
+		if err := db.Migrate(); err != nil {
+			return err
+		}
+	case "down":
+		statuses, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		// Revert the newest applied migration only
+		target, applied := 0, 0
+		for _, s := range statuses {
+			if s.AppliedAt != nil {
+				target, applied = applied, s.Version
+			}
+		}
+		if applied == 0 {
+			return fmt.Errorf("no migrations are applied")
+		}
+		if err := db.MigrateTo(target); err != nil {
+			return err
+		}
+	case "to":
+		if len(args) != 2 {
+			return usage
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := db.MigrateTo(version); err != nil {
+			return err
+		}
+	default:
+		return usage
+	}
+
+	return printMigrationStatus(db)
+}
+
+// openBackupService connects to the configured database for the backup and
+// restore commands. The caller closes the database.
+func openBackupService() (*services.BackupService, database.Database, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	quietSQL(db)
+
+	backups, err := services.NewBackupService(cfg.Backup, db, utils.NewLogger())
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return backups, db, nil
+}
+
+// runBackup writes a snapshot to BACKUP_DIR, or lists the existing ones:
+//
+//	backup [list]
+func runBackup(args []string) error {
+	backups, db, err := openBackupService()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if len(args) > 0 && args[0] == "list" {
+		list, err := backups.List()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSIZE\tCREATED")
+		for _, b := range list {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", b.Name, b.Size, b.CreatedAt.Format(time.RFC3339))
+		}
+		return w.Flush()
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("usage: backup [list]")
+	}
+
+	info, err := backups.Create()
+	if err != nil {
+		return err
+	}
+	path, err := backups.Path(info.Name)
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
+
+// runRestore replaces the database with a backup file. Stop the server first.
+//
+//	restore FILE
+func runRestore(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: restore FILE")
+	}
+
+	backups, db, err := openBackupService()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := backups.Restore(args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s\n", args[0])
+	return nil
+}
+
+// runRollups recomputes the attendance rollups from the raw check-ins still
+// stored. Days whose check-ins have been purged keep their rollups.
+//
+//	rollups rebuild [--since YYYY-MM-DD]
+func runRollups(args []string) error {
+	usage := fmt.Errorf("usage: rollups rebuild [--since YYYY-MM-DD]")
+	if len(args) == 0 || args[0] != "rebuild" {
+		return usage
+	}
+	flags := flag.NewFlagSet("rollups rebuild", flag.ContinueOnError)
+	sinceFlag := flags.String("since", "", "first day to rebuild (default: the check-in retention window)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	since := time.Now().AddDate(0, 0, -services.CheckInRetentionDays)
+	if *sinceFlag != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", *sinceFlag, time.Local)
+		if err != nil {
+			return usage
+		}
+		since = parsed
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+	quietSQL(db)
+
+	if err := db.Migrate(); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	store, err := repository.New(db)
+	if err != nil {
+		return err
+	}
+
+	days, err := services.NewRollupService(store, utils.NewLogger()).Rebuild(since)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Rebuilt rollups for %d days since %s\n", days, since.Format("2006-01-02"))
+	return nil
+}
+
+// quietSQL stops GORM logging every statement, keeping warnings
+func quietSQL(db database.Database) {
+	switch conn := db.(type) {
+	case *database.SQLiteDatabase:
+		conn.GetGormDB().Logger = logger.Default.LogMode(logger.Warn)
+	case *database.PostgresDatabase:
+		conn.GetGormDB().Logger = logger.Default.LogMode(logger.Warn)
+	}
+}
+
+// runMigrateData copies every table from one database to another, keeping
+// IDs and timestamps. Rerunning after an interruption resumes each table
+// after the highest ID already copied.
+//
+//	migrate-data --from URL --to URL [--batch N]
+func runMigrateData(args []string) error {
+	flags := flag.NewFlagSet("migrate-data", flag.ContinueOnError)
+	from := flags.String("from", "", "source database URL (sqlite://, postgres://, mongodb://)")
+	to := flags.String("to", "", "target database URL")
+	batchSize := flags.Int("batch", 500, "rows per batch")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" || *batchSize <= 0 {
+		return fmt.Errorf("usage: migrate-data --from URL --to URL [--batch N]")
+	}
+	if *from == *to {
+		return fmt.Errorf("source and target are the same database")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	// Tokens are decrypted on read and sealed again on write, so both sides
+	// use the configured keys
+	if err := configureTokenEncryption(cfg, utils.NewLogger().WithComponent("migrate_data")); err != nil {
+		return fmt.Errorf("failed to configure token encryption: %w", err)
+	}
+
+	source, err := database.ConnectURL(*from, cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to source: %w", err)
+	}
+	defer source.Close()
+	quietSQL(source)
+
+	target, err := database.ConnectURL(*to, cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to target: %w", err)
+	}
+	defer target.Close()
+	quietSQL(target)
+
+	// Every model is read with this binary's schema, so the source must be
+	// on exactly the same migration
+	statuses, err := source.MigrationStatus()
+	if err != nil {
+		return fmt.Errorf("failed to read source schema version: %w", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil || s.Unknown {
+			return fmt.Errorf("source schema does not match this release (migration %d %s), run migrate on it first", s.Version, s.Name)
+		}
+	}
+	if err := target.Migrate(); err != nil {
+		return fmt.Errorf("failed to migrate target: %w", err)
+	}
+
+	src, err := repository.NewBulk(source)
+	if err != nil {
+		return err
+	}
+	dst, err := repository.NewBulk(target)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range repository.BulkTables {
+		if err := copyTable(src, dst, table, *batchSize); err != nil {
+			return err
+		}
+	}
+	return verifyCopy(src, dst)
+}
+
+// copyTable streams one table in ID order, starting after the highest ID
+// the target already holds
+func copyTable(src, dst repository.Bulk, table string, batchSize int) error {
+	after, err := dst.MaxID(table)
+	if err != nil {
+		return err
+	}
+	if after > 0 {
+		fmt.Printf("%s: resuming after id %d\n", table, after)
+	}
+
+	copied := 0
+	for {
+		batch, err := src.Read(table, after, batchSize)
+		if err != nil {
+			return err
+		}
+		if batch.Len == 0 {
+			break
+		}
+		if err := dst.Write(batch); err != nil {
+			return err
+		}
+		copied += batch.Len
+		after = batch.LastID
+		fmt.Printf("%s: copied %d rows (through id %d)\n", table, copied, after)
+	}
+	return nil
+}
+
+// verifyCopy compares row counts table by table
+func verifyCopy(src, dst repository.Bulk) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tSOURCE\tTARGET")
+
+	var mismatched []string
+	for _, table := range repository.BulkTables {
+		sourceCount, err := src.Count(table)
+		if err != nil {
+			return err
+		}
+		targetCount, err := dst.Count(table)
+		if err != nil {
+			return err
+		}
+		status := ""
+		if sourceCount != targetCount {
+			status = "\tMISMATCH"
+			mismatched = append(mismatched, table)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d%s\n", table, sourceCount, targetCount, status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(mismatched) > 0 {
+		return fmt.Errorf("row counts differ for %s", strings.Join(mismatched, ", "))
+	}
+	return nil
+}
+
+func printMigrationStatus(db database.Database) error {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Unknown {
+			applied += " (unknown to this binary)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	return w.Flush()
+}
+
+// runCheckStorage runs the repository conformance suite against the
+// configured backend, or against the in-memory store with "check-storage
+// memory". SQLite runs in memory; PostgreSQL and MongoDB run in throwaway
+// schemas or databases on the configured server, which are dropped
+// afterwards.
+func runCheckStorage(args []string) error {
+	if len(args) > 0 && args[0] == "memory" {
+		return reportConformance("memory", repotest.Run(func(name string) (*repository.Store, func(), error) {
+			return repository.NewMemoryStore(), func() {}, nil
+		}))
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	cipher, err := utils.NewTokenCipher(cfg.Auth.TokenEncryptionKeys, cfg.Auth.TokenEncryptionKeyID)
+	if err != nil {
+		return fmt.Errorf("failed to configure token encryption: %w", err)
+	}
+	database.SetTokenCipher(cipher)
+
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer db.Close()
+
+	var newStore func(name string) (*repository.Store, func(), error)
+	switch conn := db.(type) {
+	case *database.MongoDBDatabase:
+		prefix := fmt.Sprintf("%s_conformance_%d", conn.GetMongoDB().Name(), time.Now().Unix())
+		newStore = func(name string) (*repository.Store, func(), error) {
+			mongoDB := conn.GetMongoDB().WithDatabase(prefix + "_" + name)
+			if err := mongoDB.Migrate(); err != nil {
+				return nil, nil, err
+			}
+			return repository.NewMongoStore(mongoDB), func() { mongoDB.Drop() }, nil
+		}
+	case *database.PostgresDatabase:
+		prefix := fmt.Sprintf("conformance_%d", time.Now().Unix())
+		newStore = func(name string) (*repository.Store, func(), error) {
+			scoped, err := conn.WithSchema(prefix + "_" + name)
+			if err != nil {
+				return nil, nil, err
+			}
+			scoped.GetGormDB().Logger = logger.Default.LogMode(logger.Silent)
+			if err := scoped.Migrate(); err != nil {
+				scoped.DropSchema()
+				scoped.Close()
+				return nil, nil, err
+			}
+			cleanup := func() {
+				scoped.DropSchema()
+				scoped.Close()
+			}
+			return repository.NewGormStore(scoped.GetGormDB()), cleanup, nil
+		}
+	default:
+		newStore = func(name string) (*repository.Store, func(), error) {
+			gormDB, err := database.ConnectLegacy(config.DatabaseConfig{URL: ":memory:"})
+			if err != nil {
+				return nil, nil, err
+			}
+			gormDB.Logger = logger.Default.LogMode(logger.Silent)
+			// Every connection to :memory: is a separate database
+			if err := database.ConfigureConnectionPool(gormDB, 1, 1, 0); err != nil {
+				return nil, nil, err
+			}
+			if err := database.MigrateLegacy(gormDB); err != nil {
+				return nil, nil, err
+			}
+			cleanup := func() {
+				if sqlDB, err := gormDB.DB(); err == nil {
+					sqlDB.Close()
+				}
+			}
+			return repository.NewGormStore(gormDB), cleanup, nil
+		}
+	}
+
+	return reportConformance(string(db.GetType()), repotest.Run(newStore))
+}
+
+func reportConformance(backend string, results []repotest.Result) error {
+	fmt.Print(repotest.Summary(results))
+
+	for _, result := range results {
+		if !result.Passed() {
+			return fmt.Errorf("%s storage failed the conformance suite", backend)
+		}
+	}
+	return nil
+}
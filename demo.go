@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+)
+
+// demoFolder is the folder the demo rooms are in
+const demoFolder = "demo-kids-wing"
+
+// demoLocations are the rooms seeded in demo mode, with a volunteer each
+// and the ages (in months) or grades they are for. The nursery is near its
+// capacity and the toddlers at their ratio.
+var demoLocations = []struct {
+	pcoID     string
+	name      string
+	capacity  int
+	ratio     int
+	volunteer string
+	ages      [2]int
+	grades    [2]int
+}{
+	{"demo-nursery", "Nursery", 6, 0, "Grace Miller", [2]int{0, 24}, [2]int{}},
+	{"demo-toddlers", "Toddlers", 0, 4, "Daniel Clark", [2]int{24, 48}, [2]int{}},
+	{"demo-elementary", "Elementary", 0, 8, "Olivia Lewis", [2]int{}, [2]int{1, 5}},
+}
+
+var demoChildren = []string{
+	"Ava Thompson", "Liam Nguyen", "Mia Garcia", "Noah Patel", "Emma Johnson",
+	"Lucas Kim", "Sophia Martinez", "Ethan Brown", "Isla Wilson", "Mason Lee",
+	"Harper Davis", "Leo Anderson",
+}
+
+// setDemoEnvironment fills in the PCO settings demo mode doesn't use, so
+// configuration loads without real credentials, and names the demo church
+func setDemoEnvironment() {
+	defaults := map[string]string{
+		"PCO_CLIENT_ID":     "demo",
+		"PCO_CLIENT_SECRET": "demo",
+		"PCO_REDIRECT_URI":  "http://localhost:3000/auth/callback",
+		"CHURCH_NAME":       "Demo Community Church",
+	}
+	for key, value := range defaults {
+		if os.Getenv(key) == "" {
+			os.Setenv(key, value)
+		}
+	}
+}
+
+// seedDemoData fills an empty store with an admin user, locations, today's
+// event, check-ins spread over the morning and a few pickup requests. It
+// returns the admin user so a session can be created for it.
+func seedDemoData(store *repository.Store, now time.Time) (*models.User, error) {
+	admin := &models.User{
+		PCOUserID:    "demo-admin",
+		Name:         "Demo Admin",
+		Email:        "demo@example.com",
+		IsAdmin:      true,
+		IsActive:     true,
+		AccessToken:  "demo",
+		RefreshToken: "demo",
+		TokenExpiry:  now.AddDate(1, 0, 0),
+		LastLogin:    now,
+		LastActivity: now,
+	}
+	if err := store.Users.Create(admin); err != nil {
+		return nil, fmt.Errorf("failed to seed demo user: %w", err)
+	}
+
+	folder := &models.Location{PCOLocationID: demoFolder, PCOEventID: "demo-sunday-service", Name: "Kids Wing", Kind: models.LocationKindFolder, IsActive: true}
+	if err := store.Locations.Create(folder); err != nil {
+		return nil, fmt.Errorf("failed to seed location folder: %w", err)
+	}
+	for i, l := range demoLocations {
+		location := &models.Location{PCOLocationID: l.pcoID, PCOEventID: "demo-sunday-service", Name: l.name, Kind: models.LocationKindLocation, ParentID: demoFolder, Position: i, MaxOccupancy: l.capacity, Ratio: l.ratio, IsActive: true}
+		if l.grades[1] > 0 {
+			location.GradeMin, location.GradeMax = &l.grades[0], &l.grades[1]
+		} else {
+			location.AgeMinMonths, location.AgeMaxMonths = &l.ages[0], &l.ages[1]
+		}
+		if err := store.Locations.Create(location); err != nil {
+			return nil, fmt.Errorf("failed to seed location %s: %w", l.name, err)
+		}
+	}
+
+	serviceStart := time.Date(now.Year(), now.Month(), now.Day(), 9, 0, 0, 0, now.Location())
+	event := &models.Event{
+		PCOEventID:   "demo-sunday-service",
+		Source:       models.EventSourcePCO,
+		Name:         "Sunday Service",
+		Date:         serviceStart,
+		StartTime:    serviceStart,
+		EndTime:      serviceStart.Add(90 * time.Minute),
+		LocationID:   demoLocations[0].pcoID,
+		LocationName: demoLocations[0].name,
+		IsActive:     true,
+		CreatedBy:    admin.PCOUserID,
+	}
+	if err := store.Events.Create(event); err != nil {
+		return nil, fmt.Errorf("failed to seed event: %w", err)
+	}
+	period := &models.EventPeriod{
+		PCOEventPeriodID: "demo-sunday-period",
+		EventID:          event.ID,
+		StartsAt:         event.StartTime.UTC(),
+		EndsAt:           event.EndTime.UTC(),
+	}
+	if err := store.Events.SavePeriod(period); err != nil {
+		return nil, fmt.Errorf("failed to seed event period: %w", err)
+	}
+	eventTime := &models.EventTime{
+		PCOEventTimeID: "demo-sunday-9am",
+		EventPeriodID:  period.ID,
+		EventID:        event.ID,
+		Name:           "9:00 Service",
+		StartsAt:       period.StartsAt,
+		ShowsAt:        period.StartsAt.Add(-30 * time.Minute),
+		HidesAt:        period.EndsAt,
+	}
+	if err := store.Events.SaveTime(eventTime); err != nil {
+		return nil, fmt.Errorf("failed to seed event time: %w", err)
+	}
+
+	var pickupCodes []string
+	for i, child := range demoChildren {
+		location := demoLocations[i%len(demoLocations)]
+		code := fmt.Sprintf("D%03d", 100+i*7)
+		checkIn := &models.CheckIn{
+			PCOCheckInID: fmt.Sprintf("demo-check-in-%d", i+1),
+			PersonID:     fmt.Sprintf("demo-person-%d", i+1),
+			PersonName:   child,
+			LocationID:   location.pcoID,
+			LocationName: location.name,
+			SecurityCode: code,
+			CheckInTime:  now.Add(-time.Duration(90-i*5) * time.Minute),
+			EventID:      event.PCOEventID,
+			EventName:    event.Name,
+			ParentName:   "Parent of " + child,
+			ParentPhone:  fmt.Sprintf("555-01%02d", i),
+			ParentEmail:  fmt.Sprintf("parent%d@example.com", i+1),
+			Number:       i + 1,
+			FirstTime:    i == 0,
+		}
+		// The second child has an allergy for staff with medical access to
+		// see on the roster
+		if i == 1 {
+			checkIn.MedicalNotes = "Peanut allergy, EpiPen in backpack"
+			checkIn.EmergencyContactName = checkIn.ParentName
+			checkIn.EmergencyContactPhone = "555-0142"
+		}
+		// The last child has already been picked up
+		if i == len(demoChildren)-1 {
+			out := now.Add(-5 * time.Minute)
+			checkIn.CheckedOutAt = &out
+			checkIn.Status = models.CheckInStatusCheckedOut
+		}
+		if err := store.CheckIns.Create(checkIn); err != nil {
+			return nil, fmt.Errorf("failed to seed check-in for %s: %w", child, err)
+		}
+
+		// Every fourth child has a parent waiting at the door
+		if i%4 != 0 {
+			continue
+		}
+		notification := &models.Notification{
+			PCOCheckInID: checkIn.PCOCheckInID,
+			ChildName:    child,
+			SecurityCode: code,
+			LocationID:   location.pcoID,
+			LocationName: location.name,
+			EventID:      event.ID,
+			EventName:    event.Name,
+			ParentName:   checkIn.ParentName,
+			ParentPhone:  checkIn.ParentPhone,
+			ParentEmail:  checkIn.ParentEmail,
+			ExpiresAt:    now.Add(10 * time.Minute),
+			CreatedBy:    admin.PCOUserID,
+		}
+		if err := store.Notifications.Create(notification); err != nil {
+			return nil, fmt.Errorf("failed to seed notification for %s: %w", child, err)
+		}
+		pickupCodes = append(pickupCodes, code)
+	}
+
+	for i, l := range demoLocations {
+		volunteer := &models.CheckIn{
+			PCOCheckInID: fmt.Sprintf("demo-volunteer-%d", i+1),
+			PersonID:     fmt.Sprintf("demo-volunteer-person-%d", i+1),
+			PersonName:   l.volunteer,
+			LocationID:   l.pcoID,
+			LocationName: l.name,
+			CheckInTime:  now.Add(-100 * time.Minute),
+			EventID:      event.PCOEventID,
+			EventName:    event.Name,
+			Kind:         models.CheckInKindVolunteer,
+		}
+		if err := store.CheckIns.Create(volunteer); err != nil {
+			return nil, fmt.Errorf("failed to seed volunteer %s: %w", l.volunteer, err)
+		}
+	}
+
+	for _, code := range pickupCodes {
+		if err := store.SecurityCodes.Create(&models.SecurityCode{Code: code, IsActive: true, CreatedBy: admin.PCOUserID}); err != nil {
+			return nil, fmt.Errorf("failed to seed security code %s: %w", code, err)
+		}
+	}
+
+	billboard := &models.BillboardState{
+		EventID:       event.ID,
+		EventName:     event.Name,
+		Date:          serviceStart,
+		LocationID:    demoLocations[0].pcoID,
+		LocationName:  demoLocations[0].name,
+		SecurityCodes: pickupCodes,
+		IsActive:      true,
+		LastUpdated:   now,
+		CreatedBy:     admin.PCOUserID,
+	}
+	if err := store.BillboardStates.Create(billboard); err != nil {
+		return nil, fmt.Errorf("failed to seed billboard: %w", err)
+	}
+
+	return admin, nil
+}
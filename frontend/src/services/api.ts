@@ -22,8 +22,13 @@ import type {
 
 const API_BASE_URL = '';
 
+const CSRF_HEADER = 'X-CSRF-Token';
+const SAFE_METHODS = ['GET', 'HEAD', 'OPTIONS'];
+
 class ApiService {
   private baseURL: string;
+  // Issued by /auth/status and required on state-changing requests
+  private csrfToken: string | null = null;
 
   constructor(baseURL: string = API_BASE_URL) {
     this.baseURL = baseURL;
@@ -34,14 +39,20 @@ class ApiService {
     options: RequestInit = {}
   ): Promise<T> {
     const url = `${this.baseURL}${endpoint}`;
-    
+    const method = (options.method || 'GET').toUpperCase();
+
+    const headers: Record<string, string> = {
+      'Content-Type': 'application/json',
+      ...(options.headers as Record<string, string>),
+    };
+    if (!SAFE_METHODS.includes(method) && this.csrfToken) {
+      headers[CSRF_HEADER] = this.csrfToken;
+    }
+
     const config: RequestInit = {
-      headers: {
-        'Content-Type': 'application/json',
-        ...options.headers,
-      },
-      credentials: 'include', // Include cookies for session management
       ...options,
+      headers,
+      credentials: 'include', // Include cookies for session management
     };
 
     try {
@@ -60,7 +71,9 @@ class ApiService {
 
   // Auth endpoints
   async getAuthStatus(): Promise<AuthStatusResponse> {
-    return this.request<AuthStatusResponse>('/auth/status');
+    const status = await this.request<AuthStatusResponse>('/auth/status');
+    this.csrfToken = status.csrf_token ?? null;
+    return status;
   }
 
   async login(rememberMe: boolean = false): Promise<void> {
@@ -70,6 +83,7 @@ class ApiService {
 
   async logout(): Promise<void> {
     await this.request('/auth/logout', { method: 'POST' });
+    this.csrfToken = null;
   }
 
   async refreshToken(): Promise<void> {
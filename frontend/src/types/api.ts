@@ -36,6 +36,7 @@ export interface AuthStatusResponse {
   user?: User;
   session?: SessionData;
   expires_at?: string;
+  csrf_token?: string;
 }
 
 export interface LoginRequest {
@@ -18,6 +18,8 @@ type Config struct {
 	Auth     AuthConfig     `json:"auth"`
 	Redis    RedisConfig    `json:"redis"`
 	Realtime RealtimeConfig `json:"realtime"`
+	Backup   BackupConfig   `json:"backup"`
+	Reports  ReportsConfig  `json:"reports"`
 }
 
 type ServerConfig struct {
@@ -42,15 +44,23 @@ type PCOConfig struct {
 	Scopes       string `json:"scopes"`
 	AccessToken  string `json:"access_token"`
 	AccessSecret string `json:"access_secret"`
+	// GuardianTTL is how long, in seconds, a person's guardian from PCO
+	// People is cached
+	GuardianTTL int `json:"guardian_ttl"`
 }
 
 type AuthConfig struct {
 	SessionTTL            int      `json:"session_ttl"`
+	SessionIdleTimeout    int      `json:"session_idle_timeout"`
+	SessionActivityWrite  int      `json:"session_activity_write"`
 	RememberMeDays        int      `json:"remember_me_days"`
 	AuthorizedUsers       []string `json:"authorized_users"`
 	SessionSecret         string   `json:"session_secret"`
 	JWTSecret             string   `json:"jwt_secret"`
+	APITokenTTL           int      `json:"api_token_ttl"`
 	TokenRefreshThreshold int      `json:"token_refresh_threshold"`
+	TokenEncryptionKeys   string   `json:"-"`
+	TokenEncryptionKeyID  string   `json:"token_encryption_key_id"`
 }
 
 type RedisConfig struct {
@@ -64,10 +74,23 @@ type RealtimeConfig struct {
 	PollingFallback      bool `json:"polling_fallback"`
 	PollingInterval      int  `json:"polling_interval"`
 	LocationPollInterval int  `json:"location_poll_interval"`
+	LocationSyncInterval int  `json:"location_sync_interval"`
+	NearCapacityPercent  int  `json:"near_capacity_percent"`
 	MaxConnections       int  `json:"max_connections"`
 	HeartbeatInterval    int  `json:"heartbeat_interval"`
 }
 
+type BackupConfig struct {
+	Dir           string `json:"dir"`
+	Retention     int    `json:"retention"`
+	EncryptionKey string `json:"-"`
+}
+
+// ReportsConfig is shown on exported reports
+type ReportsConfig struct {
+	ChurchName string `json:"church_name"`
+}
+
 func Load() (*Config, error) {
 	cfg := &Config{
 		Server: ServerConfig{
@@ -88,14 +111,20 @@ func Load() (*Config, error) {
 			RedirectURI:  getEnv("PCO_REDIRECT_URI", ""),
 			BaseURL:      getEnv("PCO_BASE_URL", "https://api.planningcenteronline.com"),
 			Scopes:       getEnv("PCO_SCOPES", "people check_ins"),
+			GuardianTTL:  getEnvInt("GUARDIAN_CACHE_TTL", 3600),
 		},
 		Auth: AuthConfig{
 			SessionTTL:            getEnvInt("SESSION_TTL", 3600),
+			SessionIdleTimeout:    getEnvInt("SESSION_IDLE_TIMEOUT", 1800),
+			SessionActivityWrite:  getEnvInt("SESSION_ACTIVITY_WRITE_INTERVAL", 60),
 			RememberMeDays:        getEnvInt("REMEMBER_ME_DAYS", 30),
 			AuthorizedUsers:       strings.Split(getEnv("AUTHORIZED_USERS", ""), ","),
 			SessionSecret:         getEnv("SESSION_SECRET", generateSessionSecret()),
 			JWTSecret:             getEnv("JWT_SECRET", generateJWTSecret()),
+			APITokenTTL:           getEnvInt("API_TOKEN_TTL", 900),
 			TokenRefreshThreshold: getEnvInt("TOKEN_REFRESH_THRESHOLD", 300),
+			TokenEncryptionKeys:   getEnv("TOKEN_ENCRYPTION_KEYS", ""),
+			TokenEncryptionKeyID:  getEnv("TOKEN_ENCRYPTION_KEY_ID", ""),
 		},
 		Redis: RedisConfig{
 			URL:      getEnv("REDIS_URL", ""),
@@ -107,9 +136,19 @@ func Load() (*Config, error) {
 			PollingFallback:      getEnvBool("POLLING_FALLBACK", true),
 			PollingInterval:      getEnvInt("POLLING_INTERVAL", 10),
 			LocationPollInterval: getEnvInt("LOCATION_POLL_INTERVAL", 60),
+			LocationSyncInterval: getEnvInt("LOCATION_SYNC_INTERVAL", 3600),
+			NearCapacityPercent:  getEnvInt("NEAR_CAPACITY_PERCENT", 80),
 			MaxConnections:       getEnvInt("MAX_CONNECTIONS", 1000),
 			HeartbeatInterval:    getEnvInt("HEARTBEAT_INTERVAL", 30),
 		},
+		Backup: BackupConfig{
+			Dir:           getEnv("BACKUP_DIR", "./backups"),
+			Retention:     getEnvInt("BACKUP_RETENTION", 7),
+			EncryptionKey: getEnv("BACKUP_ENCRYPTION_KEY", ""),
+		},
+		Reports: ReportsConfig{
+			ChurchName: getEnv("CHURCH_NAME", ""),
+		},
 	}
 
 	// Validate required fields
@@ -122,6 +161,9 @@ func Load() (*Config, error) {
 	if cfg.PCO.RedirectURI == "" {
 		return nil, fmt.Errorf("PCO_REDIRECT_URI is required")
 	}
+	if cfg.Realtime.NearCapacityPercent < 1 || cfg.Realtime.NearCapacityPercent > 100 {
+		return nil, fmt.Errorf("NEAR_CAPACITY_PERCENT must be between 1 and 100")
+	}
 
 	return cfg, nil
 }
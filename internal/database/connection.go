@@ -2,30 +2,39 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
 	"go_pco_arrivals/internal/config"
-	"go_pco_arrivals/internal/models"
 )
 
 // DatabaseType represents the type of database being used
 type DatabaseType string
 
 const (
-	SQLiteDB  DatabaseType = "sqlite"
-	MongoDBDB DatabaseType = "mongodb"
+	SQLiteDB   DatabaseType = "sqlite"
+	PostgresDB DatabaseType = "postgres"
+	MongoDBDB  DatabaseType = "mongodb"
 )
 
-// Database interface for both SQLite and MongoDB
+// Database interface for SQLite, PostgreSQL and MongoDB
 type Database interface {
 	Connect() error
 	Close() error
+	// Migrate applies every pending migration and fails with ErrSchemaAhead
+	// when the database is newer than the binary
 	Migrate() error
+	// MigrateTo applies or reverts migrations to reach version
+	MigrateTo(version int) error
+	MigrationStatus() ([]MigrationStatus, error)
 	GetType() DatabaseType
 }
 
@@ -35,6 +44,13 @@ type SQLiteDatabase struct {
 	db     *gorm.DB
 }
 
+// PostgresDatabase wraps GORM PostgreSQL connection
+type PostgresDatabase struct {
+	config config.DatabaseConfig
+	db     *gorm.DB
+	schema string
+}
+
 // MongoDBDatabase wraps MongoDB connection
 type MongoDBDatabase struct {
 	mongodb *MongoDB
@@ -49,6 +65,8 @@ func Connect(cfg config.DatabaseConfig) (Database, error) {
 	switch dbType {
 	case MongoDBDB:
 		return connectMongoDB()
+	case PostgresDB:
+		return ConnectPostgres(cfg)
 	case SQLiteDB:
 		fallthrough
 	default:
@@ -56,6 +74,42 @@ func Connect(cfg config.DatabaseConfig) (Database, error) {
 	}
 }
 
+// ConnectURL opens the database a URL points at, ignoring DATABASE_TYPE and
+// DATABASE_URL: sqlite://PATH, postgres://... (or postgresql://), or
+// mongodb://... (or mongodb+srv://) with the database name as the path.
+// Pool settings still come from cfg.
+func ConnectURL(rawURL string, cfg config.DatabaseConfig) (Database, error) {
+	scheme, rest, ok := strings.Cut(rawURL, "://")
+	if !ok {
+		return nil, fmt.Errorf("database URL %q has no scheme", rawURL)
+	}
+
+	switch scheme {
+	case "sqlite":
+		cfg.URL = rest
+		return connectSQLite(cfg)
+	case "postgres", "postgresql":
+		cfg.URL = rawURL
+		return ConnectPostgres(cfg)
+	case "mongodb", "mongodb+srv":
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MongoDB URL: %w", err)
+		}
+		name := strings.TrimPrefix(u.Path, "/")
+		if name == "" {
+			name = "pco_arrivals"
+		}
+		mongodb, err := ConnectMongo(rawURL, name)
+		if err != nil {
+			return nil, err
+		}
+		return &MongoDBDatabase{mongodb: mongodb}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database URL scheme %q", scheme)
+	}
+}
+
 func connectSQLite(cfg config.DatabaseConfig) (*SQLiteDatabase, error) {
 	db, err := gorm.Open(sqlite.Open(cfg.URL), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
@@ -70,6 +124,30 @@ func connectSQLite(cfg config.DatabaseConfig) (*SQLiteDatabase, error) {
 	}, nil
 }
 
+// ConnectPostgres opens a PostgreSQL connection using DATABASE_URL as the DSN
+// and sizes the pool from the database configuration
+func ConnectPostgres(cfg config.DatabaseConfig) (*PostgresDatabase, error) {
+	db, err := gorm.Open(postgres.Open(cfg.URL), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Info),
+		// Check-ins reference locations and events by PCO ID rather than
+		// primary key, so the model relationships can't be real constraints.
+		// SQLite never enforced them either.
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ConfigureConnectionPool(db, cfg.MaxOpenConns, cfg.MaxIdleConns, time.Duration(cfg.ConnMaxLifetime)*time.Second); err != nil {
+		return nil, err
+	}
+
+	return &PostgresDatabase{
+		config: cfg,
+		db:     db,
+	}, nil
+}
+
 func connectMongoDB() (*MongoDBDatabase, error) {
 	mongodb, err := NewMongoDB()
 	if err != nil {
@@ -96,16 +174,15 @@ func (s *SQLiteDatabase) Close() error {
 }
 
 func (s *SQLiteDatabase) Migrate() error {
-	return s.db.AutoMigrate(
-		&models.User{},
-		&models.Session{},
-		&models.Event{},
-		&models.Notification{},
-		&models.CheckIn{},
-		&models.Location{},
-		&models.BillboardState{},
-		&models.SecurityCode{},
-	)
+	return migrateSQL(s.db)
+}
+
+func (s *SQLiteDatabase) MigrateTo(version int) error {
+	return migrateTo(gormMigrations{db: s.db}, version)
+}
+
+func (s *SQLiteDatabase) MigrationStatus() ([]MigrationStatus, error) {
+	return migrationStatus(gormMigrations{db: s.db})
 }
 
 func (s *SQLiteDatabase) GetType() DatabaseType {
@@ -117,6 +194,84 @@ func (s *SQLiteDatabase) GetGormDB() *gorm.DB {
 	return s.db
 }
 
+// PostgresDatabase methods
+func (p *PostgresDatabase) Connect() error {
+	// Already connected in constructor
+	return nil
+}
+
+func (p *PostgresDatabase) Close() error {
+	sqlDB, err := p.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+func (p *PostgresDatabase) Migrate() error {
+	return migrateSQL(p.db)
+}
+
+func (p *PostgresDatabase) MigrateTo(version int) error {
+	return migrateTo(gormMigrations{db: p.db}, version)
+}
+
+func (p *PostgresDatabase) MigrationStatus() ([]MigrationStatus, error) {
+	return migrationStatus(gormMigrations{db: p.db})
+}
+
+func (p *PostgresDatabase) GetType() DatabaseType {
+	return PostgresDB
+}
+
+// GetGormDB returns the underlying GORM DB for PostgreSQL
+func (p *PostgresDatabase) GetGormDB() *gorm.DB {
+	return p.db
+}
+
+// WithSchema creates schema if needed and returns a separate connection whose
+// search path points at it, so its tables are isolated from the default schema
+func (p *PostgresDatabase) WithSchema(schema string) (*PostgresDatabase, error) {
+	if err := p.db.Exec("CREATE SCHEMA IF NOT EXISTS " + quoteIdentifier(schema)).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schema %s: %w", schema, err)
+	}
+
+	cfg := p.config
+	cfg.URL = withSearchPath(cfg.URL, schema)
+	scoped, err := ConnectPostgres(cfg)
+	if err != nil {
+		return nil, err
+	}
+	scoped.db.Logger = p.db.Logger
+	scoped.schema = schema
+	return scoped, nil
+}
+
+// DropSchema removes the schema created by WithSchema and everything in it
+func (p *PostgresDatabase) DropSchema() error {
+	if p.schema == "" {
+		return fmt.Errorf("connection is not scoped to a schema")
+	}
+	return p.db.Exec("DROP SCHEMA IF EXISTS " + quoteIdentifier(p.schema) + " CASCADE").Error
+}
+
+// withSearchPath adds a search_path runtime parameter to a URL or key=value DSN
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if u, err := url.Parse(dsn); err == nil {
+			query := u.Query()
+			query.Set("search_path", schema)
+			u.RawQuery = query.Encode()
+			return u.String()
+		}
+	}
+	return strings.TrimSpace(dsn) + " search_path=" + schema
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
 // MongoDBDatabase methods
 func (m *MongoDBDatabase) Connect() error {
 	return m.mongodb.Migrate()
@@ -130,6 +285,14 @@ func (m *MongoDBDatabase) Migrate() error {
 	return m.mongodb.Migrate()
 }
 
+func (m *MongoDBDatabase) MigrateTo(version int) error {
+	return migrateTo(mongoMigrationSet{db: m.mongodb}, version)
+}
+
+func (m *MongoDBDatabase) MigrationStatus() ([]MigrationStatus, error) {
+	return migrationStatus(mongoMigrationSet{db: m.mongodb})
+}
+
 func (m *MongoDBDatabase) GetType() DatabaseType {
 	return MongoDBDB
 }
@@ -165,16 +328,7 @@ func ConfigureConnectionPool(db *gorm.DB, maxOpenConns, maxIdleConns int, connMa
 }
 
 func MigrateLegacy(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&models.User{},
-		&models.Session{},
-		&models.Event{},
-		&models.Notification{},
-		&models.CheckIn{},
-		&models.Location{},
-		&models.BillboardState{},
-		&models.SecurityCode{},
-	)
+	return migrateSQL(db)
 }
 
 func GetDBStats(db *gorm.DB) (*sql.DBStats, error) {
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/utils"
+)
+
+// tokenCipher holds the cipher used by the "encrypted" GORM serializer
+var tokenCipher atomic.Pointer[utils.TokenCipher]
+
+func init() {
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+}
+
+// SetTokenCipher configures the cipher used to encrypt sensitive columns
+func SetTokenCipher(cipher *utils.TokenCipher) {
+	tokenCipher.Store(cipher)
+}
+
+// TokenCipher returns the configured cipher, which may be disabled
+func TokenCipher() *utils.TokenCipher {
+	return tokenCipher.Load()
+}
+
+// EncryptedSerializer transparently encrypts string fields tagged with
+// `gorm:"serializer:encrypted"` on write and decrypts them on read
+type EncryptedSerializer struct{}
+
+// Scan implements schema.SerializerInterface
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("unsupported type %T for encrypted field %s", dbValue, field.Name)
+	}
+
+	plaintext, err := TokenCipher().Decrypt(stored)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt field %s: %w", field.Name, err)
+	}
+
+	return field.Set(ctx, dst, plaintext)
+}
+
+// Value implements schema.SerializerValuerInterface
+func (EncryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	plaintext, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("encrypted field %s must be a string, got %T", field.Name, fieldValue)
+	}
+
+	return TokenCipher().Encrypt(plaintext)
+}
+
+// ReencryptUserTokens rewrites every user's PCO tokens with the active key.
+// Plaintext tokens left over from before encryption was enabled, and tokens
+// sealed with a retired key, are both upgraded. It returns the number of
+// users whose tokens were rewritten.
+func ReencryptUserTokens(db *gorm.DB) (int, error) {
+	cipher := TokenCipher()
+	if !cipher.Enabled() {
+		return 0, fmt.Errorf("no token encryption keys are configured")
+	}
+
+	var raw []struct {
+		ID           uint
+		AccessToken  string
+		RefreshToken string
+	}
+	if err := db.Model(&models.User{}).Select("id", "access_token", "refresh_token").Scan(&raw).Error; err != nil {
+		return 0, fmt.Errorf("failed to list user tokens: %w", err)
+	}
+
+	updated := 0
+	for _, row := range raw {
+		if !cipher.NeedsRotation(row.AccessToken) && !cipher.NeedsRotation(row.RefreshToken) {
+			continue
+		}
+
+		var user models.User
+		if err := db.First(&user, row.ID).Error; err != nil {
+			return updated, fmt.Errorf("failed to load user %d: %w", row.ID, err)
+		}
+
+		if err := db.Model(&user).Select("access_token", "refresh_token").Updates(&user).Error; err != nil {
+			return updated, fmt.Errorf("failed to re-encrypt tokens for user %d: %w", row.ID, err)
+		}
+		updated++
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,385 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The structs below are frozen copies of the models as each SQL migration
+// shipped them, so replaying the migrations creates the schema of every
+// version rather than whatever internal/models holds today. Later versions
+// only carry the columns they add. Never edit a struct once its migration
+// has shipped; add a new one for the next version instead.
+//
+// Model relationships are left out. Check-ins, events and notifications
+// refer to each other by PCO ID, so they were never real constraints, and
+// they would give those columns the integer type of the referenced key.
+
+// Version 1: initial_schema
+
+type v1User struct {
+	ID           uint   `gorm:"primaryKey"`
+	PCOUserID    string `gorm:"uniqueIndex;not null"`
+	Name         string `gorm:"not null"`
+	Email        string `gorm:"uniqueIndex;not null"`
+	Avatar       string
+	IsAdmin      bool   `gorm:"default:false"`
+	AccessToken  string `gorm:"not null"`
+	RefreshToken string `gorm:"not null"`
+	TokenExpiry  time.Time
+	LastLogin    time.Time
+	LastActivity time.Time
+	IsActive     bool `gorm:"default:true"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1Session struct {
+	ID           uint      `gorm:"primaryKey"`
+	Token        string    `gorm:"uniqueIndex;not null"`
+	UserID       uint      `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	IsRememberMe bool      `gorm:"default:false"`
+	UserAgent    string
+	IPAddress    string
+	LastActivity time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Session) TableName() string { return "sessions" }
+
+type v1Event struct {
+	ID           uint   `gorm:"primaryKey"`
+	PCOEventID   string `gorm:"uniqueIndex;not null"`
+	Name         string `gorm:"not null"`
+	Description  string
+	Date         time.Time `gorm:"not null"`
+	StartTime    time.Time
+	EndTime      time.Time
+	LocationID   string
+	LocationName string
+	IsActive     bool   `gorm:"default:true"`
+	CreatedBy    string `gorm:"not null"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Event) TableName() string { return "events" }
+
+type v1Notification struct {
+	ID           uint   `gorm:"primaryKey"`
+	PCOCheckInID string `gorm:"uniqueIndex;not null"`
+	ChildName    string `gorm:"not null"`
+	SecurityCode string `gorm:"not null"`
+	LocationID   string
+	LocationName string
+	EventID      uint `gorm:"not null"`
+	EventName    string
+	ParentName   string
+	ParentPhone  string
+	Notes        string
+	Status       string `gorm:"default:'active'"`
+	ExpiresAt    time.Time
+	CreatedBy    string `gorm:"not null"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Notification) TableName() string { return "notifications" }
+
+type v1CheckIn struct {
+	ID           uint      `gorm:"primaryKey"`
+	PCOCheckInID string    `gorm:"uniqueIndex;not null"`
+	PersonID     string    `gorm:"not null"`
+	PersonName   string    `gorm:"not null"`
+	LocationID   string    `gorm:"not null"`
+	LocationName string    `gorm:"not null"`
+	SecurityCode string    `gorm:"not null"`
+	CheckInTime  time.Time `gorm:"not null"`
+	EventID      string    `gorm:"not null"`
+	EventName    string
+	ParentName   string
+	ParentPhone  string
+	Notes        string
+	Status       string `gorm:"default:'active'"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1CheckIn) TableName() string { return "check_ins" }
+
+type v1Location struct {
+	ID            uint   `gorm:"primaryKey"`
+	PCOLocationID string `gorm:"uniqueIndex;not null"`
+	Name          string `gorm:"not null"`
+	Description   string
+	Address       string
+	IsActive      bool `gorm:"default:true"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Location) TableName() string { return "locations" }
+
+type v1BillboardState struct {
+	ID            uint `gorm:"primaryKey"`
+	EventID       uint
+	EventName     string
+	Date          time.Time
+	LocationID    string
+	LocationName  string
+	SecurityCodes []string `gorm:"serializer:json"`
+	IsActive      bool     `gorm:"default:false"`
+	LastUpdated   time.Time
+	CreatedBy     string `gorm:"not null"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1BillboardState) TableName() string { return "billboard_states" }
+
+type v1SecurityCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	Code      string `gorm:"uniqueIndex;not null"`
+	IsActive  bool   `gorm:"default:true"`
+	CreatedBy string `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1SecurityCode) TableName() string { return "security_codes" }
+
+type v1APIKey struct {
+	ID         uint     `gorm:"primaryKey"`
+	Name       string   `gorm:"not null"`
+	Prefix     string   `gorm:"index;not null"`
+	KeyHash    string   `gorm:"uniqueIndex;not null"`
+	Scopes     []string `gorm:"serializer:json"`
+	LocationID string
+	UserID     uint `gorm:"not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	LastUsedIP string
+	RevokedAt  *time.Time
+	CreatedBy  string `gorm:"not null"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1APIKey) TableName() string { return "api_keys" }
+
+type v1AuditEvent struct {
+	ID         uint `gorm:"primaryKey"`
+	ActorID    uint `gorm:"index"`
+	ActorName  string
+	AuthMethod string
+	APIKeyID   uint
+	Action     string      `gorm:"index;not null"`
+	TargetType string      `gorm:"index"`
+	TargetID   string      `gorm:"index"`
+	Before     interface{} `gorm:"serializer:json"`
+	After      interface{} `gorm:"serializer:json"`
+	IPAddress  string
+	UserAgent  string
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+func (v1AuditEvent) TableName() string { return "audit_events" }
+
+// Version 2: attendance_rollups
+
+type v2AttendanceRollup struct {
+	ID                uint      `gorm:"primaryKey"`
+	Period            string    `gorm:"not null;uniqueIndex:idx_rollup_bucket"`
+	PeriodStart       time.Time `gorm:"not null;uniqueIndex:idx_rollup_bucket"`
+	LocationID        string    `gorm:"not null;uniqueIndex:idx_rollup_bucket"`
+	LocationName      string
+	EventID           string `gorm:"not null;uniqueIndex:idx_rollup_bucket"`
+	CheckIns          int64
+	UniquePeople      int64
+	FirstTimeVisitors int64
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+func (v2AttendanceRollup) TableName() string { return "attendance_rollups" }
+
+type v2Visitor struct {
+	ID             uint   `gorm:"primaryKey"`
+	PersonID       string `gorm:"uniqueIndex;not null"`
+	PersonName     string
+	FirstCheckInAt time.Time `gorm:"index;not null"`
+	LocationID     string
+	LocationName   string
+	EventID        string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (v2Visitor) TableName() string { return "visitors" }
+
+// Version 3: event_periods
+
+type v3Event struct {
+	Frequency string
+}
+
+func (v3Event) TableName() string { return "events" }
+
+type v3Location struct {
+	PCOEventID string `gorm:"index"`
+}
+
+func (v3Location) TableName() string { return "locations" }
+
+type v3EventPeriod struct {
+	ID               uint      `gorm:"primaryKey"`
+	PCOEventPeriodID string    `gorm:"uniqueIndex;not null"`
+	EventID          uint      `gorm:"index;not null"`
+	StartsAt         time.Time `gorm:"index"`
+	EndsAt           time.Time
+	RegularCount     int
+	GuestCount       int
+	VolunteerCount   int
+	Note             string
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+func (v3EventPeriod) TableName() string { return "event_periods" }
+
+type v3EventTime struct {
+	ID             uint   `gorm:"primaryKey"`
+	PCOEventTimeID string `gorm:"uniqueIndex;not null"`
+	EventPeriodID  uint   `gorm:"index;not null"`
+	EventID        uint   `gorm:"index;not null"`
+	Name           string
+	StartsAt       time.Time
+	ShowsAt        time.Time
+	HidesAt        time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (v3EventTime) TableName() string { return "event_times" }
+
+// Version 4: event_source
+
+type v4Event struct {
+	Source string `gorm:"not null;default:local;index"`
+}
+
+func (v4Event) TableName() string { return "events" }
+
+// Version 5: event_recurrence
+
+type v5Event struct {
+	RRule      string   `gorm:"column:rrule;index"`
+	ExDates    []string `gorm:"serializer:json"`
+	AutoLaunch bool
+}
+
+func (v5Event) TableName() string { return "events" }
+
+// Version 6: user_calendar_token
+
+type v6User struct {
+	CalendarTokenHash string `gorm:"index"`
+}
+
+func (v6User) TableName() string { return "users" }
+
+// Version 7: location_hierarchy
+
+type v7Location struct {
+	Kind         string `gorm:"not null;default:location"`
+	ParentID     string `gorm:"index"`
+	Position     int
+	AgeMinMonths *int
+	AgeMaxMonths *int
+	GradeMin     *int
+	GradeMax     *int
+	MaxOccupancy int
+}
+
+func (v7Location) TableName() string { return "locations" }
+
+// Version 8: location_overrides
+
+type v8Location struct {
+	DisplayName string
+	Color       string
+	Hidden      bool
+}
+
+func (v8Location) TableName() string { return "locations" }
+
+// Version 9: room_occupancy
+
+type v9Location struct {
+	Capacity int
+	Ratio    int
+}
+
+func (v9Location) TableName() string { return "locations" }
+
+type v9CheckIn struct {
+	CheckedOutAt *time.Time
+	Kind         string `gorm:"default:'Regular'"`
+}
+
+func (v9CheckIn) TableName() string { return "check_ins" }
+
+// Version 11: check_in_details
+
+type v11CheckIn struct {
+	FirstTime             bool `gorm:"default:false"`
+	OneTimeGuest          bool `gorm:"default:false"`
+	Number                int
+	MedicalNotes          string
+	EmergencyContactName  string
+	EmergencyContactPhone string
+}
+
+func (v11CheckIn) TableName() string { return "check_ins" }
+
+type v11User struct {
+	MedicalAccess bool `gorm:"default:false"`
+}
+
+func (v11User) TableName() string { return "users" }
+
+// Version 12: parent_email
+
+type v12CheckIn struct {
+	ParentEmail string
+}
+
+func (v12CheckIn) TableName() string { return "check_ins" }
+
+type v12Notification struct {
+	ParentEmail string
+}
+
+func (v12Notification) TableName() string { return "notifications" }
+
+// Version 13: user_contact_access
+
+type v13User struct {
+	ContactAccess bool `gorm:"default:false"`
+}
+
+func (v13User) TableName() string { return "users" }
@@ -0,0 +1,429 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSchemaAhead is returned when the database has migrations applied that
+// this binary doesn't know about, usually because a newer release ran first
+var ErrSchemaAhead = errors.New("database schema is newer than this binary")
+
+// MigrationStatus describes one migration known to the binary or recorded in
+// the database
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	// Unknown is set for migrations recorded in the database that this
+	// binary doesn't ship
+	Unknown bool
+}
+
+// migrationSet is the backend-specific half of the migration runner
+type migrationSet interface {
+	// known lists the migrations shipped with the binary, by version
+	known() []MigrationStatus
+	// applied returns the applied versions and when they ran
+	applied() (map[int]appliedMigration, error)
+	// apply runs one migration up or down and updates the bookkeeping
+	apply(version int, up bool) error
+}
+
+type appliedMigration struct {
+	Name      string
+	AppliedAt time.Time
+}
+
+func migrationStatus(set migrationSet) ([]MigrationStatus, error) {
+	applied, err := set.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := set.known()
+	for i := range statuses {
+		if a, ok := applied[statuses[i].Version]; ok {
+			appliedAt := a.AppliedAt
+			statuses[i].AppliedAt = &appliedAt
+			delete(applied, statuses[i].Version)
+		}
+	}
+	for version, a := range applied {
+		appliedAt := a.AppliedAt
+		statuses = append(statuses, MigrationStatus{Version: version, Name: a.Name, AppliedAt: &appliedAt, Unknown: true})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+func schemaVersion(set migrationSet) (int, error) {
+	applied, err := set.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+func latestVersion(set migrationSet) int {
+	known := set.known()
+	if len(known) == 0 {
+		return 0
+	}
+	return known[len(known)-1].Version
+}
+
+// migrateLatest applies every pending migration, refusing to touch a schema
+// that is ahead of the binary
+func migrateLatest(set migrationSet) error {
+	current, err := schemaVersion(set)
+	if err != nil {
+		return err
+	}
+	latest := latestVersion(set)
+	if current > latest {
+		return fmt.Errorf("%w: database is at version %d, binary supports %d", ErrSchemaAhead, current, latest)
+	}
+	return migrateTo(set, latest)
+}
+
+// migrateTo applies or reverts migrations until exactly the known migrations
+// up to target are applied
+func migrateTo(set migrationSet, target int) error {
+	latest := latestVersion(set)
+	if target < 0 || target > latest {
+		return fmt.Errorf("unknown migration version %d (latest is %d)", target, latest)
+	}
+
+	statuses, err := migrationStatus(set)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		if s.Unknown {
+			return fmt.Errorf("%w: migration %d is applied but unknown", ErrSchemaAhead, s.Version)
+		}
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		s := statuses[i]
+		if s.Version > target && s.AppliedAt != nil {
+			if err := set.apply(s.Version, false); err != nil {
+				return fmt.Errorf("failed to revert migration %d %s: %w", s.Version, s.Name, err)
+			}
+			log.Printf("Reverted migration %d %s", s.Version, s.Name)
+		}
+	}
+	for _, s := range statuses {
+		if s.Version <= target && s.AppliedAt == nil {
+			if err := set.apply(s.Version, true); err != nil {
+				return fmt.Errorf("failed to apply migration %d %s: %w", s.Version, s.Name, err)
+			}
+			log.Printf("Applied migration %d %s", s.Version, s.Name)
+		}
+	}
+	return nil
+}
+
+// sqlMigration is one numbered, reversible change to the SQL schema. Up and
+// Down run inside a transaction together with the bookkeeping update.
+type sqlMigration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// sqlMigrations is the SQL schema history. Each migration works on the
+// frozen structs of its version in migration_models.go, never on
+// internal/models. Append new migrations with the next version number; never
+// edit one that has shipped.
+var sqlMigrations = []sqlMigration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		// Databases created by AutoMigrate before versioned migrations
+		// already have these tables and columns, so it is safe to record
+		// them as version 1
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(initialSchema()...)
+		},
+		Down: func(tx *gorm.DB) error {
+			tables := initialSchema()
+			for i := len(tables) - 1; i >= 0; i-- {
+				if err := tx.Migrator().DropTable(tables[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 2,
+		Name:    "attendance_rollups",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v2AttendanceRollup{}, &v2Visitor{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v2Visitor{}, &v2AttendanceRollup{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "event_periods",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v3Event{}, &v3Location{}, &v3EventPeriod{}, &v3EventTime{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&v3EventTime{}, &v3EventPeriod{}); err != nil {
+				return err
+			}
+			if err := dropColumns(tx, &v3Location{}, "PCOEventID"); err != nil {
+				return err
+			}
+			return dropColumns(tx, &v3Event{}, "Frequency")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "event_source",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&v4Event{}); err != nil {
+				return err
+			}
+			// Synced events were marked by their creator until now
+			return tx.Exec("UPDATE events SET source = CASE WHEN created_by = ? THEN ? ELSE ? END",
+				"pco", "pco", "local").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v4Event{}, "Source")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "event_recurrence",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v5Event{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v5Event{}, "RRule", "ExDates", "AutoLaunch")
+		},
+	},
+	{
+		Version: 6,
+		Name:    "user_calendar_token",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v6User{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v6User{}, "CalendarTokenHash")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "location_hierarchy",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v7Location{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v7Location{}, "Kind", "ParentID", "Position", "AgeMinMonths", "AgeMaxMonths", "GradeMin", "GradeMax", "MaxOccupancy")
+		},
+	},
+	{
+		Version: 8,
+		Name:    "location_overrides",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v8Location{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v8Location{}, "DisplayName", "Color", "Hidden")
+		},
+	},
+	{
+		Version: 9,
+		Name:    "room_occupancy",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v9Location{}, &v9CheckIn{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &v9Location{}, "Capacity", "Ratio"); err != nil {
+				return err
+			}
+			return dropColumns(tx, &v9CheckIn{}, "Kind", "CheckedOutAt")
+		},
+	},
+	{
+		Version: 10,
+		Name:    "check_in_status",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("UPDATE check_ins SET status = 'checked_out' WHERE checked_out_at IS NOT NULL").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("UPDATE check_ins SET status = 'active' WHERE status = 'checked_out'").Error
+		},
+	},
+	{
+		Version: 11,
+		Name:    "check_in_details",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v11CheckIn{}, &v11User{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &v11CheckIn{}, "FirstTime", "OneTimeGuest", "Number", "MedicalNotes", "EmergencyContactName", "EmergencyContactPhone"); err != nil {
+				return err
+			}
+			return dropColumns(tx, &v11User{}, "MedicalAccess")
+		},
+	},
+	{
+		Version: 12,
+		Name:    "parent_email",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v12CheckIn{}, &v12Notification{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &v12CheckIn{}, "ParentEmail"); err != nil {
+				return err
+			}
+			return dropColumns(tx, &v12Notification{}, "ParentEmail")
+		},
+	},
+	{
+		Version: 13,
+		Name:    "user_contact_access",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v13User{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &v13User{}, "ContactAccess")
+		},
+	},
+}
+
+func initialSchema() []interface{} {
+	return []interface{}{
+		&v1User{},
+		&v1Session{},
+		&v1Event{},
+		&v1Notification{},
+		&v1CheckIn{},
+		&v1Location{},
+		&v1BillboardState{},
+		&v1SecurityCode{},
+		&v1APIKey{},
+		&v1AuditEvent{},
+	}
+}
+
+// dropColumns drops the given fields' columns and their indexes from a
+// table, skipping any that are already gone. It uses ALTER TABLE rather than
+// the SQLite migrator's table rebuild, which loses the table's other indexes.
+func dropColumns(tx *gorm.DB, model interface{}, fields ...string) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	for _, name := range fields {
+		field := stmt.Schema.LookUpField(name)
+		if field == nil {
+			return fmt.Errorf("unknown field %s", name)
+		}
+		if !tx.Migrator().HasColumn(model, name) {
+			continue
+		}
+		if tx.Migrator().HasIndex(model, name) {
+			if err := tx.Migrator().DropIndex(model, name); err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: stmt.Schema.Table}, clause.Column{Name: field.DBName}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaMigration is a row of the schema_migrations bookkeeping table
+type schemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"not null"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// gormMigrations runs sqlMigrations against a GORM connection
+type gormMigrations struct {
+	db *gorm.DB
+}
+
+func (g gormMigrations) known() []MigrationStatus {
+	statuses := make([]MigrationStatus, len(sqlMigrations))
+	for i, m := range sqlMigrations {
+		statuses[i] = MigrationStatus{Version: m.Version, Name: m.Name}
+	}
+	return statuses
+}
+
+func (g gormMigrations) applied() (map[int]appliedMigration, error) {
+	if err := g.db.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	var rows []schemaMigration
+	if err := g.db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	applied := make(map[int]appliedMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = appliedMigration{Name: row.Name, AppliedAt: row.AppliedAt}
+	}
+	return applied, nil
+}
+
+func (g gormMigrations) apply(version int, up bool) error {
+	var migration *sqlMigration
+	for i := range sqlMigrations {
+		if sqlMigrations[i].Version == version {
+			migration = &sqlMigrations[i]
+		}
+	}
+	if migration == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		if !up {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{}, version).Error
+		}
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&schemaMigration{Version: version, Name: migration.Name, AppliedAt: time.Now()}).Error
+	})
+}
+
+// migrateSQL brings a SQL database up to the latest migration
+func migrateSQL(db *gorm.DB) error {
+	return migrateLatest(gormMigrations{db: db})
+}
@@ -0,0 +1,415 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoMigration is one numbered, reversible change to the MongoDB
+// collections and indexes
+type mongoMigration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// mongoIndex is an index created by a migration
+type mongoIndex struct {
+	collection string
+	keys       bson.D
+	unique     bool
+}
+
+var initialMongoIndexes = []mongoIndex{
+	{"users", bson.D{{Key: "pco_user_id", Value: 1}}, true},
+	{"users", bson.D{{Key: "email", Value: 1}}, true},
+	{"sessions", bson.D{{Key: "token", Value: 1}}, true},
+	{"events", bson.D{{Key: "pco_event_id", Value: 1}}, true},
+	{"locations", bson.D{{Key: "pco_location_id", Value: 1}}, true},
+	{"check_ins", bson.D{{Key: "pco_check_in_id", Value: 1}}, true},
+	{"notifications", bson.D{{Key: "pco_check_in_id", Value: 1}}, true},
+	{"security_codes", bson.D{{Key: "code", Value: 1}}, true},
+	{"api_keys", bson.D{{Key: "key_hash", Value: 1}}, true},
+	{"audit_events", bson.D{{Key: "created_at", Value: -1}}, false},
+	{"audit_events", bson.D{{Key: "action", Value: 1}}, false},
+	{"audit_events", bson.D{{Key: "actor_id", Value: 1}}, false},
+	{"audit_events", bson.D{{Key: "target_type", Value: 1}, {Key: "target_id", Value: 1}}, false},
+}
+
+var initialMongoCollections = []string{"users", "sessions", "events", "locations", "check_ins", "notifications", "billboard_states", "security_codes", "api_keys", "audit_events"}
+
+// mongoMigrations is the MongoDB schema history. Append new migrations with
+// the next version number; never edit one that has shipped.
+var mongoMigrations = []mongoMigration{
+	{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := createCollections(ctx, db, initialMongoCollections); err != nil {
+				return err
+			}
+			return createIndexes(ctx, db, initialMongoIndexes)
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			for _, name := range append(initialMongoCollections, "counters") {
+				if err := db.Collection(name).Drop(ctx); err != nil {
+					return fmt.Errorf("failed to drop %s: %w", name, err)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 2,
+		Name:    "attendance_rollups",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := createCollections(ctx, db, []string{"attendance_rollups", "visitors"}); err != nil {
+				return err
+			}
+			return createIndexes(ctx, db, []mongoIndex{
+				{"attendance_rollups", bson.D{{Key: "period", Value: 1}, {Key: "period_start", Value: 1}, {Key: "location_id", Value: 1}, {Key: "event_id", Value: 1}}, true},
+				{"visitors", bson.D{{Key: "person_id", Value: 1}}, true},
+				{"visitors", bson.D{{Key: "first_check_in_at", Value: 1}}, false},
+			})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			for _, name := range []string{"visitors", "attendance_rollups"} {
+				if err := db.Collection(name).Drop(ctx); err != nil {
+					return fmt.Errorf("failed to drop %s: %w", name, err)
+				}
+				if _, err := db.Collection("counters").DeleteOne(ctx, bson.M{"_id": name}); err != nil {
+					return fmt.Errorf("failed to reset %s counter: %w", name, err)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 3,
+		Name:    "event_periods",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if err := createCollections(ctx, db, []string{"event_periods", "event_times"}); err != nil {
+				return err
+			}
+			return createIndexes(ctx, db, []mongoIndex{
+				{"event_periods", bson.D{{Key: "pco_event_period_id", Value: 1}}, true},
+				{"event_periods", bson.D{{Key: "event_id", Value: 1}, {Key: "starts_at", Value: 1}}, false},
+				{"event_periods", bson.D{{Key: "starts_at", Value: 1}}, false},
+				{"event_times", bson.D{{Key: "pco_event_time_id", Value: 1}}, true},
+				{"event_times", bson.D{{Key: "event_period_id", Value: 1}}, false},
+				{"locations", bson.D{{Key: "pco_event_id", Value: 1}}, false},
+			})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			for _, name := range []string{"event_times", "event_periods"} {
+				if err := db.Collection(name).Drop(ctx); err != nil {
+					return fmt.Errorf("failed to drop %s: %w", name, err)
+				}
+				if _, err := db.Collection("counters").DeleteOne(ctx, bson.M{"_id": name}); err != nil {
+					return fmt.Errorf("failed to reset %s counter: %w", name, err)
+				}
+			}
+			if _, err := db.Collection("locations").Indexes().DropOne(ctx, "pco_event_id_1"); err != nil {
+				return fmt.Errorf("failed to drop locations index: %w", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 4,
+		Name:    "event_source",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			// Synced events were marked by their creator until now
+			events := db.Collection("events")
+			if _, err := events.UpdateMany(ctx, bson.M{"created_by": "pco"}, bson.M{"$set": bson.M{"source": "pco"}}); err != nil {
+				return fmt.Errorf("failed to mark synced events: %w", err)
+			}
+			if _, err := events.UpdateMany(ctx, bson.M{"source": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"source": "local"}}); err != nil {
+				return fmt.Errorf("failed to mark local events: %w", err)
+			}
+			return createIndexes(ctx, db, []mongoIndex{
+				{"events", bson.D{{Key: "source", Value: 1}}, false},
+			})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("events").Indexes().DropOne(ctx, "source_1"); err != nil {
+				return fmt.Errorf("failed to drop events index: %w", err)
+			}
+			if _, err := db.Collection("events").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"source": ""}}); err != nil {
+				return fmt.Errorf("failed to remove event sources: %w", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 5,
+		Name:    "event_recurrence",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db, []mongoIndex{
+				{"events", bson.D{{Key: "rrule", Value: 1}}, false},
+			})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("events").Indexes().DropOne(ctx, "rrule_1"); err != nil {
+				return fmt.Errorf("failed to drop events index: %w", err)
+			}
+			if _, err := db.Collection("events").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"rrule": "", "exdates": "", "auto_launch": ""}}); err != nil {
+				return fmt.Errorf("failed to remove event recurrence: %w", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "user_calendar_token",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return createIndexes(ctx, db, []mongoIndex{
+				{"users", bson.D{{Key: "calendar_token_hash", Value: 1}}, false},
+			})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("users").Indexes().DropOne(ctx, "calendar_token_hash_1"); err != nil {
+				return fmt.Errorf("failed to drop users index: %w", err)
+			}
+			if _, err := db.Collection("users").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"calendar_token_hash": ""}}); err != nil {
+				return fmt.Errorf("failed to remove calendar tokens: %w", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 7,
+		Name:    "location_hierarchy",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("locations").UpdateMany(ctx, bson.M{"kind": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"kind": "location"}}); err != nil {
+				return fmt.Errorf("failed to set location kinds: %w", err)
+			}
+			return createIndexes(ctx, db, []mongoIndex{
+				{"locations", bson.D{{Key: "parent_id", Value: 1}}, false},
+			})
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("locations").Indexes().DropOne(ctx, "parent_id_1"); err != nil {
+				return fmt.Errorf("failed to drop locations index: %w", err)
+			}
+			unset := bson.M{"kind": "", "parent_id": "", "position": "", "age_min_months": "", "age_max_months": "", "grade_min": "", "grade_max": "", "max_occupancy": ""}
+			if _, err := db.Collection("locations").UpdateMany(ctx, bson.M{}, bson.M{"$unset": unset}); err != nil {
+				return fmt.Errorf("failed to remove location hierarchy: %w", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 8,
+		Name:    "location_overrides",
+		// The fields are optional, so there is nothing to create
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("locations").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"display_name": "", "color": "", "hidden": ""}}); err != nil {
+				return fmt.Errorf("failed to remove location overrides: %w", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 9,
+		Name:    "room_occupancy",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("check_ins").UpdateMany(ctx, bson.M{"kind": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"kind": "Regular"}}); err != nil {
+				return fmt.Errorf("failed to set check-in kinds: %w", err)
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("locations").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"capacity": "", "ratio": ""}}); err != nil {
+				return fmt.Errorf("failed to remove location limits: %w", err)
+			}
+			if _, err := db.Collection("check_ins").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"kind": "", "checked_out_at": ""}}); err != nil {
+				return fmt.Errorf("failed to remove check-in kinds: %w", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 10,
+		Name:    "check_in_status",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("check_ins").UpdateMany(ctx, bson.M{"checked_out_at": bson.M{"$ne": nil}}, bson.M{"$set": bson.M{"status": "checked_out"}}); err != nil {
+				return fmt.Errorf("failed to mark checked out check-ins: %w", err)
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("check_ins").UpdateMany(ctx, bson.M{"status": "checked_out"}, bson.M{"$set": bson.M{"status": "active"}}); err != nil {
+				return fmt.Errorf("failed to reset check-in statuses: %w", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 11,
+		Name:    "check_in_details",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("users").UpdateMany(ctx, bson.M{"medical_access": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"medical_access": false}}); err != nil {
+				return fmt.Errorf("failed to default medical access: %w", err)
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			unset := bson.M{"first_time": "", "one_time_guest": "", "number": "", "medical_notes": "", "emergency_contact_name": "", "emergency_contact_phone": ""}
+			if _, err := db.Collection("check_ins").UpdateMany(ctx, bson.M{}, bson.M{"$unset": unset}); err != nil {
+				return fmt.Errorf("failed to remove check-in details: %w", err)
+			}
+			if _, err := db.Collection("users").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"medical_access": ""}}); err != nil {
+				return fmt.Errorf("failed to remove medical access: %w", err)
+			}
+			return nil
+		},
+	},
+	{
+		Version: 12,
+		Name:    "parent_email",
+		// The field is optional, so there is nothing to create
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			for _, collection := range []string{"check_ins", "notifications"} {
+				if _, err := db.Collection(collection).UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"parent_email": ""}}); err != nil {
+					return fmt.Errorf("failed to remove parent emails from %s: %w", collection, err)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 13,
+		Name:    "user_contact_access",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("users").UpdateMany(ctx, bson.M{"contact_access": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"contact_access": false}}); err != nil {
+				return fmt.Errorf("failed to default contact access: %w", err)
+			}
+			return nil
+		},
+		Down: func(ctx context.Context, db *mongo.Database) error {
+			if _, err := db.Collection("users").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"contact_access": ""}}); err != nil {
+				return fmt.Errorf("failed to remove contact access: %w", err)
+			}
+			return nil
+		},
+	},
+}
+
+func createCollections(ctx context.Context, db *mongo.Database, names []string) error {
+	existing, err := db.ListCollectionNames(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	present := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		present[name] = true
+	}
+	for _, name := range names {
+		if present[name] {
+			continue
+		}
+		if err := db.CreateCollection(ctx, name); err != nil {
+			return fmt.Errorf("failed to create collection %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func createIndexes(ctx context.Context, db *mongo.Database, indexes []mongoIndex) error {
+	for _, index := range indexes {
+		model := mongo.IndexModel{Keys: index.keys}
+		if index.unique {
+			model.Options = options.Index().SetUnique(true)
+		}
+		if _, err := db.Collection(index.collection).Indexes().CreateOne(ctx, model); err != nil {
+			return fmt.Errorf("failed to create index on %s: %w", index.collection, err)
+		}
+	}
+	return nil
+}
+
+// migrationRecord is a document in the migrations bookkeeping collection
+type migrationRecord struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// mongoMigrationSet runs mongoMigrations against a database
+type mongoMigrationSet struct {
+	db *MongoDB
+}
+
+func (m mongoMigrationSet) known() []MigrationStatus {
+	statuses := make([]MigrationStatus, len(mongoMigrations))
+	for i, migration := range mongoMigrations {
+		statuses[i] = MigrationStatus{Version: migration.Version, Name: migration.Name}
+	}
+	return statuses
+}
+
+func (m mongoMigrationSet) applied() (map[int]appliedMigration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := m.db.database.Collection("migrations").Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var records []migrationRecord
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	applied := make(map[int]appliedMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = appliedMigration{Name: record.Name, AppliedAt: record.AppliedAt}
+	}
+	return applied, nil
+}
+
+func (m mongoMigrationSet) apply(version int, up bool) error {
+	var migration *mongoMigration
+	for i := range mongoMigrations {
+		if mongoMigrations[i].Version == version {
+			migration = &mongoMigrations[i]
+		}
+	}
+	if migration == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	records := m.db.database.Collection("migrations")
+	if !up {
+		if err := migration.Down(ctx, m.db.database); err != nil {
+			return err
+		}
+		_, err := records.DeleteOne(ctx, bson.M{"_id": version})
+		return err
+	}
+
+	if err := migration.Up(ctx, m.db.database); err != nil {
+		return err
+	}
+	_, err := records.InsertOne(ctx, migrationRecord{Version: version, Name: migration.Name, AppliedAt: time.Now()})
+	return err
+}
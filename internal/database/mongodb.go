@@ -3,11 +3,9 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -32,6 +30,11 @@ func NewMongoDB() (*MongoDB, error) {
 		databaseName = "pco_arrivals"
 	}
 
+	return ConnectMongo(uri, databaseName)
+}
+
+// ConnectMongo connects to the named database on a MongoDB server
+func ConnectMongo(uri, databaseName string) (*MongoDB, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -60,91 +63,42 @@ func (m *MongoDB) Close() error {
 	return m.client.Disconnect(ctx)
 }
 
-// Migrate creates the necessary collections and indexes
+// Migrate brings the database up to the latest migration, refusing to touch
+// a database that is ahead of the binary
 func (m *MongoDB) Migrate() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	return migrateLatest(mongoMigrationSet{db: m})
+}
 
-	// Create collections and indexes
-	collections := []string{"users", "sessions", "events", "locations", "check_ins", "notifications", "billboard_states", "security_codes"}
-
-	for _, collectionName := range collections {
-		collection := m.database.Collection(collectionName)
-
-		// Create collection if it doesn't exist
-		if err := m.database.CreateCollection(ctx, collectionName); err != nil {
-			// Collection might already exist, which is fine
-			log.Printf("Collection %s might already exist: %v", collectionName, err)
-		}
-
-		// Create indexes based on collection
-		switch collectionName {
-		case "users":
-			_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
-				Keys:    bson.D{{Key: "pco_user_id", Value: 1}},
-				Options: options.Index().SetUnique(true),
-			})
-			if err != nil {
-				log.Printf("Failed to create index on users.pco_user_id: %v", err)
-			}
-
-		case "sessions":
-			_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
-				Keys:    bson.D{{Key: "token", Value: 1}},
-				Options: options.Index().SetUnique(true),
-			})
-			if err != nil {
-				log.Printf("Failed to create index on sessions.token: %v", err)
-			}
-
-		case "events":
-			_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
-				Keys:    bson.D{{Key: "pco_event_id", Value: 1}},
-				Options: options.Index().SetUnique(true),
-			})
-			if err != nil {
-				log.Printf("Failed to create index on events.pco_event_id: %v", err)
-			}
-
-		case "locations":
-			_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
-				Keys:    bson.D{{Key: "pco_location_id", Value: 1}},
-				Options: options.Index().SetUnique(true),
-			})
-			if err != nil {
-				log.Printf("Failed to create index on locations.pco_location_id: %v", err)
-			}
-
-		case "check_ins":
-			_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
-				Keys:    bson.D{{Key: "pco_check_in_id", Value: 1}},
-				Options: options.Index().SetUnique(true),
-			})
-			if err != nil {
-				log.Printf("Failed to create index on check_ins.pco_check_in_id: %v", err)
-			}
-
-		case "notifications":
-			_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
-				Keys: bson.D{{Key: "pco_check_in_id", Value: 1}},
-			})
-			if err != nil {
-				log.Printf("Failed to create index on notifications.pco_check_in_id: %v", err)
-			}
-
-		case "security_codes":
-			_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
-				Keys:    bson.D{{Key: "code", Value: 1}},
-				Options: options.Index().SetUnique(true),
-			})
-			if err != nil {
-				log.Printf("Failed to create index on security_codes.code: %v", err)
-			}
-		}
+// WithDatabase returns a handle to another database on the same connection
+func (m *MongoDB) WithDatabase(name string) *MongoDB {
+	return &MongoDB{
+		client:   m.client,
+		database: m.client.Database(name),
 	}
+}
+
+// Name returns the name of the database in use
+func (m *MongoDB) Name() string {
+	return m.database.Name()
+}
+
+// Drop deletes the database and everything in it
+func (m *MongoDB) Drop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return m.database.Drop(ctx)
+}
+
+// Ping checks that the server is reachable
+func (m *MongoDB) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return m.client.Ping(ctx, readpref.Primary())
+}
 
-	log.Println("MongoDB migration completed successfully")
-	return nil
+// Database returns the underlying driver handle
+func (m *MongoDB) Database() *mongo.Database {
+	return m.database
 }
 
 // GetCollection returns a MongoDB collection
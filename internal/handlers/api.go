@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
 	"go_pco_arrivals/internal/services"
 	"strconv"
 	"time"
@@ -10,172 +12,316 @@ import (
 	"go_pco_arrivals/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
 )
 
 type APIHandler struct {
-	db                  *gorm.DB
+	store               *repository.Store
 	pcoService          *services.PCOService
 	notificationService *services.NotificationService
 	billboardService    *services.BillboardService
+	rollups             *services.RollupService
+	events              *services.EventService
+	occupancy           *services.OccupancyService
 	websocketHub        *services.WebSocketHub
+	audit               *services.AuditService
 	logger              *utils.Logger
 }
 
-func NewAPIHandler(db *gorm.DB, pcoService *services.PCOService, notificationService *services.NotificationService, billboardService *services.BillboardService, websocketHub *services.WebSocketHub, logger *utils.Logger) *APIHandler {
+func NewAPIHandler(store *repository.Store, pcoService *services.PCOService, notificationService *services.NotificationService, billboardService *services.BillboardService, rollups *services.RollupService, events *services.EventService, occupancy *services.OccupancyService, websocketHub *services.WebSocketHub, audit *services.AuditService, logger *utils.Logger) *APIHandler {
 	return &APIHandler{
-		db:                  db,
+		store:               store,
 		pcoService:          pcoService,
 		notificationService: notificationService,
 		billboardService:    billboardService,
+		rollups:             rollups,
+		events:              events,
+		occupancy:           occupancy,
 		websocketHub:        websocketHub,
+		audit:               audit,
 		logger:              logger,
 	}
 }
 
 // Event endpoints
-func (h *APIHandler) GetEvents(c *fiber.Ctx) error {
-	// Get query parameters
-	date := c.Query("date")
 
-	// Get the current user's access token
+// GetEvents lists the events on a day from the locally synced copy. A stale
+// copy is refreshed from PCO first; if that fails the stored events are
+// returned with a warning.
+func (h *APIHandler) GetEvents(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
 	}
 
-	// Parse the date parameter
-	var targetDate time.Time
-	if date != "" {
-		parsedDate, err := time.Parse("2006-01-02", date)
+	targetDate := time.Now()
+	if date := c.Query("date"); date != "" {
+		targetDate, err = time.ParseInLocation("2006-01-02", date, time.Local)
 		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Invalid date format. Use YYYY-MM-DD",
 			})
 		}
-		targetDate = parsedDate
-	} else {
-		// Default to today if no date provided
-		targetDate = time.Now()
 	}
 
-	// Try to get real events from PCO first
-	events, err := h.pcoService.GetEvents(user.AccessToken, targetDate)
+	h.events.SyncIfStale(user.AccessToken)
+	occurrences, err := h.events.EventsOn(targetDate)
 	if err != nil {
-		h.logger.Warn("Failed to get events from PCO, using mock data", "error", err.Error())
-
-		// Return enhanced mock data when PCO API fails
-		mockEvents := []fiber.Map{
-			{
-				"id":          "mock-event-1",
-				"name":        "Sunday Service",
-				"date":        targetDate.Format("2006-01-02"),
-				"location":    "Main Auditorium",
-				"location_id": "main-auditorium",
-				"time":        "09:00 AM",
-				"start_time":  targetDate.Format("2006-01-02") + "T09:00:00Z",
-				"end_time":    targetDate.Format("2006-01-02") + "T10:30:00Z",
-				"is_active":   true,
-				"created_at":  time.Now().Format(time.RFC3339),
-			},
-			{
-				"id":          "mock-event-2",
-				"name":        "Youth Group",
-				"date":        targetDate.Format("2006-01-02"),
-				"location":    "Youth Room",
-				"location_id": "youth-room",
-				"time":        "06:00 PM",
-				"start_time":  targetDate.Format("2006-01-02") + "T18:00:00Z",
-				"end_time":    targetDate.Format("2006-01-02") + "T19:30:00Z",
-				"is_active":   true,
-				"created_at":  time.Now().Format(time.RFC3339),
-			},
-			{
-				"id":          "mock-event-3",
-				"name":        "Wednesday Bible Study",
-				"date":        targetDate.Format("2006-01-02"),
-				"location":    "Fellowship Hall",
-				"location_id": "fellowship-hall",
-				"time":        "07:00 PM",
-				"start_time":  targetDate.Format("2006-01-02") + "T19:00:00Z",
-				"end_time":    targetDate.Format("2006-01-02") + "T20:30:00Z",
-				"is_active":   true,
-				"created_at":  time.Now().Format(time.RFC3339),
-			},
-		}
+		h.logger.Error("Failed to list events", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list events",
+		})
+	}
 
-		return c.JSON(fiber.Map{
-			"success": true,
-			"events":  mockEvents,
-			"note":    "Using mock data - PCO API access pending approval",
-		})
-	}
-
-	// Convert PCO events to response format
-	responseEvents := make([]fiber.Map, len(events))
-	for i, event := range events {
-		responseEvents[i] = fiber.Map{
-			"id":          event.ID,
-			"name":        event.Name,
-			"date":        event.Date.Format("2006-01-02"),
-			"location":    event.LocationName,
-			"location_id": event.LocationID,
-			"time":        event.StartTime.Format("03:04 PM"),
-			"start_time":  event.StartTime.Format(time.RFC3339),
-			"end_time":    event.EndTime.Format(time.RFC3339),
-			"is_active":   event.IsActive,
-			"created_at":  time.Now().Format(time.RFC3339),
-		}
+	responseEvents := make([]fiber.Map, len(occurrences))
+	for i, occurrence := range occurrences {
+		responseEvents[i] = eventResponse(occurrence)
 	}
 
-	return c.JSON(fiber.Map{
+	response := fiber.Map{
 		"success": true,
 		"events":  responseEvents,
+	}
+	status := h.events.Status()
+	if !status.LastSuccess.IsZero() {
+		response["synced_at"] = status.LastSuccess.Format(time.RFC3339)
+	}
+	if h.events.Stale() {
+		response["stale"] = true
+		response["warning"] = "Could not refresh events from Planning Center, showing the last synced copy: " + status.LastError
+	}
+	return c.JSON(response)
+}
+
+// SyncEvents refreshes the local events from PCO now
+func (h *APIHandler) SyncEvents(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	result, err := h.events.Sync(user.AccessToken)
+	if err != nil {
+		h.logger.Warn("Event sync failed", "error", err)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to sync events from Planning Center: " + err.Error(),
+		})
+	}
+	return c.JSON(fiber.Map{
+		"success": true,
+		"synced":  result,
 	})
 }
 
+// GetEvent returns one event by the id GetEvents lists it under
 func (h *APIHandler) GetEvent(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{"message": "Get event endpoint"})
+	event, err := h.store.Events.GetByPCOID(c.Params("id"))
+	if err != nil {
+		return h.eventError(c, err, "Failed to get event")
+	}
+
+	occurrence, err := h.events.Occurrence(*event)
+	if err != nil {
+		h.logger.Error("Failed to describe event", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get event",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"success": true,
+		"event":   eventResponse(*occurrence),
+	})
 }
 
+// CreateEvent creates a local event, one that isn't in PCO
 func (h *APIHandler) CreateEvent(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{"message": "Create event endpoint"})
+	userID := c.Locals("user_id").(uint)
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	var input services.EventInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	event, err := h.events.CreateLocal(input, user.PCOUserID)
+	if err != nil {
+		return h.eventError(c, err, "Failed to create event")
+	}
+
+	audit := newAuditEvent(c, h.audit, user, models.AuditEventCreate, "event", event.PCOEventID)
+	audit.After = event
+	h.audit.Record(audit)
+
+	return h.eventResult(c.Status(fiber.StatusCreated), event)
 }
 
+// UpdateEvent changes a local event. PCO events are read-only.
 func (h *APIHandler) UpdateEvent(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{"message": "Update event endpoint"})
+	userID := c.Locals("user_id").(uint)
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	var input services.EventInput
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	before, event, err := h.events.UpdateLocal(c.Params("id"), input)
+	if err != nil {
+		return h.eventError(c, err, "Failed to update event")
+	}
+
+	audit := newAuditEvent(c, h.audit, user, models.AuditEventUpdate, "event", event.PCOEventID)
+	audit.Before = before
+	audit.After = event
+	h.audit.Record(audit)
+
+	return h.eventResult(c, event)
 }
 
+// DeleteEvent removes a local event. PCO events are read-only.
 func (h *APIHandler) DeleteEvent(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{"message": "Delete event endpoint"})
+	userID := c.Locals("user_id").(uint)
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	event, err := h.events.DeleteLocal(c.Params("id"))
+	if err != nil {
+		return h.eventError(c, err, "Failed to delete event")
+	}
+
+	audit := newAuditEvent(c, h.audit, user, models.AuditEventDelete, "event", event.PCOEventID)
+	audit.Before = event
+	h.audit.Record(audit)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Event deleted successfully",
+	})
+}
+
+// eventResult responds with a single event
+func (h *APIHandler) eventResult(c *fiber.Ctx, event *models.Event) error {
+	occurrence, err := h.events.Occurrence(*event)
+	if err != nil {
+		h.logger.Error("Failed to describe event", "error", err)
+		occurrence = &services.EventOccurrence{Event: *event}
+	}
+	return c.JSON(fiber.Map{
+		"success": true,
+		"event":   eventResponse(*occurrence),
+	})
+}
+
+// eventError maps event service errors to responses
+func (h *APIHandler) eventError(c *fiber.Ctx, err error, message string) error {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Event not found"})
+	case errors.Is(err, services.ErrEventReadOnly):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, services.ErrEventInUse):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, utils.ErrInvalidInput):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	h.logger.Error(message, "error", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": message})
+}
+
+// eventResponse is the JSON shape of an event in the events endpoints
+func eventResponse(occurrence services.EventOccurrence) fiber.Map {
+	event := occurrence.Event
+	start, end := occurrence.StartsAt.In(time.Local), occurrence.EndsAt.In(time.Local)
+
+	times := make([]fiber.Map, len(occurrence.Times))
+	for i, t := range occurrence.Times {
+		times[i] = fiber.Map{
+			"id":        t.PCOEventTimeID,
+			"name":      t.Name,
+			"starts_at": t.StartsAt.Format(time.RFC3339),
+			"shows_at":  t.ShowsAt.Format(time.RFC3339),
+			"hides_at":  t.HidesAt.Format(time.RFC3339),
+		}
+	}
+	locations := make([]fiber.Map, len(occurrence.Locations))
+	for i, l := range occurrence.Locations {
+		locations[i] = fiber.Map{"id": l.PCOLocationID, "name": l.Name}
+	}
+	exDates := event.ExDates
+	if exDates == nil {
+		exDates = []string{}
+	}
+
+	return fiber.Map{
+		"id":          event.PCOEventID,
+		"name":        event.Name,
+		"description": event.Description,
+		"date":        start.Format("2006-01-02"),
+		"location":    event.LocationName,
+		"location_id": event.LocationID,
+		"time":        start.Format("03:04 PM"),
+		"start_time":  start.Format(time.RFC3339),
+		"end_time":    end.Format(time.RFC3339),
+		"is_active":   event.IsActive,
+		"frequency":   event.Frequency,
+		"rrule":       event.RRule,
+		"exdates":     exDates,
+		"auto_launch": event.AutoLaunch,
+		"source":      event.Source,
+		"read_only":   event.Source != models.EventSourceLocal,
+		"times":       times,
+		"locations":   locations,
+		"created_at":  event.CreatedAt.Format(time.RFC3339),
+	}
 }
 
 // Notification endpoints
 func (h *APIHandler) GetNotifications(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	if _, err := h.store.Users.GetByID(userID); err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
 	}
 
-	var notifications []models.Notification
-	query := h.db.Where("status = ? AND expires_at > ?", "active", time.Now())
-
-	if err := query.Order("created_at DESC").Find(&notifications).Error; err != nil {
+	notifications, err := h.store.Notifications.List(repository.NotificationFilter{Status: "active", ActiveAt: time.Now()})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch notifications",
 		})
 	}
 
 	// Convert to response format
+	contacts := hasContactAccess(c)
 	var responseNotifications []fiber.Map
 	for _, notification := range notifications {
+		if !locationAllowed(c, notification.LocationID) {
+			continue
+		}
 		responseNotification := fiber.Map{
 			"id":         notification.PCOCheckInID,
 			"message":    notification.ChildName + " checked in",
@@ -197,6 +343,12 @@ func (h *APIHandler) GetNotifications(c *fiber.Ctx) error {
 		if notification.ParentName != "" {
 			responseNotification["parent_name"] = notification.ParentName
 		}
+		if contacts && notification.ParentPhone != "" {
+			responseNotification["parent_phone"] = notification.ParentPhone
+		}
+		if contacts && notification.ParentEmail != "" {
+			responseNotification["parent_email"] = notification.ParentEmail
+		}
 		if !notification.ExpiresAt.IsZero() {
 			responseNotification["expires_at"] = notification.ExpiresAt.Format(time.RFC3339)
 		}
@@ -211,27 +363,161 @@ func (h *APIHandler) GetNotifications(c *fiber.Ctx) error {
 }
 
 func (h *APIHandler) CreateNotification(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{"message": "Create notification endpoint"})
+	userID := c.Locals("user_id").(uint)
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	var request services.PickupRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	// Keys restricted to a location may only post pickups for that location
+	if restricted := apiLocationRestriction(c); restricted != "" {
+		if request.LocationID != "" && request.LocationID != restricted {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "API key is not permitted for this location",
+			})
+		}
+		request.LocationID = restricted
+	}
+
+	notification, err := h.notificationService.CreatePickupRequest(request, user.Name)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("Failed to create notification", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create notification",
+		})
+	}
+
+	event := newAuditEvent(c, h.audit, user, models.AuditNotificationCreate, "notification", notification.PCOCheckInID)
+	event.After = notification
+	h.audit.Record(event)
+
+	if h.websocketHub != nil {
+		h.websocketHub.Broadcast("notification_update", notification)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success":      true,
+		"notification": notification,
+	})
 }
 
+// DeleteNotification cancels the pickup request for a check-in
 func (h *APIHandler) DeleteNotification(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{"message": "Delete notification endpoint"})
+	userID := c.Locals("user_id").(uint)
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	pcoCheckInID := c.Params("id")
+	if pcoCheckInID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Notification ID is required",
+		})
+	}
+
+	notification, err := h.notificationService.GetNotificationByCheckInID(pcoCheckInID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Notification not found",
+		})
+	}
+
+	if restricted := apiLocationRestriction(c); restricted != "" && notification.LocationID != restricted {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "API key is not permitted for this location",
+		})
+	}
+
+	previousStatus := notification.Status
+	if err := h.notificationService.CancelNotification(notification); err != nil {
+		h.logger.Error("Failed to cancel notification", "error", err, "pco_check_in_id", pcoCheckInID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to cancel notification",
+		})
+	}
+
+	event := newAuditEvent(c, h.audit, user, models.AuditNotificationCancel, "notification", notification.PCOCheckInID)
+	event.Before = fiber.Map{"status": previousStatus, "child_name": notification.ChildName, "location_id": notification.LocationID}
+	event.After = fiber.Map{"status": notification.Status}
+	h.audit.Record(event)
+
+	if h.websocketHub != nil {
+		h.websocketHub.Broadcast("notification_removed", fiber.Map{
+			"id":     notification.PCOCheckInID,
+			"status": notification.Status,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Notification cancelled successfully",
+	})
+}
+
+// apiLocationRestriction returns the location an API key is limited to, if any
+func apiLocationRestriction(c *fiber.Ctx) string {
+	locationID, _ := c.Locals("api_location_id").(string)
+	return locationID
+}
+
+// locationAllowed reports whether the caller may see a location's data. API
+// keys limited to one location may only see that location.
+func locationAllowed(c *fiber.Ctx, locationID string) bool {
+	restricted := apiLocationRestriction(c)
+	return restricted == "" || restricted == locationID
+}
+
+// locationForbidden rejects a request for a location the API key is not limited to
+func locationForbidden(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error": "API key is not permitted for this location",
+	})
+}
+
+// allowedLocations drops the PCO locations the caller may not see
+func allowedLocations(c *fiber.Ctx, locations []services.PCOLocation) []services.PCOLocation {
+	if apiLocationRestriction(c) == "" {
+		return locations
+	}
+	var allowed []services.PCOLocation
+	for _, location := range locations {
+		if locationAllowed(c, location.ID) {
+			allowed = append(allowed, location)
+		}
+	}
+	return allowed
 }
 
 // Security Code endpoints
 func (h *APIHandler) GetSecurityCodes(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	if _, err := h.store.Users.GetByID(userID); err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
 	}
 
 	// Get all active security codes
-	var securityCodes []models.SecurityCode
-	if err := h.db.Where("is_active = ?", true).Find(&securityCodes).Error; err != nil {
+	securityCodes, err := h.store.SecurityCodes.ListActive()
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch security codes",
 		})
@@ -258,8 +544,8 @@ func (h *APIHandler) GetSecurityCodes(c *fiber.Ctx) error {
 func (h *APIHandler) AddSecurityCode(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -282,8 +568,7 @@ func (h *APIHandler) AddSecurityCode(c *fiber.Ctx) error {
 	}
 
 	// Check if code already exists
-	var existingCode models.SecurityCode
-	if err := h.db.Where("code = ?", request.Code).First(&existingCode).Error; err == nil {
+	if _, err := h.store.SecurityCodes.GetByCode(request.Code); err == nil {
 		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
 			"error": "Security code already exists",
 		})
@@ -296,12 +581,16 @@ func (h *APIHandler) AddSecurityCode(c *fiber.Ctx) error {
 		CreatedBy: user.Name,
 	}
 
-	if err := h.db.Create(&securityCode).Error; err != nil {
+	if err := h.store.SecurityCodes.Create(&securityCode); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create security code",
 		})
 	}
 
+	event := newAuditEvent(c, h.audit, user, models.AuditSecurityCodeAdd, "security_code", securityCode.Code)
+	event.After = securityCode
+	h.audit.Record(event)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Security code added successfully",
@@ -311,8 +600,8 @@ func (h *APIHandler) AddSecurityCode(c *fiber.Ctx) error {
 func (h *APIHandler) RemoveSecurityCode(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -326,9 +615,9 @@ func (h *APIHandler) RemoveSecurityCode(c *fiber.Ctx) error {
 	}
 
 	// Find and delete the security code
-	var securityCode models.SecurityCode
-	if err := h.db.Where("code = ?", code).First(&securityCode).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	securityCode, err := h.store.SecurityCodes.GetByCode(code)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 				"error": "Security code not found",
 			})
@@ -338,12 +627,16 @@ func (h *APIHandler) RemoveSecurityCode(c *fiber.Ctx) error {
 		})
 	}
 
-	if err := h.db.Delete(&securityCode).Error; err != nil {
+	if err := h.store.SecurityCodes.Delete(securityCode.ID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to remove security code",
 		})
 	}
 
+	event := newAuditEvent(c, h.audit, user, models.AuditSecurityCodeRemove, "security_code", securityCode.Code)
+	event.Before = securityCode
+	h.audit.Record(event)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Security code removed successfully",
@@ -354,17 +647,20 @@ func (h *APIHandler) RemoveSecurityCode(c *fiber.Ctx) error {
 func (h *APIHandler) GetBillboardControl(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	if _, err := h.store.Users.GetByID(userID); err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
 	}
 
-	// Get the current billboard state
-	var billboardState models.BillboardState
-	if err := h.db.Where("is_active = ?", true).First(&billboardState).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	// Get the current billboard state, or the one at the key's location for
+	// restricted API keys
+	billboardState, err := h.store.BillboardStates.GetActive()
+	if restricted := apiLocationRestriction(c); restricted != "" {
+		billboardState, err = h.activeBillboardAt(restricted)
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			// No active billboard state
 			return c.JSON(fiber.Map{
 				"success": true,
@@ -404,11 +700,23 @@ func (h *APIHandler) GetBillboardControl(c *fiber.Ctx) error {
 	})
 }
 
+// activeBillboardAt returns the active billboard state at a location
+func (h *APIHandler) activeBillboardAt(locationID string) (*models.BillboardState, error) {
+	states, err := h.store.BillboardStates.ListActive(locationID)
+	if err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &states[0], nil
+}
+
 func (h *APIHandler) LaunchBillboard(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -432,47 +740,48 @@ func (h *APIHandler) LaunchBillboard(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get event details (for now, use mock event since we don't have real PCO events)
-	// In a real implementation, you would query the events table
-	eventName := "Sunday Service"     // Mock event name
-	locationName := "Main Auditorium" // Mock location name
-
-	// Get all active security codes from the database
-	var securityCodes []models.SecurityCode
-	if err := h.db.Where("is_active = ?", true).Find(&securityCodes).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch security codes",
+	restricted := apiLocationRestriction(c)
+	if restricted != "" && request.LocationID != restricted {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "API key is not permitted for this location",
 		})
 	}
 
-	// Extract code strings
-	var codeStrings []string
-	for _, code := range securityCodes {
-		codeStrings = append(codeStrings, code.Code)
+	// Either a PCO event or a local one, by the id GetEvents lists
+	launchEvent, err := h.store.Events.GetByPCOID(request.EventID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Event not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch event",
+		})
 	}
-
-	// Deactivate all existing billboard states
-	h.db.Model(&models.BillboardState{}).Where("is_active = ?", true).Update("is_active", false)
-
-	// Create new billboard state
-	newBillboardState := models.BillboardState{
-		EventID:       1, // Mock event ID
-		EventName:     eventName,
-		Date:          time.Now(),
-		LocationID:    request.LocationID,
-		LocationName:  locationName,
-		SecurityCodes: codeStrings,
-		IsActive:      true,
-		LastUpdated:   time.Now(),
-		CreatedBy:     user.Name,
+	if !launchEvent.IsActive {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Event is not active",
+		})
 	}
 
-	if err := h.db.Create(&newBillboardState).Error; err != nil {
+	// Replaces every active billboard, as before events had locations, or
+	// only the key's location for restricted API keys
+	previousStates, newBillboardState, err := h.billboardService.Launch(launchEvent, request.LocationID, user.Name, restricted)
+	if err != nil {
+		h.logger.Error("Failed to launch billboard", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to launch billboard",
 		})
 	}
 
+	event := newAuditEvent(c, h.audit, user, models.AuditBillboardLaunch, "location", request.LocationID)
+	if len(previousStates) > 0 {
+		event.Before = previousStates
+	}
+	event.After = newBillboardState
+	h.audit.Record(event)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Billboard launched successfully",
@@ -482,20 +791,33 @@ func (h *APIHandler) LaunchBillboard(c *fiber.Ctx) error {
 func (h *APIHandler) ClearBillboard(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
 	}
 
-	// Deactivate all active billboard states
-	if err := h.db.Model(&models.BillboardState{}).Where("is_active = ?", true).Update("is_active", false).Error; err != nil {
+	// Deactivate all active billboard states, or only the key's location for restricted API keys
+	restricted := apiLocationRestriction(c)
+	clearedStates, err := h.store.BillboardStates.ListActive(restricted)
+	if err != nil {
+		h.logger.Error("Failed to load active billboards", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to clear billboard",
+		})
+	}
+	if _, err := h.store.BillboardStates.Deactivate(restricted); err != nil {
+		h.logger.Error("Failed to clear billboard", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to clear billboard",
 		})
 	}
 
+	event := newAuditEvent(c, h.audit, user, models.AuditBillboardClear, "location", restricted)
+	event.Before = clearedStates
+	h.audit.Record(event)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "Billboard cleared successfully",
@@ -506,8 +828,8 @@ func (h *APIHandler) ClearBillboard(c *fiber.Ctx) error {
 func (h *APIHandler) GetCheckIns(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -517,6 +839,14 @@ func (h *APIHandler) GetCheckIns(c *fiber.Ctx) error {
 	locationID := c.Query("location_id")
 	since := c.Query("since")
 
+	// API keys limited to one location only see that location's check-ins
+	if restricted := apiLocationRestriction(c); restricted != "" {
+		if locationID != "" && locationID != restricted {
+			return locationForbidden(c)
+		}
+		locationID = restricted
+	}
+
 	var sinceTime time.Time
 	if since != "" {
 		var err error
@@ -538,6 +868,7 @@ func (h *APIHandler) GetCheckIns(c *fiber.Ctx) error {
 			"error": "Failed to fetch check-ins from PCO",
 		})
 	}
+	redactCheckIns(c, checkIns)
 
 	return c.JSON(fiber.Map{
 		"check_ins": checkIns,
@@ -551,11 +882,14 @@ func (h *APIHandler) GetCheckInsByLocation(c *fiber.Ctx) error {
 			"error": "Location ID is required",
 		})
 	}
+	if !locationAllowed(c, locationID) {
+		return locationForbidden(c)
+	}
 
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -585,6 +919,7 @@ func (h *APIHandler) GetCheckInsByLocation(c *fiber.Ctx) error {
 			"error": "Failed to fetch check-ins from PCO",
 		})
 	}
+	redactCheckIns(c, checkIns)
 
 	return c.JSON(fiber.Map{
 		"check_ins":   checkIns,
@@ -592,6 +927,36 @@ func (h *APIHandler) GetCheckInsByLocation(c *fiber.Ctx) error {
 	})
 }
 
+// hasMedicalAccess reports whether the caller may see medical notes and
+// emergency contacts: admins, users granted medical access, and API keys with
+// the medical:read scope
+func hasMedicalAccess(c *fiber.Ctx) bool {
+	allowed, _ := c.Locals("medical_access").(bool)
+	return allowed
+}
+
+// hasContactAccess reports whether the caller may see parents' phone numbers
+// and email addresses: admins, users granted contact access, and API keys
+// with the contacts:read scope
+func hasContactAccess(c *fiber.Ctx) bool {
+	allowed, _ := c.Locals("contact_access").(bool)
+	return allowed
+}
+
+// redactCheckIns removes medical details and parent contacts from PCO
+// check-ins unless the caller may see them
+func redactCheckIns(c *fiber.Ctx, checkIns []services.PCOCheckIn) {
+	medical, contacts := hasMedicalAccess(c), hasContactAccess(c)
+	for i := range checkIns {
+		if !medical {
+			checkIns[i].Redact()
+		}
+		if !contacts {
+			checkIns[i].RedactContact()
+		}
+	}
+}
+
 func (h *APIHandler) GetCheckInsByEvent(c *fiber.Ctx) error {
 	eventID := c.Params("eventId")
 	if eventID == "" {
@@ -602,8 +967,7 @@ func (h *APIHandler) GetCheckInsByEvent(c *fiber.Ctx) error {
 
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	if _, err := h.store.Users.GetByID(userID); err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -625,8 +989,8 @@ func (h *APIHandler) GetCheckInsByEvent(c *fiber.Ctx) error {
 func (h *APIHandler) GetLocations(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -641,7 +1005,7 @@ func (h *APIHandler) GetLocations(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"locations": locations,
+		"locations": allowedLocations(c, locations),
 	})
 }
 
@@ -653,8 +1017,8 @@ func (h *APIHandler) GetLocation(c *fiber.Ctx) error {
 func (h *APIHandler) GetLocationStatus(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -667,11 +1031,14 @@ func (h *APIHandler) GetLocationStatus(c *fiber.Ctx) error {
 			"error": "Location ID is required",
 		})
 	}
+	if !locationAllowed(c, locationId) {
+		return locationForbidden(c)
+	}
 
 	// Get active notifications for this location
-	var notifications []models.Notification
-	if err := h.db.Where("location_name = ? AND status = ? AND expires_at > ?", locationId, "active", time.Now()).
-		Order("created_at DESC").Find(&notifications).Error; err != nil {
+	activeFilter := repository.NotificationFilter{Status: "active", LocationName: locationId, ActiveAt: time.Now()}
+	notifications, err := h.store.Notifications.List(activeFilter)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch location notifications",
 		})
@@ -679,28 +1046,29 @@ func (h *APIHandler) GetLocationStatus(c *fiber.Ctx) error {
 
 	// Get recent check-ins for this location (last 24 hours)
 	todayStart := time.Now().AddDate(0, 0, -1)
-	var recentCheckIns []models.CheckIn
-	if err := h.db.Where("location_id = ? AND check_in_time >= ?", locationId, todayStart).
-		Order("check_in_time DESC").Limit(10).Find(&recentCheckIns).Error; err != nil {
+	recentCheckIns, err := h.store.CheckIns.List(repository.CheckInFilter{LocationID: locationId, Since: todayStart, Limit: 10})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch recent check-ins",
 		})
 	}
 
 	// Calculate location metrics
-	var totalCheckIns int64
-	var todayCheckIns int64
-	var activeChildren int64
 
 	// Total check-ins
-	h.db.Model(&models.CheckIn{}).Where("location_id = ?", locationId).Count(&totalCheckIns)
+	totalCheckIns, _ := h.store.CheckIns.Count(repository.CheckInFilter{LocationID: locationId})
 
 	// Today's check-ins
 	today := time.Now().Truncate(24 * time.Hour)
-	h.db.Model(&models.CheckIn{}).Where("location_id = ? AND check_in_time >= ?", locationId, today).Count(&todayCheckIns)
+	todayCheckIns, _ := h.store.CheckIns.Count(repository.CheckInFilter{LocationID: locationId, Since: today})
 
-	// Active children (notifications)
-	h.db.Model(&models.Notification{}).Where("location_name = ? AND status = ? AND expires_at > ?", locationId, "active", time.Now()).Count(&activeChildren)
+	// Active children are those checked in and not yet checked out
+	var activeChildren int
+	if roster, err := h.occupancy.Roster(locationId, time.Now(), false, false); err == nil {
+		activeChildren = len(roster.Children)
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		h.logger.Error("Failed to get location roster", "error", err, "location_id", locationId)
+	}
 
 	// Get location details from PCO (if available)
 	locationName := locationId // Default to ID if no name available
@@ -729,12 +1097,43 @@ func (h *APIHandler) GetLocationStatus(c *fiber.Ctx) error {
 	})
 }
 
+// GetLocationRoster lists the children and volunteers in a room, or in every
+// room in a folder, with how long each has been there
+func (h *APIHandler) GetLocationRoster(c *fiber.Ctx) error {
+	locationID := c.Params("locationId")
+	if !utils.ValidateLocationID(locationID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid location ID",
+		})
+	}
+	if !locationAllowed(c, locationID) {
+		return locationForbidden(c)
+	}
+
+	roster, err := h.occupancy.Roster(locationID, time.Now(), hasMedicalAccess(c), hasContactAccess(c))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Location not found",
+			})
+		}
+		h.logger.Error("Failed to get location roster", "error", err, "location_id", locationID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get roster",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"roster":  roster,
+	})
+}
+
 // GetLocationAnalytics returns comprehensive analytics for a location
 func (h *APIHandler) GetLocationAnalytics(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	if _, err := h.store.Users.GetByID(userID); err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -747,6 +1146,9 @@ func (h *APIHandler) GetLocationAnalytics(c *fiber.Ctx) error {
 			"error": "Location ID is required",
 		})
 	}
+	if !locationAllowed(c, locationId) {
+		return locationForbidden(c)
+	}
 
 	// Get query parameters
 	daysStr := c.Query("days", "30")
@@ -759,76 +1161,49 @@ func (h *APIHandler) GetLocationAnalytics(c *fiber.Ctx) error {
 	endDate := time.Now()
 	startDate := endDate.AddDate(0, 0, -days)
 
-	// Get daily check-in counts for the period
-	var dailyStats []struct {
-		Date  string `json:"date"`
-		Count int64  `json:"count"`
+	// Get daily attendance for the period from the rollups
+	dailyStats, err := h.rollups.Daily(locationId, startDate, endDate)
+	if err != nil {
+		h.logger.Error("Failed to get daily attendance", "error", err, "location_id", locationId)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get location analytics",
+		})
 	}
 
-	rows, err := h.db.Raw(`
-		SELECT DATE(check_in_time) as date, COUNT(*) as count 
-		FROM check_ins 
-		WHERE location_id = ? AND check_in_time >= ? AND check_in_time <= ?
-		GROUP BY DATE(check_in_time)
-		ORDER BY date
-	`, locationId, startDate, endDate).Rows()
-
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var stat struct {
-				Date  string `json:"date"`
-				Count int64  `json:"count"`
-			}
-			rows.Scan(&stat.Date, &stat.Count)
-			dailyStats = append(dailyStats, stat)
-		}
+	var totalCheckIns, firstTimeVisitors int64
+	for _, day := range dailyStats {
+		totalCheckIns += day.Count
+		firstTimeVisitors += day.FirstTimeVisitors
 	}
 
-	// Get peak hours analysis
-	var peakHours []struct {
-		Hour  int   `json:"hour"`
-		Count int64 `json:"count"`
+	// Attendance per event, including each local or recurring one
+	eventStats, err := h.rollups.ByEvent(locationId, startDate, endDate)
+	if err != nil {
+		h.logger.Error("Failed to get attendance by event", "error", err, "location_id", locationId)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get location analytics",
+		})
 	}
 
-	peakRows, err := h.db.Raw(`
-		SELECT EXTRACT(HOUR FROM check_in_time) as hour, COUNT(*) as count 
-		FROM check_ins 
-		WHERE location_id = ? AND check_in_time >= ?
-		GROUP BY EXTRACT(HOUR FROM check_in_time)
-		ORDER BY count DESC
-		LIMIT 5
-	`, locationId, startDate).Rows()
-
-	if err == nil {
-		defer peakRows.Close()
-		for peakRows.Next() {
-			var stat struct {
-				Hour  int   `json:"hour"`
-				Count int64 `json:"count"`
-			}
-			peakRows.Scan(&stat.Hour, &stat.Count)
-			peakHours = append(peakHours, stat)
-		}
+	// Get peak hours analysis
+	peakHours, err := h.rollups.PeakHours(locationId, startDate, endDate, 5)
+	if err != nil {
+		h.logger.Error("Failed to get peak hours", "error", err, "location_id", locationId)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get location analytics",
+		})
 	}
 
 	// Get average wait times (time between check-in and pickup notification)
-	var avgWaitTime float64
-	h.db.Raw(`
-		SELECT AVG(JULIANDAY(n.created_at) - JULIANDAY(c.check_in_time)) * 24 * 60 as avg_wait_minutes
-		FROM check_ins c
-		JOIN notifications n ON c.pco_check_in_id = n.pco_check_in_id
-		WHERE c.location_id = ? AND c.check_in_time >= ?
-	`, locationId, startDate).Scan(&avgWaitTime)
+	avgWaitTime, err := h.store.CheckIns.AverageWaitMinutes(locationId, startDate)
+	if err != nil {
+		h.logger.Error("Failed to get average wait time", "error", err, "location_id", locationId)
+	}
 
 	// Get location efficiency metrics
-	var totalNotifications int64
-	var completedPickups int64
-	var expiredNotifications int64
-
-	h.db.Model(&models.Notification{}).Where("location_name = ? AND created_at >= ?", locationId, startDate).Count(&totalNotifications)
-	h.db.Model(&models.Notification{}).Where("location_name = ? AND status = ? AND created_at >= ?", locationId, "completed", startDate).Count(&completedPickups)
-	h.db.Model(&models.Notification{}).Where("location_name = ? AND expires_at < ? AND created_at >= ?", locationId, time.Now(), startDate).Count(&expiredNotifications)
+	totalNotifications, _ := h.store.Notifications.Count(repository.NotificationFilter{LocationName: locationId, CreatedSince: startDate})
+	completedPickups, _ := h.store.Notifications.Count(repository.NotificationFilter{LocationName: locationId, Status: "completed", CreatedSince: startDate})
+	expiredNotifications, _ := h.store.Notifications.Count(repository.NotificationFilter{LocationName: locationId, ExpiredBefore: time.Now(), CreatedSince: startDate})
 
 	efficiencyRate := 0.0
 	if totalNotifications > 0 {
@@ -841,6 +1216,9 @@ func (h *APIHandler) GetLocationAnalytics(c *fiber.Ctx) error {
 			"location_id":           locationId,
 			"period_days":           days,
 			"daily_stats":           dailyStats,
+			"event_stats":           eventStats,
+			"total_check_ins":       totalCheckIns,
+			"first_time_visitors":   firstTimeVisitors,
 			"peak_hours":            peakHours,
 			"avg_wait_time_mins":    avgWaitTime,
 			"efficiency_rate":       efficiencyRate,
@@ -856,8 +1234,8 @@ func (h *APIHandler) GetLocationAnalytics(c *fiber.Ctx) error {
 func (h *APIHandler) GetLocationsOverview(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	user, err := h.store.Users.GetByID(userID)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -870,11 +1248,27 @@ func (h *APIHandler) GetLocationsOverview(c *fiber.Ctx) error {
 			"error": "Failed to fetch locations from PCO",
 		})
 	}
+	locations = allowedLocations(c, locations)
+
+	// Get query parameters
+	days := 7 // default to 7 days
+	if parsedDays, err := strconv.ParseInt(c.Query("days", "7"), 10, 32); err == nil && parsedDays > 0 {
+		days = int(parsedDays)
+	}
+
+	// Check-ins per location over the period and today, from the rollups
+	now := time.Now()
+	totals, err := h.rollups.TotalsByLocation(now.AddDate(0, 0, -days), now)
+	if err != nil {
+		h.logger.Error("Failed to get attendance totals", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get attendance totals",
+		})
+	}
 
 	// Get active notifications grouped by location
-	var notifications []models.Notification
-	if err := h.db.Where("status = ? AND expires_at > ?", "active", time.Now()).
-		Order("created_at DESC").Find(&notifications).Error; err != nil {
+	notifications, err := h.store.Notifications.List(repository.NotificationFilter{Status: "active", ActiveAt: time.Now()})
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch active notifications",
 		})
@@ -882,7 +1276,12 @@ func (h *APIHandler) GetLocationsOverview(c *fiber.Ctx) error {
 
 	// Group notifications by location
 	locationMap := make(map[string][]models.Notification)
+	var totalChildren int
 	for _, notification := range notifications {
+		if !locationAllowed(c, notification.LocationID) {
+			continue
+		}
+		totalChildren++
 		locationName := notification.LocationName
 		if locationName == "" {
 			locationName = "Unknown Location"
@@ -890,29 +1289,50 @@ func (h *APIHandler) GetLocationsOverview(c *fiber.Ctx) error {
 		locationMap[locationName] = append(locationMap[locationName], notification)
 	}
 
+	// Live occupancy of the synced locations, and the rooms near or at
+	// their capacity or volunteer ratio
+	occupancy := make(map[string]services.RoomOccupancy)
+	alerts := []services.RoomOccupancy{}
+	rooms, err := h.occupancy.Rooms(time.Now())
+	if err != nil {
+		h.logger.Error("Failed to get room occupancy", "error", err)
+	}
+	for _, room := range rooms {
+		if !locationAllowed(c, room.LocationID) {
+			continue
+		}
+		occupancy[room.LocationID] = room
+		if room.Status != services.RoomStatusOK {
+			alerts = append(alerts, room)
+		}
+	}
+
 	// Build location overview
 	var locationOverviews []fiber.Map
 	for _, location := range locations {
 		activeChildren := len(locationMap[location.Name])
 
-		// Get today's check-ins for this location
-		today := time.Now().Truncate(24 * time.Hour)
-		var todayCheckIns int64
-		h.db.Model(&models.CheckIn{}).Where("location_id = ? AND check_in_time >= ?", location.ID, today).Count(&todayCheckIns)
-
-		// Get total check-ins for this location
-		var totalCheckIns int64
-		h.db.Model(&models.CheckIn{}).Where("location_id = ?", location.ID).Count(&totalCheckIns)
+		// Today's check-ins and those over the period at this location
+		var todayCheckIns, totalCheckIns int64
+		if total, ok := totals[location.ID]; ok {
+			todayCheckIns = total.LastDay.CheckIns
+			totalCheckIns = total.Period.CheckIns
+		}
 
-		locationOverviews = append(locationOverviews, fiber.Map{
+		overview := fiber.Map{
 			"id":              location.ID,
 			"name":            location.Name,
 			"active_children": activeChildren,
 			"today_check_ins": todayCheckIns,
 			"total_check_ins": totalCheckIns,
+			"period_days":     days,
 			"is_active":       true, // All PCO locations are considered active
 			"last_updated":    time.Now().Format(time.RFC3339),
-		})
+		}
+		if room, ok := occupancy[location.ID]; ok {
+			overview["occupancy"] = room
+		}
+		locationOverviews = append(locationOverviews, overview)
 	}
 
 	return c.JSON(fiber.Map{
@@ -921,7 +1341,8 @@ func (h *APIHandler) GetLocationsOverview(c *fiber.Ctx) error {
 		"summary": fiber.Map{
 			"total_locations":  len(locationOverviews),
 			"active_locations": len(locationOverviews), // All locations are considered active for now
-			"total_children":   len(notifications),
+			"total_children":   totalChildren,
+			"room_alerts":      alerts,
 			"generated_at":     time.Now().Format(time.RFC3339),
 		},
 	})
@@ -931,8 +1352,7 @@ func (h *APIHandler) GetLocationsOverview(c *fiber.Ctx) error {
 func (h *APIHandler) GetCheckInStats(c *fiber.Ctx) error {
 	// Get the current user's access token
 	userID := c.Locals("user_id").(uint)
-	var user models.User
-	if err := h.db.First(&user, userID).Error; err != nil {
+	if _, err := h.store.Users.GetByID(userID); err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "User not found",
 		})
@@ -945,6 +1365,9 @@ func (h *APIHandler) GetCheckInStats(c *fiber.Ctx) error {
 			"error": "Location ID is required",
 		})
 	}
+	if !locationAllowed(c, locationId) {
+		return locationForbidden(c)
+	}
 
 	// Get query parameters
 	daysStr := c.Query("days", "7")
@@ -958,33 +1381,24 @@ func (h *APIHandler) GetCheckInStats(c *fiber.Ctx) error {
 	startDate := endDate.AddDate(0, 0, -days)
 
 	// Get total check-ins for the period
-	var totalCheckIns int64
-	if err := h.db.Model(&models.CheckIn{}).
-		Where("location_id = ? AND check_in_time >= ? AND check_in_time <= ?", locationId, startDate, endDate).
-		Count(&totalCheckIns).Error; err != nil {
+	total, err := h.rollups.Totals(locationId, startDate, endDate)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch check-in statistics",
 		})
 	}
 
 	// Get today's check-ins
-	todayStart := time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 0, 0, 0, 0, endDate.Location())
-	todayEnd := todayStart.Add(24 * time.Hour)
-	var todayCheckIns int64
-	if err := h.db.Model(&models.CheckIn{}).
-		Where("location_id = ? AND check_in_time >= ? AND check_in_time < ?", locationId, todayStart, todayEnd).
-		Count(&todayCheckIns).Error; err != nil {
+	today, err := h.rollups.Totals(locationId, endDate, endDate)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch today's check-ins",
 		})
 	}
 
 	// Get weekly check-ins (last 7 days)
-	weekStart := endDate.AddDate(0, 0, -7)
-	var weeklyCheckIns int64
-	if err := h.db.Model(&models.CheckIn{}).
-		Where("location_id = ? AND check_in_time >= ? AND check_in_time <= ?", locationId, weekStart, endDate).
-		Count(&weeklyCheckIns).Error; err != nil {
+	weekly, err := h.rollups.Totals(locationId, endDate.AddDate(0, 0, -7), endDate)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to fetch weekly check-ins",
 		})
@@ -993,11 +1407,12 @@ func (h *APIHandler) GetCheckInStats(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"success": true,
 		"stats": fiber.Map{
-			"total_check_ins":  totalCheckIns,
-			"today_check_ins":  todayCheckIns,
-			"weekly_check_ins": weeklyCheckIns,
-			"period_days":      days,
-			"location_id":      locationId,
+			"total_check_ins":     total.CheckIns,
+			"today_check_ins":     today.CheckIns,
+			"weekly_check_ins":    weekly.CheckIns,
+			"first_time_visitors": total.FirstTimeVisitors,
+			"period_days":         days,
+			"location_id":         locationId,
 		},
 	})
 }
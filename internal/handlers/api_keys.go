@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/middleware"
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/services"
+	"go_pco_arrivals/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type APIKeyHandler struct {
+	config *config.Config
+	auth   *services.AuthService
+	logger *utils.Logger
+}
+
+func NewAPIKeyHandler(cfg *config.Config, auth *services.AuthService, logger *utils.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		config: cfg,
+		auth:   auth,
+		logger: logger,
+	}
+}
+
+// ListAPIKeys returns all API keys (admin only)
+func (h *APIKeyHandler) ListAPIKeys(c *fiber.Ctx) error {
+	keys, err := h.auth.ListAPIKeys()
+	if err != nil {
+		h.logger.Error("Failed to list API keys", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch API keys",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"api_keys": keys,
+		"scopes":   models.APIKeyScopes,
+	})
+}
+
+// CreateAPIKey issues a new API key owned by the current admin. The key
+// itself is only included in this response.
+func (h *APIKeyHandler) CreateAPIKey(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	user, err := h.auth.GetUserByID(userID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	var request struct {
+		Name       string     `json:"name"`
+		Scopes     []string   `json:"scopes"`
+		LocationID string     `json:"location_id"`
+		ExpiresAt  *time.Time `json:"expires_at"`
+	}
+
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	apiKey, rawKey, err := h.auth.CreateAPIKey(user, request.Name, request.Scopes, request.LocationID, request.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("Failed to create API key", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create API key",
+		})
+	}
+
+	h.logger.Info("API key created", "api_key_id", apiKey.ID, "name", apiKey.Name, "created_by", user.Name)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"api_key": apiKey,
+		"key":     rawKey,
+		"message": "Store this key now; it will not be shown again",
+	})
+}
+
+// RevokeAPIKey revokes an API key (admin only)
+func (h *APIKeyHandler) RevokeAPIKey(c *fiber.Ctx) error {
+	keyID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid API key ID",
+		})
+	}
+
+	if _, err := h.auth.GetAPIKeyByID(uint(keyID)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "API key not found",
+		})
+	}
+
+	if err := h.auth.RevokeAPIKey(uint(keyID)); err != nil {
+		h.logger.Error("Failed to revoke API key", "error", err, "api_key_id", keyID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke API key",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "API key revoked successfully",
+	})
+}
+
+// IssueToken exchanges an API key sent as "Authorization: Bearer <key>" for
+// a short-lived token with the same scopes
+func (h *APIKeyHandler) IssueToken(c *fiber.Ctx) error {
+	rawKey := middleware.BearerCredential(c)
+	if rawKey == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "API key required",
+		})
+	}
+
+	token, expiresAt, err := h.auth.IssueAPIToken(rawKey, middleware.ClientIP(c, h.config.Server.TrustProxy))
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid API key",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(time.Until(expiresAt).Seconds()),
+		"expires_at":   expiresAt.Format(time.RFC3339),
+	})
+}
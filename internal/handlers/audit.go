@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go_pco_arrivals/internal/middleware"
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/services"
+	"go_pco_arrivals/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditHandler struct {
+	audit  *services.AuditService
+	logger *utils.Logger
+}
+
+func NewAuditHandler(audit *services.AuditService, logger *utils.Logger) *AuditHandler {
+	return &AuditHandler{
+		audit:  audit,
+		logger: logger,
+	}
+}
+
+// ListAuditEvents returns audit events filtered by actor_id, action,
+// target_type, target_id, since and until. With ?format=csv the matching
+// events are downloaded as a CSV file.
+func (h *AuditHandler) ListAuditEvents(c *fiber.Ctx) error {
+	filter := repository.AuditFilter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		Limit:      c.QueryInt("limit", 100),
+		Offset:     c.QueryInt("offset", 0),
+	}
+
+	if actorID := c.Query("actor_id"); actorID != "" {
+		id, err := strconv.ParseUint(actorID, 10, 32)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid actor_id",
+			})
+		}
+		filter.ActorID = uint(id)
+	}
+
+	var err error
+	if filter.Since, err = parseAuditTime(c.Query("since")); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid since, expected RFC3339 or YYYY-MM-DD",
+		})
+	}
+	if filter.Until, err = parseAuditTime(c.Query("until")); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid until, expected RFC3339 or YYYY-MM-DD",
+		})
+	}
+
+	csvExport := c.Query("format") == "csv"
+	if csvExport && c.Query("limit") == "" {
+		filter.Limit = 10000
+	}
+
+	events, total, err := h.audit.ListEvents(filter)
+	if err != nil {
+		h.logger.Error("Failed to list audit events", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch audit events",
+		})
+	}
+
+	if csvExport {
+		return writeAuditCSV(c, events)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"events":  events,
+		"total":   total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+	})
+}
+
+// writeAuditCSV streams events as a CSV attachment. Names, IDs and user
+// agents come from callers, so they're made safe to open in a spreadsheet.
+func writeAuditCSV(c *fiber.Ctx, events []models.AuditEvent) error {
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="audit-`+time.Now().Format("20060102-150405")+`.csv"`)
+
+	writer := csv.NewWriter(c)
+	if err := writer.Write([]string{"id", "created_at", "actor_id", "actor_name", "auth_method", "api_key_id", "action", "target_type", "target_id", "ip_address", "user_agent", "before", "after"}); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for _, event := range events {
+		err := writer.Write([]string{
+			strconv.FormatUint(uint64(event.ID), 10),
+			event.CreatedAt.Format(time.RFC3339),
+			strconv.FormatUint(uint64(event.ActorID), 10),
+			services.SpreadsheetSafe(event.ActorName),
+			event.AuthMethod,
+			strconv.FormatUint(uint64(event.APIKeyID), 10),
+			event.Action,
+			event.TargetType,
+			services.SpreadsheetSafe(event.TargetID),
+			services.SpreadsheetSafe(event.IPAddress),
+			services.SpreadsheetSafe(event.UserAgent),
+			auditPayload(event.Before),
+			auditPayload(event.After),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func auditPayload(payload interface{}) string {
+	if payload == nil {
+		return ""
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// newAuditEvent builds an audit event for the current request. actor may be
+// nil when the caller could not be identified.
+func newAuditEvent(c *fiber.Ctx, audit *services.AuditService, actor *models.User, action, targetType, targetID string) *models.AuditEvent {
+	event := &models.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  middleware.ClientIP(c, audit != nil && audit.TrustProxy()),
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+	}
+	if actor != nil {
+		event.ActorID = actor.ID
+		event.ActorName = actor.Name
+	}
+	event.AuthMethod, _ = c.Locals("auth_method").(string)
+	event.APIKeyID, _ = c.Locals("api_key_id").(uint)
+	return event
+}
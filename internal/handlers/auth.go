@@ -3,23 +3,26 @@ package handlers
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/middleware"
 	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
 	"go_pco_arrivals/internal/services"
 	"go_pco_arrivals/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
 )
 
 type AuthHandler struct {
 	config *config.Config
-	db     *gorm.DB
+	store  *repository.Store
 	logger *utils.Logger
 	auth   *services.AuthService
 	pco    *services.PCOService
+	audit  *services.AuditService
 }
 
 type LoginRequest struct {
@@ -31,6 +34,7 @@ type AuthStatusResponse struct {
 	User            *models.User          `json:"user,omitempty"`
 	Session         *services.SessionData `json:"session,omitempty"`
 	ExpiresAt       *time.Time            `json:"expires_at,omitempty"`
+	CSRFToken       string                `json:"csrf_token,omitempty"`
 }
 
 type LogoutResponse struct {
@@ -38,13 +42,14 @@ type LogoutResponse struct {
 	Message string `json:"message"`
 }
 
-func NewAuthHandler(config *config.Config, db *gorm.DB, logger *utils.Logger, auth *services.AuthService, pco *services.PCOService) *AuthHandler {
+func NewAuthHandler(config *config.Config, store *repository.Store, logger *utils.Logger, auth *services.AuthService, pco *services.PCOService, audit *services.AuditService) *AuthHandler {
 	return &AuthHandler{
 		config: config,
-		db:     db,
+		store:  store,
 		logger: logger,
 		auth:   auth,
 		pco:    pco,
+		audit:  audit,
 	}
 }
 
@@ -151,6 +156,9 @@ func (h *AuthHandler) OAuthCallback(c *fiber.Ctx) error {
 	// Validate user is authorized
 	if !h.pco.ValidateUser(pcoUser.ID) {
 		h.logger.Error("Unauthorized user attempted login", "pco_user_id", pcoUser.ID)
+		event := newAuditEvent(c, h.audit, nil, models.AuditLoginDenied, "pco_user", pcoUser.ID)
+		event.ActorName = strings.TrimSpace(pcoUser.FirstName + " " + pcoUser.LastName)
+		h.audit.Record(event)
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
 			"error": "User is not authorized to access this application",
 		})
@@ -171,7 +179,7 @@ func (h *AuthHandler) OAuthCallback(c *fiber.Ctx) error {
 	user.TokenExpiry = time.Now().Add(time.Duration(authResp.ExpiresIn) * time.Second)
 	user.UpdatedAt = time.Now()
 
-	if err := h.db.Save(user).Error; err != nil {
+	if err := h.store.Users.Save(user); err != nil {
 		h.logger.Error("Failed to save user tokens", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to save authentication tokens",
@@ -179,7 +187,10 @@ func (h *AuthHandler) OAuthCallback(c *fiber.Ctx) error {
 	}
 
 	// Create session
-	sessionData, err := h.auth.CreateSession(user, rememberMe)
+	sessionData, err := h.auth.CreateSession(user, rememberMe, services.SessionMetadata{
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IPAddress: middleware.ClientIP(c, h.config.Server.TrustProxy),
+	})
 	if err != nil {
 		h.logger.Error("Failed to create session", "error", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -205,6 +216,11 @@ func (h *AuthHandler) OAuthCallback(c *fiber.Ctx) error {
 
 	h.logger.Info("User authenticated successfully", "user_id", user.ID, "pco_user_id", pcoUser.ID)
 
+	event := newAuditEvent(c, h.audit, user, models.AuditLogin, "session", fmt.Sprintf("%d", sessionData.ID))
+	event.AuthMethod = services.AuthMethodSession
+	event.After = fiber.Map{"remember_me": rememberMe}
+	h.audit.Record(event)
+
 	// Redirect to dashboard or return success response
 	if c.Get("Accept") == "application/json" {
 		return c.JSON(fiber.Map{
@@ -214,6 +230,7 @@ func (h *AuthHandler) OAuthCallback(c *fiber.Ctx) error {
 				"token":      sessionData.Token,
 				"expires_at": sessionData.ExpiresAt,
 			},
+			"csrf_token": h.auth.CSRFToken(sessionData.Token),
 		})
 	}
 
@@ -263,6 +280,7 @@ func (h *AuthHandler) GetAuthStatus(c *fiber.Ctx) error {
 		User:            user,
 		Session:         sessionData,
 		ExpiresAt:       &sessionData.ExpiresAt,
+		CSRFToken:       h.auth.CSRFToken(sessionToken),
 	})
 }
 
@@ -270,9 +288,18 @@ func (h *AuthHandler) GetAuthStatus(c *fiber.Ctx) error {
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	sessionToken := c.Cookies("session_token")
 	if sessionToken != "" {
+		// Identify the user for the audit log before the session goes away
+		sessionData, _ := h.auth.ValidateSession(sessionToken)
+
 		// Revoke session
 		if err := h.auth.RevokeSession(sessionToken); err != nil {
 			h.logger.Error("Failed to revoke session", "error", err)
+		} else if sessionData != nil {
+			if user, err := h.auth.GetUserByID(sessionData.UserID); err == nil {
+				event := newAuditEvent(c, h.audit, user, models.AuditLogout, "session", fmt.Sprintf("%d", sessionData.SessionID))
+				event.AuthMethod = services.AuthMethodSession
+				h.audit.Record(event)
+			}
 		}
 	}
 
@@ -419,7 +446,7 @@ func (h *AuthHandler) UpdateUserProfile(c *fiber.Ctx) error {
 	user.UpdatedAt = time.Now()
 
 	// Save changes
-	if err := h.db.Save(user).Error; err != nil {
+	if err := h.store.Users.Save(user); err != nil {
 		h.logger.Error("Failed to update user profile", "error", err, "user_id", user.ID)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update profile",
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/services"
+	"go_pco_arrivals/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type BackupHandler struct {
+	backups *services.BackupService
+	auth    *services.AuthService
+	audit   *services.AuditService
+	logger  *utils.Logger
+}
+
+func NewBackupHandler(backups *services.BackupService, auth *services.AuthService, audit *services.AuditService, logger *utils.Logger) *BackupHandler {
+	return &BackupHandler{
+		backups: backups,
+		auth:    auth,
+		audit:   audit,
+		logger:  logger,
+	}
+}
+
+// ListBackups returns the stored backups, newest first (admin only)
+func (h *BackupHandler) ListBackups(c *fiber.Ctx) error {
+	backups, err := h.backups.List()
+	if err != nil {
+		h.logger.Error("Failed to list backups", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to list backups",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"backups": backups,
+	})
+}
+
+// CreateBackup snapshots the database now (admin only)
+func (h *BackupHandler) CreateBackup(c *fiber.Ctx) error {
+	backup, err := h.backups.Create()
+	if errors.Is(err, services.ErrBackupUnsupported) {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		h.logger.Error("Failed to create backup", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create backup",
+		})
+	}
+
+	event := newAuditEvent(c, h.audit, h.actor(c), models.AuditBackupCreate, "backup", backup.Name)
+	event.After = backup
+	h.audit.Record(event)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"backup":  backup,
+	})
+}
+
+// DownloadBackup sends a backup file as an attachment (admin only)
+func (h *BackupHandler) DownloadBackup(c *fiber.Ctx) error {
+	name := c.Params("name")
+	path, err := h.backups.Path(name)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Backup not found",
+		})
+	}
+
+	h.audit.Record(newAuditEvent(c, h.audit, h.actor(c), models.AuditBackupDownload, "backup", name))
+	return c.Download(path, name)
+}
+
+// actor returns the signed-in user, or nil if they can't be loaded
+func (h *BackupHandler) actor(c *fiber.Ctx) *models.User {
+	userID, _ := c.Locals("user_id").(uint)
+	user, err := h.auth.GetUserByID(userID)
+	if err != nil {
+		return nil
+	}
+	return user
+}
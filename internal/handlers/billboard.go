@@ -1,23 +1,25 @@
 package handlers
 
 import (
+	"fmt"
 	"strconv"
 	"time"
 
 	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/repository"
 	"go_pco_arrivals/internal/services"
 	"go_pco_arrivals/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
 )
 
 type BillboardHandler struct {
 	config    *config.Config
-	db        *gorm.DB
+	store     *repository.Store
 	logger    *utils.Logger
 	billboard *services.BillboardService
 	pco       *services.PCOService
+	audit     *services.AuditService
 }
 
 type BillboardStateResponse struct {
@@ -40,18 +42,23 @@ type StatsResponse struct {
 }
 
 type SyncResponse struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Error   string `json:"error,omitempty"`
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	Created   int    `json:"created"`
+	Updated   int    `json:"updated"`
+	Unchanged int    `json:"unchanged"`
+	Failed    int    `json:"failed"`
+	Error     string `json:"error,omitempty"`
 }
 
-func NewBillboardHandler(config *config.Config, db *gorm.DB, logger *utils.Logger, billboard *services.BillboardService, pco *services.PCOService) *BillboardHandler {
+func NewBillboardHandler(config *config.Config, store *repository.Store, logger *utils.Logger, billboard *services.BillboardService, pco *services.PCOService, audit *services.AuditService) *BillboardHandler {
 	return &BillboardHandler{
 		config:    config,
-		db:        db,
+		store:     store,
 		logger:    logger,
 		billboard: billboard,
 		pco:       pco,
+		audit:     audit,
 	}
 }
 
@@ -182,7 +189,7 @@ func (h *BillboardHandler) SyncPCOCheckIns(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.billboard.SyncPCOCheckIns(accessToken, locationID)
+	result, err := h.billboard.SyncPCOCheckIns(accessToken, locationID)
 	if err != nil {
 		h.logger.Error("Failed to sync PCO check-ins", "error", err, "location_id", locationID)
 		return c.Status(fiber.StatusInternalServerError).JSON(SyncResponse{
@@ -191,9 +198,17 @@ func (h *BillboardHandler) SyncPCOCheckIns(c *fiber.Ctx) error {
 		})
 	}
 
+	message := "Successfully synced check-ins from PCO"
+	if result.Failed > 0 {
+		message = fmt.Sprintf("Synced check-ins from PCO with %d failures", result.Failed)
+	}
 	return c.JSON(SyncResponse{
-		Success: true,
-		Message: "Successfully synced check-ins from PCO",
+		Success:   result.Failed == 0,
+		Message:   message,
+		Created:   result.Created,
+		Updated:   result.Updated,
+		Unchanged: result.Unchanged,
+		Failed:    result.Failed,
 	})
 }
 
@@ -214,42 +229,6 @@ func (h *BillboardHandler) GetLocations(c *fiber.Ctx) error {
 	})
 }
 
-// AddLocation adds a new location to the system
-func (h *BillboardHandler) AddLocation(c *fiber.Ctx) error {
-	var request struct {
-		PCOLocationID string `json:"pco_location_id"`
-		Name          string `json:"name"`
-	}
-
-	if err := c.BodyParser(&request); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success": false,
-			"error":   "Invalid request body",
-		})
-	}
-
-	if request.PCOLocationID == "" || request.Name == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"success": false,
-			"error":   "PCO Location ID and Name are required",
-		})
-	}
-
-	err := h.billboard.AddLocation(request.PCOLocationID, request.Name)
-	if err != nil {
-		h.logger.Error("Failed to add location", "error", err, "pco_location_id", request.PCOLocationID)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"success": false,
-			"error":   "Failed to add location",
-		})
-	}
-
-	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Location added successfully",
-	})
-}
-
 // GetLocationBillboard returns the billboard for a specific location
 func (h *BillboardHandler) GetLocationBillboard(c *fiber.Ctx) error {
 	locationID := c.Params("locationID")
@@ -306,21 +285,17 @@ func (h *BillboardHandler) CleanupOldData(c *fiber.Ctx) error {
 func (h *BillboardHandler) GetSystemStatus(c *fiber.Ctx) error {
 	// Get locations count
 	var locationsCount int64
-	h.db.Model(&struct{}{}).Table("locations").Count(&locationsCount)
+	if locations, err := h.store.Locations.List(); err == nil {
+		locationsCount = int64(len(locations))
+	}
 
 	// Get check-ins count for today
-	var todayCheckIns int64
 	todayStart := time.Now().Truncate(24 * time.Hour)
 	todayEnd := todayStart.Add(24 * time.Hour)
-	h.db.Model(&struct{}{}).Table("check_ins").
-		Where("check_in_time >= ? AND check_in_time < ?", todayStart, todayEnd).
-		Count(&todayCheckIns)
+	todayCheckIns, _ := h.store.CheckIns.Count(repository.CheckInFilter{Since: todayStart, Until: todayEnd})
 
 	// Get active sessions count
-	var activeSessions int64
-	h.db.Model(&struct{}{}).Table("sessions").
-		Where("expires_at > ?", time.Now()).
-		Count(&activeSessions)
+	activeSessions, _ := h.store.Sessions.Count(repository.SessionFilter{ActiveAt: time.Now()})
 
 	status := fiber.Map{
 		"success": true,
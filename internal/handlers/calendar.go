@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/services"
+	"go_pco_arrivals/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type CalendarHandler struct {
+	auth   *services.AuthService
+	events *services.EventService
+	logger *utils.Logger
+}
+
+func NewCalendarHandler(auth *services.AuthService, events *services.EventService, logger *utils.Logger) *CalendarHandler {
+	return &CalendarHandler{
+		auth:   auth,
+		events: events,
+		logger: logger,
+	}
+}
+
+// GetCalendar serves the iCalendar feed of upcoming events and scheduled
+// billboards. Calendar apps can't send cookies or headers, so the feed is
+// authenticated by the user's secret token in the URL.
+func (h *CalendarHandler) GetCalendar(c *fiber.Ctx) error {
+	user, err := h.auth.UserForCalendarToken(c.Query("token"))
+	if err != nil {
+		if !errors.Is(err, services.ErrInvalidCalendarToken) {
+			h.logger.Error("Failed to check calendar token", "error", err)
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid calendar token",
+		})
+	}
+
+	locationID := c.Query("location_id")
+	if locationID != "" && !utils.ValidateLocationID(locationID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid location ID",
+		})
+	}
+
+	h.events.SyncIfStale(user.AccessToken)
+	calendar, err := h.events.Calendar(locationID, time.Now())
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Location not found",
+			})
+		}
+		h.logger.Error("Failed to build calendar", "error", err, "user_id", user.ID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build calendar",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="calendar.ics"`)
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.Send(calendar)
+}
+
+// CreateCalendarToken issues the current user a new calendar feed URL,
+// invalidating the previous one. The token is only included in this response.
+func (h *CalendarHandler) CreateCalendarToken(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	token, err := h.auth.RotateCalendarToken(userID)
+	if err != nil {
+		h.logger.Error("Failed to create calendar token", "error", err, "user_id", userID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to create calendar token",
+		})
+	}
+
+	feed := c.BaseURL() + "/api/calendar.ics?token=" + url.QueryEscape(token)
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"token":   token,
+		"url":     feed,
+		"message": "Add ?location_id= to the URL for a single location. Store the URL now; it will not be shown again.",
+	})
+}
+
+// DeleteCalendarToken revokes the current user's calendar feed URL
+func (h *CalendarHandler) DeleteCalendarToken(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	if err := h.auth.RevokeCalendarToken(userID); err != nil {
+		h.logger.Error("Failed to revoke calendar token", "error", err, "user_id", userID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke calendar token",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Calendar feed revoked",
+	})
+}
@@ -0,0 +1,520 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/middleware"
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/services"
+	"go_pco_arrivals/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// testServer serves the auth, billboard and notification routes from an
+// in-memory store, signed in as an authorized admin
+type testServer struct {
+	app     *fiber.App
+	store   *repository.Store
+	auth    *services.AuthService
+	admin   *models.User
+	session *models.Session
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	cfg := &config.Config{
+		Auth: config.AuthConfig{
+			SessionTTL:      3600,
+			RememberMeDays:  30,
+			AuthorizedUsers: []string{"pco-admin"},
+			JWTSecret:       "test-secret",
+			APITokenTTL:     3600,
+		},
+	}
+	store := repository.NewMemoryStore()
+	logger := utils.NewLogger()
+
+	rollupService := services.NewRollupService(store, logger)
+	pcoService := services.NewPCOService(cfg, store, logger, rollupService)
+	authService := services.NewAuthService(cfg, store, logger, pcoService)
+	notificationService := services.NewNotificationService(store, pcoService)
+	auditService := services.NewAuditService(cfg, store, logger)
+	billboardService := services.NewBillboardService(cfg, store, logger, pcoService, rollupService, nil, nil)
+
+	admin := &models.User{
+		PCOUserID:    "pco-admin",
+		Name:         "Test Admin",
+		Email:        "admin@example.com",
+		IsAdmin:      true,
+		IsActive:     true,
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		TokenExpiry:  time.Now().AddDate(1, 0, 0),
+	}
+	if err := store.Users.Create(admin); err != nil {
+		t.Fatalf("failed to create admin: %v", err)
+	}
+	session, err := authService.CreateSession(admin, false, services.SessionMetadata{UserAgent: "test"})
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	authHandler := NewAuthHandler(cfg, store, logger, authService, pcoService, auditService)
+	apiHandler := NewAPIHandler(store, pcoService, notificationService, billboardService, rollupService, nil, nil, nil, auditService, logger)
+
+	app := fiber.New(fiber.Config{ErrorHandler: middleware.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("auth_service", authService)
+		return c.Next()
+	})
+	app.Use(middleware.CSRFProtection())
+
+	auth := app.Group("/auth")
+	auth.Get("/status", authHandler.GetAuthStatus)
+	auth.Post("/logout", authHandler.Logout)
+
+	api := app.Group("/api", middleware.RequireAuth())
+	api.Get("/notifications", apiHandler.GetNotifications)
+	api.Post("/notifications", apiHandler.CreateNotification)
+	api.Delete("/notifications/:id", apiHandler.DeleteNotification)
+	api.Post("/billboard/launch", apiHandler.LaunchBillboard)
+	api.Post("/billboard/clear", apiHandler.ClearBillboard)
+	api.Get("/audit", middleware.RequireAdmin(), NewAuditHandler(auditService, logger).ListAuditEvents)
+
+	return &testServer{app: app, store: store, auth: authService, admin: admin, session: session}
+}
+
+// request builds a request with an optional JSON body
+func request(method, path string, body interface{}) *http.Request {
+	var reader io.Reader
+	if body != nil {
+		encoded, _ := json.Marshal(body)
+		reader = bytes.NewReader(encoded)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req
+}
+
+// signedIn adds the admin's session cookie and CSRF token to a request
+func (s *testServer) signedIn(req *http.Request) *http.Request {
+	return s.withSession(req, s.session)
+}
+
+func (s *testServer) withSession(req *http.Request, session *models.Session) *http.Request {
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: session.Token})
+	req.Header.Set(middleware.CSRFHeader, s.auth.CSRFToken(session.Token))
+	return req
+}
+
+// signIn creates a user who is not an admin and a session for them
+func (s *testServer) signIn(t *testing.T, pcoUserID string) (*models.User, *models.Session) {
+	t.Helper()
+	user := &models.User{
+		PCOUserID:    pcoUserID,
+		Name:         "Test " + pcoUserID,
+		Email:        pcoUserID + "@example.com",
+		IsActive:     true,
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		TokenExpiry:  time.Now().AddDate(1, 0, 0),
+	}
+	if err := s.store.Users.Create(user); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	session, err := s.auth.CreateSession(user, false, services.SessionMetadata{UserAgent: "test"})
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	return user, session
+}
+
+// withAPIKey authenticates a request with a new API key of the admin's
+func (s *testServer) withAPIKey(t *testing.T, req *http.Request, scopes []string, locationID string) *http.Request {
+	t.Helper()
+	_, key, err := s.auth.CreateAPIKey(s.admin, "test key", scopes, locationID, nil)
+	if err != nil {
+		t.Fatalf("failed to create API key: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	return req
+}
+
+// call sends a request, checks its status and decodes the JSON response
+func (s *testServer) call(t *testing.T, req *http.Request, wantStatus int) map[string]interface{} {
+	t.Helper()
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	raw, _ := io.ReadAll(resp.Body)
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &body); err != nil {
+			t.Fatalf("%s %s: invalid JSON response %q", req.Method, req.URL.Path, raw)
+		}
+	}
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%s %s: got status %d, want %d (%s)", req.Method, req.URL.Path, resp.StatusCode, wantStatus, raw)
+	}
+	return body
+}
+
+func (s *testServer) auditCount(t *testing.T, action string) int64 {
+	t.Helper()
+	_, total, err := s.store.AuditEvents.List(repository.AuditFilter{Action: action})
+	if err != nil {
+		t.Fatalf("failed to list audit events: %v", err)
+	}
+	return total
+}
+
+func TestAuthStatus(t *testing.T) {
+	s := newTestServer(t)
+
+	body := s.call(t, request(http.MethodGet, "/auth/status", nil), fiber.StatusOK)
+	if body["is_authenticated"] != false {
+		t.Errorf("anonymous status: got is_authenticated %v, want false", body["is_authenticated"])
+	}
+
+	req := request(http.MethodGet, "/auth/status", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "not-a-session"})
+	body = s.call(t, req, fiber.StatusOK)
+	if body["is_authenticated"] != false {
+		t.Errorf("invalid session: got is_authenticated %v, want false", body["is_authenticated"])
+	}
+
+	body = s.call(t, s.signedIn(request(http.MethodGet, "/auth/status", nil)), fiber.StatusOK)
+	if body["is_authenticated"] != true {
+		t.Fatalf("signed in: got is_authenticated %v, want true", body["is_authenticated"])
+	}
+	if body["csrf_token"] != s.auth.CSRFToken(s.session.Token) {
+		t.Errorf("signed in: got csrf_token %v, want the session's token", body["csrf_token"])
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	s := newTestServer(t)
+
+	s.call(t, request(http.MethodGet, "/api/notifications", nil), fiber.StatusUnauthorized)
+
+	req := request(http.MethodGet, "/api/notifications", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "not-a-session"})
+	s.call(t, req, fiber.StatusUnauthorized)
+
+	req = request(http.MethodGet, "/api/notifications", nil)
+	req.Header.Set("Authorization", "Bearer not-a-key")
+	s.call(t, req, fiber.StatusUnauthorized)
+
+	s.call(t, s.signedIn(request(http.MethodGet, "/api/notifications", nil)), fiber.StatusOK)
+}
+
+func TestCSRFProtection(t *testing.T) {
+	s := newTestServer(t)
+
+	req := request(http.MethodPost, "/api/billboard/clear", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: s.session.Token})
+	s.call(t, req, fiber.StatusForbidden)
+
+	req = request(http.MethodPost, "/api/billboard/clear", nil)
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: s.session.Token})
+	req.Header.Set(middleware.CSRFHeader, "wrong")
+	s.call(t, req, fiber.StatusForbidden)
+
+	s.call(t, s.signedIn(request(http.MethodPost, "/api/billboard/clear", nil)), fiber.StatusOK)
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t)
+
+	s.call(t, s.signedIn(request(http.MethodPost, "/auth/logout", nil)), fiber.StatusOK)
+	s.call(t, s.signedIn(request(http.MethodGet, "/api/notifications", nil)), fiber.StatusUnauthorized)
+
+	if got := s.auditCount(t, models.AuditLogout); got != 1 {
+		t.Errorf("logout audit events: got %d, want 1", got)
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	s := newTestServer(t)
+
+	launch := map[string]string{"event_id": "evt-1", "location_id": "loc-1"}
+	s.call(t, s.withAPIKey(t, request(http.MethodPost, "/api/billboard/launch", launch), []string{models.ScopeRead}, ""), fiber.StatusForbidden)
+	s.call(t, s.withAPIKey(t, request(http.MethodGet, "/api/notifications", nil), []string{models.ScopeRead}, ""), fiber.StatusOK)
+	s.call(t, s.withAPIKey(t, request(http.MethodPost, "/api/billboard/launch", launch), []string{models.ScopeBillboardWrite}, "loc-2"), fiber.StatusForbidden)
+}
+
+func TestBillboardLaunchAndClear(t *testing.T) {
+	s := newTestServer(t)
+
+	event := &models.Event{PCOEventID: "evt-1", Name: "Sunday Service", Date: time.Now(), IsActive: true, CreatedBy: "pco-admin"}
+	if err := s.store.Events.Create(event); err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+	inactive := &models.Event{PCOEventID: "evt-old", Name: "Old Service", Date: time.Now(), CreatedBy: "pco-admin"}
+	if err := s.store.Events.Create(inactive); err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+	inactive.IsActive = false
+	if err := s.store.Events.Save(inactive); err != nil {
+		t.Fatalf("failed to deactivate event: %v", err)
+	}
+
+	launch := func(eventID, locationID string) *http.Request {
+		return s.signedIn(request(http.MethodPost, "/api/billboard/launch", map[string]string{"event_id": eventID, "location_id": locationID}))
+	}
+	s.call(t, launch("", "loc-1"), fiber.StatusBadRequest)
+	s.call(t, launch("missing", "loc-1"), fiber.StatusNotFound)
+	s.call(t, launch("evt-old", "loc-1"), fiber.StatusBadRequest)
+
+	s.call(t, launch("evt-1", "loc-1"), fiber.StatusOK)
+	active, err := s.store.BillboardStates.ListActive("")
+	if err != nil {
+		t.Fatalf("failed to list billboards: %v", err)
+	}
+	if len(active) != 1 || active[0].LocationID != "loc-1" || active[0].EventID != event.ID {
+		t.Fatalf("after launch: got active billboards %+v, want one for loc-1", active)
+	}
+
+	// A second launch replaces the first billboard
+	s.call(t, launch("evt-1", "loc-2"), fiber.StatusOK)
+	active, err = s.store.BillboardStates.ListActive("")
+	if err != nil {
+		t.Fatalf("failed to list billboards: %v", err)
+	}
+	if len(active) != 1 || active[0].LocationID != "loc-2" {
+		t.Fatalf("after relaunch: got active billboards %+v, want one for loc-2", active)
+	}
+
+	s.call(t, s.signedIn(request(http.MethodPost, "/api/billboard/clear", nil)), fiber.StatusOK)
+	active, err = s.store.BillboardStates.ListActive("")
+	if err != nil {
+		t.Fatalf("failed to list billboards: %v", err)
+	}
+	if len(active) != 0 {
+		t.Fatalf("after clear: got %d active billboards, want 0", len(active))
+	}
+
+	if got := s.auditCount(t, models.AuditBillboardLaunch); got != 2 {
+		t.Errorf("launch audit events: got %d, want 2", got)
+	}
+	if got := s.auditCount(t, models.AuditBillboardClear); got != 1 {
+		t.Errorf("clear audit events: got %d, want 1", got)
+	}
+}
+
+func TestClearBillboardWithLocationKey(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, locationID := range []string{"loc-1", "loc-2"} {
+		state := &models.BillboardState{LocationID: locationID, IsActive: true, CreatedBy: "pco-admin"}
+		if err := s.store.BillboardStates.Create(state); err != nil {
+			t.Fatalf("failed to create billboard: %v", err)
+		}
+	}
+
+	s.call(t, s.withAPIKey(t, request(http.MethodPost, "/api/billboard/clear", nil), []string{models.ScopeBillboardWrite}, "loc-1"), fiber.StatusOK)
+
+	active, err := s.store.BillboardStates.ListActive("")
+	if err != nil {
+		t.Fatalf("failed to list billboards: %v", err)
+	}
+	if len(active) != 1 || active[0].LocationID != "loc-2" {
+		t.Fatalf("got active billboards %+v, want only loc-2's", active)
+	}
+}
+
+func TestLaunchBillboardWithLocationKey(t *testing.T) {
+	s := newTestServer(t)
+
+	event := &models.Event{PCOEventID: "evt-1", Name: "Sunday Service", Date: time.Now(), IsActive: true, CreatedBy: "pco-admin"}
+	if err := s.store.Events.Create(event); err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+	for _, locationID := range []string{"loc-1", "loc-2"} {
+		state := &models.BillboardState{LocationID: locationID, IsActive: true, CreatedBy: "pco-admin"}
+		if err := s.store.BillboardStates.Create(state); err != nil {
+			t.Fatalf("failed to create billboard: %v", err)
+		}
+	}
+
+	launch := map[string]string{"event_id": "evt-1", "location_id": "loc-1"}
+	s.call(t, s.withAPIKey(t, request(http.MethodPost, "/api/billboard/launch", launch), []string{models.ScopeBillboardWrite}, "loc-1"), fiber.StatusOK)
+
+	active, err := s.store.BillboardStates.ListActive("")
+	if err != nil {
+		t.Fatalf("failed to list billboards: %v", err)
+	}
+	if len(active) != 2 {
+		t.Fatalf("got %d active billboards, want the relaunched loc-1 and untouched loc-2", len(active))
+	}
+	for _, state := range active {
+		if state.LocationID == "loc-1" && state.EventID != event.ID {
+			t.Errorf("loc-1 billboard: got event %d, want the launched event %d", state.EventID, event.ID)
+		}
+	}
+
+	other, err := s.store.BillboardStates.ListActive("loc-2")
+	if err != nil {
+		t.Fatalf("failed to list billboards: %v", err)
+	}
+	if len(other) != 1 {
+		t.Errorf("loc-2: got %d active billboards, want 1", len(other))
+	}
+}
+
+func TestNotifications(t *testing.T) {
+	s := newTestServer(t)
+
+	checkIn := &models.CheckIn{
+		PCOCheckInID: "ci-1",
+		PersonID:     "person-1",
+		PersonName:   "Ada Child",
+		LocationID:   "loc-1",
+		LocationName: "Nursery",
+		SecurityCode: "ABC",
+		CheckInTime:  time.Now(),
+		EventID:      "evt-1",
+		ParentName:   "Pat Parent",
+		ParentPhone:  "555-0100",
+		Status:       "active",
+	}
+	if err := s.store.CheckIns.Create(checkIn); err != nil {
+		t.Fatalf("failed to create check-in: %v", err)
+	}
+
+	s.call(t, s.signedIn(request(http.MethodPost, "/api/notifications", map[string]string{})), fiber.StatusBadRequest)
+	s.call(t, s.signedIn(request(http.MethodPost, "/api/notifications", map[string]string{"security_code": "ZZZ"})), fiber.StatusBadRequest)
+
+	body := s.call(t, s.signedIn(request(http.MethodPost, "/api/notifications", map[string]string{"security_code": "abc"})), fiber.StatusCreated)
+	created, _ := body["notification"].(map[string]interface{})
+	if created["pco_check_in_id"] != "ci-1" || created["child_name"] != "Ada Child" {
+		t.Fatalf("created notification: got %v, want the details of check-in ci-1", created)
+	}
+
+	body = s.call(t, s.signedIn(request(http.MethodGet, "/api/notifications", nil)), fiber.StatusOK)
+	listed, _ := body["notifications"].([]interface{})
+	if len(listed) != 1 {
+		t.Fatalf("got %d notifications, want 1", len(listed))
+	}
+	notification, _ := listed[0].(map[string]interface{})
+	if notification["id"] != "ci-1" || notification["security_code"] != "ABC" || notification["parent_phone"] != "555-0100" {
+		t.Errorf("listed notification: got %v", notification)
+	}
+
+	// Parents' contacts are only shown to callers with contact access
+	parentPhone := func(req *http.Request) interface{} {
+		t.Helper()
+		body := s.call(t, req, fiber.StatusOK)
+		listed, _ := body["notifications"].([]interface{})
+		if len(listed) != 1 {
+			t.Fatalf("got %d notifications, want 1", len(listed))
+		}
+		notification, _ := listed[0].(map[string]interface{})
+		if notification["parent_name"] != "Pat Parent" {
+			t.Errorf("got parent_name %v, want it for everyone", notification["parent_name"])
+		}
+		return notification["parent_phone"]
+	}
+	teacher, teacherSession := s.signIn(t, "pco-teacher")
+	if got := parentPhone(s.withSession(request(http.MethodGet, "/api/notifications", nil), teacherSession)); got != nil {
+		t.Errorf("user without contact access: got parent_phone %v, want none", got)
+	}
+	if err := s.auth.SetUserContactAccess(teacher.ID, true); err != nil {
+		t.Fatalf("failed to grant contact access: %v", err)
+	}
+	if got := parentPhone(s.withSession(request(http.MethodGet, "/api/notifications", nil), teacherSession)); got != "555-0100" {
+		t.Errorf("user granted contact access: got parent_phone %v, want 555-0100", got)
+	}
+	if got := parentPhone(s.withAPIKey(t, request(http.MethodGet, "/api/notifications", nil), []string{models.ScopeRead}, "")); got != nil {
+		t.Errorf("key without contacts:read: got parent_phone %v, want none", got)
+	}
+	if got := parentPhone(s.withAPIKey(t, request(http.MethodGet, "/api/notifications", nil), []string{models.ScopeRead, models.ScopeContactsRead}, "")); got != "555-0100" {
+		t.Errorf("key with contacts:read: got parent_phone %v, want 555-0100", got)
+	}
+
+	// Keys limited to another location neither see nor cancel it
+	body = s.call(t, s.withAPIKey(t, request(http.MethodGet, "/api/notifications", nil), []string{models.ScopeRead}, "loc-2"), fiber.StatusOK)
+	if listed, _ := body["notifications"].([]interface{}); len(listed) != 0 {
+		t.Errorf("other location's key: got %d notifications, want 0", len(listed))
+	}
+	s.call(t, s.withAPIKey(t, request(http.MethodDelete, "/api/notifications/ci-1", nil), []string{models.ScopeNotificationsWrite}, "loc-2"), fiber.StatusForbidden)
+	s.call(t, s.withAPIKey(t, request(http.MethodPost, "/api/notifications", map[string]string{"security_code": "ABC", "location_id": "loc-1"}), []string{models.ScopeNotificationsWrite}, "loc-2"), fiber.StatusForbidden)
+
+	s.call(t, s.signedIn(request(http.MethodDelete, "/api/notifications/missing", nil)), fiber.StatusNotFound)
+	s.call(t, s.signedIn(request(http.MethodDelete, "/api/notifications/ci-1", nil)), fiber.StatusOK)
+
+	cancelled, err := s.store.Notifications.GetByCheckInID("ci-1")
+	if err != nil {
+		t.Fatalf("failed to load notification: %v", err)
+	}
+	if cancelled.Status != "cancelled" {
+		t.Errorf("after delete: got status %q, want cancelled", cancelled.Status)
+	}
+
+	body = s.call(t, s.signedIn(request(http.MethodGet, "/api/notifications", nil)), fiber.StatusOK)
+	if listed, _ := body["notifications"].([]interface{}); len(listed) != 0 {
+		t.Errorf("after delete: got %d active notifications, want 0", len(listed))
+	}
+
+	if got := s.auditCount(t, models.AuditNotificationCreate); got != 1 {
+		t.Errorf("create audit events: got %d, want 1", got)
+	}
+	if got := s.auditCount(t, models.AuditNotificationCancel); got != 1 {
+		t.Errorf("cancel audit events: got %d, want 1", got)
+	}
+}
+
+func TestAuditCSV(t *testing.T) {
+	s := newTestServer(t)
+
+	req := s.signedIn(request(http.MethodPost, "/api/billboard/clear", nil))
+	req.Header.Set("User-Agent", `=HYPERLINK("http://example.com","open")`)
+	s.call(t, req, fiber.StatusOK)
+
+	resp, err := s.app.Test(s.signedIn(request(http.MethodGet, "/api/audit?format=csv", nil)), -1)
+	if err != nil {
+		t.Fatalf("GET /api/audit: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("GET /api/audit: got status %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+
+	records, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d CSV records, want a header and one event", len(records))
+	}
+	column := map[string]int{}
+	for i, name := range records[0] {
+		column[name] = i
+	}
+	event := records[1]
+	if got := event[column["action"]]; got != models.AuditBillboardClear {
+		t.Errorf("got action %q, want %q", got, models.AuditBillboardClear)
+	}
+	if got, want := event[column["user_agent"]], `'=HYPERLINK("http://example.com","open")`; got != want {
+		t.Errorf("got user_agent %q, want %q", got, want)
+	}
+	if got := event[column["actor_name"]]; got != "Test Admin" {
+		t.Errorf("got actor_name %q, want Test Admin", got)
+	}
+}
@@ -1,17 +1,18 @@
 package handlers
 
 import (
+	"go_pco_arrivals/internal/repository"
+
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
 )
 
 type HealthHandler struct {
-	db *gorm.DB
+	store *repository.Store
 }
 
-func NewHealthHandler(db *gorm.DB) *HealthHandler {
+func NewHealthHandler(store *repository.Store) *HealthHandler {
 	return &HealthHandler{
-		db: db,
+		store: store,
 	}
 }
 
@@ -24,8 +25,7 @@ func (h *HealthHandler) Health(c *fiber.Ctx) error {
 
 func (h *HealthHandler) DetailedHealth(c *fiber.Ctx) error {
 	// Check database connection
-	var result int
-	err := h.db.Raw("SELECT 1").Scan(&result).Error
+	err := h.store.Ping()
 
 	status := "healthy"
 	if err != nil {
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/services"
+	"go_pco_arrivals/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LocationHandler struct {
+	locations *services.LocationService
+	auth      *services.AuthService
+	audit     *services.AuditService
+	logger    *utils.Logger
+}
+
+func NewLocationHandler(locations *services.LocationService, auth *services.AuthService, audit *services.AuditService, logger *utils.Logger) *LocationHandler {
+	return &LocationHandler{
+		locations: locations,
+		auth:      auth,
+		audit:     audit,
+		logger:    logger,
+	}
+}
+
+// GetLocationTree returns the synced locations nested in their folders
+// (admin only)
+func (h *LocationHandler) GetLocationTree(c *fiber.Ctx) error {
+	tree, err := h.locations.Tree()
+	if err != nil {
+		h.logger.Error("Failed to build location tree", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch locations",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"locations": tree,
+	})
+}
+
+// GetSyncReport returns the report of the latest location sync (admin only)
+func (h *LocationHandler) GetSyncReport(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"report":  h.locations.LastReport(),
+	})
+}
+
+// SyncLocations reconciles the locations with PCO now using the admin's own
+// token, or with ?dry_run=true reports what would change (admin only)
+func (h *LocationHandler) SyncLocations(c *fiber.Ctx) error {
+	user, err := h.auth.GetUserByID(c.Locals("user_id").(uint))
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+	if h.auth.IsTokenExpiringSoon(user) {
+		if err := h.auth.RefreshUserTokens(user); err != nil {
+			h.logger.Error("Failed to refresh tokens for location sync", "error", err, "user_id", user.ID)
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": "Failed to refresh PCO access token",
+			})
+		}
+	}
+
+	dryRun := c.QueryBool("dry_run")
+	report, err := h.locations.Reconcile(user.AccessToken, dryRun)
+	if err != nil {
+		if errors.Is(err, services.ErrLocationSyncUnavailable) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "Location sync is not available",
+			})
+		}
+		h.logger.Error("Failed to sync locations", "error", err, "user_id", user.ID)
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": "Failed to sync locations with PCO",
+		})
+	}
+
+	if !dryRun && report.Changed() {
+		event := newAuditEvent(c, h.audit, user, models.AuditLocationSync, "location", "")
+		event.After = report
+		h.audit.Record(event)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"report":  report,
+	})
+}
+
+// UpdateLocation sets the local display name, color, hidden flag, capacity
+// and volunteer ratio of a location, which syncs leave alone (admin only)
+func (h *LocationHandler) UpdateLocation(c *fiber.Ctx) error {
+	locationID := c.Params("id")
+	if !utils.ValidateLocationID(locationID) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid location ID",
+		})
+	}
+
+	var request services.LocationOverrides
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	before, location, err := h.locations.UpdateOverrides(locationID, request)
+	if err != nil {
+		switch {
+		case errors.Is(err, utils.ErrInvalidInput):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		case errors.Is(err, repository.ErrNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Location not found",
+			})
+		}
+		h.logger.Error("Failed to update location", "error", err, "location_id", locationID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update location",
+		})
+	}
+
+	actor, _ := h.auth.GetUserByID(c.Locals("user_id").(uint))
+	event := newAuditEvent(c, h.audit, actor, models.AuditLocationUpdate, "location", location.PCOLocationID)
+	event.Before = locationOverrides(before)
+	event.After = locationOverrides(location)
+	h.audit.Record(event)
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"location": location,
+	})
+}
+
+// locationOverrides returns the local-only fields of a location for the
+// audit log
+func locationOverrides(location *models.Location) fiber.Map {
+	return fiber.Map{
+		"display_name":    location.DisplayName,
+		"color":           location.Color,
+		"hidden":          location.Hidden,
+		"capacity":        location.Capacity,
+		"volunteer_ratio": location.Ratio,
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/services"
+	"go_pco_arrivals/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultReportDays is the range of a report without a from date
+const defaultReportDays = 7
+
+type ReportHandler struct {
+	reports    *services.ReportService
+	churchName string
+	logger     *utils.Logger
+}
+
+func NewReportHandler(cfg *config.Config, reports *services.ReportService, logger *utils.Logger) *ReportHandler {
+	return &ReportHandler{
+		reports:    reports,
+		churchName: cfg.Reports.ChurchName,
+		logger:     logger,
+	}
+}
+
+// GetFirstTimeGuests reports the families who came for the first time
+// between from and to, by default in the last week. Their contacts' phone
+// numbers and email addresses are only included for callers with contact
+// access. With ?format=csv, xlsx or pdf the report is downloaded.
+func (h *ReportHandler) GetFirstTimeGuests(c *fiber.Ctx) error {
+	from, to, err := parseReportRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	report, err := h.reports.FirstTimeGuests(from, to, hasContactAccess(c))
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("Failed to build first-time guest report", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build report",
+		})
+	}
+
+	if format := c.Query("format"); format != "" {
+		return h.sendReport(c, report.Table(), format, "first-time-guests", from, to)
+	}
+	return c.JSON(fiber.Map{
+		"success": true,
+		"report":  report,
+	})
+}
+
+// GetAttendance reports attendance between from and to, by default in the
+// last week, grouped by ?by=week, event, location or age_group (week by
+// default), with unique people alongside total check-ins. With
+// ?format=csv, xlsx or pdf the report is downloaded.
+func (h *ReportHandler) GetAttendance(c *fiber.Ctx) error {
+	from, to, err := parseReportRange(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	report, err := h.reports.Attendance(from, to, c.Query("by", services.AttendanceByWeek))
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidInput) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		h.logger.Error("Failed to build attendance report", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to build report",
+		})
+	}
+
+	if format := c.Query("format"); format != "" {
+		return h.sendReport(c, report.Table(), format, "attendance-by-"+report.By, from, to)
+	}
+	return c.JSON(fiber.Map{
+		"success": true,
+		"report":  report,
+	})
+}
+
+// parseReportRange reads a report's from and to, each RFC3339 or
+// YYYY-MM-DD. A to date includes that whole day.
+func parseReportRange(c *fiber.Ctx) (time.Time, time.Time, error) {
+	from, err := parseAuditTime(c.Query("from"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: from must be RFC3339 or YYYY-MM-DD", utils.ErrInvalidInput)
+	}
+	to, err := parseAuditTime(c.Query("to"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to must be RFC3339 or YYYY-MM-DD", utils.ErrInvalidInput)
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	} else if len(c.Query("to")) == len("2006-01-02") {
+		to = to.AddDate(0, 0, 1)
+	}
+	if from.IsZero() {
+		now := time.Now()
+		from = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -defaultReportDays)
+	}
+	return from, to, nil
+}
+
+// sendReport downloads a report table as CSV, XLSX or PDF, named after the
+// report and its range. PDFs are headed by the church name and the range.
+func (h *ReportHandler) sendReport(c *fiber.Ctx, table *services.Table, format, name string, from, to time.Time) error {
+	last := to.Add(-time.Nanosecond)
+	filename := fmt.Sprintf("%s-%s-%s", name, from.Format("20060102"), last.Format("20060102"))
+	switch format {
+	case "csv":
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`.csv"`)
+		return table.WriteCSV(c)
+	case "xlsx":
+		c.Set(fiber.HeaderContentType, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`.xlsx"`)
+		return table.WriteXLSX(c)
+	case "pdf":
+		period := from.Format("Jan 2, 2006") + " – " + last.Format("Jan 2, 2006")
+		c.Set(fiber.HeaderContentType, "application/pdf")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`.pdf"`)
+		return table.WritePDF(c, h.churchName, period)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid format, expected csv, xlsx or pdf",
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/services"
+	"go_pco_arrivals/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SessionHandler struct {
+	auth   *services.AuthService
+	logger *utils.Logger
+}
+
+func NewSessionHandler(auth *services.AuthService, logger *utils.Logger) *SessionHandler {
+	return &SessionHandler{
+		auth:   auth,
+		logger: logger,
+	}
+}
+
+// ListMySessions returns the active sessions of the current user
+func (h *SessionHandler) ListMySessions(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+
+	sessions, err := h.auth.ListUserSessions(userID)
+	if err != nil {
+		h.logger.Error("Failed to list sessions", "error", err, "user_id", userID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch sessions",
+		})
+	}
+
+	currentID, _ := c.Locals("session_id").(uint)
+	responseSessions := make([]fiber.Map, len(sessions))
+	for i, session := range sessions {
+		responseSessions[i] = sessionResponse(&session, currentID)
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"sessions": responseSessions,
+	})
+}
+
+// ListAllSessions returns the active sessions of every user (admin only)
+func (h *SessionHandler) ListAllSessions(c *fiber.Ctx) error {
+	var sessions []models.Session
+	var err error
+
+	if userIDStr := c.Query("user_id"); userIDStr != "" {
+		userID, parseErr := strconv.ParseUint(userIDStr, 10, 32)
+		if parseErr != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid user_id",
+			})
+		}
+		sessions, err = h.auth.ListUserSessions(uint(userID))
+	} else {
+		sessions, err = h.auth.ListAllSessions()
+	}
+
+	if err != nil {
+		h.logger.Error("Failed to list all sessions", "error", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to fetch sessions",
+		})
+	}
+
+	currentID, _ := c.Locals("session_id").(uint)
+	responseSessions := make([]fiber.Map, len(sessions))
+	for i, session := range sessions {
+		response := sessionResponse(&session, currentID)
+		if session.User.ID != 0 {
+			response["user_name"] = session.User.Name
+			response["user_email"] = session.User.Email
+		}
+		responseSessions[i] = response
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"sessions": responseSessions,
+	})
+}
+
+// RevokeSession revokes a single session. Users may revoke their own
+// sessions; admins may revoke anyone's.
+func (h *SessionHandler) RevokeSession(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	isAdmin, _ := c.Locals("is_admin").(bool)
+
+	sessionID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid session ID",
+		})
+	}
+
+	session, err := h.auth.GetSessionByID(uint(sessionID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Session not found",
+		})
+	}
+
+	if session.UserID != userID && !isAdmin {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Cannot revoke another user's session",
+		})
+	}
+
+	if err := h.auth.RevokeSessionByID(session.ID); err != nil {
+		h.logger.Error("Failed to revoke session", "error", err, "session_id", session.ID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke session",
+		})
+	}
+
+	if currentID, _ := c.Locals("session_id").(uint); currentID == session.ID {
+		c.ClearCookie("session_token")
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Session revoked successfully",
+	})
+}
+
+// RevokeMySessions revokes all sessions of the current user. With
+// ?keep_current=true the session making the request stays signed in.
+func (h *SessionHandler) RevokeMySessions(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(uint)
+	currentID, _ := c.Locals("session_id").(uint)
+
+	var err error
+	if c.QueryBool("keep_current") && currentID != 0 {
+		err = h.auth.RevokeOtherUserSessions(userID, currentID)
+	} else {
+		err = h.auth.RevokeAllUserSessions(userID)
+		c.ClearCookie("session_token")
+	}
+
+	if err != nil {
+		h.logger.Error("Failed to revoke sessions", "error", err, "user_id", userID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke sessions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Sessions revoked successfully",
+	})
+}
+
+// RevokeUserSessions revokes all sessions of another user (admin only)
+func (h *SessionHandler) RevokeUserSessions(c *fiber.Ctx) error {
+	targetID, err := strconv.ParseUint(c.Params("userId"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	if err := h.auth.RevokeAllUserSessions(uint(targetID)); err != nil {
+		h.logger.Error("Failed to revoke user sessions", "error", err, "user_id", targetID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to revoke sessions",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "User sessions revoked successfully",
+	})
+}
+
+// sessionResponse converts a session to its API form, omitting the token
+func sessionResponse(session *models.Session, currentID uint) fiber.Map {
+	return fiber.Map{
+		"id":             session.ID,
+		"user_id":        session.UserID,
+		"user_agent":     session.UserAgent,
+		"ip_address":     session.IPAddress,
+		"is_remember_me": session.IsRememberMe,
+		"is_current":     session.ID == currentID,
+		"last_activity":  session.LastActivity.Format(time.RFC3339),
+		"expires_at":     session.ExpiresAt.Format(time.RFC3339),
+		"created_at":     session.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"strconv"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/services"
+	"go_pco_arrivals/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type UserHandler struct {
+	auth   *services.AuthService
+	audit  *services.AuditService
+	logger *utils.Logger
+}
+
+func NewUserHandler(auth *services.AuthService, audit *services.AuditService, logger *utils.Logger) *UserHandler {
+	return &UserHandler{
+		auth:   auth,
+		audit:  audit,
+		logger: logger,
+	}
+}
+
+// UpdateUserRole grants or removes the admin role of another user (admin only)
+func (h *UserHandler) UpdateUserRole(c *fiber.Ctx) error {
+	actorID := c.Locals("user_id").(uint)
+	actor, err := h.auth.GetUserByID(actorID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	targetID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var request struct {
+		IsAdmin *bool `json:"is_admin"`
+	}
+	if err := c.BodyParser(&request); err != nil || request.IsAdmin == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "is_admin is required",
+		})
+	}
+
+	if uint(targetID) == actor.ID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot change your own role",
+		})
+	}
+
+	target, err := h.auth.GetUserByID(uint(targetID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if target.IsAdmin == *request.IsAdmin {
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Role unchanged",
+		})
+	}
+
+	if err := h.auth.SetUserAdmin(target.ID, *request.IsAdmin); err != nil {
+		h.logger.Error("Failed to update user role", "error", err, "user_id", target.ID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update user role",
+		})
+	}
+
+	event := newAuditEvent(c, h.audit, actor, models.AuditUserRoleChange, "user", strconv.FormatUint(uint64(target.ID), 10))
+	event.Before = fiber.Map{"is_admin": target.IsAdmin}
+	event.After = fiber.Map{"is_admin": *request.IsAdmin}
+	h.audit.Record(event)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "User role updated successfully",
+	})
+}
+
+// UpdateMedicalAccess grants or removes another user's access to check-ins'
+// medical notes and emergency contacts (admin only). Admins always have it.
+func (h *UserHandler) UpdateMedicalAccess(c *fiber.Ctx) error {
+	actorID := c.Locals("user_id").(uint)
+	actor, err := h.auth.GetUserByID(actorID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	targetID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var request struct {
+		MedicalAccess *bool `json:"medical_access"`
+	}
+	if err := c.BodyParser(&request); err != nil || request.MedicalAccess == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "medical_access is required",
+		})
+	}
+
+	target, err := h.auth.GetUserByID(uint(targetID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if target.MedicalAccess == *request.MedicalAccess {
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Medical access unchanged",
+		})
+	}
+
+	if err := h.auth.SetUserMedicalAccess(target.ID, *request.MedicalAccess); err != nil {
+		h.logger.Error("Failed to update medical access", "error", err, "user_id", target.ID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update medical access",
+		})
+	}
+
+	event := newAuditEvent(c, h.audit, actor, models.AuditUserMedicalAccess, "user", strconv.FormatUint(uint64(target.ID), 10))
+	event.Before = fiber.Map{"medical_access": target.MedicalAccess}
+	event.After = fiber.Map{"medical_access": *request.MedicalAccess}
+	h.audit.Record(event)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Medical access updated successfully",
+	})
+}
+
+// UpdateContactAccess grants or removes another user's access to parents'
+// phone numbers and email addresses (admin only). Admins always have it.
+func (h *UserHandler) UpdateContactAccess(c *fiber.Ctx) error {
+	actorID := c.Locals("user_id").(uint)
+	actor, err := h.auth.GetUserByID(actorID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	targetID, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid user ID",
+		})
+	}
+
+	var request struct {
+		ContactAccess *bool `json:"contact_access"`
+	}
+	if err := c.BodyParser(&request); err != nil || request.ContactAccess == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "contact_access is required",
+		})
+	}
+
+	target, err := h.auth.GetUserByID(uint(targetID))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	if target.ContactAccess == *request.ContactAccess {
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Contact access unchanged",
+		})
+	}
+
+	if err := h.auth.SetUserContactAccess(target.ID, *request.ContactAccess); err != nil {
+		h.logger.Error("Failed to update contact access", "error", err, "user_id", target.ID)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update contact access",
+		})
+	}
+
+	event := newAuditEvent(c, h.audit, actor, models.AuditUserContactAccess, "user", strconv.FormatUint(uint64(target.ID), 10))
+	event.Before = fiber.Map{"contact_access": target.ContactAccess}
+	event.After = fiber.Map{"contact_access": *request.ContactAccess}
+	h.audit.Record(event)
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Contact access updated successfully",
+	})
+}
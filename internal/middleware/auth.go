@@ -1,115 +1,151 @@
 package middleware
 
 import (
+	"strings"
+
 	"github.com/gofiber/fiber/v2"
 )
 
 // AuthServiceInterface defines the interface for auth service methods used in middleware
 type AuthServiceInterface interface {
 	ValidateSessionForMiddleware(token string) (interface{}, error)
+	ValidateBearerForMiddleware(credential, ipAddress string) (interface{}, error)
 }
 
-// RequireAuth middleware that validates session tokens and sets user_id in context
+// RequireAuth middleware that validates session tokens or bearer credentials
+// (API keys and tokens issued for them) and sets user_id in context
 func RequireAuth() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Get the auth service from the app
-		authService := c.Locals("auth_service")
-		if authService == nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Auth service not available",
-			})
+		if err := authenticate(c); err != nil {
+			return err
 		}
+		return c.Next()
+	}
+}
 
-		// Get session token from cookie
-		sessionToken := c.Cookies("session_token")
-		if sessionToken == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "No session token provided",
-			})
+// RequireAdmin middleware that requires admin privileges
+func RequireAdmin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Authenticate unless an earlier RequireAuth already did
+		if c.Locals("user_id") == nil {
+			if err := authenticate(c); err != nil {
+				return err
+			}
 		}
 
-		// Validate session using auth service
-		auth, ok := authService.(AuthServiceInterface)
-		if !ok {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Invalid auth service type",
-			})
+		if isAdmin, _ := c.Locals("is_admin").(bool); !isAdmin {
+			return fiber.NewError(fiber.StatusForbidden, "Admin access required")
 		}
 
-		sessionData, err := auth.ValidateSessionForMiddleware(sessionToken)
-		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid session",
-			})
-		}
+		return c.Next()
+	}
+}
 
-		// Extract user_id from SessionData struct
-		if sessionDataStruct, ok := sessionData.(interface{ GetUserID() uint }); ok {
-			c.Locals("user_id", sessionDataStruct.GetUserID())
-			return c.Next()
-		}
+// authenticate validates the bearer credential or session cookie and stores the caller's identity
+// in the request locals. Failures are returned as *fiber.Error so that the
+// app's ErrorHandler renders them.
+func authenticate(c *fiber.Ctx) error {
+	// Get the auth service from the app
+	authService := c.Locals("auth_service")
+	if authService == nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Auth service not available")
+	}
 
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid session data",
-		})
+	// Validate session using auth service
+	auth, ok := authService.(AuthServiceInterface)
+	if !ok {
+		return fiber.NewError(fiber.StatusInternalServerError, "Invalid auth service type")
 	}
-}
 
-// RequireAdmin middleware that requires admin privileges
-func RequireAdmin() fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		// First check authentication
-		if err := RequireAuth()(c); err != nil {
-			return err
+	var sessionData interface{}
+	if credential := BearerCredential(c); credential != "" {
+		trustProxy := false
+		if proxyData, ok := authService.(interface{ TrustProxy() bool }); ok {
+			trustProxy = proxyData.TrustProxy()
 		}
-
-		// Get the auth service from the app
-		authService := c.Locals("auth_service")
-		if authService == nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Auth service not available",
-			})
+		data, err := auth.ValidateBearerForMiddleware(credential, ClientIP(c, trustProxy))
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid API credential")
 		}
-
+		sessionData = data
+	} else {
 		// Get session token from cookie
 		sessionToken := c.Cookies("session_token")
 		if sessionToken == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "No session token provided",
-			})
-		}
-
-		// Validate session and check admin status
-		auth, ok := authService.(AuthServiceInterface)
-		if !ok {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Invalid auth service type",
-			})
+			return fiber.NewError(fiber.StatusUnauthorized, "No session token provided")
 		}
 
-		sessionData, err := auth.ValidateSessionForMiddleware(sessionToken)
+		data, err := auth.ValidateSessionForMiddleware(sessionToken)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid session",
-			})
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid session")
 		}
+		sessionData = data
+	}
 
-		// Check if user is admin
-		var isAdmin bool
-		if sessionDataStruct, ok := sessionData.(interface{ IsAdmin() bool }); ok {
-			isAdmin = sessionDataStruct.IsAdmin()
-		} else if sessionDataStruct, ok := sessionData.(interface{ GetIsAdmin() bool }); ok {
-			isAdmin = sessionDataStruct.GetIsAdmin()
-		}
+	// Extract user_id from SessionData struct
+	sessionDataStruct, ok := sessionData.(interface{ GetUserID() uint })
+	if !ok {
+		return fiber.NewError(fiber.StatusUnauthorized, "Invalid session data")
+	}
+	c.Locals("user_id", sessionDataStruct.GetUserID())
 
-		if !isAdmin {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-				"error": "Admin access required",
-			})
+	if adminData, ok := sessionData.(interface{ GetIsAdmin() bool }); ok {
+		c.Locals("is_admin", adminData.GetIsAdmin())
+	}
+	if medicalData, ok := sessionData.(interface{ GetMedicalAccess() bool }); ok {
+		c.Locals("medical_access", medicalData.GetMedicalAccess())
+	}
+	if contactData, ok := sessionData.(interface{ GetContactAccess() bool }); ok {
+		c.Locals("contact_access", contactData.GetContactAccess())
+	}
+	if sessionIDData, ok := sessionData.(interface{ GetSessionID() uint }); ok {
+		c.Locals("session_id", sessionIDData.GetSessionID())
+	}
+	if methodData, ok := sessionData.(interface{ GetAuthMethod() string }); ok {
+		c.Locals("auth_method", methodData.GetAuthMethod())
+	}
+
+	// API keys and their tokens are limited to the routes their scopes allow
+	if scopeData, ok := sessionData.(interface {
+		GetScopes() []string
+		GetLocationID() string
+	}); ok && isAPICredential(c) {
+		if !scopeAllows(scopeData.GetScopes(), c.Method(), c.Path()) {
+			return fiber.NewError(fiber.StatusForbidden, "API key is not permitted to access this endpoint")
 		}
+		c.Locals("api_location_id", scopeData.GetLocationID())
+		if keyData, ok := sessionData.(interface{ GetAPIKeyID() uint }); ok {
+			c.Locals("api_key_id", keyData.GetAPIKeyID())
+		}
+	}
 
-		return c.Next()
+	return nil
+}
+
+// BearerCredential extracts the credential from an "Authorization: Bearer" header
+func BearerCredential(c *fiber.Ctx) string {
+	header := c.Get(fiber.HeaderAuthorization)
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
 	}
+	return ""
+}
+
+// ClientIP returns the caller's address, honouring X-Forwarded-For only when
+// the server is configured to trust its proxy
+func ClientIP(c *fiber.Ctx, trustProxy bool) string {
+	if trustProxy {
+		if ips := c.IPs(); len(ips) > 0 {
+			return ips[0]
+		}
+	}
+	return c.IP()
+}
+
+// isAPICredential reports whether the request was authenticated with an API key or token
+func isAPICredential(c *fiber.Ctx) bool {
+	method, _ := c.Locals("auth_method").(string)
+	return method == "api_key" || method == "token"
 }
 
 // OptionalAuth middleware that optionally sets user_id if authenticated
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// CSRFHeader carries the token returned by /auth/status
+const CSRFHeader = "X-CSRF-Token"
+
+// csrfValidator is implemented by the auth service
+type csrfValidator interface {
+	ValidateCSRFToken(sessionToken, token string) bool
+}
+
+// CSRFProtection rejects state-changing requests that authenticate with the
+// session cookie but do not echo the session's CSRF token in the X-CSRF-Token
+// header. Requests using Authorization: Bearer are exempt because browsers
+// never attach that header cross-site.
+func CSRFProtection() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return c.Next()
+		}
+
+		if BearerCredential(c) != "" {
+			return c.Next()
+		}
+
+		sessionToken := c.Cookies("session_token")
+		if sessionToken == "" {
+			return c.Next()
+		}
+
+		validator, ok := c.Locals("auth_service").(csrfValidator)
+		if !ok {
+			return fiber.NewError(fiber.StatusInternalServerError, "Auth service not available")
+		}
+
+		if !validator.ValidateCSRFToken(sessionToken, c.Get(CSRFHeader)) {
+			return fiber.NewError(fiber.StatusForbidden, "Invalid or missing CSRF token")
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"strings"
+
+	"go_pco_arrivals/internal/models"
+)
+
+// apiScopeRule grants access to requests matching a route pattern, in
+// which a ":name" segment matches any single path segment
+type apiScopeRule struct {
+	method  string
+	pattern string
+	scope   string
+}
+
+// apiScopeRules lists every endpoint reachable with an API key or token.
+// Anything not listed here is denied to API credentials, so new routes are
+// session-only until they are deliberately added. Handlers limit keys
+// restricted to one location to that location's data.
+var apiScopeRules = []apiScopeRule{
+	{method: "POST", pattern: "/api/billboard/launch", scope: models.ScopeBillboardWrite},
+	{method: "POST", pattern: "/api/billboard/clear", scope: models.ScopeBillboardWrite},
+	{method: "POST", pattern: "/api/notifications", scope: models.ScopeNotificationsWrite},
+	{method: "DELETE", pattern: "/api/notifications/:id", scope: models.ScopeNotificationsWrite},
+	{method: "GET", pattern: "/api/billboard/control", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/billboard/stats/:locationId", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/notifications", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/notifications/active", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/events", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/events/:id", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/locations", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/locations/overview", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/locations/:id", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/locations/:locationId/status", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/locations/:locationId/roster", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/check-ins", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/check-ins/location/:locationId", scope: models.ScopeRead},
+	{method: "GET", pattern: "/api/check-ins/event/:eventId", scope: models.ScopeRead},
+}
+
+// scopeAllows reports whether the granted scopes permit the request
+func scopeAllows(granted []string, method, path string) bool {
+	for _, rule := range apiScopeRules {
+		if rule.method != method || !routeMatches(rule.pattern, path) {
+			continue
+		}
+		for _, scope := range granted {
+			if scope == rule.scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// routeMatches reports whether path matches a route pattern segment by
+// segment. A trailing slash is ignored, as it is by the router.
+func routeMatches(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternSegments) != len(pathSegments) {
+		return false
+	}
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, ":") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Scopes that can be granted to API keys
+const (
+	ScopeRead               = "read"
+	ScopeBillboardWrite     = "billboard:write"
+	ScopeNotificationsWrite = "notifications:write"
+	// ScopeMedicalRead adds medical notes and emergency contacts to the
+	// check-ins a key can read
+	ScopeMedicalRead = "medical:read"
+	// ScopeContactsRead adds parents' phone numbers and email addresses to
+	// the check-ins, rosters and pickup requests a key can read
+	ScopeContactsRead = "contacts:read"
+)
+
+// APIKeyScopes lists every scope an API key may be granted
+var APIKeyScopes = []string{ScopeRead, ScopeBillboardWrite, ScopeNotificationsWrite, ScopeMedicalRead, ScopeContactsRead}
+
+// APIKey is a named, scoped credential for integrations that act without a browser session
+type APIKey struct {
+	ID         uint           `json:"id" bson:"_id" gorm:"primaryKey"`
+	Name       string         `json:"name" bson:"name" gorm:"not null"`
+	Prefix     string         `json:"prefix" bson:"prefix" gorm:"index;not null"`
+	KeyHash    string         `json:"-" bson:"key_hash" gorm:"uniqueIndex;not null"`
+	Scopes     []string       `json:"scopes" bson:"scopes" gorm:"serializer:json"`
+	LocationID string         `json:"location_id" bson:"location_id"`
+	UserID     uint           `json:"user_id" bson:"user_id" gorm:"not null"`
+	ExpiresAt  *time.Time     `json:"expires_at" bson:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at" bson:"last_used_at"`
+	LastUsedIP string         `json:"last_used_ip" bson:"last_used_ip"`
+	RevokedAt  *time.Time     `json:"revoked_at" bson:"revoked_at"`
+	CreatedBy  string         `json:"created_by" bson:"created_by" gorm:"not null"`
+	CreatedAt  time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at" bson:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" bson:"-" gorm:"index"`
+
+	// Relationships
+	User User `json:"-" bson:"-" gorm:"foreignKey:UserID"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// HasScope reports whether the key grants the given scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
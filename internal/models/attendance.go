@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+// Rollup periods
+const (
+	RollupHour = "hour"
+	RollupDay  = "day"
+)
+
+// AttendanceRollup is the attendance of one location and event over an hour
+// or a day (UTC). Rollups outlive the raw check-ins they were built from.
+type AttendanceRollup struct {
+	ID                uint      `json:"id" bson:"_id" gorm:"primaryKey"`
+	Period            string    `json:"period" bson:"period" gorm:"not null;uniqueIndex:idx_rollup_bucket"`
+	PeriodStart       time.Time `json:"period_start" bson:"period_start" gorm:"not null;uniqueIndex:idx_rollup_bucket"`
+	LocationID        string    `json:"location_id" bson:"location_id" gorm:"not null;uniqueIndex:idx_rollup_bucket"`
+	LocationName      string    `json:"location_name" bson:"location_name"`
+	EventID           string    `json:"event_id" bson:"event_id" gorm:"not null;uniqueIndex:idx_rollup_bucket"`
+	CheckIns          int64     `json:"check_ins" bson:"check_ins"`
+	UniquePeople      int64     `json:"unique_people" bson:"unique_people"`
+	FirstTimeVisitors int64     `json:"first_time_visitors" bson:"first_time_visitors"`
+	CreatedAt         time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+func (AttendanceRollup) TableName() string {
+	return "attendance_rollups"
+}
+
+// Visitor records the first check-in seen for each person, which is what
+// makes them a first-time visitor on that day
+type Visitor struct {
+	ID             uint      `json:"id" bson:"_id" gorm:"primaryKey"`
+	PersonID       string    `json:"person_id" bson:"person_id" gorm:"uniqueIndex;not null"`
+	PersonName     string    `json:"person_name" bson:"person_name"`
+	FirstCheckInAt time.Time `json:"first_check_in_at" bson:"first_check_in_at" gorm:"index;not null"`
+	LocationID     string    `json:"location_id" bson:"location_id"`
+	LocationName   string    `json:"location_name" bson:"location_name"`
+	EventID        string    `json:"event_id" bson:"event_id"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+func (Visitor) TableName() string {
+	return "visitors"
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audited actions
+const (
+	AuditBillboardLaunch    = "billboard.launch"
+	AuditBillboardClear     = "billboard.clear"
+	AuditSecurityCodeAdd    = "security_code.add"
+	AuditSecurityCodeRemove = "security_code.remove"
+	AuditLocationAdd        = "location.add"
+	AuditLocationSync       = "location.sync"
+	AuditLocationUpdate     = "location.update"
+	AuditEventCreate        = "event.create"
+	AuditEventUpdate        = "event.update"
+	AuditEventDelete        = "event.delete"
+	AuditNotificationCreate = "notification.create"
+	AuditNotificationCancel = "notification.cancel"
+	AuditUserRoleChange     = "user.role_change"
+	AuditUserMedicalAccess  = "user.medical_access"
+	AuditUserContactAccess  = "user.contact_access"
+	AuditLogin              = "auth.login"
+	AuditLoginDenied        = "auth.login_denied"
+	AuditLogout             = "auth.logout"
+	AuditBackupCreate       = "backup.create"
+	AuditBackupDownload     = "backup.download"
+)
+
+// ErrAuditImmutable is returned when something tries to modify a recorded audit event
+var ErrAuditImmutable = errors.New("audit events are append-only")
+
+// AuditEvent records who did what to which object, with the state before and after
+type AuditEvent struct {
+	ID         uint        `json:"id" bson:"_id" gorm:"primaryKey"`
+	ActorID    uint        `json:"actor_id" bson:"actor_id" gorm:"index"`
+	ActorName  string      `json:"actor_name" bson:"actor_name"`
+	AuthMethod string      `json:"auth_method" bson:"auth_method"`
+	APIKeyID   uint        `json:"api_key_id,omitempty" bson:"api_key_id"`
+	Action     string      `json:"action" bson:"action" gorm:"index;not null"`
+	TargetType string      `json:"target_type" bson:"target_type" gorm:"index"`
+	TargetID   string      `json:"target_id" bson:"target_id" gorm:"index"`
+	Before     interface{} `json:"before,omitempty" bson:"-" gorm:"serializer:json"`
+	After      interface{} `json:"after,omitempty" bson:"-" gorm:"serializer:json"`
+	IPAddress  string      `json:"ip_address" bson:"ip_address"`
+	UserAgent  string      `json:"user_agent" bson:"user_agent"`
+	CreatedAt  time.Time   `json:"created_at" bson:"created_at" gorm:"index"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// BeforeUpdate keeps the audit log append-only
+func (AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditImmutable
+}
+
+// BeforeDelete keeps the audit log append-only
+func (AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditImmutable
+}
@@ -7,22 +7,22 @@ import (
 )
 
 type BillboardState struct {
-	ID            uint           `json:"id" gorm:"primaryKey"`
-	EventID       uint           `json:"event_id"`
-	EventName     string         `json:"event_name"`
-	Date          time.Time      `json:"date"`
-	LocationID    string         `json:"location_id"`
-	LocationName  string         `json:"location_name"`
-	SecurityCodes []string       `json:"security_codes" gorm:"serializer:json"`
-	IsActive      bool           `json:"is_active" gorm:"default:false"`
-	LastUpdated   time.Time      `json:"last_updated"`
-	CreatedBy     string         `json:"created_by" gorm:"not null"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+	ID            uint           `json:"id" bson:"_id" gorm:"primaryKey"`
+	EventID       uint           `json:"event_id" bson:"event_id"`
+	EventName     string         `json:"event_name" bson:"event_name"`
+	Date          time.Time      `json:"date" bson:"date"`
+	LocationID    string         `json:"location_id" bson:"location_id"`
+	LocationName  string         `json:"location_name" bson:"location_name"`
+	SecurityCodes []string       `json:"security_codes" bson:"security_codes" gorm:"serializer:json"`
+	IsActive      bool           `json:"is_active" bson:"is_active" gorm:"default:false"`
+	LastUpdated   time.Time      `json:"last_updated" bson:"last_updated"`
+	CreatedBy     string         `json:"created_by" bson:"created_by" gorm:"not null"`
+	CreatedAt     time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at" bson:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" bson:"-" gorm:"index"`
 
 	// Relationships
-	Event Event `json:"event" gorm:"foreignKey:EventID"`
+	Event Event `json:"event" bson:"-" gorm:"foreignKey:EventID"`
 }
 
 func (BillboardState) TableName() string {
@@ -1,29 +1,70 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Check-in kinds from PCO
+const (
+	CheckInKindRegular   = "Regular"
+	CheckInKindGuest     = "Guest"
+	CheckInKindVolunteer = "Volunteer"
+)
+
+// Check-in statuses
+const (
+	CheckInStatusActive     = "active"
+	CheckInStatusCheckedOut = "checked_out"
+)
+
+// CheckIn is a PCO check-in. CheckedOutAt is nil while the person is still
+// checked in. The parent is the primary contact of the person's PCO
+// household. FirstTime marks a person's first check-in, OneTimeGuest a guest
+// checked in without a PCO profile, and Number is the check-in's number on
+// the event's labels.
 type CheckIn struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	PCOCheckInID string         `json:"pco_check_in_id" gorm:"uniqueIndex;not null"`
-	PersonID     string         `json:"person_id" gorm:"not null"`
-	PersonName   string         `json:"person_name" gorm:"not null"`
-	LocationID   string         `json:"location_id" gorm:"not null"`
-	LocationName string         `json:"location_name" gorm:"not null"`
-	SecurityCode string         `json:"security_code" gorm:"not null"`
-	CheckInTime  time.Time      `json:"check_in_time" gorm:"not null"`
-	EventID      string         `json:"event_id" gorm:"not null"`
-	EventName    string         `json:"event_name"`
-	ParentName   string         `json:"parent_name"`
-	ParentPhone  string         `json:"parent_phone"`
-	Notes        string         `json:"notes"`
-	Status       string         `json:"status" gorm:"default:'active'"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint           `json:"id" bson:"_id" gorm:"primaryKey"`
+	PCOCheckInID string         `json:"pco_check_in_id" bson:"pco_check_in_id" gorm:"uniqueIndex;not null"`
+	PersonID     string         `json:"person_id" bson:"person_id" gorm:"not null"`
+	PersonName   string         `json:"person_name" bson:"person_name" gorm:"not null"`
+	LocationID   string         `json:"location_id" bson:"location_id" gorm:"not null"`
+	LocationName string         `json:"location_name" bson:"location_name" gorm:"not null"`
+	SecurityCode string         `json:"security_code" bson:"security_code" gorm:"not null"`
+	CheckInTime  time.Time      `json:"check_in_time" bson:"check_in_time" gorm:"not null"`
+	CheckedOutAt *time.Time     `json:"checked_out_at" bson:"checked_out_at"`
+	EventID      string         `json:"event_id" bson:"event_id" gorm:"not null"`
+	EventName    string         `json:"event_name" bson:"event_name"`
+	ParentName   string         `json:"parent_name" bson:"parent_name"`
+	Notes        string         `json:"notes" bson:"notes"`
+	Status       string         `json:"status" bson:"status" gorm:"default:'active'"`
+	Kind         string         `json:"kind" bson:"kind" gorm:"default:'Regular'"`
+	FirstTime    bool           `json:"first_time" bson:"first_time" gorm:"default:false"`
+	OneTimeGuest bool           `json:"one_time_guest" bson:"one_time_guest" gorm:"default:false"`
+	Number       int            `json:"number" bson:"number"`
+	CreatedAt    time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at" bson:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" bson:"-" gorm:"index"`
+
+	// Medical notes and the emergency contact are never serialized with the
+	// check-in; they are only shown to callers with medical access, and
+	// never on billboards
+	MedicalNotes          string `json:"-" bson:"medical_notes"`
+	EmergencyContactName  string `json:"-" bson:"emergency_contact_name"`
+	EmergencyContactPhone string `json:"-" bson:"emergency_contact_phone"`
+
+	// The parent's phone number and email address are never serialized
+	// either; they are only shown to callers with contact access
+	ParentPhone string `json:"-" bson:"parent_phone"`
+	ParentEmail string `json:"-" bson:"parent_email"`
+}
+
+// HasMedicalNotes reports whether the check-in carries medical notes, such as
+// allergies, that staff should be alerted to
+func (c *CheckIn) HasMedicalNotes() bool {
+	return strings.TrimSpace(c.MedicalNotes) != ""
 }
 
 func (CheckIn) TableName() string {
@@ -6,27 +6,83 @@ import (
 	"gorm.io/gorm"
 )
 
+// Event sources. PCO events are synced and read-only; local events are
+// created in this app for things PCO doesn't know about.
+const (
+	EventSourcePCO   = "pco"
+	EventSourceLocal = "local"
+)
+
 type Event struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	PCOEventID   string         `json:"pco_event_id" gorm:"uniqueIndex;not null"`
-	Name         string         `json:"name" gorm:"not null"`
-	Description  string         `json:"description"`
-	Date         time.Time      `json:"date" gorm:"not null"`
-	StartTime    time.Time      `json:"start_time"`
-	EndTime      time.Time      `json:"end_time"`
-	LocationID   string         `json:"location_id"`
-	LocationName string         `json:"location_name"`
-	IsActive     bool           `json:"is_active" gorm:"default:true"`
-	CreatedBy    string         `json:"created_by" gorm:"not null"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID uint `json:"id" bson:"_id" gorm:"primaryKey"`
+	// PCOEventID is the PCO event ID, or a generated "local-" key for local
+	// events
+	PCOEventID   string    `json:"pco_event_id" bson:"pco_event_id" gorm:"uniqueIndex;not null"`
+	Source       string    `json:"source" bson:"source" gorm:"not null;default:local;index"`
+	Name         string    `json:"name" bson:"name" gorm:"not null"`
+	Description  string    `json:"description" bson:"description"`
+	Frequency    string    `json:"frequency" bson:"frequency"`
+	Date         time.Time `json:"date" bson:"date" gorm:"not null"`
+	StartTime    time.Time `json:"start_time" bson:"start_time"`
+	EndTime      time.Time `json:"end_time" bson:"end_time"`
+	LocationID   string    `json:"location_id" bson:"location_id"`
+	LocationName string    `json:"location_name" bson:"location_name"`
+	// RRule makes a local event repeat, as an iCalendar RRULE with
+	// StartTime as its first occurrence. ExDates lists local dates
+	// (YYYY-MM-DD) the event is skipped on.
+	RRule      string         `json:"rrule" bson:"rrule" gorm:"column:rrule;index"`
+	ExDates    []string       `json:"exdates" bson:"exdates" gorm:"serializer:json"`
+	AutoLaunch bool           `json:"auto_launch" bson:"auto_launch"`
+	IsActive   bool           `json:"is_active" bson:"is_active" gorm:"default:true"`
+	CreatedBy  string         `json:"created_by" bson:"created_by" gorm:"not null"`
+	CreatedAt  time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at" bson:"updated_at"`
+	DeletedAt  gorm.DeletedAt `json:"-" bson:"-" gorm:"index"`
 
 	// Relationships
-	Notifications []Notification `json:"notifications" gorm:"foreignKey:EventID"`
-	CheckIns      []CheckIn      `json:"check_ins" gorm:"foreignKey:EventID"`
+	Notifications []Notification `json:"notifications" bson:"-" gorm:"foreignKey:EventID"`
+	CheckIns      []CheckIn      `json:"check_ins" bson:"-" gorm:"foreignKey:EventID"`
 }
 
 func (Event) TableName() string {
 	return "events"
 }
+
+// EventPeriod is one occurrence of an event in PCO Check-Ins, such as a
+// Sunday morning, with its headcounts
+type EventPeriod struct {
+	ID               uint      `json:"id" bson:"_id" gorm:"primaryKey"`
+	PCOEventPeriodID string    `json:"pco_event_period_id" bson:"pco_event_period_id" gorm:"uniqueIndex;not null"`
+	EventID          uint      `json:"event_id" bson:"event_id" gorm:"index;not null"`
+	StartsAt         time.Time `json:"starts_at" bson:"starts_at" gorm:"index"`
+	EndsAt           time.Time `json:"ends_at" bson:"ends_at"`
+	RegularCount     int       `json:"regular_count" bson:"regular_count"`
+	GuestCount       int       `json:"guest_count" bson:"guest_count"`
+	VolunteerCount   int       `json:"volunteer_count" bson:"volunteer_count"`
+	Note             string    `json:"note" bson:"note"`
+	CreatedAt        time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+func (EventPeriod) TableName() string {
+	return "event_periods"
+}
+
+// EventTime is a check-in time within an event period, such as the 9:00
+// and 11:00 services on the same Sunday
+type EventTime struct {
+	ID             uint      `json:"id" bson:"_id" gorm:"primaryKey"`
+	PCOEventTimeID string    `json:"pco_event_time_id" bson:"pco_event_time_id" gorm:"uniqueIndex;not null"`
+	EventPeriodID  uint      `json:"event_period_id" bson:"event_period_id" gorm:"index;not null"`
+	EventID        uint      `json:"event_id" bson:"event_id" gorm:"index;not null"`
+	Name           string    `json:"name" bson:"name"`
+	StartsAt       time.Time `json:"starts_at" bson:"starts_at"`
+	ShowsAt        time.Time `json:"shows_at" bson:"shows_at"`
+	HidesAt        time.Time `json:"hides_at" bson:"hides_at"`
+	CreatedAt      time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+func (EventTime) TableName() string {
+	return "event_times"
+}
@@ -6,21 +6,70 @@ import (
 	"gorm.io/gorm"
 )
 
+// Location kinds. Folders group locations, such as a "Kids Wing" holding
+// the nursery and toddler rooms; children only check in to locations.
+const (
+	LocationKindLocation = "location"
+	LocationKindFolder   = "folder"
+)
+
+// Location is a PCO Check-Ins location or folder. ParentID is the PCO ID of
+// the folder it is in, empty at the top of the tree. Age and grade bounds
+// are nil when PCO sets none, and a MaxOccupancy of zero means no limit.
+// DisplayName, Color, Hidden, Capacity and Ratio are set locally and never
+// synced. Capacity overrides MaxOccupancy when set, and Ratio is the most
+// children allowed per volunteer, zero for no ratio.
 type Location struct {
-	ID            uint           `json:"id" gorm:"primaryKey"`
-	PCOLocationID string         `json:"pco_location_id" gorm:"uniqueIndex;not null"`
-	Name          string         `json:"name" gorm:"not null"`
-	Description   string         `json:"description"`
-	Address       string         `json:"address"`
-	IsActive      bool           `json:"is_active" gorm:"default:true"`
-	CreatedAt     time.Time      `json:"created_at"`
-	UpdatedAt     time.Time      `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+	ID            uint           `json:"id" bson:"_id" gorm:"primaryKey"`
+	PCOLocationID string         `json:"pco_location_id" bson:"pco_location_id" gorm:"uniqueIndex;not null"`
+	PCOEventID    string         `json:"pco_event_id" bson:"pco_event_id" gorm:"index"`
+	Name          string         `json:"name" bson:"name" gorm:"not null"`
+	Description   string         `json:"description" bson:"description"`
+	Address       string         `json:"address" bson:"address"`
+	Kind          string         `json:"kind" bson:"kind" gorm:"not null;default:location"`
+	ParentID      string         `json:"parent_id" bson:"parent_id" gorm:"index"`
+	Position      int            `json:"position" bson:"position"`
+	AgeMinMonths  *int           `json:"age_min_months" bson:"age_min_months"`
+	AgeMaxMonths  *int           `json:"age_max_months" bson:"age_max_months"`
+	GradeMin      *int           `json:"grade_min" bson:"grade_min"`
+	GradeMax      *int           `json:"grade_max" bson:"grade_max"`
+	MaxOccupancy  int            `json:"max_occupancy" bson:"max_occupancy"`
+	DisplayName   string         `json:"display_name" bson:"display_name"`
+	Color         string         `json:"color" bson:"color"`
+	Hidden        bool           `json:"hidden" bson:"hidden"`
+	Capacity      int            `json:"capacity" bson:"capacity"`
+	Ratio         int            `json:"volunteer_ratio" bson:"ratio"`
+	IsActive      bool           `json:"is_active" bson:"is_active" gorm:"default:true"`
+	CreatedAt     time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at" bson:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" bson:"-" gorm:"index"`
 
 	// Relationships
-	CheckIns []CheckIn `json:"check_ins" gorm:"foreignKey:LocationID"`
+	CheckIns []CheckIn `json:"check_ins" bson:"-" gorm:"foreignKey:LocationID"`
 }
 
 func (Location) TableName() string {
 	return "locations"
 }
+
+// Label is the name to show for the location, its display name if set
+func (l *Location) Label() string {
+	if l.DisplayName != "" {
+		return l.DisplayName
+	}
+	return l.Name
+}
+
+// CapacityLimit is the most people allowed in the location, zero for no
+// limit
+func (l *Location) CapacityLimit() int {
+	if l.Capacity > 0 {
+		return l.Capacity
+	}
+	return l.MaxOccupancy
+}
+
+// IsFolder reports whether the location only groups other locations
+func (l *Location) IsFolder() bool {
+	return l.Kind == LocationKindFolder
+}
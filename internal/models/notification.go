@@ -7,26 +7,31 @@ import (
 )
 
 type Notification struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	PCOCheckInID string         `json:"pco_check_in_id" gorm:"uniqueIndex;not null"`
-	ChildName    string         `json:"child_name" gorm:"not null"`
-	SecurityCode string         `json:"security_code" gorm:"not null"`
-	LocationID   string         `json:"location_id"`
-	LocationName string         `json:"location_name"`
-	EventID      uint           `json:"event_id" gorm:"not null"`
-	EventName    string         `json:"event_name"`
-	ParentName   string         `json:"parent_name"`
-	ParentPhone  string         `json:"parent_phone"`
-	Notes        string         `json:"notes"`
-	Status       string         `json:"status" gorm:"default:'active'"`
-	ExpiresAt    time.Time      `json:"expires_at"`
-	CreatedBy    string         `json:"created_by" gorm:"not null"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint           `json:"id" bson:"_id" gorm:"primaryKey"`
+	PCOCheckInID string         `json:"pco_check_in_id" bson:"pco_check_in_id" gorm:"uniqueIndex;not null"`
+	ChildName    string         `json:"child_name" bson:"child_name" gorm:"not null"`
+	SecurityCode string         `json:"security_code" bson:"security_code" gorm:"not null"`
+	LocationID   string         `json:"location_id" bson:"location_id"`
+	LocationName string         `json:"location_name" bson:"location_name"`
+	EventID      uint           `json:"event_id" bson:"event_id" gorm:"not null"`
+	EventName    string         `json:"event_name" bson:"event_name"`
+	ParentName   string         `json:"parent_name" bson:"parent_name"`
+	Notes        string         `json:"notes" bson:"notes"`
+	Status       string         `json:"status" bson:"status" gorm:"default:'active'"`
+	ExpiresAt    time.Time      `json:"expires_at" bson:"expires_at"`
+	CreatedBy    string         `json:"created_by" bson:"created_by" gorm:"not null"`
+	CreatedAt    time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at" bson:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" bson:"-" gorm:"index"`
+
+	// The parent's phone number and email address are never serialized, so
+	// broadcasts to billboards don't carry them; handlers add them for
+	// callers with contact access
+	ParentPhone string `json:"-" bson:"parent_phone"`
+	ParentEmail string `json:"-" bson:"parent_email"`
 
 	// Relationships
-	Event Event `json:"event" gorm:"foreignKey:EventID"`
+	Event Event `json:"event" bson:"-" gorm:"foreignKey:EventID"`
 }
 
 func (Notification) TableName() string {
@@ -7,20 +7,20 @@ import (
 )
 
 type Session struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	Token        string         `json:"token" gorm:"uniqueIndex;not null"`
-	UserID       uint           `json:"user_id" gorm:"not null"`
-	ExpiresAt    time.Time      `json:"expires_at" gorm:"not null"`
-	IsRememberMe bool           `json:"is_remember_me" gorm:"default:false"`
-	UserAgent    string         `json:"user_agent"`
-	IPAddress    string         `json:"ip_address"`
-	LastActivity time.Time      `json:"last_activity"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint           `json:"id" bson:"_id" gorm:"primaryKey"`
+	Token        string         `json:"token" bson:"token" gorm:"uniqueIndex;not null"`
+	UserID       uint           `json:"user_id" bson:"user_id" gorm:"not null"`
+	ExpiresAt    time.Time      `json:"expires_at" bson:"expires_at" gorm:"not null"`
+	IsRememberMe bool           `json:"is_remember_me" bson:"is_remember_me" gorm:"default:false"`
+	UserAgent    string         `json:"user_agent" bson:"user_agent"`
+	IPAddress    string         `json:"ip_address" bson:"ip_address"`
+	LastActivity time.Time      `json:"last_activity" bson:"last_activity"`
+	CreatedAt    time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at" bson:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" bson:"-" gorm:"index"`
 
 	// Relationships
-	User User `json:"user" gorm:"foreignKey:UserID"`
+	User User `json:"user" bson:"-" gorm:"foreignKey:UserID"`
 }
 
 func (Session) TableName() string {
@@ -7,26 +7,38 @@ import (
 )
 
 type User struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	PCOUserID    string         `json:"pco_user_id" gorm:"uniqueIndex;not null"`
-	Name         string         `json:"name" gorm:"not null"`
-	Email        string         `json:"email" gorm:"uniqueIndex;not null"`
-	Avatar       string         `json:"avatar"`
-	IsAdmin      bool           `json:"is_admin" gorm:"default:false"`
-	AccessToken  string         `json:"-" gorm:"not null"`
-	RefreshToken string         `json:"-" gorm:"not null"`
-	TokenExpiry  time.Time      `json:"token_expiry"`
-	LastLogin    time.Time      `json:"last_login"`
-	LastActivity time.Time      `json:"last_activity"`
-	IsActive     bool           `json:"is_active" gorm:"default:true"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint           `json:"id" bson:"_id" gorm:"primaryKey"`
+	PCOUserID    string         `json:"pco_user_id" bson:"pco_user_id" gorm:"uniqueIndex;not null"`
+	Name         string         `json:"name" bson:"name" gorm:"not null"`
+	Email        string         `json:"email" bson:"email" gorm:"uniqueIndex;not null"`
+	Avatar       string         `json:"avatar" bson:"avatar"`
+	IsAdmin      bool           `json:"is_admin" bson:"is_admin" gorm:"default:false"`
+	AccessToken  string         `json:"-" bson:"access_token" gorm:"not null;serializer:encrypted"`
+	RefreshToken string         `json:"-" bson:"refresh_token" gorm:"not null;serializer:encrypted"`
+	TokenExpiry  time.Time      `json:"token_expiry" bson:"token_expiry"`
+	LastLogin    time.Time      `json:"last_login" bson:"last_login"`
+	LastActivity time.Time      `json:"last_activity" bson:"last_activity"`
+	IsActive     bool           `json:"is_active" bson:"is_active" gorm:"default:true"`
+	CreatedAt    time.Time      `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at" bson:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" bson:"-" gorm:"index"`
+
+	// CalendarTokenHash is the hash of the secret in the user's calendar
+	// feed URL, empty until one is issued
+	CalendarTokenHash string `json:"-" bson:"calendar_token_hash" gorm:"index"`
+
+	// MedicalAccess lets a user who is not an admin see check-ins' medical
+	// notes and emergency contacts; admins always can
+	MedicalAccess bool `json:"medical_access" bson:"medical_access" gorm:"default:false"`
+
+	// ContactAccess lets a user who is not an admin, such as a teacher, see
+	// parents' phone numbers and email addresses; admins always can
+	ContactAccess bool `json:"contact_access" bson:"contact_access" gorm:"default:false"`
 
 	// Relationships
-	Sessions      []Session      `json:"-" gorm:"foreignKey:UserID"`
-	Events        []Event        `json:"-" gorm:"foreignKey:CreatedBy"`
-	Notifications []Notification `json:"-" gorm:"foreignKey:CreatedBy"`
+	Sessions      []Session      `json:"-" bson:"-" gorm:"foreignKey:UserID"`
+	Events        []Event        `json:"-" bson:"-" gorm:"foreignKey:CreatedBy"`
+	Notifications []Notification `json:"-" bson:"-" gorm:"foreignKey:CreatedBy"`
 }
 
 func (User) TableName() string {
@@ -0,0 +1,322 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go_pco_arrivals/internal/database"
+	"go_pco_arrivals/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BulkTables lists every table a bulk copy moves, parents before the rows
+// that reference them
+var BulkTables = []string{"users", "sessions", "api_keys", "audit_events", "locations", "events", "event_periods", "event_times", "check_ins", "notifications", "billboard_states", "security_codes", "visitors", "attendance_rollups"}
+
+// hardDeleted lists the tables without a deleted_at column
+var hardDeleted = map[string]bool{"audit_events": true, "event_periods": true, "event_times": true, "visitors": true, "attendance_rollups": true}
+
+// Batch is a run of rows from one table, in ID order
+type Batch struct {
+	Table string
+	Len   int
+	// LastID is the ID of the final row, where the next read resumes
+	LastID uint
+	rows   interface{}
+}
+
+// Bulk reads and writes whole tables by ID for copying data between
+// backends. IDs and timestamps are kept as they are; soft-deleted rows are
+// not read.
+type Bulk interface {
+	Count(table string) (int64, error)
+	// MaxID returns the highest ID in table, or 0 when it is empty
+	MaxID(table string) (uint, error)
+	// Read returns up to limit rows of table with IDs above after
+	Read(table string, after uint, limit int) (Batch, error)
+	// Write stores a batch read from another backend, replacing rows with the
+	// same ID, and moves the ID sequence past them
+	Write(batch Batch) error
+}
+
+// NewBulk returns bulk table access for a database
+func NewBulk(db database.Database) (Bulk, error) {
+	switch conn := db.(type) {
+	case *database.SQLiteDatabase:
+		return &gormBulk{db: conn.GetGormDB()}, nil
+	case *database.PostgresDatabase:
+		return &gormBulk{db: conn.GetGormDB(), postgres: true}, nil
+	case *database.MongoDBDatabase:
+		return &mongoBulk{&mongoBackend{db: conn.GetMongoDB()}}, nil
+	default:
+		return nil, fmt.Errorf("no bulk access for %s databases", db.GetType())
+	}
+}
+
+// bulkTable moves one model. D is the document type stored in MongoDB,
+// which differs from the model where the Mongo repositories encode fields.
+type bulkTable[T, D any] struct {
+	id     func(row *T) uint
+	encode func(row T) (D, error)
+	decode func(doc D) (T, error)
+}
+
+// sameDocument is for models stored in MongoDB as they are
+func sameDocument[T any](id func(row *T) uint) bulkTable[T, T] {
+	identity := func(row T) (T, error) { return row, nil }
+	return bulkTable[T, T]{id: id, encode: identity, decode: identity}
+}
+
+// bulkCopier is the type-erased form of bulkTable
+type bulkCopier interface {
+	gormRead(db *gorm.DB, table string, after uint, limit int) (Batch, error)
+	gormWrite(db *gorm.DB, batch Batch) error
+	mongoRead(ctx context.Context, collection *mongo.Collection, table string, after uint, limit int) (Batch, error)
+	mongoWrite(ctx context.Context, collection *mongo.Collection, batch Batch) error
+}
+
+var bulkCopiers = map[string]bulkCopier{
+	"users": bulkTable[models.User, models.User]{
+		id: func(u *models.User) uint { return u.ID },
+		encode: func(u models.User) (models.User, error) {
+			sealed, err := (&mongoUserRepository{}).sealed(&u)
+			if err != nil {
+				return u, err
+			}
+			return *sealed, nil
+		},
+		decode: func(u models.User) (models.User, error) {
+			return u, (&mongoUserRepository{}).open(&u)
+		},
+	},
+	"sessions":           sameDocument(func(s *models.Session) uint { return s.ID }),
+	"api_keys":           sameDocument(func(k *models.APIKey) uint { return k.ID }),
+	"locations":          sameDocument(func(l *models.Location) uint { return l.ID }),
+	"events":             sameDocument(func(e *models.Event) uint { return e.ID }),
+	"event_periods":      sameDocument(func(p *models.EventPeriod) uint { return p.ID }),
+	"event_times":        sameDocument(func(t *models.EventTime) uint { return t.ID }),
+	"check_ins":          sameDocument(func(c *models.CheckIn) uint { return c.ID }),
+	"notifications":      sameDocument(func(n *models.Notification) uint { return n.ID }),
+	"security_codes":     sameDocument(func(c *models.SecurityCode) uint { return c.ID }),
+	"billboard_states":   sameDocument(func(s *models.BillboardState) uint { return s.ID }),
+	"visitors":           sameDocument(func(v *models.Visitor) uint { return v.ID }),
+	"attendance_rollups": sameDocument(func(a *models.AttendanceRollup) uint { return a.ID }),
+	"audit_events": bulkTable[models.AuditEvent, mongoAuditEvent]{
+		id: func(e *models.AuditEvent) uint { return e.ID },
+		encode: func(e models.AuditEvent) (mongoAuditEvent, error) {
+			doc := mongoAuditEvent{AuditEvent: e}
+			var err error
+			if doc.Before, err = encodePayload(e.Before); err != nil {
+				return doc, err
+			}
+			doc.After, err = encodePayload(e.After)
+			return doc, err
+		},
+		decode: func(doc mongoAuditEvent) (models.AuditEvent, error) {
+			e := doc.AuditEvent
+			var err error
+			if e.Before, err = decodePayload(doc.Before); err != nil {
+				return e, err
+			}
+			e.After, err = decodePayload(doc.After)
+			return e, err
+		},
+	},
+}
+
+func copierFor(table string) (bulkCopier, error) {
+	copier, ok := bulkCopiers[table]
+	if !ok {
+		return nil, fmt.Errorf("unknown table %q", table)
+	}
+	return copier, nil
+}
+
+func (t bulkTable[T, D]) batch(table string, rows []T) Batch {
+	batch := Batch{Table: table, Len: len(rows), rows: rows}
+	if len(rows) > 0 {
+		batch.LastID = t.id(&rows[len(rows)-1])
+	}
+	return batch
+}
+
+func (t bulkTable[T, D]) gormRead(db *gorm.DB, table string, after uint, limit int) (Batch, error) {
+	var rows []T
+	if err := db.Where("id > ?", after).Order("id").Limit(limit).Find(&rows).Error; err != nil {
+		return Batch{}, gormError(err, "read "+table)
+	}
+	return t.batch(table, rows), nil
+}
+
+func (t bulkTable[T, D]) gormWrite(db *gorm.DB, batch Batch) error {
+	rows := batch.rows.([]T)
+	if len(rows) == 0 {
+		return nil
+	}
+
+	// Audit events can't be updated, so rows copied by an earlier run are
+	// skipped rather than replaced
+	conflict := clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, UpdateAll: true}
+	if batch.Table == "audit_events" {
+		conflict = clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, DoNothing: true}
+	}
+	if err := db.Omit(clause.Associations).Clauses(conflict).Create(&rows).Error; err != nil {
+		return gormError(err, "write "+batch.Table)
+	}
+	return nil
+}
+
+func (t bulkTable[T, D]) mongoRead(ctx context.Context, collection *mongo.Collection, table string, after uint, limit int) (Batch, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := collection.Find(ctx, bson.M{"_id": bson.M{"$gt": after}}, opts)
+	if err != nil {
+		return Batch{}, mongoError(err, "read "+table)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []D
+	if err := cursor.All(ctx, &docs); err != nil {
+		return Batch{}, mongoError(err, "read "+table)
+	}
+	rows := make([]T, len(docs))
+	for i, doc := range docs {
+		if rows[i], err = t.decode(doc); err != nil {
+			return Batch{}, err
+		}
+	}
+	return t.batch(table, rows), nil
+}
+
+func (t bulkTable[T, D]) mongoWrite(ctx context.Context, collection *mongo.Collection, batch Batch) error {
+	rows := batch.rows.([]T)
+	if len(rows) == 0 {
+		return nil
+	}
+
+	writes := make([]mongo.WriteModel, len(rows))
+	for i := range rows {
+		doc, err := t.encode(rows[i])
+		if err != nil {
+			return err
+		}
+		writes[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": t.id(&rows[i])}).
+			SetReplacement(doc).
+			SetUpsert(true)
+	}
+	if _, err := collection.BulkWrite(ctx, writes); err != nil {
+		return mongoError(err, "write "+batch.Table)
+	}
+	return nil
+}
+
+type gormBulk struct {
+	db       *gorm.DB
+	postgres bool
+}
+
+func (b *gormBulk) Count(table string) (int64, error) {
+	var count int64
+	query := b.db.Table(table)
+	if !hardDeleted[table] {
+		query = query.Where("deleted_at IS NULL")
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return 0, gormError(err, "count "+table)
+	}
+	return count, nil
+}
+
+func (b *gormBulk) MaxID(table string) (uint, error) {
+	var maxID uint
+	if err := b.db.Table(table).Select("COALESCE(MAX(id), 0)").Scan(&maxID).Error; err != nil {
+		return 0, gormError(err, "read highest id in "+table)
+	}
+	return maxID, nil
+}
+
+func (b *gormBulk) Read(table string, after uint, limit int) (Batch, error) {
+	copier, err := copierFor(table)
+	if err != nil {
+		return Batch{}, err
+	}
+	return copier.gormRead(b.db, table, after, limit)
+}
+
+func (b *gormBulk) Write(batch Batch) error {
+	copier, err := copierFor(batch.Table)
+	if err != nil {
+		return err
+	}
+	if err := copier.gormWrite(b.db, batch); err != nil {
+		return err
+	}
+	if !b.postgres || batch.Len == 0 {
+		return nil
+	}
+
+	// Explicit IDs don't advance PostgreSQL sequences, so move it past the
+	// copied rows before the app inserts any of its own
+	err = b.db.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', 'id'), (SELECT MAX(id) FROM %s))", batch.Table, batch.Table)).Error
+	return gormError(err, "advance "+batch.Table+" id sequence")
+}
+
+type mongoBulk struct {
+	*mongoBackend
+}
+
+func (b *mongoBulk) Count(table string) (int64, error) {
+	return b.count(table, bson.M{}, "count "+table)
+}
+
+func (b *mongoBulk) MaxID(table string) (uint, error) {
+	var doc struct {
+		ID uint `bson:"_id"`
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}).SetProjection(bson.M{"_id": 1})
+	err := b.findOne(table, bson.M{}, opts, &doc, "read highest id in "+table)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	return doc.ID, err
+}
+
+func (b *mongoBulk) Read(table string, after uint, limit int) (Batch, error) {
+	copier, err := copierFor(table)
+	if err != nil {
+		return Batch{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	return copier.mongoRead(ctx, b.collection(table), table, after, limit)
+}
+
+func (b *mongoBulk) Write(batch Batch) error {
+	copier, err := copierFor(batch.Table)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	if err := copier.mongoWrite(ctx, b.collection(batch.Table), batch); err != nil {
+		return err
+	}
+	if batch.Len == 0 {
+		return nil
+	}
+
+	// Keep the ID counter ahead of the copied rows
+	_, err = b.collection("counters").UpdateOne(ctx,
+		bson.M{"_id": batch.Table},
+		bson.M{"$max": bson.M{"seq": int64(batch.LastID)}},
+		options.Update().SetUpsert(true),
+	)
+	return mongoError(err, "advance "+batch.Table+" id counter")
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/database"
+	"go_pco_arrivals/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestBulkTablesHaveCopiers(t *testing.T) {
+	for _, table := range BulkTables {
+		if _, err := copierFor(table); err != nil {
+			t.Errorf("table %s: %v", table, err)
+		}
+	}
+	if len(bulkCopiers) != len(BulkTables) {
+		t.Errorf("got %d copiers for %d bulk tables", len(bulkCopiers), len(BulkTables))
+	}
+}
+
+// openSQLite returns a migrated in-memory SQLite database
+func openSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.ConnectLegacy(config.DatabaseConfig{URL: ":memory:"})
+	if err != nil {
+		t.Fatalf("failed to open SQLite: %v", err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	// Every connection to :memory: is a separate database
+	if err := database.ConfigureConnectionPool(db, 1, 1, 0); err != nil {
+		t.Fatalf("failed to configure connection pool: %v", err)
+	}
+	if err := database.MigrateLegacy(db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return db
+}
+
+func TestBulkCopy(t *testing.T) {
+	sourceDB, targetDB := openSQLite(t), openSQLite(t)
+	source := NewGormStore(sourceDB)
+
+	start := time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC)
+	user := &models.User{PCOUserID: "pco-1", Name: "Admin", Email: "admin@example.com", AccessToken: "a", RefreshToken: "r", IsActive: true}
+	if err := source.Users.Create(user); err != nil {
+		t.Fatalf("failed to seed user: %v", err)
+	}
+	event := &models.Event{PCOEventID: "evt-1", Name: "Sunday Service", Date: start, IsActive: true, CreatedBy: "pco-1"}
+	if err := source.Events.Create(event); err != nil {
+		t.Fatalf("failed to seed event: %v", err)
+	}
+	period := &models.EventPeriod{PCOEventPeriodID: "period-1", EventID: event.ID, StartsAt: start, EndsAt: start.Add(2 * time.Hour), RegularCount: 12}
+	if err := source.Events.SavePeriod(period); err != nil {
+		t.Fatalf("failed to seed event period: %v", err)
+	}
+	eventTime := &models.EventTime{PCOEventTimeID: "time-1", EventPeriodID: period.ID, EventID: event.ID, Name: "9:00", StartsAt: start}
+	if err := source.Events.SaveTime(eventTime); err != nil {
+		t.Fatalf("failed to seed event time: %v", err)
+	}
+
+	from, to := &gormBulk{db: sourceDB}, &gormBulk{db: targetDB}
+	for _, table := range BulkTables {
+		batch, err := from.Read(table, 0, 100)
+		if err != nil {
+			t.Fatalf("read %s: %v", table, err)
+		}
+		if err := to.Write(batch); err != nil {
+			t.Fatalf("write %s: %v", table, err)
+		}
+
+		want, err := from.Count(table)
+		if err != nil {
+			t.Fatalf("count source %s: %v", table, err)
+		}
+		got, err := to.Count(table)
+		if err != nil {
+			t.Fatalf("count target %s: %v", table, err)
+		}
+		if got != want {
+			t.Errorf("table %s: copied %d rows, want %d", table, got, want)
+		}
+	}
+
+	target := NewGormStore(targetDB)
+	periods, err := target.Events.ListPeriods(EventPeriodFilter{EventID: event.ID})
+	if err != nil {
+		t.Fatalf("failed to list copied periods: %v", err)
+	}
+	if len(periods) != 1 || periods[0].PCOEventPeriodID != "period-1" || periods[0].RegularCount != 12 {
+		t.Fatalf("copied periods: got %+v", periods)
+	}
+	times, err := target.Events.ListTimes([]uint{periods[0].ID})
+	if err != nil {
+		t.Fatalf("failed to list copied times: %v", err)
+	}
+	if len(times) != 1 || times[0].PCOEventTimeID != "time-1" || times[0].Name != "9:00" {
+		t.Fatalf("copied times: got %+v", times)
+	}
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+)
+
+// upsertBatchSize caps how many check-ins go into one upsert statement
+const upsertBatchSize = 500
+
+// checkInSyncColumns are the columns an upsert rewrites on existing rows.
+// Everything else (security code, event) is owned locally. The parent is only
+// synced when one was resolved, so a failed lookup keeps the stored one.
+var checkInSyncColumns = []string{"person_id", "person_name", "location_id", "location_name", "check_in_time", "checked_out_at", "notes", "status", "kind", "first_time", "one_time_guest", "number", "medical_notes", "emergency_contact_name", "emergency_contact_phone", "parent_name", "parent_phone", "parent_email", "updated_at"}
+
+// checkInSyncChanged reports whether incoming differs from stored in a field
+// the sync owns
+func checkInSyncChanged(stored, incoming models.CheckIn) bool {
+	return stored.PersonID != incoming.PersonID ||
+		stored.PersonName != incoming.PersonName ||
+		stored.LocationID != incoming.LocationID ||
+		stored.LocationName != incoming.LocationName ||
+		!stored.CheckInTime.Equal(incoming.CheckInTime) ||
+		!sameTime(stored.CheckedOutAt, incoming.CheckedOutAt) ||
+		stored.Notes != incoming.Notes ||
+		stored.Status != incoming.Status ||
+		stored.Kind != incoming.Kind ||
+		stored.FirstTime != incoming.FirstTime ||
+		stored.OneTimeGuest != incoming.OneTimeGuest ||
+		stored.Number != incoming.Number ||
+		stored.MedicalNotes != incoming.MedicalNotes ||
+		stored.EmergencyContactName != incoming.EmergencyContactName ||
+		stored.EmergencyContactPhone != incoming.EmergencyContactPhone ||
+		(incoming.ParentName != "" && (stored.ParentName != incoming.ParentName ||
+			stored.ParentPhone != incoming.ParentPhone ||
+			stored.ParentEmail != incoming.ParentEmail))
+}
+
+// sameTime reports whether two optional times are both unset or equal
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+// applyCheckInSync copies the fields the sync owns from incoming onto stored
+func applyCheckInSync(stored *models.CheckIn, incoming models.CheckIn, now time.Time) {
+	stored.PersonID = incoming.PersonID
+	stored.PersonName = incoming.PersonName
+	stored.LocationID = incoming.LocationID
+	stored.LocationName = incoming.LocationName
+	stored.CheckInTime = incoming.CheckInTime
+	stored.CheckedOutAt = incoming.CheckedOutAt
+	stored.Notes = incoming.Notes
+	stored.Status = incoming.Status
+	stored.Kind = incoming.Kind
+	stored.FirstTime = incoming.FirstTime
+	stored.OneTimeGuest = incoming.OneTimeGuest
+	stored.Number = incoming.Number
+	stored.MedicalNotes = incoming.MedicalNotes
+	stored.EmergencyContactName = incoming.EmergencyContactName
+	stored.EmergencyContactPhone = incoming.EmergencyContactPhone
+	if incoming.ParentName != "" {
+		stored.ParentName = incoming.ParentName
+		stored.ParentPhone = incoming.ParentPhone
+		stored.ParentEmail = incoming.ParentEmail
+	}
+	stored.UpdatedAt = now
+}
+
+// upsertPlan is a batch of check-ins sorted by what an upsert must do
+type upsertPlan struct {
+	create []models.CheckIn
+	update []models.CheckIn
+}
+
+// prepareUpsert validates and de-duplicates incoming check-ins, keeping the
+// last copy of each PCO ID. Rows without a PCO ID are counted as failed and
+// repeats as unchanged.
+func prepareUpsert(checkIns []models.CheckIn, result *UpsertResult) ([]models.CheckIn, []string) {
+	index := make(map[string]int, len(checkIns))
+	var rows []models.CheckIn
+	for _, checkIn := range checkIns {
+		if checkIn.PCOCheckInID == "" {
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Errorf("check-in for %q has no PCO ID", checkIn.PersonName))
+			continue
+		}
+		if checkIn.Status == "" {
+			checkIn.Status = "active"
+		}
+		if checkIn.Kind == "" {
+			checkIn.Kind = models.CheckInKindRegular
+		}
+		if i, ok := index[checkIn.PCOCheckInID]; ok {
+			rows[i] = checkIn
+			result.Unchanged++
+			continue
+		}
+		index[checkIn.PCOCheckInID] = len(rows)
+		rows = append(rows, checkIn)
+	}
+
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = row.PCOCheckInID
+	}
+	return rows, ids
+}
+
+// planUpsert compares incoming rows with the stored ones, counting the
+// unchanged rows and returning the ones to write. Updates carry the stored
+// row with the synced fields applied.
+func planUpsert(rows []models.CheckIn, existing []models.CheckIn, now time.Time, result *UpsertResult) upsertPlan {
+	stored := make(map[string]models.CheckIn, len(existing))
+	for _, checkIn := range existing {
+		stored[checkIn.PCOCheckInID] = checkIn
+	}
+
+	var plan upsertPlan
+	for _, row := range rows {
+		current, ok := stored[row.PCOCheckInID]
+		switch {
+		case !ok:
+			row.ID = 0
+			row.CreatedAt, row.UpdatedAt = now, now
+			plan.create = append(plan.create, row)
+		case checkInSyncChanged(current, row):
+			applyCheckInSync(&current, row, now)
+			plan.update = append(plan.update, current)
+		default:
+			result.Unchanged++
+		}
+	}
+	return plan
+}
+
+// batches splits rows into slices of at most upsertBatchSize
+func batches[T any](rows []T) [][]T {
+	var out [][]T
+	for len(rows) > upsertBatchSize {
+		out = append(out, rows[:upsertBatchSize])
+		rows = rows[upsertBatchSize:]
+	}
+	if len(rows) > 0 {
+		out = append(out, rows)
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// NewGormStore returns a store backed by a GORM connection
+func NewGormStore(db *gorm.DB) *Store {
+	return &Store{
+		Users:           &gormUserRepository{db: db},
+		Sessions:        &gormSessionRepository{db: db},
+		APIKeys:         &gormAPIKeyRepository{db: db},
+		AuditEvents:     &gormAuditRepository{db: db},
+		CheckIns:        &gormCheckInRepository{db: db},
+		Notifications:   &gormNotificationRepository{db: db},
+		Locations:       &gormLocationRepository{db: db},
+		Events:          &gormEventRepository{db: db},
+		BillboardStates: &gormBillboardStateRepository{db: db},
+		SecurityCodes:   &gormSecurityCodeRepository{db: db},
+		Rollups:         &gormRollupRepository{db: db},
+		Visitors:        &gormVisitorRepository{db: db},
+		ping: func() error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.Ping()
+		},
+	}
+}
+
+// gormError maps GORM errors onto the repository errors
+func gormError(err error, action string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey) || isUniqueViolation(err):
+		return fmt.Errorf("%w: %v", ErrDuplicate, err)
+	default:
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+}
+
+// isUniqueViolation recognises unique constraint errors from drivers that
+// don't translate them to gorm.ErrDuplicatedKey
+func isUniqueViolation(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "UNIQUE constraint failed") ||
+		strings.Contains(message, "duplicate key value")
+}
+
+// rowsAffected returns ErrNotFound when an update by ID matched nothing
+func rowsAffected(result *gorm.DB, action string) error {
+	if result.Error != nil {
+		return gormError(result.Error, action)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
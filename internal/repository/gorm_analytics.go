@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"time"
+
+	"go_pco_arrivals/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormRollupRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormRollupRepository) Replace(since, until time.Time, rollups []models.AttendanceRollup) error {
+	rows := stampRollups(rollups, time.Now())
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("period_start >= ? AND period_start < ?", since, until).Delete(&models.AttendanceRollup{}).Error; err != nil {
+			return err
+		}
+		for _, batch := range batches(rows) {
+			if err := tx.Create(&batch).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return gormError(err, "replace rollups")
+}
+
+func (r *gormRollupRepository) List(filter RollupFilter) ([]models.AttendanceRollup, error) {
+	query := r.db.Model(&models.AttendanceRollup{})
+	if filter.Period != "" {
+		query = query.Where("period = ?", filter.Period)
+	}
+	if filter.LocationID != "" {
+		query = query.Where("location_id = ?", filter.LocationID)
+	}
+	if filter.EventID != "" {
+		query = query.Where("event_id = ?", filter.EventID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("period_start >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("period_start < ?", filter.Until)
+	}
+
+	var rollups []models.AttendanceRollup
+	if err := query.Order("period_start, location_id, event_id").Find(&rollups).Error; err != nil {
+		return nil, gormError(err, "list rollups")
+	}
+	return rollups, nil
+}
+
+type gormVisitorRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormVisitorRepository) Record(visitors []models.Visitor) ([]models.Visitor, error) {
+	var existing []models.Visitor
+	for _, batch := range batches(visitorPersonIDs(visitors)) {
+		var found []models.Visitor
+		if err := r.db.Where("person_id IN ?", batch).Find(&found).Error; err != nil {
+			return nil, gormError(err, "load visitors")
+		}
+		existing = append(existing, found...)
+	}
+
+	write, moved := planVisitors(visitors, existing, time.Now())
+	var created []models.Visitor
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, visitor := range write {
+			if visitor.ID != 0 {
+				if err := tx.Save(&visitor).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			created = append(created, visitor)
+		}
+		// A concurrent sync may have recorded the same person first; its
+		// row is kept
+		for _, batch := range batches(created) {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&batch).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, gormError(err, "record visitors")
+	}
+	return moved, nil
+}
+
+func (r *gormVisitorRepository) List(filter VisitorFilter) ([]models.Visitor, error) {
+	query := r.db.Model(&models.Visitor{})
+	if filter.LocationID != "" {
+		query = query.Where("location_id = ?", filter.LocationID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("first_check_in_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("first_check_in_at < ?", filter.Until)
+	}
+
+	var visitors []models.Visitor
+	if err := query.Order("first_check_in_at, id").Find(&visitors).Error; err != nil {
+		return nil, gormError(err, "list visitors")
+	}
+	return visitors, nil
+}
@@ -0,0 +1,557 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormCheckInRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormCheckInRepository) Create(checkIn *models.CheckIn) error {
+	return gormError(r.db.Create(checkIn).Error, "create check-in")
+}
+
+// Upsert writes new and changed check-ins with INSERT ... ON CONFLICT on
+// pco_check_in_id. A batch the database rejects is retried row by row so one
+// bad row doesn't sink the rest.
+func (r *gormCheckInRepository) Upsert(checkIns []models.CheckIn) (*UpsertResult, error) {
+	result := &UpsertResult{Inserted: make(map[string]bool)}
+	rows, ids := prepareUpsert(checkIns, result)
+
+	var existing []models.CheckIn
+	for _, batch := range batches(ids) {
+		var found []models.CheckIn
+		if err := r.db.Where("pco_check_in_id IN ?", batch).Find(&found).Error; err != nil {
+			return nil, gormError(err, "load check-ins")
+		}
+		existing = append(existing, found...)
+	}
+
+	plan := planUpsert(rows, existing, time.Now(), result)
+	created := make(map[string]bool, len(plan.create))
+	pending := make([]models.CheckIn, 0, len(plan.create)+len(plan.update))
+	for _, checkIn := range plan.create {
+		created[checkIn.PCOCheckInID] = true
+		pending = append(pending, checkIn)
+	}
+	for _, checkIn := range plan.update {
+		checkIn.ID = 0
+		pending = append(pending, checkIn)
+	}
+
+	upsert := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "pco_check_in_id"}},
+		DoUpdates: clause.AssignmentColumns(checkInSyncColumns),
+	}
+	var written []string
+	for _, batch := range batches(pending) {
+		if err := r.db.Clauses(upsert).Create(&batch).Error; err == nil {
+			for _, checkIn := range batch {
+				written = append(written, checkIn.PCOCheckInID)
+			}
+			continue
+		}
+		for _, checkIn := range batch {
+			if err := r.db.Clauses(upsert).Create(&checkIn).Error; err != nil {
+				result.Failed++
+				result.Errors = append(result.Errors, gormError(err, "upsert check-in "+checkIn.PCOCheckInID))
+				continue
+			}
+			written = append(written, checkIn.PCOCheckInID)
+		}
+	}
+
+	for _, batch := range batches(written) {
+		var stored []models.CheckIn
+		if err := r.db.Where("pco_check_in_id IN ?", batch).Order("id").Find(&stored).Error; err != nil {
+			return nil, gormError(err, "reload check-ins")
+		}
+		result.Changed = append(result.Changed, stored...)
+	}
+	for _, checkIn := range result.Changed {
+		if created[checkIn.PCOCheckInID] {
+			result.Created++
+			result.Inserted[checkIn.PCOCheckInID] = true
+		} else {
+			result.Updated++
+		}
+	}
+	return result, nil
+}
+
+func (r *gormCheckInRepository) GetByPCOID(pcoCheckInID string) (*models.CheckIn, error) {
+	var checkIn models.CheckIn
+	if err := r.db.Where("pco_check_in_id = ?", pcoCheckInID).First(&checkIn).Error; err != nil {
+		return nil, gormError(err, "get check-in")
+	}
+	return &checkIn, nil
+}
+
+func (r *gormCheckInRepository) query(filter CheckInFilter) *gorm.DB {
+	query := r.db.Model(&models.CheckIn{})
+	if filter.LocationID != "" {
+		query = query.Where("location_id = ?", filter.LocationID)
+	}
+	if len(filter.LocationIDs) > 0 {
+		query = query.Where("location_id IN ?", filter.LocationIDs)
+	}
+	if filter.SecurityCode != "" {
+		query = query.Where("security_code = ?", filter.SecurityCode)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.PersonID != "" {
+		query = query.Where("person_id = ?", filter.PersonID)
+	}
+	if filter.Present {
+		query = query.Where("checked_out_at IS NULL")
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("check_in_time >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("check_in_time < ?", filter.Until)
+	}
+	return query
+}
+
+func (r *gormCheckInRepository) List(filter CheckInFilter) ([]models.CheckIn, error) {
+	query := r.query(filter).Order("check_in_time DESC")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var checkIns []models.CheckIn
+	if err := query.Find(&checkIns).Error; err != nil {
+		return nil, gormError(err, "list check-ins")
+	}
+	return checkIns, nil
+}
+
+func (r *gormCheckInRepository) Count(filter CheckInFilter) (int64, error) {
+	var count int64
+	if err := r.query(filter).Count(&count).Error; err != nil {
+		return 0, gormError(err, "count check-ins")
+	}
+	return count, nil
+}
+
+func (r *gormCheckInRepository) DeleteBefore(cutoff time.Time) (int64, error) {
+	result := r.db.Unscoped().Where("check_in_time < ?", cutoff).Delete(&models.CheckIn{})
+	if result.Error != nil {
+		return 0, gormError(result.Error, "delete old check-ins")
+	}
+	return result.RowsAffected, nil
+}
+
+// sqlDialect holds the date expressions that differ between SQL backends
+type sqlDialect struct {
+	// day formats a timestamp column as YYYY-MM-DD
+	day func(column string) string
+	// hour extracts the hour of day from a timestamp column as an integer
+	hour func(column string) string
+	// minutesBetween is the number of minutes from one timestamp to another
+	minutesBetween func(from, to string) string
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"sqlite": {
+		day:  func(column string) string { return "DATE(" + column + ")" },
+		hour: func(column string) string { return "CAST(strftime('%H', " + column + ") AS INTEGER)" },
+		minutesBetween: func(from, to string) string {
+			return "(JULIANDAY(" + to + ") - JULIANDAY(" + from + ")) * 24 * 60"
+		},
+	},
+	"postgres": {
+		day: func(column string) string { return "TO_CHAR(" + column + " AT TIME ZONE 'UTC', 'YYYY-MM-DD')" },
+		hour: func(column string) string {
+			return "CAST(EXTRACT(HOUR FROM " + column + " AT TIME ZONE 'UTC') AS INTEGER)"
+		},
+		minutesBetween: func(from, to string) string {
+			return "EXTRACT(EPOCH FROM (" + to + " - " + from + ")) / 60"
+		},
+	},
+}
+
+func (r *gormCheckInRepository) dialect() (sqlDialect, error) {
+	name := r.db.Dialector.Name()
+	dialect, ok := sqlDialects[name]
+	if !ok {
+		return sqlDialect{}, fmt.Errorf("analytics are not supported on %s", name)
+	}
+	return dialect, nil
+}
+
+func (r *gormCheckInRepository) DailyCounts(locationID string, since, until time.Time) ([]DailyCount, error) {
+	dialect, err := r.dialect()
+	if err != nil {
+		return nil, err
+	}
+
+	day := dialect.day("check_in_time")
+	var counts []DailyCount
+	err = r.db.Raw(`
+		SELECT `+day+` AS date, COUNT(*) AS count
+		FROM check_ins
+		WHERE location_id = ? AND check_in_time >= ? AND check_in_time <= ?
+		GROUP BY `+day+`
+		ORDER BY date
+	`, locationID, since, until).Scan(&counts).Error
+	if err != nil {
+		return nil, gormError(err, "count check-ins by day")
+	}
+	return counts, nil
+}
+
+func (r *gormCheckInRepository) HourlyCounts(locationID string, since time.Time) ([]HourlyCount, error) {
+	dialect, err := r.dialect()
+	if err != nil {
+		return nil, err
+	}
+
+	hour := dialect.hour("check_in_time")
+	var counts []HourlyCount
+	err = r.db.Raw(`
+		SELECT `+hour+` AS hour, COUNT(*) AS count
+		FROM check_ins
+		WHERE location_id = ? AND check_in_time >= ?
+		GROUP BY `+hour+`
+		ORDER BY count DESC, hour
+	`, locationID, since).Scan(&counts).Error
+	if err != nil {
+		return nil, gormError(err, "count check-ins by hour")
+	}
+	return counts, nil
+}
+
+func (r *gormCheckInRepository) AverageWaitMinutes(locationID string, since time.Time) (float64, error) {
+	dialect, err := r.dialect()
+	if err != nil {
+		return 0, err
+	}
+
+	var average sql.NullFloat64
+	err = r.db.Raw(`
+		SELECT AVG(`+dialect.minutesBetween("c.check_in_time", "n.created_at")+`)
+		FROM check_ins c
+		JOIN notifications n ON c.pco_check_in_id = n.pco_check_in_id
+		WHERE c.location_id = ? AND c.check_in_time >= ?
+	`, locationID, since).Row().Scan(&average)
+	if err != nil {
+		return 0, gormError(err, "average wait time")
+	}
+	return average.Float64, nil
+}
+
+type gormNotificationRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormNotificationRepository) Create(notification *models.Notification) error {
+	return gormError(r.db.Create(notification).Error, "create notification")
+}
+
+func (r *gormNotificationRepository) GetByCheckInID(pcoCheckInID string) (*models.Notification, error) {
+	var notification models.Notification
+	if err := r.db.Where("pco_check_in_id = ?", pcoCheckInID).First(&notification).Error; err != nil {
+		return nil, gormError(err, "get notification")
+	}
+	return &notification, nil
+}
+
+func (r *gormNotificationRepository) query(filter NotificationFilter) *gorm.DB {
+	query := r.db.Model(&models.Notification{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.LocationName != "" {
+		query = query.Where("location_name = ?", filter.LocationName)
+	}
+	if !filter.ActiveAt.IsZero() {
+		query = query.Where("expires_at > ?", filter.ActiveAt)
+	}
+	if !filter.ExpiredBefore.IsZero() {
+		query = query.Where("expires_at < ?", filter.ExpiredBefore)
+	}
+	if !filter.CreatedSince.IsZero() {
+		query = query.Where("created_at >= ?", filter.CreatedSince)
+	}
+	return query
+}
+
+func (r *gormNotificationRepository) List(filter NotificationFilter) ([]models.Notification, error) {
+	var notifications []models.Notification
+	if err := r.query(filter).Order("created_at DESC").Find(&notifications).Error; err != nil {
+		return nil, gormError(err, "list notifications")
+	}
+	return notifications, nil
+}
+
+func (r *gormNotificationRepository) Count(filter NotificationFilter) (int64, error) {
+	var count int64
+	if err := r.query(filter).Count(&count).Error; err != nil {
+		return 0, gormError(err, "count notifications")
+	}
+	return count, nil
+}
+
+func (r *gormNotificationRepository) UpdateStatus(id uint, status string) error {
+	result := r.db.Model(&models.Notification{}).Where("id = ?", id).Update("status", status)
+	return rowsAffected(result, "update notification status")
+}
+
+func (r *gormNotificationRepository) ExpireActive(now time.Time) (int64, error) {
+	result := r.db.Model(&models.Notification{}).
+		Where("status = ? AND expires_at <= ?", "active", now).
+		Update("status", "expired")
+	if result.Error != nil {
+		return 0, gormError(result.Error, "expire notifications")
+	}
+	return result.RowsAffected, nil
+}
+
+func (r *gormNotificationRepository) Delete(id uint) error {
+	return gormError(r.db.Unscoped().Delete(&models.Notification{}, id).Error, "delete notification")
+}
+
+func (r *gormNotificationRepository) DeleteByCheckInID(pcoCheckInID string) error {
+	return gormError(r.db.Unscoped().Where("pco_check_in_id = ?", pcoCheckInID).Delete(&models.Notification{}).Error, "delete notification")
+}
+
+type gormLocationRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormLocationRepository) Create(location *models.Location) error {
+	return gormError(r.db.Create(location).Error, "create location")
+}
+
+func (r *gormLocationRepository) Save(location *models.Location) error {
+	return gormError(r.db.Save(location).Error, "save location")
+}
+
+func (r *gormLocationRepository) GetByPCOID(pcoLocationID string) (*models.Location, error) {
+	var location models.Location
+	if err := r.db.Where("pco_location_id = ?", pcoLocationID).First(&location).Error; err != nil {
+		return nil, gormError(err, "get location")
+	}
+	return &location, nil
+}
+
+func (r *gormLocationRepository) List() ([]models.Location, error) {
+	var locations []models.Location
+	if err := r.db.Order("id").Find(&locations).Error; err != nil {
+		return nil, gormError(err, "list locations")
+	}
+	return locations, nil
+}
+
+type gormEventRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormEventRepository) Create(event *models.Event) error {
+	return gormError(r.db.Create(event).Error, "create event")
+}
+
+func (r *gormEventRepository) Save(event *models.Event) error {
+	return gormError(r.db.Save(event).Error, "save event")
+}
+
+func (r *gormEventRepository) GetByID(id uint) (*models.Event, error) {
+	var event models.Event
+	if err := r.db.First(&event, id).Error; err != nil {
+		return nil, gormError(err, "get event")
+	}
+	return &event, nil
+}
+
+func (r *gormEventRepository) GetByPCOID(pcoEventID string) (*models.Event, error) {
+	var event models.Event
+	if err := r.db.Where("pco_event_id = ?", pcoEventID).First(&event).Error; err != nil {
+		return nil, gormError(err, "get event")
+	}
+	return &event, nil
+}
+
+func (r *gormEventRepository) List(filter EventFilter) ([]models.Event, error) {
+	query := r.db.Model(&models.Event{})
+	if filter.LocationID != "" {
+		query = query.Where("location_id = ?", filter.LocationID)
+	}
+	if filter.Recurring {
+		query = query.Where("rrule <> ''")
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("start_time >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("start_time < ?", filter.To)
+	}
+
+	var events []models.Event
+	if err := query.Order("start_time, id").Find(&events).Error; err != nil {
+		return nil, gormError(err, "list events")
+	}
+	return events, nil
+}
+
+// Delete removes the event with its periods and times
+func (r *gormEventRepository) Delete(id uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("event_id = ?", id).Delete(&models.EventTime{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id = ?", id).Delete(&models.EventPeriod{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.Event{}, id).Error
+	})
+	return gormError(err, "delete event")
+}
+
+func (r *gormEventRepository) SavePeriod(period *models.EventPeriod) error {
+	var existing models.EventPeriod
+	err := r.db.Where("pco_event_period_id = ?", period.PCOEventPeriodID).First(&existing).Error
+	switch {
+	case err == nil:
+		period.ID = existing.ID
+		period.CreatedAt = existing.CreatedAt
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return gormError(err, "get event period")
+	}
+	return gormError(r.db.Save(period).Error, "save event period")
+}
+
+func (r *gormEventRepository) ListPeriods(filter EventPeriodFilter) ([]models.EventPeriod, error) {
+	query := r.db.Model(&models.EventPeriod{})
+	if filter.EventID != 0 {
+		query = query.Where("event_id = ?", filter.EventID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("starts_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("starts_at < ?", filter.To)
+	}
+
+	var periods []models.EventPeriod
+	if err := query.Order("starts_at, id").Find(&periods).Error; err != nil {
+		return nil, gormError(err, "list event periods")
+	}
+	return periods, nil
+}
+
+func (r *gormEventRepository) SaveTime(eventTime *models.EventTime) error {
+	var existing models.EventTime
+	err := r.db.Where("pco_event_time_id = ?", eventTime.PCOEventTimeID).First(&existing).Error
+	switch {
+	case err == nil:
+		eventTime.ID = existing.ID
+		eventTime.CreatedAt = existing.CreatedAt
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return gormError(err, "get event time")
+	}
+	return gormError(r.db.Save(eventTime).Error, "save event time")
+}
+
+func (r *gormEventRepository) ListTimes(periodIDs []uint) ([]models.EventTime, error) {
+	times := []models.EventTime{}
+	if len(periodIDs) == 0 {
+		return times, nil
+	}
+	if err := r.db.Where("event_period_id IN ?", periodIDs).Order("starts_at, id").Find(&times).Error; err != nil {
+		return nil, gormError(err, "list event times")
+	}
+	return times, nil
+}
+
+type gormBillboardStateRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormBillboardStateRepository) Create(state *models.BillboardState) error {
+	return gormError(r.db.Create(state).Error, "create billboard state")
+}
+
+func (r *gormBillboardStateRepository) Save(state *models.BillboardState) error {
+	return gormError(r.db.Omit("Event").Save(state).Error, "save billboard state")
+}
+
+func (r *gormBillboardStateRepository) GetByLocation(locationID string) (*models.BillboardState, error) {
+	var state models.BillboardState
+	if err := r.db.Where("location_id = ?", locationID).Order("id DESC").First(&state).Error; err != nil {
+		return nil, gormError(err, "get billboard state")
+	}
+	return &state, nil
+}
+
+func (r *gormBillboardStateRepository) GetActive() (*models.BillboardState, error) {
+	var state models.BillboardState
+	if err := r.db.Where("is_active = ?", true).Order("id DESC").First(&state).Error; err != nil {
+		return nil, gormError(err, "get active billboard")
+	}
+	return &state, nil
+}
+
+func (r *gormBillboardStateRepository) active(locationID string) *gorm.DB {
+	query := r.db.Model(&models.BillboardState{}).Where("is_active = ?", true)
+	if locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+	return query
+}
+
+func (r *gormBillboardStateRepository) ListActive(locationID string) ([]models.BillboardState, error) {
+	var states []models.BillboardState
+	if err := r.active(locationID).Order("id DESC").Find(&states).Error; err != nil {
+		return nil, gormError(err, "list active billboards")
+	}
+	return states, nil
+}
+
+func (r *gormBillboardStateRepository) Deactivate(locationID string) (int64, error) {
+	result := r.active(locationID).Update("is_active", false)
+	if result.Error != nil {
+		return 0, gormError(result.Error, "deactivate billboards")
+	}
+	return result.RowsAffected, nil
+}
+
+type gormSecurityCodeRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormSecurityCodeRepository) Create(code *models.SecurityCode) error {
+	return gormError(r.db.Create(code).Error, "create security code")
+}
+
+func (r *gormSecurityCodeRepository) GetByCode(code string) (*models.SecurityCode, error) {
+	var securityCode models.SecurityCode
+	if err := r.db.Where("code = ?", code).First(&securityCode).Error; err != nil {
+		return nil, gormError(err, "get security code")
+	}
+	return &securityCode, nil
+}
+
+func (r *gormSecurityCodeRepository) ListActive() ([]models.SecurityCode, error) {
+	var codes []models.SecurityCode
+	if err := r.db.Where("is_active = ?", true).Order("id").Find(&codes).Error; err != nil {
+		return nil, gormError(err, "list security codes")
+	}
+	return codes, nil
+}
+
+func (r *gormSecurityCodeRepository) Delete(id uint) error {
+	return gormError(r.db.Unscoped().Delete(&models.SecurityCode{}, id).Error, "delete security code")
+}
@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"time"
+
+	"go_pco_arrivals/internal/database"
+	"go_pco_arrivals/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormUserRepository) Create(user *models.User) error {
+	return gormError(r.db.Create(user).Error, "create user")
+}
+
+func (r *gormUserRepository) Save(user *models.User) error {
+	return gormError(r.db.Save(user).Error, "save user")
+}
+
+func (r *gormUserRepository) GetByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, gormError(err, "get user")
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) GetByPCOUserID(pcoUserID string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("pco_user_id = ?", pcoUserID).First(&user).Error; err != nil {
+		return nil, gormError(err, "get user")
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) List() ([]models.User, error) {
+	var users []models.User
+	if err := r.db.Order("name").Find(&users).Error; err != nil {
+		return nil, gormError(err, "list users")
+	}
+	return users, nil
+}
+
+func (r *gormUserRepository) SetAdmin(id uint, isAdmin bool) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", id).Update("is_admin", isAdmin)
+	return rowsAffected(result, "update user role")
+}
+
+func (r *gormUserRepository) SetMedicalAccess(id uint, allowed bool) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", id).Update("medical_access", allowed)
+	return rowsAffected(result, "update medical access")
+}
+
+func (r *gormUserRepository) SetContactAccess(id uint, allowed bool) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", id).Update("contact_access", allowed)
+	return rowsAffected(result, "update contact access")
+}
+
+func (r *gormUserRepository) GetByCalendarToken(hash string) (*models.User, error) {
+	if hash == "" {
+		return nil, ErrNotFound
+	}
+	var user models.User
+	if err := r.db.Where("calendar_token_hash = ?", hash).First(&user).Error; err != nil {
+		return nil, gormError(err, "get user")
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) SetCalendarToken(id uint, hash string) error {
+	result := r.db.Model(&models.User{}).Where("id = ?", id).Update("calendar_token_hash", hash)
+	return rowsAffected(result, "update calendar token")
+}
+
+func (r *gormUserRepository) TouchLastActivity(id uint, at time.Time) error {
+	return gormError(r.db.Model(&models.User{}).Where("id = ?", id).UpdateColumn("last_activity", at).Error, "update user activity")
+}
+
+func (r *gormUserRepository) ReencryptTokens() (int, error) {
+	return database.ReencryptUserTokens(r.db)
+}
+
+type gormSessionRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormSessionRepository) Create(session *models.Session) error {
+	return gormError(r.db.Create(session).Error, "create session")
+}
+
+func (r *gormSessionRepository) GetByToken(token string) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.Preload("User").Where("token = ?", token).First(&session).Error; err != nil {
+		return nil, gormError(err, "get session")
+	}
+	return &session, nil
+}
+
+func (r *gormSessionRepository) GetByID(id uint) (*models.Session, error) {
+	var session models.Session
+	if err := r.db.First(&session, id).Error; err != nil {
+		return nil, gormError(err, "get session")
+	}
+	return &session, nil
+}
+
+func (r *gormSessionRepository) query(filter SessionFilter) *gorm.DB {
+	query := r.db.Model(&models.Session{})
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if !filter.ActiveAt.IsZero() {
+		query = query.Where("expires_at > ?", filter.ActiveAt)
+	}
+	if !filter.IdleBefore.IsZero() {
+		query = query.Where("(is_remember_me = ? OR last_activity > ?)", true, filter.IdleBefore)
+	}
+	return query
+}
+
+func (r *gormSessionRepository) List(filter SessionFilter) ([]models.Session, error) {
+	query := r.query(filter)
+	if filter.WithUser {
+		query = query.Preload("User")
+	}
+
+	var sessions []models.Session
+	if err := query.Order("last_activity DESC").Find(&sessions).Error; err != nil {
+		return nil, gormError(err, "list sessions")
+	}
+	return sessions, nil
+}
+
+func (r *gormSessionRepository) Count(filter SessionFilter) (int64, error) {
+	var count int64
+	if err := r.query(filter).Count(&count).Error; err != nil {
+		return 0, gormError(err, "count sessions")
+	}
+	return count, nil
+}
+
+func (r *gormSessionRepository) TouchActivity(id uint, at time.Time) error {
+	return gormError(r.db.Model(&models.Session{}).Where("id = ?", id).UpdateColumn("last_activity", at).Error, "update session activity")
+}
+
+func (r *gormSessionRepository) Delete(id uint) error {
+	return gormError(r.db.Unscoped().Delete(&models.Session{}, id).Error, "delete session")
+}
+
+func (r *gormSessionRepository) DeleteByToken(token string) error {
+	return gormError(r.db.Unscoped().Where("token = ?", token).Delete(&models.Session{}).Error, "delete session")
+}
+
+func (r *gormSessionRepository) DeleteByUser(userID, exceptID uint) error {
+	query := r.db.Unscoped().Where("user_id = ?", userID)
+	if exceptID != 0 {
+		query = query.Where("id <> ?", exceptID)
+	}
+	return gormError(query.Delete(&models.Session{}).Error, "delete user sessions")
+}
+
+func (r *gormSessionRepository) DeleteExpired(now, idleBefore time.Time) (int64, error) {
+	query := r.db.Unscoped().Where("expires_at < ?", now)
+	if !idleBefore.IsZero() {
+		query = query.Or("is_remember_me = ? AND last_activity < ?", false, idleBefore)
+	}
+
+	result := query.Delete(&models.Session{})
+	if result.Error != nil {
+		return 0, gormError(result.Error, "delete expired sessions")
+	}
+	return result.RowsAffected, nil
+}
+
+type gormAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAPIKeyRepository) Create(key *models.APIKey) error {
+	return gormError(r.db.Create(key).Error, "create API key")
+}
+
+func (r *gormAPIKeyRepository) List() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := r.db.Order("created_at DESC, id DESC").Find(&keys).Error; err != nil {
+		return nil, gormError(err, "list API keys")
+	}
+	return keys, nil
+}
+
+func (r *gormAPIKeyRepository) GetByID(id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.Preload("User").First(&key, id).Error; err != nil {
+		return nil, gormError(err, "get API key")
+	}
+	return &key, nil
+}
+
+func (r *gormAPIKeyRepository) GetByHash(keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.Preload("User").Where("key_hash = ?", keyHash).First(&key).Error; err != nil {
+		return nil, gormError(err, "get API key")
+	}
+	return &key, nil
+}
+
+func (r *gormAPIKeyRepository) Revoke(id uint, at time.Time) error {
+	result := r.db.Model(&models.APIKey{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", at)
+	return gormError(result.Error, "revoke API key")
+}
+
+func (r *gormAPIKeyRepository) RecordUse(id uint, at time.Time, ipAddress string) error {
+	result := r.db.Model(&models.APIKey{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"last_used_at": at,
+		"last_used_ip": ipAddress,
+	})
+	return gormError(result.Error, "record API key use")
+}
+
+type gormAuditRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAuditRepository) Create(event *models.AuditEvent) error {
+	return gormError(r.db.Create(event).Error, "record audit event")
+}
+
+func (r *gormAuditRepository) List(filter AuditFilter) ([]models.AuditEvent, int64, error) {
+	query := r.db.Model(&models.AuditEvent{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, gormError(err, "count audit events")
+	}
+
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	var events []models.AuditEvent
+	if err := query.Order("created_at DESC, id DESC").Find(&events).Error; err != nil {
+		return nil, 0, gormError(err, "list audit events")
+	}
+	return events, total, nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+)
+
+// NewMemoryStore returns an empty store held in process memory. It is safe
+// for concurrent use and is meant for demo mode and tests; nothing survives
+// a restart.
+func NewMemoryStore() *Store {
+	m := &memoryBackend{
+		users:           newMemoryTable(func(u *models.User) *uint { return &u.ID }, func(u models.User) string { return u.PCOUserID }, func(u models.User) string { return u.Email }),
+		sessions:        newMemoryTable(func(s *models.Session) *uint { return &s.ID }, func(s models.Session) string { return s.Token }),
+		apiKeys:         newMemoryTable(func(k *models.APIKey) *uint { return &k.ID }, func(k models.APIKey) string { return k.KeyHash }),
+		auditEvents:     newMemoryTable(func(e *models.AuditEvent) *uint { return &e.ID }),
+		checkIns:        newMemoryTable(func(c *models.CheckIn) *uint { return &c.ID }, func(c models.CheckIn) string { return c.PCOCheckInID }),
+		notifications:   newMemoryTable(func(n *models.Notification) *uint { return &n.ID }, func(n models.Notification) string { return n.PCOCheckInID }),
+		locations:       newMemoryTable(func(l *models.Location) *uint { return &l.ID }, func(l models.Location) string { return l.PCOLocationID }),
+		events:          newMemoryTable(func(e *models.Event) *uint { return &e.ID }, func(e models.Event) string { return e.PCOEventID }),
+		billboardStates: newMemoryTable(func(s *models.BillboardState) *uint { return &s.ID }),
+		securityCodes:   newMemoryTable(func(c *models.SecurityCode) *uint { return &c.ID }, func(c models.SecurityCode) string { return c.Code }),
+		eventPeriods:    newMemoryTable(func(p *models.EventPeriod) *uint { return &p.ID }, func(p models.EventPeriod) string { return p.PCOEventPeriodID }),
+		eventTimes:      newMemoryTable(func(t *models.EventTime) *uint { return &t.ID }, func(t models.EventTime) string { return t.PCOEventTimeID }),
+		rollups: newMemoryTable(func(a *models.AttendanceRollup) *uint { return &a.ID }, func(a models.AttendanceRollup) string {
+			return a.Period + "|" + a.PeriodStart.UTC().Format(time.RFC3339) + "|" + a.LocationID + "|" + a.EventID
+		}),
+		visitors: newMemoryTable(func(v *models.Visitor) *uint { return &v.ID }, func(v models.Visitor) string { return v.PersonID }),
+	}
+
+	return &Store{
+		Users:           &memoryUserRepository{m},
+		Sessions:        &memorySessionRepository{m},
+		APIKeys:         &memoryAPIKeyRepository{m},
+		AuditEvents:     &memoryAuditRepository{m},
+		CheckIns:        &memoryCheckInRepository{m},
+		Notifications:   &memoryNotificationRepository{m},
+		Locations:       &memoryLocationRepository{m},
+		Events:          &memoryEventRepository{m},
+		BillboardStates: &memoryBillboardStateRepository{m},
+		SecurityCodes:   &memorySecurityCodeRepository{m},
+		Rollups:         &memoryRollupRepository{m},
+		Visitors:        &memoryVisitorRepository{m},
+	}
+}
+
+// memoryBackend holds every table behind one lock, so reads that join
+// tables (sessions with their user) see a consistent snapshot
+type memoryBackend struct {
+	mu sync.RWMutex
+
+	users           *memoryTable[models.User]
+	sessions        *memoryTable[models.Session]
+	apiKeys         *memoryTable[models.APIKey]
+	auditEvents     *memoryTable[models.AuditEvent]
+	checkIns        *memoryTable[models.CheckIn]
+	notifications   *memoryTable[models.Notification]
+	locations       *memoryTable[models.Location]
+	events          *memoryTable[models.Event]
+	billboardStates *memoryTable[models.BillboardState]
+	securityCodes   *memoryTable[models.SecurityCode]
+	eventPeriods    *memoryTable[models.EventPeriod]
+	eventTimes      *memoryTable[models.EventTime]
+	rollups         *memoryTable[models.AttendanceRollup]
+	visitors        *memoryTable[models.Visitor]
+}
+
+// memoryTable stores rows by ID and enforces unique keys. Rows are stored
+// and returned by value so callers never share memory with the table.
+type memoryTable[T any] struct {
+	rows   map[uint]T
+	nextID uint
+	id     func(row *T) *uint
+	unique []func(row T) string
+}
+
+func newMemoryTable[T any](id func(row *T) *uint, unique ...func(row T) string) *memoryTable[T] {
+	return &memoryTable[T]{rows: make(map[uint]T), id: id, unique: unique}
+}
+
+// checkUnique returns ErrDuplicate if another row shares a unique key with row
+func (t *memoryTable[T]) checkUnique(row T, self uint) error {
+	for _, key := range t.unique {
+		value := key(row)
+		for id, existing := range t.rows {
+			if id != self && key(existing) == value {
+				return ErrDuplicate
+			}
+		}
+	}
+	return nil
+}
+
+// insert assigns the next ID to row and stores it
+func (t *memoryTable[T]) insert(row *T) error {
+	if err := t.checkUnique(*row, 0); err != nil {
+		return err
+	}
+	t.nextID++
+	*t.id(row) = t.nextID
+	t.rows[t.nextID] = *row
+	return nil
+}
+
+// put inserts row if it has no ID and otherwise replaces the stored row
+func (t *memoryTable[T]) put(row *T) error {
+	id := *t.id(row)
+	if id == 0 {
+		return t.insert(row)
+	}
+	if err := t.checkUnique(*row, id); err != nil {
+		return err
+	}
+	if id > t.nextID {
+		t.nextID = id
+	}
+	t.rows[id] = *row
+	return nil
+}
+
+func (t *memoryTable[T]) get(id uint) (T, error) {
+	row, ok := t.rows[id]
+	if !ok {
+		return row, ErrNotFound
+	}
+	return row, nil
+}
+
+// find returns the rows accepted by match in ID order
+func (t *memoryTable[T]) find(match func(row T) bool) []T {
+	ids := make([]uint, 0, len(t.rows))
+	for id, row := range t.rows {
+		if match == nil || match(row) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	rows := make([]T, len(ids))
+	for i, id := range ids {
+		rows[i] = t.rows[id]
+	}
+	return rows
+}
+
+// first returns the lowest-ID row accepted by match
+func (t *memoryTable[T]) first(match func(row T) bool) (T, error) {
+	rows := t.find(match)
+	if len(rows) == 0 {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return rows[0], nil
+}
+
+func (t *memoryTable[T]) count(match func(row T) bool) int64 {
+	var count int64
+	for _, row := range t.rows {
+		if match == nil || match(row) {
+			count++
+		}
+	}
+	return count
+}
+
+// update applies change to every row accepted by match and returns how many
+// rows were changed
+func (t *memoryTable[T]) update(match func(row T) bool, change func(row *T)) int64 {
+	var updated int64
+	for id, row := range t.rows {
+		if match(row) {
+			change(&row)
+			t.rows[id] = row
+			updated++
+		}
+	}
+	return updated
+}
+
+func (t *memoryTable[T]) remove(match func(row T) bool) int64 {
+	var removed int64
+	for id, row := range t.rows {
+		if match(row) {
+			delete(t.rows, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// byID matches the row with the given ID
+func byID[T any](t *memoryTable[T], id uint) func(row T) bool {
+	return func(row T) bool { return *t.id(&row) == id }
+}
+
+// limit truncates rows to n when n is positive
+func limit[T any](rows []T, n int) []T {
+	if n > 0 && len(rows) > n {
+		return rows[:n]
+	}
+	return rows
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+)
+
+type memoryRollupRepository struct {
+	*memoryBackend
+}
+
+func (r *memoryRollupRepository) Replace(since, until time.Time, rollups []models.AttendanceRollup) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	removed := r.rollups.find(func(a models.AttendanceRollup) bool {
+		return !a.PeriodStart.Before(since) && a.PeriodStart.Before(until)
+	})
+	for _, rollup := range removed {
+		r.rollups.remove(byID(r.rollups, rollup.ID))
+	}
+
+	rows := stampRollups(rollups, time.Now())
+	for i := range rows {
+		if err := r.rollups.insert(&rows[i]); err != nil {
+			// Put the range back as it was
+			for _, row := range rows[:i] {
+				r.rollups.remove(byID(r.rollups, row.ID))
+			}
+			for _, rollup := range removed {
+				r.rollups.put(&rollup)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryRollupRepository) List(filter RollupFilter) ([]models.AttendanceRollup, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rollups := r.rollups.find(func(a models.AttendanceRollup) bool {
+		switch {
+		case filter.Period != "" && a.Period != filter.Period,
+			filter.LocationID != "" && a.LocationID != filter.LocationID,
+			filter.EventID != "" && a.EventID != filter.EventID,
+			!filter.Since.IsZero() && a.PeriodStart.Before(filter.Since),
+			!filter.Until.IsZero() && !a.PeriodStart.Before(filter.Until):
+			return false
+		}
+		return true
+	})
+	sort.SliceStable(rollups, func(i, j int) bool {
+		a, b := rollups[i], rollups[j]
+		if !a.PeriodStart.Equal(b.PeriodStart) {
+			return a.PeriodStart.Before(b.PeriodStart)
+		}
+		if a.LocationID != b.LocationID {
+			return a.LocationID < b.LocationID
+		}
+		return a.EventID < b.EventID
+	})
+	return rollups, nil
+}
+
+type memoryVisitorRepository struct {
+	*memoryBackend
+}
+
+func (r *memoryVisitorRepository) Record(visitors []models.Visitor) ([]models.Visitor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wanted := make(map[string]bool)
+	for _, id := range visitorPersonIDs(visitors) {
+		wanted[id] = true
+	}
+	existing := r.visitors.find(func(v models.Visitor) bool { return wanted[v.PersonID] })
+
+	write, moved := planVisitors(visitors, existing, time.Now())
+	for i := range write {
+		if err := r.visitors.put(&write[i]); err != nil {
+			return nil, err
+		}
+	}
+	return moved, nil
+}
+
+func (r *memoryVisitorRepository) List(filter VisitorFilter) ([]models.Visitor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	visitors := r.visitors.find(func(v models.Visitor) bool {
+		switch {
+		case filter.LocationID != "" && v.LocationID != filter.LocationID,
+			!filter.Since.IsZero() && v.FirstCheckInAt.Before(filter.Since),
+			!filter.Until.IsZero() && !v.FirstCheckInAt.Before(filter.Until):
+			return false
+		}
+		return true
+	})
+	sort.SliceStable(visitors, func(i, j int) bool { return visitors[i].FirstCheckInAt.Before(visitors[j].FirstCheckInAt) })
+	return visitors, nil
+}
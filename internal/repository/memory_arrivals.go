@@ -0,0 +1,598 @@
+package repository
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+)
+
+type memoryCheckInRepository struct {
+	*memoryBackend
+}
+
+func (r *memoryCheckInRepository) Create(checkIn *models.CheckIn) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&checkIn.CreatedAt, &checkIn.UpdatedAt)
+	if checkIn.Status == "" {
+		checkIn.Status = "active"
+	}
+	if checkIn.Kind == "" {
+		checkIn.Kind = models.CheckInKindRegular
+	}
+	return r.checkIns.insert(checkIn)
+}
+
+func (r *memoryCheckInRepository) Upsert(checkIns []models.CheckIn) (*UpsertResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &UpsertResult{Inserted: make(map[string]bool)}
+	rows, ids := prepareUpsert(checkIns, result)
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	existing := r.checkIns.find(func(c models.CheckIn) bool { return wanted[c.PCOCheckInID] })
+
+	plan := planUpsert(rows, existing, time.Now(), result)
+	for _, checkIn := range plan.create {
+		if err := r.checkIns.insert(&checkIn); err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		result.Created++
+		result.Inserted[checkIn.PCOCheckInID] = true
+		result.Changed = append(result.Changed, checkIn)
+	}
+	for _, checkIn := range plan.update {
+		if err := r.checkIns.put(&checkIn); err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, err)
+			continue
+		}
+		result.Updated++
+		result.Changed = append(result.Changed, checkIn)
+	}
+	sort.SliceStable(result.Changed, func(i, j int) bool { return result.Changed[i].ID < result.Changed[j].ID })
+	return result, nil
+}
+
+func (r *memoryCheckInRepository) GetByPCOID(pcoCheckInID string) (*models.CheckIn, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	checkIn, err := r.checkIns.first(func(c models.CheckIn) bool { return c.PCOCheckInID == pcoCheckInID })
+	if err != nil {
+		return nil, err
+	}
+	return &checkIn, nil
+}
+
+func (r *memoryCheckInRepository) match(filter CheckInFilter) func(models.CheckIn) bool {
+	return func(c models.CheckIn) bool {
+		switch {
+		case filter.LocationID != "" && c.LocationID != filter.LocationID,
+			len(filter.LocationIDs) > 0 && !slices.Contains(filter.LocationIDs, c.LocationID),
+			filter.SecurityCode != "" && c.SecurityCode != filter.SecurityCode,
+			filter.Kind != "" && c.Kind != filter.Kind,
+			filter.PersonID != "" && c.PersonID != filter.PersonID,
+			filter.Present && c.CheckedOutAt != nil,
+			!filter.Since.IsZero() && c.CheckInTime.Before(filter.Since),
+			!filter.Until.IsZero() && !c.CheckInTime.Before(filter.Until):
+			return false
+		}
+		return true
+	}
+}
+
+func (r *memoryCheckInRepository) List(filter CheckInFilter) ([]models.CheckIn, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	checkIns := r.checkIns.find(r.match(filter))
+	sort.SliceStable(checkIns, func(i, j int) bool { return checkIns[i].CheckInTime.After(checkIns[j].CheckInTime) })
+	return limit(checkIns, filter.Limit), nil
+}
+
+func (r *memoryCheckInRepository) Count(filter CheckInFilter) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.checkIns.count(r.match(filter)), nil
+}
+
+func (r *memoryCheckInRepository) DeleteBefore(cutoff time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.checkIns.remove(func(c models.CheckIn) bool { return c.CheckInTime.Before(cutoff) }), nil
+}
+
+func (r *memoryCheckInRepository) DailyCounts(locationID string, since, until time.Time) ([]DailyCount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := map[string]int64{}
+	for _, c := range r.checkIns.find(func(c models.CheckIn) bool {
+		return c.LocationID == locationID && !c.CheckInTime.Before(since) && !c.CheckInTime.After(until)
+	}) {
+		counts[c.CheckInTime.UTC().Format("2006-01-02")]++
+	}
+
+	daily := make([]DailyCount, 0, len(counts))
+	for date, count := range counts {
+		daily = append(daily, DailyCount{Date: date, Count: count})
+	}
+	sort.Slice(daily, func(i, j int) bool { return daily[i].Date < daily[j].Date })
+	return daily, nil
+}
+
+func (r *memoryCheckInRepository) HourlyCounts(locationID string, since time.Time) ([]HourlyCount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := map[int]int64{}
+	for _, c := range r.checkIns.find(func(c models.CheckIn) bool {
+		return c.LocationID == locationID && !c.CheckInTime.Before(since)
+	}) {
+		counts[c.CheckInTime.UTC().Hour()]++
+	}
+
+	hourly := make([]HourlyCount, 0, len(counts))
+	for hour, count := range counts {
+		hourly = append(hourly, HourlyCount{Hour: hour, Count: count})
+	}
+	sort.Slice(hourly, func(i, j int) bool {
+		if hourly[i].Count != hourly[j].Count {
+			return hourly[i].Count > hourly[j].Count
+		}
+		return hourly[i].Hour < hourly[j].Hour
+	})
+	return hourly, nil
+}
+
+func (r *memoryCheckInRepository) AverageWaitMinutes(locationID string, since time.Time) (float64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var total float64
+	var matched int
+	for _, c := range r.checkIns.find(func(c models.CheckIn) bool {
+		return c.LocationID == locationID && !c.CheckInTime.Before(since)
+	}) {
+		for _, n := range r.notifications.find(func(n models.Notification) bool { return n.PCOCheckInID == c.PCOCheckInID }) {
+			total += n.CreatedAt.Sub(c.CheckInTime).Minutes()
+			matched++
+		}
+	}
+
+	if matched == 0 {
+		return 0, nil
+	}
+	return total / float64(matched), nil
+}
+
+type memoryNotificationRepository struct {
+	*memoryBackend
+}
+
+func (r *memoryNotificationRepository) Create(notification *models.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&notification.CreatedAt, &notification.UpdatedAt)
+	if notification.Status == "" {
+		notification.Status = "active"
+	}
+	stored := *notification
+	stored.Event = models.Event{}
+	if err := r.notifications.insert(&stored); err != nil {
+		return err
+	}
+	notification.ID = stored.ID
+	return nil
+}
+
+func (r *memoryNotificationRepository) GetByCheckInID(pcoCheckInID string) (*models.Notification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	notification, err := r.notifications.first(func(n models.Notification) bool { return n.PCOCheckInID == pcoCheckInID })
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (r *memoryNotificationRepository) match(filter NotificationFilter) func(models.Notification) bool {
+	return func(n models.Notification) bool {
+		switch {
+		case filter.Status != "" && n.Status != filter.Status,
+			filter.LocationName != "" && n.LocationName != filter.LocationName,
+			!filter.ActiveAt.IsZero() && !n.ExpiresAt.After(filter.ActiveAt),
+			!filter.ExpiredBefore.IsZero() && !n.ExpiresAt.Before(filter.ExpiredBefore),
+			!filter.CreatedSince.IsZero() && n.CreatedAt.Before(filter.CreatedSince):
+			return false
+		}
+		return true
+	}
+}
+
+func (r *memoryNotificationRepository) List(filter NotificationFilter) ([]models.Notification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	notifications := r.notifications.find(r.match(filter))
+	sort.SliceStable(notifications, func(i, j int) bool { return notifications[i].CreatedAt.After(notifications[j].CreatedAt) })
+	return notifications, nil
+}
+
+func (r *memoryNotificationRepository) Count(filter NotificationFilter) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.notifications.count(r.match(filter)), nil
+}
+
+func (r *memoryNotificationRepository) UpdateStatus(id uint, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.notifications.update(byID(r.notifications, id), func(n *models.Notification) {
+		n.Status = status
+		n.UpdatedAt = time.Now()
+	}) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryNotificationRepository) ExpireActive(now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.notifications.update(func(n models.Notification) bool {
+		return n.Status == "active" && !n.ExpiresAt.After(now)
+	}, func(n *models.Notification) {
+		n.Status = "expired"
+		n.UpdatedAt = time.Now()
+	}), nil
+}
+
+func (r *memoryNotificationRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.notifications.remove(byID(r.notifications, id))
+	return nil
+}
+
+func (r *memoryNotificationRepository) DeleteByCheckInID(pcoCheckInID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.notifications.remove(func(n models.Notification) bool { return n.PCOCheckInID == pcoCheckInID })
+	return nil
+}
+
+type memoryLocationRepository struct {
+	*memoryBackend
+}
+
+func (r *memoryLocationRepository) Create(location *models.Location) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&location.CreatedAt, &location.UpdatedAt)
+	stored := *location
+	stored.CheckIns = nil
+	if err := r.locations.insert(&stored); err != nil {
+		return err
+	}
+	location.ID = stored.ID
+	return nil
+}
+
+func (r *memoryLocationRepository) Save(location *models.Location) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&location.CreatedAt, &location.UpdatedAt)
+	stored := *location
+	stored.CheckIns = nil
+	if err := r.locations.put(&stored); err != nil {
+		return err
+	}
+	location.ID = stored.ID
+	return nil
+}
+
+func (r *memoryLocationRepository) GetByPCOID(pcoLocationID string) (*models.Location, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	location, err := r.locations.first(func(l models.Location) bool { return l.PCOLocationID == pcoLocationID })
+	if err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+func (r *memoryLocationRepository) List() ([]models.Location, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.locations.find(nil), nil
+}
+
+type memoryEventRepository struct {
+	*memoryBackend
+}
+
+// stored strips the relationships, which the SQL backends keep in other tables
+func (r *memoryEventRepository) stored(event *models.Event) models.Event {
+	stored := *event
+	stored.Notifications = nil
+	stored.CheckIns = nil
+	return stored
+}
+
+func (r *memoryEventRepository) Create(event *models.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&event.CreatedAt, &event.UpdatedAt)
+	stored := r.stored(event)
+	if err := r.events.insert(&stored); err != nil {
+		return err
+	}
+	event.ID = stored.ID
+	return nil
+}
+
+func (r *memoryEventRepository) Save(event *models.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&event.CreatedAt, &event.UpdatedAt)
+	stored := r.stored(event)
+	if err := r.events.put(&stored); err != nil {
+		return err
+	}
+	event.ID = stored.ID
+	return nil
+}
+
+func (r *memoryEventRepository) GetByID(id uint) (*models.Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	event, err := r.events.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *memoryEventRepository) GetByPCOID(pcoEventID string) (*models.Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	event, err := r.events.first(func(e models.Event) bool { return e.PCOEventID == pcoEventID })
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *memoryEventRepository) List(filter EventFilter) ([]models.Event, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := r.events.find(func(e models.Event) bool {
+		switch {
+		case filter.LocationID != "" && e.LocationID != filter.LocationID,
+			filter.Recurring && e.RRule == "",
+			!filter.From.IsZero() && e.StartTime.Before(filter.From),
+			!filter.To.IsZero() && !e.StartTime.Before(filter.To):
+			return false
+		}
+		return true
+	})
+	sort.SliceStable(events, func(i, j int) bool { return events[i].StartTime.Before(events[j].StartTime) })
+	return events, nil
+}
+
+// Delete removes the event with its periods and times
+func (r *memoryEventRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.eventTimes.remove(func(t models.EventTime) bool { return t.EventID == id })
+	r.eventPeriods.remove(func(p models.EventPeriod) bool { return p.EventID == id })
+	r.events.remove(byID(r.events, id))
+	return nil
+}
+
+func (r *memoryEventRepository) SavePeriod(period *models.EventPeriod) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, err := r.eventPeriods.first(func(p models.EventPeriod) bool { return p.PCOEventPeriodID == period.PCOEventPeriodID }); err == nil {
+		period.ID = existing.ID
+		period.CreatedAt = existing.CreatedAt
+	}
+	stamp(&period.CreatedAt, &period.UpdatedAt)
+	return r.eventPeriods.put(period)
+}
+
+func (r *memoryEventRepository) ListPeriods(filter EventPeriodFilter) ([]models.EventPeriod, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	periods := r.eventPeriods.find(func(p models.EventPeriod) bool {
+		switch {
+		case filter.EventID != 0 && p.EventID != filter.EventID,
+			!filter.From.IsZero() && p.StartsAt.Before(filter.From),
+			!filter.To.IsZero() && !p.StartsAt.Before(filter.To):
+			return false
+		}
+		return true
+	})
+	sort.SliceStable(periods, func(i, j int) bool { return periods[i].StartsAt.Before(periods[j].StartsAt) })
+	return periods, nil
+}
+
+func (r *memoryEventRepository) SaveTime(eventTime *models.EventTime) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, err := r.eventTimes.first(func(t models.EventTime) bool { return t.PCOEventTimeID == eventTime.PCOEventTimeID }); err == nil {
+		eventTime.ID = existing.ID
+		eventTime.CreatedAt = existing.CreatedAt
+	}
+	stamp(&eventTime.CreatedAt, &eventTime.UpdatedAt)
+	return r.eventTimes.put(eventTime)
+}
+
+func (r *memoryEventRepository) ListTimes(periodIDs []uint) ([]models.EventTime, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[uint]bool, len(periodIDs))
+	for _, id := range periodIDs {
+		wanted[id] = true
+	}
+	times := r.eventTimes.find(func(t models.EventTime) bool { return wanted[t.EventPeriodID] })
+	sort.SliceStable(times, func(i, j int) bool { return times[i].StartsAt.Before(times[j].StartsAt) })
+	return times, nil
+}
+
+type memoryBillboardStateRepository struct {
+	*memoryBackend
+}
+
+// clone copies a billboard state without sharing its security code slice
+func (r *memoryBillboardStateRepository) clone(state models.BillboardState) models.BillboardState {
+	state.SecurityCodes = append([]string(nil), state.SecurityCodes...)
+	state.Event = models.Event{}
+	return state
+}
+
+func (r *memoryBillboardStateRepository) Create(state *models.BillboardState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&state.CreatedAt, &state.UpdatedAt)
+	stored := r.clone(*state)
+	if err := r.billboardStates.insert(&stored); err != nil {
+		return err
+	}
+	state.ID = stored.ID
+	return nil
+}
+
+func (r *memoryBillboardStateRepository) Save(state *models.BillboardState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&state.CreatedAt, &state.UpdatedAt)
+	stored := r.clone(*state)
+	if err := r.billboardStates.put(&stored); err != nil {
+		return err
+	}
+	state.ID = stored.ID
+	return nil
+}
+
+// latest returns the newest state accepted by match
+func (r *memoryBillboardStateRepository) latest(match func(models.BillboardState) bool) (*models.BillboardState, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	states := r.billboardStates.find(match)
+	if len(states) == 0 {
+		return nil, ErrNotFound
+	}
+	state := r.clone(states[len(states)-1])
+	return &state, nil
+}
+
+func (r *memoryBillboardStateRepository) GetByLocation(locationID string) (*models.BillboardState, error) {
+	return r.latest(func(s models.BillboardState) bool { return s.LocationID == locationID })
+}
+
+func (r *memoryBillboardStateRepository) GetActive() (*models.BillboardState, error) {
+	return r.latest(func(s models.BillboardState) bool { return s.IsActive })
+}
+
+func (r *memoryBillboardStateRepository) active(locationID string) func(models.BillboardState) bool {
+	return func(s models.BillboardState) bool {
+		return s.IsActive && (locationID == "" || s.LocationID == locationID)
+	}
+}
+
+func (r *memoryBillboardStateRepository) ListActive(locationID string) ([]models.BillboardState, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := r.billboardStates.find(r.active(locationID))
+	states := make([]models.BillboardState, 0, len(found))
+	for i := len(found) - 1; i >= 0; i-- {
+		states = append(states, r.clone(found[i]))
+	}
+	return states, nil
+}
+
+func (r *memoryBillboardStateRepository) Deactivate(locationID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.billboardStates.update(r.active(locationID), func(s *models.BillboardState) {
+		s.IsActive = false
+		s.UpdatedAt = time.Now()
+	}), nil
+}
+
+type memorySecurityCodeRepository struct {
+	*memoryBackend
+}
+
+func (r *memorySecurityCodeRepository) Create(code *models.SecurityCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&code.CreatedAt, &code.UpdatedAt)
+	return r.securityCodes.insert(code)
+}
+
+func (r *memorySecurityCodeRepository) GetByCode(code string) (*models.SecurityCode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	securityCode, err := r.securityCodes.first(func(c models.SecurityCode) bool { return c.Code == code })
+	if err != nil {
+		return nil, err
+	}
+	return &securityCode, nil
+}
+
+func (r *memorySecurityCodeRepository) ListActive() ([]models.SecurityCode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.securityCodes.find(func(c models.SecurityCode) bool { return c.IsActive }), nil
+}
+
+func (r *memorySecurityCodeRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.securityCodes.remove(byID(r.securityCodes, id))
+	return nil
+}
@@ -0,0 +1,398 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+)
+
+type memoryUserRepository struct {
+	*memoryBackend
+}
+
+func (r *memoryUserRepository) Create(user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&user.CreatedAt, &user.UpdatedAt)
+	return r.users.insert(user)
+}
+
+func (r *memoryUserRepository) Save(user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&user.CreatedAt, &user.UpdatedAt)
+	return r.users.put(user)
+}
+
+func (r *memoryUserRepository) GetByID(id uint) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, err := r.users.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) GetByPCOUserID(pcoUserID string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, err := r.users.first(func(u models.User) bool { return u.PCOUserID == pcoUserID })
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) List() ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := r.users.find(nil)
+	sort.SliceStable(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	return users, nil
+}
+
+func (r *memoryUserRepository) SetAdmin(id uint, isAdmin bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.users.update(byID(r.users, id), func(u *models.User) { u.IsAdmin = isAdmin }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) SetMedicalAccess(id uint, allowed bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.users.update(byID(r.users, id), func(u *models.User) { u.MedicalAccess = allowed }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) SetContactAccess(id uint, allowed bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.users.update(byID(r.users, id), func(u *models.User) { u.ContactAccess = allowed }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) GetByCalendarToken(hash string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if hash == "" {
+		return nil, ErrNotFound
+	}
+	user, err := r.users.first(func(u models.User) bool { return u.CalendarTokenHash == hash })
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) SetCalendarToken(id uint, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.users.update(byID(r.users, id), func(u *models.User) { u.CalendarTokenHash = hash }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) TouchLastActivity(id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users.update(byID(r.users, id), func(u *models.User) { u.LastActivity = at })
+	return nil
+}
+
+// ReencryptTokens has nothing to do: tokens held in memory are never sealed
+func (r *memoryUserRepository) ReencryptTokens() (int, error) {
+	return 0, nil
+}
+
+type memorySessionRepository struct {
+	*memoryBackend
+}
+
+func (r *memorySessionRepository) Create(session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&session.CreatedAt, &session.UpdatedAt)
+	stored := *session
+	stored.User = models.User{}
+	if err := r.sessions.insert(&stored); err != nil {
+		return err
+	}
+	session.ID = stored.ID
+	return nil
+}
+
+// withUser populates session.User, leaving it empty if the user is gone
+func (r *memorySessionRepository) withUser(session *models.Session) {
+	if user, err := r.users.get(session.UserID); err == nil {
+		session.User = user
+	}
+}
+
+func (r *memorySessionRepository) GetByToken(token string) (*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, err := r.sessions.first(func(s models.Session) bool { return s.Token == token })
+	if err != nil {
+		return nil, err
+	}
+	r.withUser(&session)
+	return &session, nil
+}
+
+func (r *memorySessionRepository) GetByID(id uint) (*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, err := r.sessions.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *memorySessionRepository) match(filter SessionFilter) func(models.Session) bool {
+	return func(s models.Session) bool {
+		if filter.UserID != 0 && s.UserID != filter.UserID {
+			return false
+		}
+		if !filter.ActiveAt.IsZero() && !s.ExpiresAt.After(filter.ActiveAt) {
+			return false
+		}
+		if !filter.IdleBefore.IsZero() && !s.IsRememberMe && !s.LastActivity.After(filter.IdleBefore) {
+			return false
+		}
+		return true
+	}
+}
+
+func (r *memorySessionRepository) List(filter SessionFilter) ([]models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := r.sessions.find(r.match(filter))
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].LastActivity.After(sessions[j].LastActivity) })
+	if filter.WithUser {
+		for i := range sessions {
+			r.withUser(&sessions[i])
+		}
+	}
+	return sessions, nil
+}
+
+func (r *memorySessionRepository) Count(filter SessionFilter) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.sessions.count(r.match(filter)), nil
+}
+
+func (r *memorySessionRepository) TouchActivity(id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions.update(byID(r.sessions, id), func(s *models.Session) { s.LastActivity = at })
+	return nil
+}
+
+func (r *memorySessionRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions.remove(byID(r.sessions, id))
+	return nil
+}
+
+func (r *memorySessionRepository) DeleteByToken(token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions.remove(func(s models.Session) bool { return s.Token == token })
+	return nil
+}
+
+func (r *memorySessionRepository) DeleteByUser(userID, exceptID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions.remove(func(s models.Session) bool { return s.UserID == userID && s.ID != exceptID })
+	return nil
+}
+
+func (r *memorySessionRepository) DeleteExpired(now, idleBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.sessions.remove(func(s models.Session) bool {
+		if s.ExpiresAt.Before(now) {
+			return true
+		}
+		return !idleBefore.IsZero() && !s.IsRememberMe && s.LastActivity.Before(idleBefore)
+	}), nil
+}
+
+type memoryAPIKeyRepository struct {
+	*memoryBackend
+}
+
+func (r *memoryAPIKeyRepository) Create(key *models.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&key.CreatedAt, &key.UpdatedAt)
+	stored := *key
+	stored.User = models.User{}
+	stored.Scopes = append([]string(nil), key.Scopes...)
+	if err := r.apiKeys.insert(&stored); err != nil {
+		return err
+	}
+	key.ID = stored.ID
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) List() ([]models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := r.apiKeys.find(nil)
+	sort.SliceStable(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.After(keys[j].CreatedAt)
+		}
+		return keys[i].ID > keys[j].ID
+	})
+	return keys, nil
+}
+
+func (r *memoryAPIKeyRepository) get(match func(models.APIKey) bool) (*models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, err := r.apiKeys.first(match)
+	if err != nil {
+		return nil, err
+	}
+	key.Scopes = append([]string(nil), key.Scopes...)
+	if user, err := r.users.get(key.UserID); err == nil {
+		key.User = user
+	}
+	return &key, nil
+}
+
+func (r *memoryAPIKeyRepository) GetByID(id uint) (*models.APIKey, error) {
+	return r.get(byID(r.apiKeys, id))
+}
+
+func (r *memoryAPIKeyRepository) GetByHash(keyHash string) (*models.APIKey, error) {
+	return r.get(func(k models.APIKey) bool { return k.KeyHash == keyHash })
+}
+
+func (r *memoryAPIKeyRepository) Revoke(id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.apiKeys.update(func(k models.APIKey) bool { return k.ID == id && k.RevokedAt == nil }, func(k *models.APIKey) {
+		revokedAt := at
+		k.RevokedAt = &revokedAt
+	})
+	return nil
+}
+
+func (r *memoryAPIKeyRepository) RecordUse(id uint, at time.Time, ipAddress string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.apiKeys.update(byID(r.apiKeys, id), func(k *models.APIKey) {
+		usedAt := at
+		k.LastUsedAt = &usedAt
+		k.LastUsedIP = ipAddress
+	})
+	return nil
+}
+
+type memoryAuditRepository struct {
+	*memoryBackend
+}
+
+// Create stores the before/after payloads as JSON, like the other backends,
+// so readers get the same decoded shapes back
+func (r *memoryAuditRepository) Create(event *models.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	stored := *event
+	for _, payload := range []*interface{}{&stored.Before, &stored.After} {
+		data, err := encodePayload(*payload)
+		if err != nil {
+			return err
+		}
+		if *payload, err = decodePayload(data); err != nil {
+			return err
+		}
+	}
+
+	if err := r.auditEvents.insert(&stored); err != nil {
+		return err
+	}
+	event.ID = stored.ID
+	return nil
+}
+
+func (r *memoryAuditRepository) List(filter AuditFilter) ([]models.AuditEvent, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := r.auditEvents.find(func(e models.AuditEvent) bool {
+		switch {
+		case filter.ActorID != 0 && e.ActorID != filter.ActorID,
+			filter.Action != "" && e.Action != filter.Action,
+			filter.TargetType != "" && e.TargetType != filter.TargetType,
+			filter.TargetID != "" && e.TargetID != filter.TargetID,
+			!filter.Since.IsZero() && e.CreatedAt.Before(filter.Since),
+			!filter.Until.IsZero() && !e.CreatedAt.Before(filter.Until):
+			return false
+		}
+		return true
+	})
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.After(events[j].CreatedAt)
+		}
+		return events[i].ID > events[j].ID
+	})
+
+	total := int64(len(events))
+	if filter.Offset > 0 {
+		if filter.Offset >= len(events) {
+			return []models.AuditEvent{}, total, nil
+		}
+		events = events[filter.Offset:]
+	}
+	return limit(events, filter.Limit), total, nil
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go_pco_arrivals/internal/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoTimeout bounds every repository call against MongoDB
+const mongoTimeout = 10 * time.Second
+
+// NewMongoStore returns a store backed by MongoDB. Documents use the same
+// numeric IDs as the SQL tables, allocated from the "counters" collection.
+func NewMongoStore(db *database.MongoDB) *Store {
+	m := &mongoBackend{db: db}
+	users := &mongoUserRepository{m}
+	return &Store{
+		Users:           users,
+		Sessions:        &mongoSessionRepository{m, users},
+		APIKeys:         &mongoAPIKeyRepository{m, users},
+		AuditEvents:     &mongoAuditRepository{m},
+		CheckIns:        &mongoCheckInRepository{m},
+		Notifications:   &mongoNotificationRepository{m},
+		Locations:       &mongoLocationRepository{m},
+		Events:          &mongoEventRepository{m},
+		BillboardStates: &mongoBillboardStateRepository{m},
+		SecurityCodes:   &mongoSecurityCodeRepository{m},
+		Rollups:         &mongoRollupRepository{m},
+		Visitors:        &mongoVisitorRepository{m},
+		ping:            db.Ping,
+	}
+}
+
+// mongoBackend holds the helpers shared by the Mongo repositories
+type mongoBackend struct {
+	db *database.MongoDB
+}
+
+func (m *mongoBackend) collection(name string) *mongo.Collection {
+	return m.db.GetCollection(name)
+}
+
+// nextID allocates the next numeric ID for a collection
+func (m *mongoBackend) nextID(ctx context.Context, collection string) (uint, error) {
+	return m.nextIDs(ctx, collection, 1)
+}
+
+// nextIDs reserves n consecutive IDs for a collection and returns the first
+func (m *mongoBackend) nextIDs(ctx context.Context, collection string, n int) (uint, error) {
+	var counter struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := m.collection("counters").FindOneAndUpdate(ctx,
+		bson.M{"_id": collection},
+		bson.M{"$inc": bson.M{"seq": n}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate %s id: %w", collection, err)
+	}
+	return uint(counter.Seq) - uint(n) + 1, nil
+}
+
+// insert allocates an ID through assign and stores the document
+func (m *mongoBackend) insert(collection string, assign func(id uint), document interface{}, action string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	id, err := m.nextID(ctx, collection)
+	if err != nil {
+		return err
+	}
+	assign(id)
+
+	_, err = m.collection(collection).InsertOne(ctx, document)
+	return mongoError(err, action)
+}
+
+// replace writes a full document over the one with the same ID
+func (m *mongoBackend) replace(collection string, id uint, document interface{}, action string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	_, err := m.collection(collection).ReplaceOne(ctx, bson.M{"_id": id}, document, options.Replace().SetUpsert(true))
+	return mongoError(err, action)
+}
+
+// findOne decodes the first document matching filter into result
+func (m *mongoBackend) findOne(collection string, filter interface{}, opts *options.FindOneOptions, result interface{}, action string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	if opts == nil {
+		opts = options.FindOne()
+	}
+	return mongoError(m.collection(collection).FindOne(ctx, filter, opts).Decode(result), action)
+}
+
+// find decodes every document matching filter into results
+func (m *mongoBackend) find(collection string, filter interface{}, opts *options.FindOptions, results interface{}, action string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	cursor, err := m.collection(collection).Find(ctx, filter, opts)
+	if err != nil {
+		return mongoError(err, action)
+	}
+	defer cursor.Close(ctx)
+
+	return mongoError(cursor.All(ctx, results), action)
+}
+
+func (m *mongoBackend) count(collection string, filter interface{}, action string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	count, err := m.collection(collection).CountDocuments(ctx, filter)
+	return count, mongoError(err, action)
+}
+
+// updateOne applies update to the document with the given ID and returns
+// ErrNotFound when it doesn't exist
+func (m *mongoBackend) updateOne(collection string, id uint, update interface{}, action string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	result, err := m.collection(collection).UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return mongoError(err, action)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoBackend) updateMany(collection string, filter, update interface{}, action string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	result, err := m.collection(collection).UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, mongoError(err, action)
+	}
+	return result.ModifiedCount, nil
+}
+
+func (m *mongoBackend) deleteMany(collection string, filter interface{}, action string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	result, err := m.collection(collection).DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, mongoError(err, action)
+	}
+	return result.DeletedCount, nil
+}
+
+func (m *mongoBackend) aggregate(collection string, pipeline interface{}, results interface{}, action string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	cursor, err := m.collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return mongoError(err, action)
+	}
+	defer cursor.Close(ctx)
+
+	return mongoError(cursor.All(ctx, results), action)
+}
+
+// mongoError maps driver errors onto the repository errors
+func mongoError(err error, action string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return fmt.Errorf("%w: %v", ErrDuplicate, err)
+	default:
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
+}
+
+// stamp fills in GORM-style timestamps before a write
+func stamp(createdAt, updatedAt *time.Time) {
+	now := time.Now()
+	if createdAt != nil && createdAt.IsZero() {
+		*createdAt = now
+	}
+	if updatedAt != nil {
+		*updatedAt = now
+	}
+}
+
+// timeRange adds $gte/$lt bounds on field to filter, skipping zero times
+func timeRange(filter bson.M, field string, since, until time.Time) {
+	bounds := bson.M{}
+	if !since.IsZero() {
+		bounds["$gte"] = since
+	}
+	if !until.IsZero() {
+		bounds["$lt"] = until
+	}
+	if len(bounds) > 0 {
+		filter[field] = bounds
+	}
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRollupRepository struct {
+	*mongoBackend
+}
+
+// Replace deletes and reinserts the range. MongoDB doesn't give us a
+// transaction on standalone servers, so a reader can briefly see the range
+// empty.
+func (r *mongoRollupRepository) Replace(since, until time.Time, rollups []models.AttendanceRollup) error {
+	rows := stampRollups(rollups, time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	collection := r.collection("attendance_rollups")
+	if _, err := collection.DeleteMany(ctx, bson.M{"period_start": bson.M{"$gte": since, "$lt": until}}); err != nil {
+		return mongoError(err, "replace rollups")
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	first, err := r.nextIDs(ctx, "attendance_rollups", len(rows))
+	if err != nil {
+		return err
+	}
+	documents := make([]interface{}, len(rows))
+	for i := range rows {
+		rows[i].ID = first + uint(i)
+		documents[i] = rows[i]
+	}
+	_, err = collection.InsertMany(ctx, documents)
+	return mongoError(err, "replace rollups")
+}
+
+func (r *mongoRollupRepository) List(filter RollupFilter) ([]models.AttendanceRollup, error) {
+	query := bson.M{}
+	if filter.Period != "" {
+		query["period"] = filter.Period
+	}
+	if filter.LocationID != "" {
+		query["location_id"] = filter.LocationID
+	}
+	if filter.EventID != "" {
+		query["event_id"] = filter.EventID
+	}
+	timeRange(query, "period_start", filter.Since, filter.Until)
+
+	opts := options.Find().SetSort(bson.D{{Key: "period_start", Value: 1}, {Key: "location_id", Value: 1}, {Key: "event_id", Value: 1}})
+	rollups := []models.AttendanceRollup{}
+	if err := r.find("attendance_rollups", query, opts, &rollups, "list rollups"); err != nil {
+		return nil, err
+	}
+	return rollups, nil
+}
+
+type mongoVisitorRepository struct {
+	*mongoBackend
+}
+
+func (r *mongoVisitorRepository) Record(visitors []models.Visitor) ([]models.Visitor, error) {
+	var existing []models.Visitor
+	for _, batch := range batches(visitorPersonIDs(visitors)) {
+		var found []models.Visitor
+		if err := r.find("visitors", bson.M{"person_id": bson.M{"$in": batch}}, nil, &found, "load visitors"); err != nil {
+			return nil, err
+		}
+		existing = append(existing, found...)
+	}
+
+	write, moved := planVisitors(visitors, existing, time.Now())
+	if len(write) == 0 {
+		return moved, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	var created int
+	for _, visitor := range write {
+		if visitor.ID == 0 {
+			created++
+		}
+	}
+	var next uint
+	if created > 0 {
+		first, err := r.nextIDs(ctx, "visitors", created)
+		if err != nil {
+			return nil, err
+		}
+		next = first
+	}
+
+	writes := make([]mongo.WriteModel, len(write))
+	for i, visitor := range write {
+		if visitor.ID != 0 {
+			writes[i] = mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": visitor.ID}).SetReplacement(visitor)
+			continue
+		}
+		// A concurrent sync may have recorded the same person first; its
+		// document is kept
+		visitor.ID = next
+		next++
+		writes[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"person_id": visitor.PersonID}).
+			SetUpdate(bson.M{"$setOnInsert": visitor}).
+			SetUpsert(true)
+	}
+	if _, err := r.collection("visitors").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+		return nil, mongoError(err, "record visitors")
+	}
+	return moved, nil
+}
+
+func (r *mongoVisitorRepository) List(filter VisitorFilter) ([]models.Visitor, error) {
+	query := bson.M{}
+	if filter.LocationID != "" {
+		query["location_id"] = filter.LocationID
+	}
+	timeRange(query, "first_check_in_at", filter.Since, filter.Until)
+
+	opts := options.Find().SetSort(bson.D{{Key: "first_check_in_at", Value: 1}, {Key: "_id", Value: 1}})
+	visitors := []models.Visitor{}
+	if err := r.find("visitors", query, opts, &visitors, "list visitors"); err != nil {
+		return nil, err
+	}
+	return visitors, nil
+}
@@ -0,0 +1,613 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoCheckInRepository struct {
+	*mongoBackend
+}
+
+func (r *mongoCheckInRepository) Create(checkIn *models.CheckIn) error {
+	stamp(&checkIn.CreatedAt, &checkIn.UpdatedAt)
+	if checkIn.Status == "" {
+		checkIn.Status = "active"
+	}
+	if checkIn.Kind == "" {
+		checkIn.Kind = models.CheckInKindRegular
+	}
+	return r.insert("check_ins", func(id uint) { checkIn.ID = id }, checkIn, "create check-in")
+}
+
+// Upsert writes new and changed check-ins in one unordered BulkWrite of
+// upserting UpdateOne calls, so a failed row doesn't stop the others
+func (r *mongoCheckInRepository) Upsert(checkIns []models.CheckIn) (*UpsertResult, error) {
+	result := &UpsertResult{Inserted: make(map[string]bool)}
+	rows, ids := prepareUpsert(checkIns, result)
+
+	var existing []models.CheckIn
+	for _, batch := range batches(ids) {
+		var found []models.CheckIn
+		if err := r.find("check_ins", bson.M{"pco_check_in_id": bson.M{"$in": batch}}, nil, &found, "load check-ins"); err != nil {
+			return nil, err
+		}
+		existing = append(existing, found...)
+	}
+
+	plan := planUpsert(rows, existing, time.Now(), result)
+	pending := append(plan.create, plan.update...)
+	if len(pending) == 0 {
+		return result, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	if len(plan.create) > 0 {
+		first, err := r.nextIDs(ctx, "check_ins", len(plan.create))
+		if err != nil {
+			return nil, err
+		}
+		for i := range plan.create {
+			pending[i].ID = first + uint(i)
+		}
+	}
+
+	writes := make([]mongo.WriteModel, len(pending))
+	for i, checkIn := range pending {
+		set := bson.M{
+			"person_id":               checkIn.PersonID,
+			"person_name":             checkIn.PersonName,
+			"location_id":             checkIn.LocationID,
+			"location_name":           checkIn.LocationName,
+			"check_in_time":           checkIn.CheckInTime,
+			"checked_out_at":          checkIn.CheckedOutAt,
+			"notes":                   checkIn.Notes,
+			"status":                  checkIn.Status,
+			"kind":                    checkIn.Kind,
+			"first_time":              checkIn.FirstTime,
+			"one_time_guest":          checkIn.OneTimeGuest,
+			"number":                  checkIn.Number,
+			"medical_notes":           checkIn.MedicalNotes,
+			"emergency_contact_name":  checkIn.EmergencyContactName,
+			"emergency_contact_phone": checkIn.EmergencyContactPhone,
+			"parent_name":             checkIn.ParentName,
+			"parent_phone":            checkIn.ParentPhone,
+			"parent_email":            checkIn.ParentEmail,
+			"updated_at":              checkIn.UpdatedAt,
+		}
+		if i >= len(plan.create) {
+			writes[i] = mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": checkIn.ID}).SetUpdate(bson.M{"$set": set})
+			continue
+		}
+
+		// Fields outside the sync are only written when the row is new
+		data, err := bson.Marshal(checkIn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode check-in: %w", err)
+		}
+		var insert bson.M
+		if err := bson.Unmarshal(data, &insert); err != nil {
+			return nil, fmt.Errorf("failed to encode check-in: %w", err)
+		}
+		for _, column := range checkInSyncColumns {
+			delete(insert, column)
+		}
+		writes[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"pco_check_in_id": checkIn.PCOCheckInID}).
+			SetUpdate(bson.M{"$set": set, "$setOnInsert": insert}).
+			SetUpsert(true)
+	}
+
+	failed := make(map[int]bool)
+	bulk, err := r.collection("check_ins").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		var writeErr mongo.BulkWriteException
+		if !errors.As(err, &writeErr) || len(writeErr.WriteErrors) == 0 {
+			return nil, mongoError(err, "upsert check-ins")
+		}
+		for _, e := range writeErr.WriteErrors {
+			failed[e.Index] = true
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Errorf("failed to upsert check-in %s: %s", pending[e.Index].PCOCheckInID, e.Message))
+		}
+	}
+
+	var written []string
+	inserted := make(map[string]bool)
+	for i, checkIn := range pending {
+		if failed[i] {
+			continue
+		}
+		written = append(written, checkIn.PCOCheckInID)
+		if bulk != nil {
+			if _, ok := bulk.UpsertedIDs[int64(i)]; ok {
+				inserted[checkIn.PCOCheckInID] = true
+			}
+		}
+	}
+
+	for _, batch := range batches(written) {
+		var stored []models.CheckIn
+		opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+		if err := r.find("check_ins", bson.M{"pco_check_in_id": bson.M{"$in": batch}}, opts, &stored, "reload check-ins"); err != nil {
+			return nil, err
+		}
+		result.Changed = append(result.Changed, stored...)
+	}
+	for _, checkIn := range result.Changed {
+		if inserted[checkIn.PCOCheckInID] {
+			result.Created++
+			result.Inserted[checkIn.PCOCheckInID] = true
+		} else {
+			result.Updated++
+		}
+	}
+	return result, nil
+}
+
+func (r *mongoCheckInRepository) GetByPCOID(pcoCheckInID string) (*models.CheckIn, error) {
+	var checkIn models.CheckIn
+	if err := r.findOne("check_ins", bson.M{"pco_check_in_id": pcoCheckInID}, nil, &checkIn, "get check-in"); err != nil {
+		return nil, err
+	}
+	return &checkIn, nil
+}
+
+func (r *mongoCheckInRepository) filter(filter CheckInFilter) bson.M {
+	query := bson.M{}
+	if filter.LocationID != "" {
+		query["location_id"] = filter.LocationID
+	}
+	if len(filter.LocationIDs) > 0 {
+		query["$and"] = bson.A{bson.M{"location_id": bson.M{"$in": filter.LocationIDs}}}
+	}
+	if filter.SecurityCode != "" {
+		query["security_code"] = filter.SecurityCode
+	}
+	if filter.Kind != "" {
+		query["kind"] = filter.Kind
+	}
+	if filter.PersonID != "" {
+		query["person_id"] = filter.PersonID
+	}
+	if filter.Present {
+		// Matches both null and missing
+		query["checked_out_at"] = nil
+	}
+	timeRange(query, "check_in_time", filter.Since, filter.Until)
+	return query
+}
+
+func (r *mongoCheckInRepository) List(filter CheckInFilter) ([]models.CheckIn, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "check_in_time", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+
+	checkIns := []models.CheckIn{}
+	if err := r.find("check_ins", r.filter(filter), opts, &checkIns, "list check-ins"); err != nil {
+		return nil, err
+	}
+	return checkIns, nil
+}
+
+func (r *mongoCheckInRepository) Count(filter CheckInFilter) (int64, error) {
+	return r.count("check_ins", r.filter(filter), "count check-ins")
+}
+
+func (r *mongoCheckInRepository) DeleteBefore(cutoff time.Time) (int64, error) {
+	return r.deleteMany("check_ins", bson.M{"check_in_time": bson.M{"$lt": cutoff}}, "delete old check-ins")
+}
+
+func (r *mongoCheckInRepository) DailyCounts(locationID string, since, until time.Time) ([]DailyCount, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"location_id":   locationID,
+			"check_in_time": bson.M{"$gte": since, "$lte": until},
+		}},
+		bson.M{"$group": bson.M{
+			"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$check_in_time"}},
+			"count": bson.M{"$sum": 1},
+		}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+		bson.M{"$project": bson.M{"_id": 0, "date": "$_id", "count": 1}},
+	}
+
+	counts := []DailyCount{}
+	if err := r.aggregate("check_ins", pipeline, &counts, "count check-ins by day"); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (r *mongoCheckInRepository) HourlyCounts(locationID string, since time.Time) ([]HourlyCount, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"location_id":   locationID,
+			"check_in_time": bson.M{"$gte": since},
+		}},
+		bson.M{"$group": bson.M{
+			"_id":   bson.M{"$hour": "$check_in_time"},
+			"count": bson.M{"$sum": 1},
+		}},
+		bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$project": bson.M{"_id": 0, "hour": "$_id", "count": 1}},
+	}
+
+	counts := []HourlyCount{}
+	if err := r.aggregate("check_ins", pipeline, &counts, "count check-ins by hour"); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func (r *mongoCheckInRepository) AverageWaitMinutes(locationID string, since time.Time) (float64, error) {
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"location_id":   locationID,
+			"check_in_time": bson.M{"$gte": since},
+		}},
+		bson.M{"$lookup": bson.M{
+			"from":         "notifications",
+			"localField":   "pco_check_in_id",
+			"foreignField": "pco_check_in_id",
+			"as":           "notification",
+		}},
+		bson.M{"$unwind": "$notification"},
+		bson.M{"$group": bson.M{
+			"_id": nil,
+			"average": bson.M{"$avg": bson.M{
+				"$subtract": bson.A{"$notification.created_at", "$check_in_time"},
+			}},
+		}},
+	}
+
+	var results []struct {
+		Average float64 `bson:"average"`
+	}
+	if err := r.aggregate("check_ins", pipeline, &results, "average wait time"); err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+	return results[0].Average / float64(time.Minute/time.Millisecond), nil
+}
+
+type mongoNotificationRepository struct {
+	*mongoBackend
+}
+
+func (r *mongoNotificationRepository) Create(notification *models.Notification) error {
+	stamp(&notification.CreatedAt, &notification.UpdatedAt)
+	if notification.Status == "" {
+		notification.Status = "active"
+	}
+	return r.insert("notifications", func(id uint) { notification.ID = id }, notification, "create notification")
+}
+
+func (r *mongoNotificationRepository) GetByCheckInID(pcoCheckInID string) (*models.Notification, error) {
+	var notification models.Notification
+	if err := r.findOne("notifications", bson.M{"pco_check_in_id": pcoCheckInID}, nil, &notification, "get notification"); err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (r *mongoNotificationRepository) filter(filter NotificationFilter) bson.M {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.LocationName != "" {
+		query["location_name"] = filter.LocationName
+	}
+
+	expires := bson.M{}
+	if !filter.ActiveAt.IsZero() {
+		expires["$gt"] = filter.ActiveAt
+	}
+	if !filter.ExpiredBefore.IsZero() {
+		expires["$lt"] = filter.ExpiredBefore
+	}
+	if len(expires) > 0 {
+		query["expires_at"] = expires
+	}
+
+	if !filter.CreatedSince.IsZero() {
+		query["created_at"] = bson.M{"$gte": filter.CreatedSince}
+	}
+	return query
+}
+
+func (r *mongoNotificationRepository) List(filter NotificationFilter) ([]models.Notification, error) {
+	notifications := []models.Notification{}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if err := r.find("notifications", r.filter(filter), opts, &notifications, "list notifications"); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *mongoNotificationRepository) Count(filter NotificationFilter) (int64, error) {
+	return r.count("notifications", r.filter(filter), "count notifications")
+}
+
+func (r *mongoNotificationRepository) UpdateStatus(id uint, status string) error {
+	return r.updateOne("notifications", id, bson.M{"$set": bson.M{"status": status, "updated_at": time.Now()}}, "update notification status")
+}
+
+func (r *mongoNotificationRepository) ExpireActive(now time.Time) (int64, error) {
+	return r.updateMany("notifications",
+		bson.M{"status": "active", "expires_at": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"status": "expired", "updated_at": time.Now()}},
+		"expire notifications")
+}
+
+func (r *mongoNotificationRepository) Delete(id uint) error {
+	_, err := r.deleteMany("notifications", bson.M{"_id": id}, "delete notification")
+	return err
+}
+
+func (r *mongoNotificationRepository) DeleteByCheckInID(pcoCheckInID string) error {
+	_, err := r.deleteMany("notifications", bson.M{"pco_check_in_id": pcoCheckInID}, "delete notification")
+	return err
+}
+
+type mongoLocationRepository struct {
+	*mongoBackend
+}
+
+func (r *mongoLocationRepository) Create(location *models.Location) error {
+	stamp(&location.CreatedAt, &location.UpdatedAt)
+	return r.insert("locations", func(id uint) { location.ID = id }, location, "create location")
+}
+
+func (r *mongoLocationRepository) Save(location *models.Location) error {
+	if location.ID == 0 {
+		return r.Create(location)
+	}
+	stamp(&location.CreatedAt, &location.UpdatedAt)
+	return r.replace("locations", location.ID, location, "save location")
+}
+
+func (r *mongoLocationRepository) GetByPCOID(pcoLocationID string) (*models.Location, error) {
+	var location models.Location
+	if err := r.findOne("locations", bson.M{"pco_location_id": pcoLocationID}, nil, &location, "get location"); err != nil {
+		return nil, err
+	}
+	return &location, nil
+}
+
+func (r *mongoLocationRepository) List() ([]models.Location, error) {
+	locations := []models.Location{}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if err := r.find("locations", bson.M{}, opts, &locations, "list locations"); err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+type mongoEventRepository struct {
+	*mongoBackend
+}
+
+func (r *mongoEventRepository) Create(event *models.Event) error {
+	stamp(&event.CreatedAt, &event.UpdatedAt)
+	return r.insert("events", func(id uint) { event.ID = id }, event, "create event")
+}
+
+func (r *mongoEventRepository) Save(event *models.Event) error {
+	if event.ID == 0 {
+		return r.Create(event)
+	}
+	stamp(&event.CreatedAt, &event.UpdatedAt)
+	return r.replace("events", event.ID, event, "save event")
+}
+
+func (r *mongoEventRepository) GetByID(id uint) (*models.Event, error) {
+	var event models.Event
+	if err := r.findOne("events", bson.M{"_id": id}, nil, &event, "get event"); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *mongoEventRepository) GetByPCOID(pcoEventID string) (*models.Event, error) {
+	var event models.Event
+	if err := r.findOne("events", bson.M{"pco_event_id": pcoEventID}, nil, &event, "get event"); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *mongoEventRepository) List(filter EventFilter) ([]models.Event, error) {
+	query := bson.M{}
+	if filter.LocationID != "" {
+		query["location_id"] = filter.LocationID
+	}
+	if filter.Recurring {
+		query["rrule"] = bson.M{"$gt": ""}
+	}
+	timeRange(query, "start_time", filter.From, filter.To)
+
+	events := []models.Event{}
+	opts := options.Find().SetSort(bson.D{{Key: "start_time", Value: 1}, {Key: "_id", Value: 1}})
+	if err := r.find("events", query, opts, &events, "list events"); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Delete removes the event with its periods and times
+func (r *mongoEventRepository) Delete(id uint) error {
+	for _, collection := range []string{"event_times", "event_periods"} {
+		if _, err := r.deleteMany(collection, bson.M{"event_id": id}, "delete event"); err != nil {
+			return err
+		}
+	}
+	_, err := r.deleteMany("events", bson.M{"_id": id}, "delete event")
+	return err
+}
+
+func (r *mongoEventRepository) SavePeriod(period *models.EventPeriod) error {
+	var existing models.EventPeriod
+	err := r.findOne("event_periods", bson.M{"pco_event_period_id": period.PCOEventPeriodID}, nil, &existing, "get event period")
+	switch {
+	case err == nil:
+		period.ID = existing.ID
+		period.CreatedAt = existing.CreatedAt
+	case !errors.Is(err, ErrNotFound):
+		return err
+	}
+
+	stamp(&period.CreatedAt, &period.UpdatedAt)
+	if period.ID == 0 {
+		return r.insert("event_periods", func(id uint) { period.ID = id }, period, "create event period")
+	}
+	return r.replace("event_periods", period.ID, period, "save event period")
+}
+
+func (r *mongoEventRepository) ListPeriods(filter EventPeriodFilter) ([]models.EventPeriod, error) {
+	query := bson.M{}
+	if filter.EventID != 0 {
+		query["event_id"] = filter.EventID
+	}
+	timeRange(query, "starts_at", filter.From, filter.To)
+
+	periods := []models.EventPeriod{}
+	opts := options.Find().SetSort(bson.D{{Key: "starts_at", Value: 1}, {Key: "_id", Value: 1}})
+	if err := r.find("event_periods", query, opts, &periods, "list event periods"); err != nil {
+		return nil, err
+	}
+	return periods, nil
+}
+
+func (r *mongoEventRepository) SaveTime(eventTime *models.EventTime) error {
+	var existing models.EventTime
+	err := r.findOne("event_times", bson.M{"pco_event_time_id": eventTime.PCOEventTimeID}, nil, &existing, "get event time")
+	switch {
+	case err == nil:
+		eventTime.ID = existing.ID
+		eventTime.CreatedAt = existing.CreatedAt
+	case !errors.Is(err, ErrNotFound):
+		return err
+	}
+
+	stamp(&eventTime.CreatedAt, &eventTime.UpdatedAt)
+	if eventTime.ID == 0 {
+		return r.insert("event_times", func(id uint) { eventTime.ID = id }, eventTime, "create event time")
+	}
+	return r.replace("event_times", eventTime.ID, eventTime, "save event time")
+}
+
+func (r *mongoEventRepository) ListTimes(periodIDs []uint) ([]models.EventTime, error) {
+	times := []models.EventTime{}
+	if len(periodIDs) == 0 {
+		return times, nil
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "starts_at", Value: 1}, {Key: "_id", Value: 1}})
+	if err := r.find("event_times", bson.M{"event_period_id": bson.M{"$in": periodIDs}}, opts, &times, "list event times"); err != nil {
+		return nil, err
+	}
+	return times, nil
+}
+
+type mongoBillboardStateRepository struct {
+	*mongoBackend
+}
+
+func (r *mongoBillboardStateRepository) Create(state *models.BillboardState) error {
+	stamp(&state.CreatedAt, &state.UpdatedAt)
+	return r.insert("billboard_states", func(id uint) { state.ID = id }, state, "create billboard state")
+}
+
+func (r *mongoBillboardStateRepository) Save(state *models.BillboardState) error {
+	if state.ID == 0 {
+		return r.Create(state)
+	}
+	stamp(&state.CreatedAt, &state.UpdatedAt)
+	return r.replace("billboard_states", state.ID, state, "save billboard state")
+}
+
+var latestFirst = options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})
+
+func (r *mongoBillboardStateRepository) GetByLocation(locationID string) (*models.BillboardState, error) {
+	var state models.BillboardState
+	if err := r.findOne("billboard_states", bson.M{"location_id": locationID}, latestFirst, &state, "get billboard state"); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (r *mongoBillboardStateRepository) GetActive() (*models.BillboardState, error) {
+	var state models.BillboardState
+	if err := r.findOne("billboard_states", bson.M{"is_active": true}, latestFirst, &state, "get active billboard"); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (r *mongoBillboardStateRepository) active(locationID string) bson.M {
+	query := bson.M{"is_active": true}
+	if locationID != "" {
+		query["location_id"] = locationID
+	}
+	return query
+}
+
+func (r *mongoBillboardStateRepository) ListActive(locationID string) ([]models.BillboardState, error) {
+	states := []models.BillboardState{}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}})
+	if err := r.find("billboard_states", r.active(locationID), opts, &states, "list active billboards"); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+func (r *mongoBillboardStateRepository) Deactivate(locationID string) (int64, error) {
+	return r.updateMany("billboard_states", r.active(locationID),
+		bson.M{"$set": bson.M{"is_active": false, "updated_at": time.Now()}},
+		"deactivate billboards")
+}
+
+type mongoSecurityCodeRepository struct {
+	*mongoBackend
+}
+
+func (r *mongoSecurityCodeRepository) Create(code *models.SecurityCode) error {
+	stamp(&code.CreatedAt, &code.UpdatedAt)
+	return r.insert("security_codes", func(id uint) { code.ID = id }, code, "create security code")
+}
+
+func (r *mongoSecurityCodeRepository) GetByCode(code string) (*models.SecurityCode, error) {
+	var securityCode models.SecurityCode
+	if err := r.findOne("security_codes", bson.M{"code": code}, nil, &securityCode, "get security code"); err != nil {
+		return nil, err
+	}
+	return &securityCode, nil
+}
+
+func (r *mongoSecurityCodeRepository) ListActive() ([]models.SecurityCode, error) {
+	codes := []models.SecurityCode{}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if err := r.find("security_codes", bson.M{"is_active": true}, opts, &codes, "list security codes"); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (r *mongoSecurityCodeRepository) Delete(id uint) error {
+	_, err := r.deleteMany("security_codes", bson.M{"_id": id}, "delete security code")
+	return err
+}
@@ -0,0 +1,444 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go_pco_arrivals/internal/database"
+	"go_pco_arrivals/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoUserRepository struct {
+	*mongoBackend
+}
+
+// sealed returns a copy of user with its PCO tokens encrypted, matching what
+// the "encrypted" GORM serializer stores in SQL
+func (r *mongoUserRepository) sealed(user *models.User) (*models.User, error) {
+	cipher := database.TokenCipher()
+	stored := *user
+
+	var err error
+	if stored.AccessToken, err = cipher.Encrypt(user.AccessToken); err != nil {
+		return nil, fmt.Errorf("failed to encrypt access token: %w", err)
+	}
+	if stored.RefreshToken, err = cipher.Encrypt(user.RefreshToken); err != nil {
+		return nil, fmt.Errorf("failed to encrypt refresh token: %w", err)
+	}
+	return &stored, nil
+}
+
+// open decrypts the PCO tokens of a user read from MongoDB
+func (r *mongoUserRepository) open(user *models.User) error {
+	cipher := database.TokenCipher()
+
+	var err error
+	if user.AccessToken, err = cipher.Decrypt(user.AccessToken); err != nil {
+		return fmt.Errorf("failed to decrypt access token for user %d: %w", user.ID, err)
+	}
+	if user.RefreshToken, err = cipher.Decrypt(user.RefreshToken); err != nil {
+		return fmt.Errorf("failed to decrypt refresh token for user %d: %w", user.ID, err)
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) Create(user *models.User) error {
+	stamp(&user.CreatedAt, &user.UpdatedAt)
+	stored, err := r.sealed(user)
+	if err != nil {
+		return err
+	}
+	return r.insert("users", func(id uint) { user.ID, stored.ID = id, id }, stored, "create user")
+}
+
+func (r *mongoUserRepository) Save(user *models.User) error {
+	if user.ID == 0 {
+		return r.Create(user)
+	}
+	stamp(&user.CreatedAt, &user.UpdatedAt)
+	stored, err := r.sealed(user)
+	if err != nil {
+		return err
+	}
+	return r.replace("users", user.ID, stored, "save user")
+}
+
+func (r *mongoUserRepository) get(filter bson.M) (*models.User, error) {
+	var user models.User
+	if err := r.findOne("users", filter, nil, &user, "get user"); err != nil {
+		return nil, err
+	}
+	if err := r.open(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) GetByID(id uint) (*models.User, error) {
+	return r.get(bson.M{"_id": id})
+}
+
+func (r *mongoUserRepository) GetByPCOUserID(pcoUserID string) (*models.User, error) {
+	return r.get(bson.M{"pco_user_id": pcoUserID})
+}
+
+func (r *mongoUserRepository) List() ([]models.User, error) {
+	users := []models.User{}
+	if err := r.find("users", bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}), &users, "list users"); err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if err := r.open(&users[i]); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+func (r *mongoUserRepository) SetAdmin(id uint, isAdmin bool) error {
+	return r.updateOne("users", id, bson.M{"$set": bson.M{"is_admin": isAdmin, "updated_at": time.Now()}}, "update user role")
+}
+
+func (r *mongoUserRepository) SetMedicalAccess(id uint, allowed bool) error {
+	return r.updateOne("users", id, bson.M{"$set": bson.M{"medical_access": allowed, "updated_at": time.Now()}}, "update medical access")
+}
+
+func (r *mongoUserRepository) SetContactAccess(id uint, allowed bool) error {
+	return r.updateOne("users", id, bson.M{"$set": bson.M{"contact_access": allowed, "updated_at": time.Now()}}, "update contact access")
+}
+
+func (r *mongoUserRepository) GetByCalendarToken(hash string) (*models.User, error) {
+	if hash == "" {
+		return nil, ErrNotFound
+	}
+	return r.get(bson.M{"calendar_token_hash": hash})
+}
+
+func (r *mongoUserRepository) SetCalendarToken(id uint, hash string) error {
+	return r.updateOne("users", id, bson.M{"$set": bson.M{"calendar_token_hash": hash, "updated_at": time.Now()}}, "update calendar token")
+}
+
+func (r *mongoUserRepository) TouchLastActivity(id uint, at time.Time) error {
+	err := r.updateOne("users", id, bson.M{"$set": bson.M{"last_activity": at}}, "update user activity")
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (r *mongoUserRepository) ReencryptTokens() (int, error) {
+	cipher := database.TokenCipher()
+	if !cipher.Enabled() {
+		return 0, fmt.Errorf("no token encryption keys are configured")
+	}
+
+	var raw []struct {
+		ID           uint   `bson:"_id"`
+		AccessToken  string `bson:"access_token"`
+		RefreshToken string `bson:"refresh_token"`
+	}
+	projection := options.Find().SetProjection(bson.M{"access_token": 1, "refresh_token": 1})
+	if err := r.find("users", bson.M{}, projection, &raw, "list user tokens"); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, row := range raw {
+		if !cipher.NeedsRotation(row.AccessToken) && !cipher.NeedsRotation(row.RefreshToken) {
+			continue
+		}
+
+		user, err := r.GetByID(row.ID)
+		if err != nil {
+			return updated, fmt.Errorf("failed to load user %d: %w", row.ID, err)
+		}
+		stored, err := r.sealed(user)
+		if err != nil {
+			return updated, err
+		}
+
+		update := bson.M{"$set": bson.M{"access_token": stored.AccessToken, "refresh_token": stored.RefreshToken}}
+		if err := r.updateOne("users", row.ID, update, "re-encrypt tokens"); err != nil {
+			return updated, fmt.Errorf("failed to re-encrypt tokens for user %d: %w", row.ID, err)
+		}
+		updated++
+	}
+
+	return updated, nil
+}
+
+type mongoSessionRepository struct {
+	*mongoBackend
+	users *mongoUserRepository
+}
+
+func (r *mongoSessionRepository) Create(session *models.Session) error {
+	stamp(&session.CreatedAt, &session.UpdatedAt)
+	return r.insert("sessions", func(id uint) { session.ID = id }, session, "create session")
+}
+
+// withUser populates session.User, leaving it empty if the user is gone
+func (r *mongoSessionRepository) withUser(session *models.Session) error {
+	user, err := r.users.GetByID(session.UserID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	session.User = *user
+	return nil
+}
+
+func (r *mongoSessionRepository) GetByToken(token string) (*models.Session, error) {
+	var session models.Session
+	if err := r.findOne("sessions", bson.M{"token": token}, nil, &session, "get session"); err != nil {
+		return nil, err
+	}
+	if err := r.withUser(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *mongoSessionRepository) GetByID(id uint) (*models.Session, error) {
+	var session models.Session
+	if err := r.findOne("sessions", bson.M{"_id": id}, nil, &session, "get session"); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *mongoSessionRepository) filter(filter SessionFilter) bson.M {
+	query := bson.M{}
+	if filter.UserID != 0 {
+		query["user_id"] = filter.UserID
+	}
+	if !filter.ActiveAt.IsZero() {
+		query["expires_at"] = bson.M{"$gt": filter.ActiveAt}
+	}
+	if !filter.IdleBefore.IsZero() {
+		query["$or"] = bson.A{
+			bson.M{"is_remember_me": true},
+			bson.M{"last_activity": bson.M{"$gt": filter.IdleBefore}},
+		}
+	}
+	return query
+}
+
+func (r *mongoSessionRepository) List(filter SessionFilter) ([]models.Session, error) {
+	sessions := []models.Session{}
+	opts := options.Find().SetSort(bson.D{{Key: "last_activity", Value: -1}})
+	if err := r.find("sessions", r.filter(filter), opts, &sessions, "list sessions"); err != nil {
+		return nil, err
+	}
+	if filter.WithUser {
+		for i := range sessions {
+			if err := r.withUser(&sessions[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sessions, nil
+}
+
+func (r *mongoSessionRepository) Count(filter SessionFilter) (int64, error) {
+	return r.count("sessions", r.filter(filter), "count sessions")
+}
+
+func (r *mongoSessionRepository) TouchActivity(id uint, at time.Time) error {
+	err := r.updateOne("sessions", id, bson.M{"$set": bson.M{"last_activity": at}}, "update session activity")
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (r *mongoSessionRepository) Delete(id uint) error {
+	_, err := r.deleteMany("sessions", bson.M{"_id": id}, "delete session")
+	return err
+}
+
+func (r *mongoSessionRepository) DeleteByToken(token string) error {
+	_, err := r.deleteMany("sessions", bson.M{"token": token}, "delete session")
+	return err
+}
+
+func (r *mongoSessionRepository) DeleteByUser(userID, exceptID uint) error {
+	filter := bson.M{"user_id": userID}
+	if exceptID != 0 {
+		filter["_id"] = bson.M{"$ne": exceptID}
+	}
+	_, err := r.deleteMany("sessions", filter, "delete user sessions")
+	return err
+}
+
+func (r *mongoSessionRepository) DeleteExpired(now, idleBefore time.Time) (int64, error) {
+	conditions := bson.A{bson.M{"expires_at": bson.M{"$lt": now}}}
+	if !idleBefore.IsZero() {
+		conditions = append(conditions, bson.M{"is_remember_me": false, "last_activity": bson.M{"$lt": idleBefore}})
+	}
+	return r.deleteMany("sessions", bson.M{"$or": conditions}, "delete expired sessions")
+}
+
+type mongoAPIKeyRepository struct {
+	*mongoBackend
+	users *mongoUserRepository
+}
+
+func (r *mongoAPIKeyRepository) Create(key *models.APIKey) error {
+	stamp(&key.CreatedAt, &key.UpdatedAt)
+	return r.insert("api_keys", func(id uint) { key.ID = id }, key, "create API key")
+}
+
+func (r *mongoAPIKeyRepository) List() ([]models.APIKey, error) {
+	keys := []models.APIKey{}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	if err := r.find("api_keys", bson.M{}, opts, &keys, "list API keys"); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *mongoAPIKeyRepository) get(filter bson.M) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.findOne("api_keys", filter, nil, &key, "get API key"); err != nil {
+		return nil, err
+	}
+	user, err := r.users.GetByID(key.UserID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if user != nil {
+		key.User = *user
+	}
+	return &key, nil
+}
+
+func (r *mongoAPIKeyRepository) GetByID(id uint) (*models.APIKey, error) {
+	return r.get(bson.M{"_id": id})
+}
+
+func (r *mongoAPIKeyRepository) GetByHash(keyHash string) (*models.APIKey, error) {
+	return r.get(bson.M{"key_hash": keyHash})
+}
+
+func (r *mongoAPIKeyRepository) Revoke(id uint, at time.Time) error {
+	_, err := r.updateMany("api_keys",
+		bson.M{"_id": id, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": at, "updated_at": time.Now()}},
+		"revoke API key")
+	return err
+}
+
+func (r *mongoAPIKeyRepository) RecordUse(id uint, at time.Time, ipAddress string) error {
+	_, err := r.updateMany("api_keys",
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"last_used_at": at, "last_used_ip": ipAddress}},
+		"record API key use")
+	return err
+}
+
+// mongoAuditEvent stores the free-form before/after payloads as JSON text so
+// they read back with the same shape as the SQL serializer produces
+type mongoAuditEvent struct {
+	models.AuditEvent `bson:",inline"`
+	Before            string `bson:"before,omitempty"`
+	After             string `bson:"after,omitempty"`
+}
+
+type mongoAuditRepository struct {
+	*mongoBackend
+}
+
+func encodePayload(value interface{}) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit payload: %w", err)
+	}
+	return string(data), nil
+}
+
+func decodePayload(data string) (interface{}, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return nil, fmt.Errorf("failed to decode audit payload: %w", err)
+	}
+	return value, nil
+}
+
+func (r *mongoAuditRepository) Create(event *models.AuditEvent) error {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	stored := mongoAuditEvent{AuditEvent: *event}
+	var err error
+	if stored.Before, err = encodePayload(event.Before); err != nil {
+		return err
+	}
+	if stored.After, err = encodePayload(event.After); err != nil {
+		return err
+	}
+
+	return r.insert("audit_events", func(id uint) { event.ID, stored.ID = id, id }, stored, "record audit event")
+}
+
+func (r *mongoAuditRepository) List(filter AuditFilter) ([]models.AuditEvent, int64, error) {
+	query := bson.M{}
+	if filter.ActorID != 0 {
+		query["actor_id"] = filter.ActorID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.TargetType != "" {
+		query["target_type"] = filter.TargetType
+	}
+	if filter.TargetID != "" {
+		query["target_id"] = filter.TargetID
+	}
+	timeRange(query, "created_at", filter.Since, filter.Until)
+
+	total, err := r.count("audit_events", query, "count audit events")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	if filter.Limit > 0 {
+		opts.SetLimit(int64(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		opts.SetSkip(int64(filter.Offset))
+	}
+
+	var stored []mongoAuditEvent
+	if err := r.find("audit_events", query, opts, &stored, "list audit events"); err != nil {
+		return nil, 0, err
+	}
+
+	events := make([]models.AuditEvent, 0, len(stored))
+	for _, s := range stored {
+		event := s.AuditEvent
+		if event.Before, err = decodePayload(s.Before); err != nil {
+			return nil, 0, err
+		}
+		if event.After, err = decodePayload(s.After); err != nil {
+			return nil, 0, err
+		}
+		events = append(events, event)
+	}
+	return events, total, nil
+}
@@ -0,0 +1,322 @@
+// Package repository defines the storage interfaces used by services and
+// handlers, with GORM (SQL) and MongoDB implementations.
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go_pco_arrivals/internal/database"
+	"go_pco_arrivals/internal/models"
+)
+
+var (
+	// ErrNotFound is returned when a lookup matches no record
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a create or update violates a unique key
+	ErrDuplicate = errors.New("duplicate record")
+)
+
+// Store groups the repositories of one storage backend
+type Store struct {
+	Users           UserRepository
+	Sessions        SessionRepository
+	APIKeys         APIKeyRepository
+	AuditEvents     AuditRepository
+	CheckIns        CheckInRepository
+	Notifications   NotificationRepository
+	Locations       LocationRepository
+	Events          EventRepository
+	BillboardStates BillboardStateRepository
+	SecurityCodes   SecurityCodeRepository
+	Rollups         RollupRepository
+	Visitors        VisitorRepository
+
+	ping func() error
+}
+
+// Ping checks that the backend is reachable
+func (s *Store) Ping() error {
+	if s.ping == nil {
+		return nil
+	}
+	return s.ping()
+}
+
+// New returns the store for an open database connection
+func New(db database.Database) (*Store, error) {
+	switch conn := db.(type) {
+	case *database.SQLiteDatabase:
+		return NewGormStore(conn.GetGormDB()), nil
+	case *database.PostgresDatabase:
+		return NewGormStore(conn.GetGormDB()), nil
+	case *database.MongoDBDatabase:
+		return NewMongoStore(conn.GetMongoDB()), nil
+	default:
+		return nil, fmt.Errorf("no repository implementation for %s databases", db.GetType())
+	}
+}
+
+type UserRepository interface {
+	Create(user *models.User) error
+	Save(user *models.User) error
+	GetByID(id uint) (*models.User, error)
+	GetByPCOUserID(pcoUserID string) (*models.User, error)
+	List() ([]models.User, error)
+	SetAdmin(id uint, isAdmin bool) error
+	SetMedicalAccess(id uint, allowed bool) error
+	SetContactAccess(id uint, allowed bool) error
+	// GetByCalendarToken returns the user whose calendar token hashes to hash
+	GetByCalendarToken(hash string) (*models.User, error)
+	// SetCalendarToken replaces the user's calendar token hash; an empty hash
+	// revokes the feed
+	SetCalendarToken(id uint, hash string) error
+	TouchLastActivity(id uint, at time.Time) error
+	// ReencryptTokens rewrites PCO tokens not sealed with the active key and
+	// returns the number of users updated
+	ReencryptTokens() (int, error)
+}
+
+// SessionFilter narrows a session listing. Zero values are ignored.
+type SessionFilter struct {
+	UserID uint
+	// ActiveAt excludes sessions that expired at or before this time
+	ActiveAt time.Time
+	// IdleBefore excludes non-remember-me sessions last active at or before this time
+	IdleBefore time.Time
+	// WithUser populates Session.User
+	WithUser bool
+}
+
+type SessionRepository interface {
+	Create(session *models.Session) error
+	// GetByToken returns the session with its User populated
+	GetByToken(token string) (*models.Session, error)
+	GetByID(id uint) (*models.Session, error)
+	// List returns matching sessions, most recently active first
+	List(filter SessionFilter) ([]models.Session, error)
+	Count(filter SessionFilter) (int64, error)
+	TouchActivity(id uint, at time.Time) error
+	Delete(id uint) error
+	DeleteByToken(token string) error
+	// DeleteByUser removes every session of a user except exceptID, if non-zero
+	DeleteByUser(userID, exceptID uint) error
+	// DeleteExpired removes sessions expired before now and, when idleBefore
+	// is non-zero, non-remember-me sessions last active before idleBefore
+	DeleteExpired(now, idleBefore time.Time) (int64, error)
+}
+
+type APIKeyRepository interface {
+	Create(key *models.APIKey) error
+	// List returns every key, newest first
+	List() ([]models.APIKey, error)
+	// GetByID returns the key with its User populated
+	GetByID(id uint) (*models.APIKey, error)
+	// GetByHash returns the key with its User populated
+	GetByHash(keyHash string) (*models.APIKey, error)
+	Revoke(id uint, at time.Time) error
+	RecordUse(id uint, at time.Time, ipAddress string) error
+}
+
+// AuditFilter narrows an audit log query. Zero values are ignored.
+type AuditFilter struct {
+	ActorID    uint
+	Action     string
+	TargetType string
+	TargetID   string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	Offset     int
+}
+
+type AuditRepository interface {
+	Create(event *models.AuditEvent) error
+	// List returns matching events, newest first, and the total match count
+	List(filter AuditFilter) ([]models.AuditEvent, int64, error)
+}
+
+// CheckInFilter narrows a check-in query. Zero values are ignored.
+type CheckInFilter struct {
+	LocationID string
+	// LocationIDs matches check-ins at any of these locations
+	LocationIDs  []string
+	SecurityCode string
+	Since        time.Time
+	// Until is exclusive
+	Until time.Time
+	Limit int
+	// Kind matches check-ins of one PCO kind, such as Volunteer
+	Kind string
+	// Present matches check-ins that haven't been checked out
+	Present bool
+	// PersonID matches the check-ins of one PCO person
+	PersonID string
+}
+
+// DailyCount is the number of check-ins on one day (YYYY-MM-DD, UTC)
+type DailyCount struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+// HourlyCount is the number of check-ins within one hour of the day (UTC)
+type HourlyCount struct {
+	Hour  int   `json:"hour"`
+	Count int64 `json:"count"`
+}
+
+// UpsertResult summarises a batched check-in upsert
+type UpsertResult struct {
+	Created   int
+	Updated   int
+	Unchanged int
+	Failed    int
+	// Changed holds the created and updated check-ins as stored
+	Changed []models.CheckIn
+	// Inserted marks the PCO IDs of the check-ins in Changed that were created
+	Inserted map[string]bool
+	// Errors explains each failed row
+	Errors []error
+}
+
+type CheckInRepository interface {
+	Create(checkIn *models.CheckIn) error
+	// Upsert creates or updates check-ins keyed on PCOCheckInID in batches.
+	// Existing rows only have the fields PCO owns rewritten (person,
+	// location, check-in and check-out times, notes, status and kind); rows
+	// whose fields already match are left alone.
+	Upsert(checkIns []models.CheckIn) (*UpsertResult, error)
+	GetByPCOID(pcoCheckInID string) (*models.CheckIn, error)
+	// List returns matching check-ins, most recent first
+	List(filter CheckInFilter) ([]models.CheckIn, error)
+	Count(filter CheckInFilter) (int64, error)
+	DeleteBefore(cutoff time.Time) (int64, error)
+	// DailyCounts returns per-day totals in date order
+	DailyCounts(locationID string, since, until time.Time) ([]DailyCount, error)
+	// HourlyCounts returns per-hour totals, busiest first
+	HourlyCounts(locationID string, since time.Time) ([]HourlyCount, error)
+	// AverageWaitMinutes returns the mean time from check-in to pickup request
+	AverageWaitMinutes(locationID string, since time.Time) (float64, error)
+}
+
+// RollupFilter narrows a rollup query. Zero values are ignored.
+type RollupFilter struct {
+	Period     string
+	LocationID string
+	EventID    string
+	// Since and Until bound the period start; Until is exclusive
+	Since time.Time
+	Until time.Time
+}
+
+type RollupRepository interface {
+	// Replace swaps every rollup starting in [since, until) for rollups in
+	// one step, so readers never see a half-rebuilt day
+	Replace(since, until time.Time, rollups []models.AttendanceRollup) error
+	// List returns matching rollups in period start order
+	List(filter RollupFilter) ([]models.AttendanceRollup, error)
+}
+
+// VisitorFilter narrows a visitor query. Zero values are ignored.
+type VisitorFilter struct {
+	LocationID string
+	// Since and Until bound the first check-in time; Until is exclusive
+	Since time.Time
+	Until time.Time
+}
+
+type VisitorRepository interface {
+	// Record keeps the earliest check-in seen for each person. It returns the
+	// previous records of people whose first check-in moved earlier.
+	Record(visitors []models.Visitor) ([]models.Visitor, error)
+	// List returns matching visitors in first check-in order
+	List(filter VisitorFilter) ([]models.Visitor, error)
+}
+
+// NotificationFilter narrows a notification query. Zero values are ignored.
+type NotificationFilter struct {
+	Status       string
+	LocationName string
+	// ActiveAt keeps notifications expiring after this time
+	ActiveAt time.Time
+	// ExpiredBefore keeps notifications that expired before this time
+	ExpiredBefore time.Time
+	CreatedSince  time.Time
+}
+
+type NotificationRepository interface {
+	Create(notification *models.Notification) error
+	GetByCheckInID(pcoCheckInID string) (*models.Notification, error)
+	// List returns matching notifications, newest first
+	List(filter NotificationFilter) ([]models.Notification, error)
+	Count(filter NotificationFilter) (int64, error)
+	UpdateStatus(id uint, status string) error
+	// ExpireActive marks active notifications that expired by now as expired
+	ExpireActive(now time.Time) (int64, error)
+	Delete(id uint) error
+	DeleteByCheckInID(pcoCheckInID string) error
+}
+
+type LocationRepository interface {
+	Create(location *models.Location) error
+	Save(location *models.Location) error
+	GetByPCOID(pcoLocationID string) (*models.Location, error)
+	List() ([]models.Location, error)
+}
+
+// EventFilter narrows an event query. Zero values are ignored.
+type EventFilter struct {
+	LocationID string
+	// Recurring limits the query to events with an RRule
+	Recurring bool
+	From      time.Time
+	// To is exclusive
+	To time.Time
+}
+
+// EventPeriodFilter narrows an event period query. Zero values are ignored.
+type EventPeriodFilter struct {
+	EventID uint
+	// From and To bound the period start; To is exclusive
+	From time.Time
+	To   time.Time
+}
+
+type EventRepository interface {
+	Create(event *models.Event) error
+	Save(event *models.Event) error
+	GetByID(id uint) (*models.Event, error)
+	GetByPCOID(pcoEventID string) (*models.Event, error)
+	// List returns matching events in start time order
+	List(filter EventFilter) ([]models.Event, error)
+	Delete(id uint) error
+	// SavePeriod creates or updates the period with the same PCOEventPeriodID
+	SavePeriod(period *models.EventPeriod) error
+	// ListPeriods returns matching periods in start time order
+	ListPeriods(filter EventPeriodFilter) ([]models.EventPeriod, error)
+	// SaveTime creates or updates the event time with the same PCOEventTimeID
+	SaveTime(eventTime *models.EventTime) error
+	// ListTimes returns the times of the given periods in start time order
+	ListTimes(periodIDs []uint) ([]models.EventTime, error)
+}
+
+type BillboardStateRepository interface {
+	Create(state *models.BillboardState) error
+	Save(state *models.BillboardState) error
+	GetByLocation(locationID string) (*models.BillboardState, error)
+	// GetActive returns the most recently launched active billboard
+	GetActive() (*models.BillboardState, error)
+	// ListActive returns active billboards, for one location if locationID is set
+	ListActive(locationID string) ([]models.BillboardState, error)
+	// Deactivate clears active billboards, for one location if locationID is set
+	Deactivate(locationID string) (int64, error)
+}
+
+type SecurityCodeRepository interface {
+	Create(code *models.SecurityCode) error
+	GetByCode(code string) (*models.SecurityCode, error)
+	ListActive() ([]models.SecurityCode, error)
+	Delete(id uint) error
+}
@@ -0,0 +1,123 @@
+package repotest
+
+import (
+	"time"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+)
+
+func newRollup(period string, start time.Time, locationID string, checkIns int64) models.AttendanceRollup {
+	return models.AttendanceRollup{
+		Period:       period,
+		PeriodStart:  start,
+		LocationID:   locationID,
+		LocationName: "Room " + locationID,
+		EventID:      "event-1",
+		CheckIns:     checkIns,
+		UniquePeople: checkIns,
+	}
+}
+
+func testRollups(t T, store *repository.Store) {
+	day1 := base.Truncate(24 * time.Hour)
+	day2 := day1.Add(24 * time.Hour)
+
+	must(t, store.Rollups.Replace(day1, day2, []models.AttendanceRollup{
+		newRollup(models.RollupDay, day1, "loc-1", 3),
+		newRollup(models.RollupHour, day1.Add(9*time.Hour), "loc-1", 2),
+		newRollup(models.RollupHour, day1.Add(10*time.Hour), "loc-1", 1),
+		newRollup(models.RollupDay, day1, "loc-2", 1),
+	}), "replace first day")
+	must(t, store.Rollups.Replace(day2, day2.Add(24*time.Hour), []models.AttendanceRollup{
+		newRollup(models.RollupDay, day2, "loc-1", 5),
+	}), "replace second day")
+
+	all, err := store.Rollups.List(repository.RollupFilter{})
+	must(t, err, "list all rollups")
+	expectEqual(t, len(all), 5, "rollup count")
+
+	days, err := store.Rollups.List(repository.RollupFilter{Period: models.RollupDay, LocationID: "loc-1"})
+	must(t, err, "list daily rollups")
+	if len(days) != 2 {
+		t.Fatalf("expected 2 daily rollups, got %d", len(days))
+	}
+	expectTime(t, days[0].PeriodStart, day1, "first day start")
+	expectEqual(t, days[0].CheckIns, int64(3), "first day check-ins")
+	expectEqual(t, days[1].CheckIns, int64(5), "second day check-ins")
+	if days[0].ID == 0 || days[0].CreatedAt.IsZero() {
+		t.Errorf("stored rollup missing ID or timestamp: %+v", days[0])
+	}
+
+	hours, err := store.Rollups.List(repository.RollupFilter{Period: models.RollupHour, Since: day1.Add(10 * time.Hour), Until: day2})
+	must(t, err, "list hourly rollups in range")
+	if len(hours) != 1 {
+		t.Fatalf("expected 1 hourly rollup, got %d", len(hours))
+	}
+	expectTime(t, hours[0].PeriodStart, day1.Add(10*time.Hour), "hour start")
+
+	// Replacing a day drops its old buckets and leaves other days alone
+	must(t, store.Rollups.Replace(day1, day2, []models.AttendanceRollup{
+		newRollup(models.RollupDay, day1, "loc-1", 4),
+	}), "rebuild first day")
+	all, err = store.Rollups.List(repository.RollupFilter{})
+	must(t, err, "list after rebuild")
+	if len(all) != 2 {
+		t.Fatalf("expected 2 rollups after rebuild, got %d", len(all))
+	}
+	expectEqual(t, all[0].CheckIns, int64(4), "rebuilt day")
+	expectEqual(t, all[1].CheckIns, int64(5), "untouched day")
+
+	must(t, store.Rollups.Replace(day1, day2, nil), "clear first day")
+	all, err = store.Rollups.List(repository.RollupFilter{})
+	must(t, err, "list after clear")
+	expectEqual(t, len(all), 1, "rollups after clear")
+}
+
+func testVisitors(t T, store *repository.Store) {
+	visitor := func(personID, locationID string, at time.Time) models.Visitor {
+		return models.Visitor{PersonID: personID, PersonName: "Child " + personID, FirstCheckInAt: at, LocationID: locationID, EventID: "event-1"}
+	}
+
+	moved, err := store.Visitors.Record([]models.Visitor{
+		visitor("p1", "loc-1", base.Add(time.Hour)),
+		visitor("p1", "loc-1", base),
+		visitor("p2", "loc-2", base.Add(24*time.Hour)),
+		visitor("", "loc-1", base),
+	})
+	must(t, err, "record visitors")
+	expectEqual(t, len(moved), 0, "moved on first record")
+
+	visitors, err := store.Visitors.List(repository.VisitorFilter{})
+	must(t, err, "list visitors")
+	if len(visitors) != 2 {
+		t.Fatalf("expected 2 visitors, got %d", len(visitors))
+	}
+	expectEqual(t, visitors[0].PersonID, "p1", "first visitor")
+	expectTime(t, visitors[0].FirstCheckInAt, base, "earliest check-in kept")
+
+	// A later check-in doesn't change the first visit
+	moved, err = store.Visitors.Record([]models.Visitor{visitor("p1", "loc-2", base.Add(48*time.Hour))})
+	must(t, err, "record later visit")
+	expectEqual(t, len(moved), 0, "moved on later visit")
+
+	// An earlier one does, and the old record is returned
+	moved, err = store.Visitors.Record([]models.Visitor{visitor("p2", "loc-1", base.Add(-time.Hour))})
+	must(t, err, "record earlier visit")
+	if len(moved) != 1 {
+		t.Fatalf("expected 1 moved visitor, got %d", len(moved))
+	}
+	expectEqual(t, moved[0].LocationID, "loc-2", "moved visitor's old location")
+	expectTime(t, moved[0].FirstCheckInAt, base.Add(24*time.Hour), "moved visitor's old first check-in")
+
+	visitors, err = store.Visitors.List(repository.VisitorFilter{LocationID: "loc-1", Since: base.Add(-2 * time.Hour), Until: base})
+	must(t, err, "list visitors in range")
+	if len(visitors) != 1 {
+		t.Fatalf("expected 1 visitor in range, got %d", len(visitors))
+	}
+	expectEqual(t, visitors[0].PersonID, "p2", "visitor in range")
+
+	visitors, err = store.Visitors.List(repository.VisitorFilter{})
+	must(t, err, "list all visitors")
+	expectEqual(t, len(visitors), 2, "visitors after updates")
+}
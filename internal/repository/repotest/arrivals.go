@@ -0,0 +1,525 @@
+package repotest
+
+import (
+	"math"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+)
+
+func newCheckIn(id, locationID, code string, at time.Time) *models.CheckIn {
+	return &models.CheckIn{
+		PCOCheckInID: id,
+		PersonID:     "person-" + id,
+		PersonName:   "Child " + id,
+		LocationID:   locationID,
+		LocationName: "Room " + locationID,
+		SecurityCode: code,
+		CheckInTime:  at,
+		EventID:      "event-1",
+		Status:       "active",
+	}
+}
+
+func testCheckIns(t T, store *repository.Store) {
+	checkIns := []*models.CheckIn{
+		newCheckIn("c1", "loc-1", "AAA", base),
+		newCheckIn("c2", "loc-1", "BBB", base.Add(time.Hour)),
+		newCheckIn("c3", "loc-2", "AAA", base.Add(2*time.Hour)),
+		newCheckIn("c4", "loc-1", "AAA", base.Add(-48*time.Hour)),
+	}
+	for _, c := range checkIns {
+		must(t, store.CheckIns.Create(c), "create check-in "+c.PCOCheckInID)
+	}
+	expectErr(t, store.CheckIns.Create(newCheckIn("c1", "loc-1", "AAA", base)), repository.ErrDuplicate, "create duplicate check-in")
+
+	got, err := store.CheckIns.GetByPCOID("c2")
+	must(t, err, "get by PCO ID")
+	expectEqual(t, got.SecurityCode, "BBB", "security code")
+	expectTime(t, got.CheckInTime, base.Add(time.Hour), "check-in time")
+
+	_, err = store.CheckIns.GetByPCOID("missing")
+	expectErr(t, err, repository.ErrNotFound, "get missing check-in")
+
+	listed, err := store.CheckIns.List(repository.CheckInFilter{LocationID: "loc-1", Since: base.Add(-time.Hour)})
+	must(t, err, "list by location")
+	if len(listed) != 2 {
+		t.Fatalf("expected 2 recent check-ins at loc-1, got %d", len(listed))
+	}
+	expectEqual(t, listed[0].PCOCheckInID, "c2", "most recent check-in first")
+
+	listed, err = store.CheckIns.List(repository.CheckInFilter{Limit: 1})
+	must(t, err, "list with limit")
+	if len(listed) != 1 || listed[0].PCOCheckInID != "c3" {
+		t.Errorf("expected limit 1 to return the latest check-in")
+	}
+
+	count, err := store.CheckIns.Count(repository.CheckInFilter{LocationIDs: []string{"loc-2", "loc-9"}})
+	must(t, err, "count by locations")
+	expectEqual(t, count, int64(1), "check-ins at any of the locations")
+
+	count, err = store.CheckIns.Count(repository.CheckInFilter{SecurityCode: "AAA", Until: base.Add(2 * time.Hour)})
+	must(t, err, "count by code")
+	expectEqual(t, count, int64(2), "check-ins with code AAA before the exclusive upper bound")
+
+	got, err = store.CheckIns.GetByPCOID("c1")
+	must(t, err, "get defaulted kind")
+	expectEqual(t, got.Kind, models.CheckInKindRegular, "default kind")
+
+	out := base.Add(3 * time.Hour)
+	volunteer := newCheckIn("c5", "loc-2", "EEE", base.Add(time.Hour))
+	volunteer.Kind = models.CheckInKindVolunteer
+	leaver := newCheckIn("c6", "loc-2", "FFF", base.Add(time.Hour))
+	leaver.CheckedOutAt = &out
+	must(t, store.CheckIns.Create(volunteer), "create volunteer check-in")
+	must(t, store.CheckIns.Create(leaver), "create checked out check-in")
+
+	count, err = store.CheckIns.Count(repository.CheckInFilter{LocationID: "loc-2", Present: true})
+	must(t, err, "count present")
+	expectEqual(t, count, int64(2), "check-ins at loc-2 not checked out")
+	count, err = store.CheckIns.Count(repository.CheckInFilter{LocationID: "loc-2", Present: true, Kind: models.CheckInKindVolunteer})
+	must(t, err, "count present volunteers")
+	expectEqual(t, count, int64(1), "volunteers at loc-2")
+	count, err = store.CheckIns.Count(repository.CheckInFilter{PersonID: "person-c5"})
+	must(t, err, "count by person")
+	expectEqual(t, count, int64(1), "check-ins of one person")
+
+	got, err = store.CheckIns.GetByPCOID("c6")
+	must(t, err, "get checked out check-in")
+	if got.CheckedOutAt == nil {
+		t.Fatalf("expected check-out time to round trip")
+	}
+	expectTime(t, *got.CheckedOutAt, out, "check-out time")
+
+	removed, err := store.CheckIns.DeleteBefore(base.Add(-24 * time.Hour))
+	must(t, err, "delete old")
+	expectEqual(t, removed, int64(1), "old check-ins removed")
+
+	count, err = store.CheckIns.Count(repository.CheckInFilter{})
+	must(t, err, "count all")
+	expectEqual(t, count, int64(5), "check-ins left")
+}
+
+func testCheckInUpsert(t T, store *repository.Store) {
+	existing := newCheckIn("u1", "loc-1", "AAA", base)
+	existing.ParentName = "Parent"
+	must(t, store.CheckIns.Create(existing), "create check-in")
+	must(t, store.CheckIns.Create(newCheckIn("u2", "loc-1", "BBB", base)), "create check-in")
+
+	changed := *newCheckIn("u1", "loc-2", "", base)
+	changed.Notes = "Peanut allergy"
+	result, err := store.CheckIns.Upsert([]models.CheckIn{
+		changed,
+		*newCheckIn("u2", "loc-1", "BBB", base),
+		*newCheckIn("u3", "loc-2", "CCC", base.Add(time.Hour)),
+		*newCheckIn("", "loc-2", "DDD", base),
+	})
+	must(t, err, "upsert check-ins")
+	expectEqual(t, result.Created, 1, "created rows")
+	expectEqual(t, result.Updated, 1, "updated rows")
+	expectEqual(t, result.Unchanged, 1, "unchanged rows")
+	expectEqual(t, result.Failed, 1, "rows without a PCO ID")
+	if len(result.Changed) != 2 {
+		t.Fatalf("expected 2 changed check-ins, got %d", len(result.Changed))
+	}
+	expectEqual(t, result.Changed[0].PCOCheckInID, "u1", "changed rows in ID order")
+	expectEqual(t, result.Changed[0].ID, existing.ID, "updated row keeps its ID")
+	if result.Changed[1].ID == 0 {
+		t.Errorf("expected created row to have an ID")
+	}
+	expectEqual(t, result.Inserted["u3"], true, "created row marked inserted")
+	expectEqual(t, result.Inserted["u1"], false, "updated row not marked inserted")
+
+	got, err := store.CheckIns.GetByPCOID("u1")
+	must(t, err, "get updated check-in")
+	expectEqual(t, got.LocationID, "loc-2", "synced location")
+	expectEqual(t, got.Notes, "Peanut allergy", "synced notes")
+	expectEqual(t, got.SecurityCode, "AAA", "security code left alone")
+	expectEqual(t, got.ParentName, "Parent", "parent left alone")
+
+	got, err = store.CheckIns.GetByPCOID("u3")
+	must(t, err, "get created check-in")
+	expectEqual(t, got.SecurityCode, "CCC", "created security code")
+	expectTime(t, got.CheckInTime, base.Add(time.Hour), "created check-in time")
+
+	result, err = store.CheckIns.Upsert([]models.CheckIn{changed, *newCheckIn("u3", "loc-2", "CCC", base.Add(time.Hour))})
+	must(t, err, "repeat upsert")
+	expectEqual(t, result.Unchanged, 2, "rows unchanged on repeat")
+	expectEqual(t, len(result.Changed), 0, "nothing changed on repeat")
+
+	out := base.Add(2 * time.Hour)
+	changed.CheckedOutAt = &out
+	changed.Kind = models.CheckInKindVolunteer
+	changed.FirstTime = true
+	changed.Number = 12
+	changed.MedicalNotes = "EpiPen in bag"
+	changed.EmergencyContactPhone = "555-0100"
+	result, err = store.CheckIns.Upsert([]models.CheckIn{changed})
+	must(t, err, "upsert check-out")
+	expectEqual(t, result.Updated, 1, "checked out row updated")
+	got, err = store.CheckIns.GetByPCOID("u1")
+	must(t, err, "get checked out check-in")
+	expectEqual(t, got.Kind, models.CheckInKindVolunteer, "synced kind")
+	expectEqual(t, got.FirstTime, true, "synced first time")
+	expectEqual(t, got.Number, 12, "synced number")
+	expectEqual(t, got.MedicalNotes, "EpiPen in bag", "synced medical notes")
+	expectEqual(t, got.EmergencyContactPhone, "555-0100", "synced emergency contact")
+	if got.CheckedOutAt == nil {
+		t.Fatalf("expected synced check-out time")
+	}
+	expectTime(t, *got.CheckedOutAt, out, "synced check-out time")
+
+	changed.ParentName = "Guardian"
+	changed.ParentPhone = "555-0101"
+	result, err = store.CheckIns.Upsert([]models.CheckIn{changed})
+	must(t, err, "upsert resolved parent")
+	expectEqual(t, result.Updated, 1, "row with resolved parent updated")
+	changed.ParentName, changed.ParentPhone = "", ""
+	result, err = store.CheckIns.Upsert([]models.CheckIn{changed})
+	must(t, err, "upsert unresolved parent")
+	expectEqual(t, result.Unchanged, 1, "unresolved parent leaves row unchanged")
+	got, err = store.CheckIns.GetByPCOID("u1")
+	must(t, err, "get check-in with parent")
+	expectEqual(t, got.ParentName, "Guardian", "synced parent")
+	expectEqual(t, got.ParentPhone, "555-0101", "synced parent phone")
+
+	count, err := store.CheckIns.Count(repository.CheckInFilter{})
+	must(t, err, "count all")
+	expectEqual(t, count, int64(3), "check-ins stored")
+}
+
+func testCheckInAnalytics(t T, store *repository.Store) {
+	day1 := base
+	day2 := base.Add(24 * time.Hour)
+	for _, c := range []*models.CheckIn{
+		newCheckIn("c1", "loc-1", "AAA", day1),
+		newCheckIn("c2", "loc-1", "BBB", day1.Add(15*time.Minute)),
+		newCheckIn("c3", "loc-1", "CCC", day2.Add(time.Hour)),
+		newCheckIn("c4", "loc-2", "DDD", day1),
+	} {
+		must(t, store.CheckIns.Create(c), "create check-in "+c.PCOCheckInID)
+	}
+
+	for _, n := range []*models.Notification{
+		{PCOCheckInID: "c1", ChildName: "Child c1", SecurityCode: "AAA", EventID: 1, ExpiresAt: day2, CreatedBy: "pco-1", CreatedAt: day1.Add(30 * time.Minute)},
+		{PCOCheckInID: "c2", ChildName: "Child c2", SecurityCode: "BBB", EventID: 1, ExpiresAt: day2, CreatedBy: "pco-1", CreatedAt: day1.Add(25 * time.Minute)},
+	} {
+		must(t, store.Notifications.Create(n), "create notification "+n.PCOCheckInID)
+	}
+
+	daily, err := store.CheckIns.DailyCounts("loc-1", day1.Add(-time.Hour), day2.Add(2*time.Hour))
+	must(t, err, "daily counts")
+	if len(daily) != 2 {
+		t.Fatalf("expected 2 days, got %d", len(daily))
+	}
+	expectEqual(t, daily[0], repository.DailyCount{Date: "2024-03-10", Count: 2}, "first day")
+	expectEqual(t, daily[1], repository.DailyCount{Date: "2024-03-11", Count: 1}, "second day")
+
+	hourly, err := store.CheckIns.HourlyCounts("loc-1", day1.Add(-time.Hour))
+	must(t, err, "hourly counts")
+	if len(hourly) != 2 {
+		t.Fatalf("expected 2 hours, got %d", len(hourly))
+	}
+	expectEqual(t, hourly[0], repository.HourlyCount{Hour: 9, Count: 2}, "busiest hour")
+	expectEqual(t, hourly[1], repository.HourlyCount{Hour: 10, Count: 1}, "next hour")
+
+	wait, err := store.CheckIns.AverageWaitMinutes("loc-1", day1.Add(-time.Hour))
+	must(t, err, "average wait")
+	if math.Abs(wait-20) > 0.01 {
+		t.Errorf("average wait: got %.2f minutes, want 20", wait)
+	}
+
+	wait, err = store.CheckIns.AverageWaitMinutes("loc-2", day1.Add(-time.Hour))
+	must(t, err, "average wait without notifications")
+	expectEqual(t, wait, float64(0), "average wait with no pickups")
+}
+
+func newNotification(checkInID, locationName, status string, expiresAt, createdAt time.Time) *models.Notification {
+	return &models.Notification{
+		PCOCheckInID: checkInID,
+		ChildName:    "Child " + checkInID,
+		SecurityCode: "AAA",
+		LocationName: locationName,
+		EventID:      1,
+		Status:       status,
+		ExpiresAt:    expiresAt,
+		CreatedBy:    "pco-1",
+		CreatedAt:    createdAt,
+	}
+}
+
+func testNotifications(t T, store *repository.Store) {
+	notifications := []*models.Notification{
+		newNotification("n1", "Nursery", "active", base.Add(time.Hour), base),
+		newNotification("n2", "Nursery", "active", base.Add(-time.Minute), base.Add(-time.Hour)),
+		newNotification("n3", "Toddlers", "active", base.Add(time.Hour), base.Add(time.Minute)),
+	}
+	for _, n := range notifications {
+		must(t, store.Notifications.Create(n), "create notification "+n.PCOCheckInID)
+	}
+	expectErr(t, store.Notifications.Create(newNotification("n1", "Nursery", "active", base, base)), repository.ErrDuplicate, "create duplicate notification")
+
+	got, err := store.Notifications.GetByCheckInID("n1")
+	must(t, err, "get by check-in ID")
+	expectEqual(t, got.ID, notifications[0].ID, "notification ID")
+
+	listed, err := store.Notifications.List(repository.NotificationFilter{Status: "active", ActiveAt: base})
+	must(t, err, "list active")
+	if len(listed) != 2 {
+		t.Fatalf("expected 2 unexpired notifications, got %d", len(listed))
+	}
+	expectEqual(t, listed[0].PCOCheckInID, "n3", "newest notification first")
+
+	count, err := store.Notifications.Count(repository.NotificationFilter{LocationName: "Nursery", CreatedSince: base.Add(-30 * time.Minute)})
+	must(t, err, "count by location")
+	expectEqual(t, count, int64(1), "recent nursery notifications")
+
+	expired, err := store.Notifications.ExpireActive(base)
+	must(t, err, "expire active")
+	expectEqual(t, expired, int64(1), "notifications expired")
+
+	count, err = store.Notifications.Count(repository.NotificationFilter{Status: "expired", ExpiredBefore: base})
+	must(t, err, "count expired")
+	expectEqual(t, count, int64(1), "expired notifications")
+
+	must(t, store.Notifications.UpdateStatus(notifications[2].ID, "cancelled"), "update status")
+	expectErr(t, store.Notifications.UpdateStatus(9999, "cancelled"), repository.ErrNotFound, "update missing notification")
+	got, err = store.Notifications.GetByCheckInID("n3")
+	must(t, err, "reload notification")
+	expectEqual(t, got.Status, "cancelled", "updated status")
+
+	must(t, store.Notifications.DeleteByCheckInID("n3"), "delete by check-in ID")
+	must(t, store.Notifications.Delete(notifications[1].ID), "delete by ID")
+	count, err = store.Notifications.Count(repository.NotificationFilter{})
+	must(t, err, "count remaining")
+	expectEqual(t, count, int64(1), "notifications left")
+
+	must(t, store.Notifications.Create(newNotification("n3", "Toddlers", "active", base, base)), "re-create deleted notification")
+}
+
+func testLocations(t T, store *repository.Store) {
+	twelve, zero := 12, 0
+	second := &models.Location{PCOLocationID: "loc-2", Name: "Toddlers", IsActive: true}
+	first := &models.Location{PCOLocationID: "loc-1", Name: "Nursery", IsActive: true}
+	must(t, store.Locations.Create(first), "create location")
+	must(t, store.Locations.Create(second), "create location")
+	expectErr(t, store.Locations.Create(&models.Location{PCOLocationID: "loc-1", Name: "Dup"}), repository.ErrDuplicate, "create duplicate location")
+
+	got, err := store.Locations.GetByPCOID("loc-2")
+	must(t, err, "get by PCO ID")
+	got.Name = "Big Toddlers"
+	got.Kind = models.LocationKindLocation
+	got.ParentID = "wing"
+	got.AgeMinMonths = &twelve
+	got.GradeMax = &zero
+	got.MaxOccupancy = 15
+	got.Capacity = 10
+	got.Ratio = 4
+	must(t, store.Locations.Save(got), "save location")
+	got, err = store.Locations.GetByPCOID("loc-2")
+	must(t, err, "reload location")
+	expectEqual(t, got.ParentID, "wing", "parent ID")
+	expectEqual(t, got.MaxOccupancy, 15, "max occupancy")
+	expectEqual(t, got.CapacityLimit(), 10, "capacity overriding max occupancy")
+	expectEqual(t, got.Ratio, 4, "volunteer ratio")
+	if got.AgeMinMonths == nil || *got.AgeMinMonths != 12 || got.GradeMax == nil || *got.GradeMax != 0 {
+		t.Errorf("expected age and grade bounds to round trip, got %v and %v", got.AgeMinMonths, got.GradeMax)
+	}
+	if got.AgeMaxMonths != nil || got.GradeMin != nil {
+		t.Errorf("expected unset bounds to stay nil")
+	}
+
+	_, err = store.Locations.GetByPCOID("missing")
+	expectErr(t, err, repository.ErrNotFound, "get missing location")
+
+	locations, err := store.Locations.List()
+	must(t, err, "list locations")
+	if len(locations) != 2 {
+		t.Fatalf("expected 2 locations, got %d", len(locations))
+	}
+	expectEqual(t, locations[0].PCOLocationID, "loc-1", "locations in creation order")
+	expectEqual(t, locations[1].Name, "Big Toddlers", "saved name")
+}
+
+func testEvents(t T, store *repository.Store) {
+	later := &models.Event{PCOEventID: "e2", Name: "Evening", Date: base, StartTime: base.Add(8 * time.Hour), EndTime: base.Add(10 * time.Hour), LocationID: "loc-1", IsActive: true, CreatedBy: "pco-1"}
+	earlier := &models.Event{PCOEventID: "e1", Name: "Morning", Date: base, StartTime: base, EndTime: base.Add(2 * time.Hour), LocationID: "loc-1", IsActive: true, CreatedBy: "pco-1"}
+	other := &models.Event{PCOEventID: "e3", Name: "Youth", Date: base, StartTime: base.Add(time.Hour), LocationID: "loc-2", IsActive: true, CreatedBy: "pco-1"}
+	for _, e := range []*models.Event{later, earlier, other} {
+		must(t, store.Events.Create(e), "create event "+e.PCOEventID)
+	}
+	expectErr(t, store.Events.Create(&models.Event{PCOEventID: "e1", Name: "Dup", Date: base, CreatedBy: "pco-1"}), repository.ErrDuplicate, "create duplicate event")
+
+	events, err := store.Events.List(repository.EventFilter{LocationID: "loc-1"})
+	must(t, err, "list by location")
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events at loc-1, got %d", len(events))
+	}
+	expectEqual(t, events[0].PCOEventID, "e1", "events in start time order")
+
+	events, err = store.Events.List(repository.EventFilter{From: base.Add(time.Hour), To: base.Add(8 * time.Hour)})
+	must(t, err, "list by range")
+	if len(events) != 1 || events[0].PCOEventID != "e3" {
+		t.Errorf("expected only e3 inside the half-open range")
+	}
+
+	weekly := &models.Event{PCOEventID: "e4", Name: "Wednesday Night", Date: base, StartTime: base.Add(-48 * time.Hour), EndTime: base.Add(-46 * time.Hour), RRule: "FREQ=WEEKLY", ExDates: []string{"2024-03-20"}, IsActive: true, CreatedBy: "pco-1"}
+	must(t, store.Events.Create(weekly), "create recurring event")
+	events, err = store.Events.List(repository.EventFilter{Recurring: true, To: base})
+	must(t, err, "list recurring")
+	if len(events) != 1 || events[0].PCOEventID != "e4" {
+		t.Fatalf("expected only e4 to be recurring, got %d events", len(events))
+	}
+	expectEqual(t, events[0].RRule, "FREQ=WEEKLY", "stored rule")
+	if len(events[0].ExDates) != 1 || events[0].ExDates[0] != "2024-03-20" {
+		t.Errorf("expected exdates to round-trip, got %v", events[0].ExDates)
+	}
+
+	got, err := store.Events.GetByPCOID("e2")
+	must(t, err, "get by PCO ID")
+	got.Name = "Evening Service"
+	must(t, store.Events.Save(got), "save event")
+	got, err = store.Events.GetByID(later.ID)
+	must(t, err, "get by ID")
+	expectEqual(t, got.Name, "Evening Service", "saved name")
+
+	must(t, store.Events.Delete(later.ID), "delete event")
+	_, err = store.Events.GetByID(later.ID)
+	expectErr(t, err, repository.ErrNotFound, "get deleted event")
+}
+
+func testEventPeriods(t T, store *repository.Store) {
+	event := &models.Event{PCOEventID: "e1", Name: "Sunday", Date: base, StartTime: base, IsActive: true, CreatedBy: "pco"}
+	must(t, store.Events.Create(event), "create event")
+
+	later := &models.EventPeriod{PCOEventPeriodID: "p2", EventID: event.ID, StartsAt: base.Add(7 * 24 * time.Hour), EndsAt: base.Add(7*24*time.Hour + 3*time.Hour)}
+	earlier := &models.EventPeriod{PCOEventPeriodID: "p1", EventID: event.ID, StartsAt: base, EndsAt: base.Add(3 * time.Hour), RegularCount: 4}
+	for _, p := range []*models.EventPeriod{later, earlier} {
+		must(t, store.Events.SavePeriod(p), "save period "+p.PCOEventPeriodID)
+	}
+
+	// Saving by PCO ID again updates the existing period
+	update := &models.EventPeriod{PCOEventPeriodID: "p1", EventID: event.ID, StartsAt: base, EndsAt: base.Add(3 * time.Hour), RegularCount: 9}
+	must(t, store.Events.SavePeriod(update), "resave period")
+	expectEqual(t, update.ID, earlier.ID, "resaved period ID")
+
+	periods, err := store.Events.ListPeriods(repository.EventPeriodFilter{EventID: event.ID})
+	must(t, err, "list periods")
+	if len(periods) != 2 {
+		t.Fatalf("expected 2 periods, got %d", len(periods))
+	}
+	expectEqual(t, periods[0].PCOEventPeriodID, "p1", "periods in start order")
+	expectEqual(t, periods[0].RegularCount, 9, "updated count")
+
+	periods, err = store.Events.ListPeriods(repository.EventPeriodFilter{From: base.Add(time.Hour), To: base.Add(8 * 24 * time.Hour)})
+	must(t, err, "list periods in range")
+	if len(periods) != 1 || periods[0].PCOEventPeriodID != "p2" {
+		t.Errorf("expected only p2 inside the range")
+	}
+
+	times := []*models.EventTime{
+		{PCOEventTimeID: "t2", EventPeriodID: earlier.ID, EventID: event.ID, Name: "Late", StartsAt: base.Add(2 * time.Hour)},
+		{PCOEventTimeID: "t1", EventPeriodID: earlier.ID, EventID: event.ID, Name: "Early", StartsAt: base},
+		{PCOEventTimeID: "t3", EventPeriodID: later.ID, EventID: event.ID, StartsAt: later.StartsAt},
+	}
+	for _, et := range times {
+		must(t, store.Events.SaveTime(et), "save time "+et.PCOEventTimeID)
+	}
+	renamed := &models.EventTime{PCOEventTimeID: "t1", EventPeriodID: earlier.ID, EventID: event.ID, Name: "First", StartsAt: base}
+	must(t, store.Events.SaveTime(renamed), "resave time")
+	expectEqual(t, renamed.ID, times[1].ID, "resaved time ID")
+
+	got, err := store.Events.ListTimes([]uint{earlier.ID})
+	must(t, err, "list times")
+	if len(got) != 2 {
+		t.Fatalf("expected 2 times, got %d", len(got))
+	}
+	expectEqual(t, got[0].Name, "First", "times in start order")
+
+	got, err = store.Events.ListTimes(nil)
+	must(t, err, "list no times")
+	expectEqual(t, len(got), 0, "times for no periods")
+
+	// Deleting the event takes its periods and times with it
+	must(t, store.Events.Delete(event.ID), "delete event")
+	periods, err = store.Events.ListPeriods(repository.EventPeriodFilter{EventID: event.ID})
+	must(t, err, "list periods after delete")
+	expectEqual(t, len(periods), 0, "periods after delete")
+	got, err = store.Events.ListTimes([]uint{earlier.ID, later.ID})
+	must(t, err, "list times after delete")
+	expectEqual(t, len(got), 0, "times after delete")
+}
+
+func testBillboardStates(t T, store *repository.Store) {
+	states := []*models.BillboardState{
+		{LocationID: "loc-1", LocationName: "Nursery", SecurityCodes: []string{"AAA"}, IsActive: true, CreatedBy: "pco-1"},
+		{LocationID: "loc-2", LocationName: "Toddlers", SecurityCodes: []string{"BBB", "CCC"}, IsActive: true, CreatedBy: "pco-1"},
+	}
+	for _, s := range states {
+		must(t, store.BillboardStates.Create(s), "create billboard "+s.LocationID)
+	}
+
+	active, err := store.BillboardStates.GetActive()
+	must(t, err, "get active")
+	expectEqual(t, active.LocationID, "loc-2", "latest active billboard")
+	expectEqual(t, len(active.SecurityCodes), 2, "security codes")
+
+	listed, err := store.BillboardStates.ListActive("")
+	must(t, err, "list active")
+	expectEqual(t, len(listed), 2, "active billboards")
+
+	cleared, err := store.BillboardStates.Deactivate("loc-2")
+	must(t, err, "deactivate loc-2")
+	expectEqual(t, cleared, int64(1), "billboards cleared")
+
+	active, err = store.BillboardStates.GetActive()
+	must(t, err, "get active after clear")
+	expectEqual(t, active.LocationID, "loc-1", "remaining active billboard")
+
+	got, err := store.BillboardStates.GetByLocation("loc-2")
+	must(t, err, "get by location")
+	if got.IsActive {
+		t.Errorf("expected loc-2 to be inactive")
+	}
+	got.IsActive = true
+	got.SecurityCodes = []string{"DDD"}
+	must(t, store.BillboardStates.Save(got), "save billboard")
+
+	listed, err = store.BillboardStates.ListActive("loc-2")
+	must(t, err, "list active at loc-2")
+	if len(listed) != 1 || listed[0].SecurityCodes[0] != "DDD" {
+		t.Errorf("expected saved billboard to be active with code DDD")
+	}
+
+	cleared, err = store.BillboardStates.Deactivate("")
+	must(t, err, "deactivate all")
+	expectEqual(t, cleared, int64(2), "all billboards cleared")
+
+	_, err = store.BillboardStates.GetActive()
+	expectErr(t, err, repository.ErrNotFound, "get active with none active")
+}
+
+func testSecurityCodes(t T, store *repository.Store) {
+	code := &models.SecurityCode{Code: "ABC", IsActive: true, CreatedBy: "pco-1"}
+	must(t, store.SecurityCodes.Create(code), "create code")
+	must(t, store.SecurityCodes.Create(&models.SecurityCode{Code: "XYZ", IsActive: true, CreatedBy: "pco-1"}), "create code")
+	expectErr(t, store.SecurityCodes.Create(&models.SecurityCode{Code: "ABC", IsActive: true, CreatedBy: "pco-1"}), repository.ErrDuplicate, "create duplicate code")
+
+	got, err := store.SecurityCodes.GetByCode("ABC")
+	must(t, err, "get by code")
+	expectEqual(t, got.ID, code.ID, "code ID")
+
+	codes, err := store.SecurityCodes.ListActive()
+	must(t, err, "list active")
+	if len(codes) != 2 {
+		t.Fatalf("expected 2 codes, got %d", len(codes))
+	}
+	expectEqual(t, codes[0].Code, "ABC", "codes in creation order")
+
+	must(t, store.SecurityCodes.Delete(code.ID), "delete code")
+	_, err = store.SecurityCodes.GetByCode("ABC")
+	expectErr(t, err, repository.ErrNotFound, "get deleted code")
+
+	must(t, store.SecurityCodes.Create(&models.SecurityCode{Code: "ABC", IsActive: true, CreatedBy: "pco-1"}), "re-add a removed code")
+}
@@ -0,0 +1,243 @@
+package repotest
+
+import (
+	"encoding/json"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+)
+
+func newUser(pcoUserID, name string) *models.User {
+	return &models.User{
+		PCOUserID:    pcoUserID,
+		Name:         name,
+		Email:        pcoUserID + "@example.com",
+		AccessToken:  "access-" + pcoUserID,
+		RefreshToken: "refresh-" + pcoUserID,
+		TokenExpiry:  base.Add(time.Hour),
+		IsActive:     true,
+	}
+}
+
+func testUsers(t T, store *repository.Store) {
+	bob := newUser("pco-2", "Bob")
+	alice := newUser("pco-1", "Alice")
+	must(t, store.Users.Create(bob), "create bob")
+	must(t, store.Users.Create(alice), "create alice")
+	if bob.ID == 0 || alice.ID == 0 || bob.ID == alice.ID {
+		t.Fatalf("expected distinct non-zero IDs, got %d and %d", bob.ID, alice.ID)
+	}
+
+	expectErr(t, store.Users.Create(newUser("pco-1", "Duplicate")), repository.ErrDuplicate, "create duplicate pco_user_id")
+
+	got, err := store.Users.GetByPCOUserID("pco-1")
+	must(t, err, "get by PCO user ID")
+	expectEqual(t, got.ID, alice.ID, "user ID")
+	expectEqual(t, got.AccessToken, "access-pco-1", "access token round trip")
+	expectEqual(t, got.RefreshToken, "refresh-pco-1", "refresh token round trip")
+	expectTime(t, got.TokenExpiry, base.Add(time.Hour), "token expiry")
+
+	_, err = store.Users.GetByID(9999)
+	expectErr(t, err, repository.ErrNotFound, "get missing user")
+
+	must(t, store.Users.SetAdmin(bob.ID, true), "set admin")
+	expectErr(t, store.Users.SetAdmin(9999, true), repository.ErrNotFound, "set admin on missing user")
+
+	got, err = store.Users.GetByID(bob.ID)
+	must(t, err, "get by ID")
+	if !got.IsAdmin {
+		t.Errorf("expected bob to be an admin")
+	}
+
+	must(t, store.Users.SetMedicalAccess(alice.ID, true), "grant medical access")
+	expectErr(t, store.Users.SetMedicalAccess(9999, true), repository.ErrNotFound, "grant medical access to missing user")
+	got, err = store.Users.GetByID(alice.ID)
+	must(t, err, "get by ID")
+	expectEqual(t, got.MedicalAccess, true, "medical access")
+	expectEqual(t, got.ContactAccess, false, "contact access is granted separately")
+
+	must(t, store.Users.SetContactAccess(alice.ID, true), "grant contact access")
+	expectErr(t, store.Users.SetContactAccess(9999, true), repository.ErrNotFound, "grant contact access to missing user")
+	got, err = store.Users.GetByID(alice.ID)
+	must(t, err, "get by ID")
+	expectEqual(t, got.ContactAccess, true, "contact access")
+
+	must(t, store.Users.SetCalendarToken(alice.ID, "calendar-hash"), "set calendar token")
+	expectErr(t, store.Users.SetCalendarToken(9999, "other"), repository.ErrNotFound, "set calendar token on missing user")
+	got, err = store.Users.GetByCalendarToken("calendar-hash")
+	must(t, err, "get by calendar token")
+	expectEqual(t, got.ID, alice.ID, "calendar token user")
+	_, err = store.Users.GetByCalendarToken("")
+	expectErr(t, err, repository.ErrNotFound, "get by empty calendar token")
+	must(t, store.Users.SetCalendarToken(alice.ID, ""), "revoke calendar token")
+	_, err = store.Users.GetByCalendarToken("calendar-hash")
+	expectErr(t, err, repository.ErrNotFound, "get by revoked calendar token")
+
+	got, err = store.Users.GetByID(bob.ID)
+	must(t, err, "get by ID")
+	got.Name = "Robert"
+	got.AccessToken = "rotated"
+	must(t, store.Users.Save(got), "save user")
+	got, err = store.Users.GetByID(bob.ID)
+	must(t, err, "reload user")
+	expectEqual(t, got.Name, "Robert", "saved name")
+	expectEqual(t, got.AccessToken, "rotated", "saved access token")
+	if !got.IsAdmin {
+		t.Errorf("save lost the admin flag")
+	}
+
+	must(t, store.Users.TouchLastActivity(alice.ID, base), "touch last activity")
+	got, err = store.Users.GetByID(alice.ID)
+	must(t, err, "reload user")
+	expectTime(t, got.LastActivity, base, "last activity")
+
+	users, err := store.Users.List()
+	must(t, err, "list users")
+	if len(users) != 2 {
+		t.Fatalf("expected 2 users, got %d", len(users))
+	}
+	expectEqual(t, users[0].Name, "Alice", "first user by name")
+	expectEqual(t, users[1].Name, "Robert", "second user by name")
+}
+
+func testSessions(t T, store *repository.Store) {
+	user := newUser("pco-1", "Alice")
+	must(t, store.Users.Create(user), "create user")
+
+	sessions := []*models.Session{
+		{Token: "current", UserID: user.ID, ExpiresAt: base.Add(time.Hour), LastActivity: base},
+		{Token: "idle", UserID: user.ID, ExpiresAt: base.Add(time.Hour), LastActivity: base.Add(-2 * time.Hour)},
+		{Token: "remembered", UserID: user.ID, ExpiresAt: base.Add(time.Hour), IsRememberMe: true, LastActivity: base.Add(-3 * time.Hour)},
+		{Token: "expired", UserID: user.ID, ExpiresAt: base.Add(-time.Minute), LastActivity: base.Add(-time.Minute)},
+	}
+	for _, s := range sessions {
+		must(t, store.Sessions.Create(s), "create session "+s.Token)
+	}
+	expectErr(t, store.Sessions.Create(&models.Session{Token: "current", UserID: user.ID, ExpiresAt: base}), repository.ErrDuplicate, "create duplicate token")
+
+	got, err := store.Sessions.GetByToken("current")
+	must(t, err, "get by token")
+	expectEqual(t, got.ID, sessions[0].ID, "session ID")
+	expectEqual(t, got.User.Name, "Alice", "session user")
+
+	_, err = store.Sessions.GetByToken("missing")
+	expectErr(t, err, repository.ErrNotFound, "get missing token")
+
+	filter := repository.SessionFilter{UserID: user.ID, ActiveAt: base, IdleBefore: base.Add(-time.Hour)}
+	count, err := store.Sessions.Count(filter)
+	must(t, err, "count active sessions")
+	expectEqual(t, count, int64(2), "active session count")
+
+	filter.WithUser = true
+	listed, err := store.Sessions.List(filter)
+	must(t, err, "list active sessions")
+	if len(listed) != 2 {
+		t.Fatalf("expected 2 active sessions, got %d", len(listed))
+	}
+	expectEqual(t, listed[0].Token, "current", "most recently active session")
+	expectEqual(t, listed[1].User.Name, "Alice", "listed session user")
+
+	must(t, store.Sessions.TouchActivity(sessions[1].ID, base.Add(time.Minute)), "touch session")
+	got, err = store.Sessions.GetByID(sessions[1].ID)
+	must(t, err, "get by ID")
+	expectTime(t, got.LastActivity, base.Add(time.Minute), "touched last activity")
+
+	removed, err := store.Sessions.DeleteExpired(base, base.Add(-time.Hour))
+	must(t, err, "delete expired")
+	expectEqual(t, removed, int64(1), "expired sessions removed")
+
+	must(t, store.Sessions.DeleteByUser(user.ID, sessions[0].ID), "delete other sessions")
+	count, err = store.Sessions.Count(repository.SessionFilter{UserID: user.ID})
+	must(t, err, "count remaining sessions")
+	expectEqual(t, count, int64(1), "sessions left after revoking others")
+
+	must(t, store.Sessions.DeleteByToken("current"), "delete by token")
+	_, err = store.Sessions.GetByID(sessions[0].ID)
+	expectErr(t, err, repository.ErrNotFound, "get deleted session")
+}
+
+func testAPIKeys(t T, store *repository.Store) {
+	user := newUser("pco-1", "Alice")
+	must(t, store.Users.Create(user), "create user")
+
+	older := &models.APIKey{Name: "Kiosk", Prefix: "pca_aaaa", KeyHash: "hash-a", Scopes: []string{"read"}, UserID: user.ID, CreatedBy: "pco-1", CreatedAt: base}
+	newer := &models.APIKey{Name: "Sign", Prefix: "pca_bbbb", KeyHash: "hash-b", Scopes: []string{"read", "billboard:write"}, LocationID: "loc-1", UserID: user.ID, CreatedBy: "pco-1", CreatedAt: base.Add(time.Minute)}
+	must(t, store.APIKeys.Create(older), "create key")
+	must(t, store.APIKeys.Create(newer), "create key")
+	expectErr(t, store.APIKeys.Create(&models.APIKey{Name: "Dup", Prefix: "pca_cccc", KeyHash: "hash-a", UserID: user.ID, CreatedBy: "pco-1"}), repository.ErrDuplicate, "create duplicate hash")
+
+	got, err := store.APIKeys.GetByHash("hash-b")
+	must(t, err, "get by hash")
+	expectEqual(t, got.ID, newer.ID, "key ID")
+	expectEqual(t, got.User.PCOUserID, "pco-1", "key user")
+	expectEqual(t, len(got.Scopes), 2, "scope count")
+	expectEqual(t, got.LocationID, "loc-1", "location restriction")
+
+	_, err = store.APIKeys.GetByHash("missing")
+	expectErr(t, err, repository.ErrNotFound, "get missing hash")
+
+	keys, err := store.APIKeys.List()
+	must(t, err, "list keys")
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 keys, got %d", len(keys))
+	}
+	expectEqual(t, keys[0].ID, newer.ID, "newest key first")
+
+	must(t, store.APIKeys.RecordUse(older.ID, base.Add(time.Hour), "10.0.0.1"), "record use")
+	must(t, store.APIKeys.Revoke(older.ID, base.Add(2*time.Hour)), "revoke")
+	must(t, store.APIKeys.Revoke(older.ID, base.Add(3*time.Hour)), "revoke again")
+
+	got, err = store.APIKeys.GetByID(older.ID)
+	must(t, err, "get by ID")
+	if got.LastUsedAt == nil || got.RevokedAt == nil {
+		t.Fatalf("expected last_used_at and revoked_at to be set")
+	}
+	expectTime(t, *got.LastUsedAt, base.Add(time.Hour), "last used at")
+	expectEqual(t, got.LastUsedIP, "10.0.0.1", "last used IP")
+	expectTime(t, *got.RevokedAt, base.Add(2*time.Hour), "revoking twice keeps the first time")
+}
+
+func testAuditEvents(t T, store *repository.Store) {
+	events := []*models.AuditEvent{
+		{ActorID: 1, ActorName: "Alice", Action: models.AuditSecurityCodeAdd, TargetType: "security_code", TargetID: "ABC", After: map[string]interface{}{"code": "ABC"}, CreatedAt: base},
+		{ActorID: 2, ActorName: "Bob", Action: models.AuditBillboardLaunch, TargetType: "location", TargetID: "loc-1", CreatedAt: base.Add(time.Minute)},
+		{ActorID: 1, ActorName: "Alice", Action: models.AuditSecurityCodeRemove, TargetType: "security_code", TargetID: "ABC", Before: map[string]interface{}{"code": "ABC", "active": true}, CreatedAt: base.Add(2 * time.Minute)},
+	}
+	for _, e := range events {
+		must(t, store.AuditEvents.Create(e), "record "+e.Action)
+	}
+
+	listed, total, err := store.AuditEvents.List(repository.AuditFilter{})
+	must(t, err, "list all")
+	expectEqual(t, total, int64(3), "total events")
+	if len(listed) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(listed))
+	}
+	expectEqual(t, listed[0].Action, models.AuditSecurityCodeRemove, "newest event first")
+
+	before, _ := json.Marshal(listed[0].Before)
+	expectEqual(t, string(before), `{"active":true,"code":"ABC"}`, "before payload")
+	if listed[0].After != nil {
+		t.Errorf("expected no after payload, got %v", listed[0].After)
+	}
+
+	listed, total, err = store.AuditEvents.List(repository.AuditFilter{ActorID: 1, TargetType: "security_code", Limit: 1})
+	must(t, err, "list filtered")
+	expectEqual(t, total, int64(2), "filtered total ignores limit")
+	if len(listed) != 1 {
+		t.Fatalf("expected limit to return 1 event, got %d", len(listed))
+	}
+
+	listed, _, err = store.AuditEvents.List(repository.AuditFilter{Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute)})
+	must(t, err, "list time window")
+	if len(listed) != 1 || listed[0].Action != models.AuditBillboardLaunch {
+		t.Errorf("expected only the launch event inside the window, got %d events", len(listed))
+	}
+
+	listed, _, err = store.AuditEvents.List(repository.AuditFilter{Offset: 2})
+	must(t, err, "list with offset")
+	if len(listed) != 1 || listed[0].Action != models.AuditSecurityCodeAdd {
+		t.Errorf("expected offset to skip to the oldest event")
+	}
+}
@@ -0,0 +1,55 @@
+package repotest_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/database"
+	"go_pco_arrivals/internal/repository"
+
+	"gorm.io/gorm/logger"
+)
+
+// TestPostgresStore runs the suite in throwaway schemas on the server named by
+// TEST_POSTGRES_DSN. Each connection's time zone is set away from UTC so that
+// day and hour bucketing can't depend on the session settings.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+
+	conn, err := database.ConnectPostgres(config.DatabaseConfig{URL: dsn})
+	if err != nil {
+		t.Fatalf("failed to connect to PostgreSQL: %v", err)
+	}
+	conn.GetGormDB().Logger = logger.Default.LogMode(logger.Silent)
+	t.Cleanup(func() { conn.Close() })
+
+	prefix := fmt.Sprintf("repotest_%d", time.Now().UnixNano())
+	runSuite(t, func(t *testing.T, name string) *repository.Store {
+		scoped, err := conn.WithSchema(prefix + "_" + name)
+		if err != nil {
+			t.Fatalf("failed to create schema: %v", err)
+		}
+		t.Cleanup(func() {
+			scoped.DropSchema()
+			scoped.Close()
+		})
+
+		// A single connection keeps the session time zone for the whole case
+		if err := database.ConfigureConnectionPool(scoped.GetGormDB(), 1, 1, 0); err != nil {
+			t.Fatalf("failed to configure connection pool: %v", err)
+		}
+		if err := scoped.GetGormDB().Exec("SET TIME ZONE 'Pacific/Auckland'").Error; err != nil {
+			t.Fatalf("failed to set session time zone: %v", err)
+		}
+		if err := scoped.Migrate(); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+		return repository.NewGormStore(scoped.GetGormDB())
+	})
+}
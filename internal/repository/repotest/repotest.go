@@ -0,0 +1,153 @@
+// Package repotest is a conformance suite that every repository.Store
+// implementation must pass. It is run by go test and, against live
+// backends, by the "check-storage" command.
+package repotest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go_pco_arrivals/internal/repository"
+)
+
+// T is the subset of testing.T used by the suite
+type T interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// Case is one conformance check run against a fresh, empty store
+type Case struct {
+	Name string
+	Run  func(t T, store *repository.Store)
+}
+
+// Result is the outcome of one case
+type Result struct {
+	Name   string
+	Errors []string
+}
+
+// Passed reports whether the case recorded no errors
+func (r Result) Passed() bool {
+	return len(r.Errors) == 0
+}
+
+// Cases lists every conformance check
+var Cases = []Case{
+	{"users", testUsers},
+	{"sessions", testSessions},
+	{"api_keys", testAPIKeys},
+	{"audit_events", testAuditEvents},
+	{"check_ins", testCheckIns},
+	{"check_in_upsert", testCheckInUpsert},
+	{"check_in_analytics", testCheckInAnalytics},
+	{"notifications", testNotifications},
+	{"locations", testLocations},
+	{"events", testEvents},
+	{"event_periods", testEventPeriods},
+	{"billboard_states", testBillboardStates},
+	{"security_codes", testSecurityCodes},
+	{"rollups", testRollups},
+	{"visitors", testVisitors},
+}
+
+// Run executes every case. newStore must return an empty store for each case
+// and a cleanup function to release it.
+func Run(newStore func(name string) (*repository.Store, func(), error)) []Result {
+	results := make([]Result, 0, len(Cases))
+	for _, c := range Cases {
+		results = append(results, runCase(c, newStore))
+	}
+	return results
+}
+
+// errFatal unwinds a case after Fatalf
+var errFatal = errors.New("fatal")
+
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	panic(errFatal)
+}
+
+func runCase(c Case, newStore func(name string) (*repository.Store, func(), error)) (result Result) {
+	result.Name = c.Name
+	rec := &recorder{}
+
+	store, cleanup, err := newStore(c.Name)
+	if err != nil {
+		result.Errors = []string{fmt.Sprintf("failed to create store: %v", err)}
+		return result
+	}
+	defer cleanup()
+
+	defer func() {
+		if r := recover(); r != nil && r != errFatal {
+			rec.errors = append(rec.errors, fmt.Sprintf("panic: %v", r))
+		}
+		result.Errors = rec.errors
+	}()
+
+	c.Run(rec, store)
+	return result
+}
+
+// Summary formats results as one line per case
+func Summary(results []Result) string {
+	var b strings.Builder
+	for _, r := range results {
+		if r.Passed() {
+			fmt.Fprintf(&b, "PASS %s\n", r.Name)
+			continue
+		}
+		fmt.Fprintf(&b, "FAIL %s\n", r.Name)
+		for _, e := range r.Errors {
+			fmt.Fprintf(&b, "     %s\n", e)
+		}
+	}
+	return b.String()
+}
+
+// base is a fixed, second-aligned UTC time so every backend stores it exactly
+var base = time.Date(2024, time.March, 10, 9, 0, 0, 0, time.UTC)
+
+func must(t T, err error, action string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", action, err)
+	}
+}
+
+func expectErr(t T, err, want error, action string) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Errorf("%s: got error %v, want %v", action, err, want)
+	}
+}
+
+func expectEqual(t T, got, want interface{}, what string) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s: got %v, want %v", what, got, want)
+	}
+}
+
+func expectTime(t T, got, want time.Time, what string) {
+	t.Helper()
+	if !got.Equal(want) {
+		t.Errorf("%s: got %s, want %s", what, got, want)
+	}
+}
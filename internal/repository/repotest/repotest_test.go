@@ -0,0 +1,79 @@
+package repotest_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/database"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/repository/repotest"
+
+	"gorm.io/gorm/logger"
+)
+
+// runSuite runs every conformance case as a subtest against a fresh store
+func runSuite(t *testing.T, newStore func(t *testing.T, name string) *repository.Store) {
+	for _, c := range repotest.Cases {
+		t.Run(c.Name, func(t *testing.T) {
+			c.Run(t, newStore(t, c.Name))
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	runSuite(t, func(t *testing.T, name string) *repository.Store {
+		return repository.NewMemoryStore()
+	})
+}
+
+func TestSQLiteStore(t *testing.T) {
+	runSuite(t, func(t *testing.T, name string) *repository.Store {
+		gormDB, err := database.ConnectLegacy(config.DatabaseConfig{URL: ":memory:"})
+		if err != nil {
+			t.Fatalf("failed to open SQLite: %v", err)
+		}
+		gormDB.Logger = logger.Default.LogMode(logger.Silent)
+		t.Cleanup(func() {
+			if sqlDB, err := gormDB.DB(); err == nil {
+				sqlDB.Close()
+			}
+		})
+
+		// Every connection to :memory: is a separate database
+		if err := database.ConfigureConnectionPool(gormDB, 1, 1, 0); err != nil {
+			t.Fatalf("failed to configure connection pool: %v", err)
+		}
+		if err := database.MigrateLegacy(gormDB); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+		return repository.NewGormStore(gormDB)
+	})
+}
+
+// TestMongoStore runs the suite in throwaway databases on the server named by
+// TEST_MONGODB_URI
+func TestMongoStore(t *testing.T) {
+	uri := os.Getenv("TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGODB_URI not set")
+	}
+
+	prefix := fmt.Sprintf("repotest_%d", time.Now().UnixNano())
+	conn, err := database.ConnectMongo(uri, prefix)
+	if err != nil {
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	runSuite(t, func(t *testing.T, name string) *repository.Store {
+		mongoDB := conn.WithDatabase(prefix + "_" + name)
+		t.Cleanup(func() { mongoDB.Drop() })
+		if err := mongoDB.Migrate(); err != nil {
+			t.Fatalf("failed to migrate: %v", err)
+		}
+		return repository.NewMongoStore(mongoDB)
+	})
+}
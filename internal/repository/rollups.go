@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+)
+
+// planVisitors merges incoming first check-ins with the stored visitors. It
+// returns the rows to write, which carry the stored ID when they replace a
+// record, and the stored records they supersede.
+func planVisitors(incoming, existing []models.Visitor, now time.Time) (write, moved []models.Visitor) {
+	earliest := make(map[string]models.Visitor, len(incoming))
+	for _, visitor := range incoming {
+		if visitor.PersonID == "" {
+			continue
+		}
+		if seen, ok := earliest[visitor.PersonID]; !ok || visitor.FirstCheckInAt.Before(seen.FirstCheckInAt) {
+			earliest[visitor.PersonID] = visitor
+		}
+	}
+
+	stored := make(map[string]models.Visitor, len(existing))
+	for _, visitor := range existing {
+		stored[visitor.PersonID] = visitor
+	}
+
+	for _, visitor := range earliest {
+		visitor.ID = 0
+		visitor.CreatedAt = now
+		visitor.UpdatedAt = now
+		if previous, ok := stored[visitor.PersonID]; ok {
+			if !visitor.FirstCheckInAt.Before(previous.FirstCheckInAt) {
+				continue
+			}
+			visitor.ID = previous.ID
+			visitor.CreatedAt = previous.CreatedAt
+			moved = append(moved, previous)
+		}
+		write = append(write, visitor)
+	}
+
+	sort.Slice(write, func(i, j int) bool { return write[i].PersonID < write[j].PersonID })
+	return write, moved
+}
+
+// visitorPersonIDs returns the distinct person IDs of visitors
+func visitorPersonIDs(visitors []models.Visitor) []string {
+	seen := make(map[string]bool, len(visitors))
+	ids := make([]string, 0, len(visitors))
+	for _, visitor := range visitors {
+		if visitor.PersonID != "" && !seen[visitor.PersonID] {
+			seen[visitor.PersonID] = true
+			ids = append(ids, visitor.PersonID)
+		}
+	}
+	return ids
+}
+
+// stampRollups returns a copy of rollups ready to insert
+func stampRollups(rollups []models.AttendanceRollup, now time.Time) []models.AttendanceRollup {
+	stamped := make([]models.AttendanceRollup, len(rollups))
+	for i, rollup := range rollups {
+		rollup.ID = 0
+		rollup.CreatedAt = now
+		rollup.UpdatedAt = now
+		stamped[i] = rollup
+	}
+	return stamped
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// apiKeyPrefix identifies API keys presented as bearer tokens
+const apiKeyPrefix = "pcoa_"
+
+// CreateAPIKey issues a new API key acting on behalf of user. The plaintext
+// key is returned only once; only its hash is stored.
+func (s *AuthService) CreateAPIKey(user *models.User, name string, scopes []string, locationID string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", utils.ErrInvalidInput)
+	}
+	if len(scopes) == 0 {
+		return nil, "", fmt.Errorf("%w: at least one scope is required", utils.ErrInvalidInput)
+	}
+	for _, scope := range scopes {
+		if !isValidScope(scope) {
+			return nil, "", fmt.Errorf("%w: unknown scope %q", utils.ErrInvalidInput, scope)
+		}
+	}
+	if locationID != "" && !utils.ValidateLocationID(locationID) {
+		return nil, "", fmt.Errorf("%w: invalid location ID", utils.ErrInvalidInput)
+	}
+	if expiresAt != nil && expiresAt.Before(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expiry must be in the future", utils.ErrInvalidInput)
+	}
+
+	prefix := utils.GenerateID()[:8]
+	secret, err := utils.GenerateSecureToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	rawKey := apiKeyPrefix + prefix + "_" + strings.TrimRight(secret, "=")
+
+	apiKey := &models.APIKey{
+		Name:       name,
+		Prefix:     prefix,
+		KeyHash:    hashAPIKey(rawKey),
+		Scopes:     scopes,
+		LocationID: locationID,
+		UserID:     user.ID,
+		ExpiresAt:  expiresAt,
+		CreatedBy:  user.Name,
+	}
+
+	if err := s.store.APIKeys.Create(apiKey); err != nil {
+		return nil, "", fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return apiKey, rawKey, nil
+}
+
+// ListAPIKeys returns every API key, including revoked ones
+func (s *AuthService) ListAPIKeys() ([]models.APIKey, error) {
+	keys, err := s.store.APIKeys.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// GetAPIKeyByID retrieves an API key by ID
+func (s *AuthService) GetAPIKeyByID(id uint) (*models.APIKey, error) {
+	key, err := s.store.APIKeys.GetByID(id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("API key not found")
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	return key, nil
+}
+
+// RevokeAPIKey marks an API key as revoked so it can no longer authenticate
+func (s *AuthService) RevokeAPIKey(id uint) error {
+	if err := s.store.APIKeys.Revoke(id, time.Now()); err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+	return nil
+}
+
+// ValidateAPIKey checks a plaintext API key and records its use
+func (s *AuthService) ValidateAPIKey(rawKey, ipAddress string) (*models.APIKey, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, fmt.Errorf("invalid API key")
+	}
+
+	key, err := s.store.APIKeys.GetByHash(hashAPIKey(rawKey))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("invalid API key")
+		}
+		return nil, fmt.Errorf("failed to validate API key: %w", err)
+	}
+
+	if err := s.checkAPIKeyUsable(key); err != nil {
+		return nil, err
+	}
+
+	// Record usage, throttled like session activity
+	writeInterval := time.Duration(s.config.Auth.SessionActivityWrite) * time.Second
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) >= writeInterval || key.LastUsedIP != ipAddress {
+		now := time.Now()
+		if err := s.store.APIKeys.RecordUse(key.ID, now, ipAddress); err != nil {
+			s.logger.Error("Failed to record API key usage", "error", err, "api_key_id", key.ID)
+		}
+		key.LastUsedAt = &now
+		key.LastUsedIP = ipAddress
+	}
+
+	return key, nil
+}
+
+// IssueAPIToken exchanges an API key for a short-lived signed token carrying
+// the key's scopes, so integrations need not send the long-lived key on every call
+func (s *AuthService) IssueAPIToken(rawKey, ipAddress string) (string, time.Time, error) {
+	key, err := s.ValidateAPIKey(rawKey, ipAddress)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(time.Duration(s.config.Auth.APITokenTTL) * time.Second)
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(expiresAt) {
+		expiresAt = *key.ExpiresAt
+	}
+
+	claims := &Claims{
+		UserID:     key.User.ID,
+		PCOUserID:  key.User.PCOUserID,
+		Email:      key.User.Email,
+		APIKeyID:   key.ID,
+		Scopes:     key.Scopes,
+		LocationID: key.LocationID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    "go_pco_arrivals",
+			Subject:   fmt.Sprintf("api_key:%d", key.ID),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString([]byte(s.config.Auth.JWTSecret))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	return signed, expiresAt, nil
+}
+
+// ValidateBearer authenticates an Authorization bearer credential, which is
+// either an API key or a token issued by IssueAPIToken
+func (s *AuthService) ValidateBearer(credential, ipAddress string) (*SessionData, error) {
+	if strings.HasPrefix(credential, apiKeyPrefix) {
+		key, err := s.ValidateAPIKey(credential, ipAddress)
+		if err != nil {
+			return nil, err
+		}
+
+		expiresAt := time.Time{}
+		if key.ExpiresAt != nil {
+			expiresAt = *key.ExpiresAt
+		}
+
+		return &SessionData{
+			UserID:        key.User.ID,
+			PCOUserID:     key.User.PCOUserID,
+			Email:         key.User.Email,
+			ExpiresAt:     expiresAt,
+			AuthMethod:    AuthMethodAPIKey,
+			APIKeyID:      key.ID,
+			Scopes:        key.Scopes,
+			LocationID:    key.LocationID,
+			MedicalAccess: key.HasScope(models.ScopeMedicalRead),
+			ContactAccess: key.HasScope(models.ScopeContactsRead),
+		}, nil
+	}
+
+	claims, err := s.ValidateJWT(credential)
+	if err != nil {
+		return nil, err
+	}
+	if claims.APIKeyID == 0 {
+		return nil, fmt.Errorf("token was not issued for an API key")
+	}
+
+	// Tokens are short-lived, but still honour revocation of the key immediately
+	key, err := s.GetAPIKeyByID(claims.APIKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkAPIKeyUsable(key); err != nil {
+		return nil, err
+	}
+
+	return &SessionData{
+		UserID:        claims.UserID,
+		PCOUserID:     claims.PCOUserID,
+		Email:         claims.Email,
+		ExpiresAt:     claims.ExpiresAt.Time,
+		AuthMethod:    AuthMethodToken,
+		APIKeyID:      claims.APIKeyID,
+		Scopes:        claims.Scopes,
+		LocationID:    claims.LocationID,
+		MedicalAccess: key.HasScope(models.ScopeMedicalRead),
+		ContactAccess: key.HasScope(models.ScopeContactsRead),
+	}, nil
+}
+
+// TrustProxy reports whether client addresses may be taken from
+// X-Forwarded-For when recording where API keys are used from
+func (s *AuthService) TrustProxy() bool {
+	return s.config.Server.TrustProxy
+}
+
+// ValidateBearerForMiddleware implements the middleware interface
+func (s *AuthService) ValidateBearerForMiddleware(credential, ipAddress string) (interface{}, error) {
+	sessionData, err := s.ValidateBearer(credential, ipAddress)
+	if err != nil {
+		return nil, err
+	}
+	return sessionData, nil
+}
+
+// checkAPIKeyUsable rejects revoked or expired keys and keys whose owner was
+// deactivated or no longer exists
+func (s *AuthService) checkAPIKeyUsable(key *models.APIKey) error {
+	if key.RevokedAt != nil {
+		return fmt.Errorf("API key has been revoked")
+	}
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(time.Now()) {
+		return fmt.Errorf("API key has expired")
+	}
+	if key.User.ID == 0 || !key.User.IsActive {
+		return fmt.Errorf("API key owner is inactive or missing")
+	}
+	return nil
+}
+
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func isValidScope(scope string) bool {
+	for _, valid := range models.APIKeyScopes {
+		if scope == valid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/database"
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/utils"
+
+	"gorm.io/gorm/logger"
+)
+
+func TestAPIKeyOfDeletedOwner(t *testing.T) {
+	db, err := database.ConnectLegacy(config.DatabaseConfig{URL: ":memory:"})
+	if err != nil {
+		t.Fatalf("failed to open SQLite: %v", err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	// Every connection to :memory: is a separate database
+	if err := database.ConfigureConnectionPool(db, 1, 1, 0); err != nil {
+		t.Fatalf("failed to configure connection pool: %v", err)
+	}
+	if err := database.MigrateLegacy(db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	cfg := &config.Config{Auth: config.AuthConfig{JWTSecret: "test-secret", APITokenTTL: 3600}}
+	auth := NewAuthService(cfg, repository.NewGormStore(db), utils.NewLogger(), nil)
+
+	owner := &models.User{PCOUserID: "pco-1", Name: "Owner", Email: "owner@example.com", IsAdmin: true, IsActive: true, AccessToken: "a", RefreshToken: "r", TokenExpiry: time.Now().AddDate(1, 0, 0)}
+	if err := db.Create(owner).Error; err != nil {
+		t.Fatalf("failed to create owner: %v", err)
+	}
+	_, rawKey, err := auth.CreateAPIKey(owner, "test key", []string{models.ScopeRead}, "", nil)
+	if err != nil {
+		t.Fatalf("failed to create API key: %v", err)
+	}
+	if _, err := auth.ValidateBearer(rawKey, "127.0.0.1"); err != nil {
+		t.Fatalf("key of an active owner: %v", err)
+	}
+
+	if err := db.Delete(&models.User{}, owner.ID).Error; err != nil {
+		t.Fatalf("failed to delete owner: %v", err)
+	}
+	if _, err := auth.ValidateBearer(rawKey, "127.0.0.1"); err == nil {
+		t.Fatalf("key of a deleted owner was accepted")
+	}
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"fmt"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/utils"
+)
+
+// maxAuditPageSize caps how many audit events a single query returns
+const maxAuditPageSize = 10000
+
+type AuditService struct {
+	config *config.Config
+	store  *repository.Store
+	logger *utils.Logger
+}
+
+func NewAuditService(config *config.Config, store *repository.Store, logger *utils.Logger) *AuditService {
+	return &AuditService{
+		config: config,
+		store:  store,
+		logger: logger,
+	}
+}
+
+// TrustProxy reports whether client addresses may be taken from X-Forwarded-For
+func (s *AuditService) TrustProxy() bool {
+	return s.config.Server.TrustProxy
+}
+
+// Record appends an event to the audit log. Failures are logged rather than
+// returned so that auditing never blocks the action being audited.
+func (s *AuditService) Record(event *models.AuditEvent) {
+	if s == nil || s.store == nil {
+		return
+	}
+
+	if err := s.store.AuditEvents.Create(event); err != nil {
+		s.logger.Error("Failed to record audit event", "error", err, "action", event.Action, "actor_id", event.ActorID)
+	}
+}
+
+// ListEvents returns audit events matching the filter, newest first, along
+// with the total number of matches
+func (s *AuditService) ListEvents(filter repository.AuditFilter) ([]models.AuditEvent, int64, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 100
+	}
+	if filter.Limit > maxAuditPageSize {
+		filter.Limit = maxAuditPageSize
+	}
+
+	events, total, err := s.store.AuditEvents.List(filter)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	return events, total, nil
+}
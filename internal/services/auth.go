@@ -1,41 +1,71 @@
 package services
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
 	"go_pco_arrivals/internal/config"
 	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
 	"go_pco_arrivals/internal/utils"
 
 	"github.com/golang-jwt/jwt/v5"
-	"gorm.io/gorm"
 )
 
 type AuthService struct {
 	config *config.Config
-	db     *gorm.DB
+	store  *repository.Store
 	logger *utils.Logger
 	pco    *PCOService
 }
 
 type Claims struct {
-	UserID    uint   `json:"user_id"`
-	PCOUserID string `json:"pco_user_id"`
-	Email     string `json:"email"`
-	IsAdmin   bool   `json:"is_admin"`
+	UserID     uint     `json:"user_id"`
+	PCOUserID  string   `json:"pco_user_id"`
+	Email      string   `json:"email"`
+	IsAdmin    bool     `json:"is_admin"`
+	APIKeyID   uint     `json:"api_key_id,omitempty"`
+	Scopes     []string `json:"scopes,omitempty"`
+	LocationID string   `json:"location_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
 type SessionData struct {
+	SessionID    uint      `json:"session_id"`
 	UserID       uint      `json:"user_id"`
 	PCOUserID    string    `json:"pco_user_id"`
 	Email        string    `json:"email"`
 	IsAdmin      bool      `json:"is_admin"`
 	IsRememberMe bool      `json:"is_remember_me"`
 	ExpiresAt    time.Time `json:"expires_at"`
+	AuthMethod   string    `json:"auth_method"`
+	APIKeyID     uint      `json:"api_key_id,omitempty"`
+	Scopes       []string  `json:"scopes,omitempty"`
+	LocationID   string    `json:"location_id,omitempty"`
+	// MedicalAccess is whether the caller may see check-ins' medical notes
+	// and emergency contacts
+	MedicalAccess bool `json:"medical_access"`
+	// ContactAccess is whether the caller may see parents' phone numbers and
+	// email addresses
+	ContactAccess bool `json:"contact_access"`
+}
+
+// Authentication methods recorded in SessionData
+const (
+	AuthMethodSession = "session"
+	AuthMethodAPIKey  = "api_key"
+	AuthMethodToken   = "token"
+)
+
+// GetSessionID returns the session ID
+func (s *SessionData) GetSessionID() uint {
+	return s.SessionID
 }
 
 // GetUserID returns the user ID
@@ -48,10 +78,40 @@ func (s *SessionData) GetIsAdmin() bool {
 	return s.IsAdmin
 }
 
-func NewAuthService(config *config.Config, db *gorm.DB, logger *utils.Logger, pco *PCOService) *AuthService {
+// GetAuthMethod returns how the caller authenticated
+func (s *SessionData) GetAuthMethod() string {
+	return s.AuthMethod
+}
+
+// GetAPIKeyID returns the API key used to authenticate, or zero for sessions
+func (s *SessionData) GetAPIKeyID() uint {
+	return s.APIKeyID
+}
+
+// GetScopes returns the scopes granted to an API key or token, or nil for sessions
+func (s *SessionData) GetScopes() []string {
+	return s.Scopes
+}
+
+// GetLocationID returns the location an API key is restricted to, if any
+func (s *SessionData) GetLocationID() string {
+	return s.LocationID
+}
+
+// GetMedicalAccess returns whether the caller may see medical details
+func (s *SessionData) GetMedicalAccess() bool {
+	return s.MedicalAccess
+}
+
+// GetContactAccess returns whether the caller may see parents' contacts
+func (s *SessionData) GetContactAccess() bool {
+	return s.ContactAccess
+}
+
+func NewAuthService(config *config.Config, store *repository.Store, logger *utils.Logger, pco *PCOService) *AuthService {
 	return &AuthService{
 		config: config,
-		db:     db,
+		store:  store,
 		logger: logger,
 		pco:    pco,
 	}
@@ -75,8 +135,14 @@ func (s *AuthService) GenerateNonce() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// SessionMetadata describes the device a session was created from
+type SessionMetadata struct {
+	UserAgent string
+	IPAddress string
+}
+
 // CreateSession creates a new session for a user
-func (s *AuthService) CreateSession(user *models.User, isRememberMe bool) (*models.Session, error) {
+func (s *AuthService) CreateSession(user *models.User, isRememberMe bool, metadata SessionMetadata) (*models.Session, error) {
 	sessionToken, err := utils.GenerateSecureToken()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate session token: %w", err)
@@ -93,13 +159,15 @@ func (s *AuthService) CreateSession(user *models.User, isRememberMe bool) (*mode
 		UserID:       user.ID,
 		Token:        sessionToken,
 		IsRememberMe: isRememberMe,
+		UserAgent:    metadata.UserAgent,
+		IPAddress:    metadata.IPAddress,
 		ExpiresAt:    expiresAt,
 		LastActivity: time.Now(),
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
 
-	if err := s.db.Create(session).Error; err != nil {
+	if err := s.store.Sessions.Create(session); err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
 
@@ -108,32 +176,78 @@ func (s *AuthService) CreateSession(user *models.User, isRememberMe bool) (*mode
 
 // ValidateSession validates a session token and returns session data
 func (s *AuthService) ValidateSession(token string) (*SessionData, error) {
-	var session models.Session
-	result := s.db.Preload("User").Where("token = ? AND expires_at > ?", token, time.Now()).First(&session)
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
+	session, err := s.store.Sessions.GetByToken(token)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return nil, fmt.Errorf("invalid or expired session")
 		}
-		return nil, fmt.Errorf("failed to validate session: %w", result.Error)
+		return nil, fmt.Errorf("failed to validate session: %w", err)
+	}
+	if !session.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("invalid or expired session")
+	}
+
+	// Expire sessions that have been idle for too long
+	if s.isIdle(session) {
+		if err := s.store.Sessions.Delete(session.ID); err != nil {
+			s.logger.Error("Failed to remove idle session", "error", err, "session_id", session.ID)
+		}
+		return nil, fmt.Errorf("session expired due to inactivity")
 	}
 
-	// Update last activity
-	session.LastActivity = time.Now()
-	s.db.Save(&session)
+	// Update last activity, but only write it periodically
+	writeInterval := time.Duration(s.config.Auth.SessionActivityWrite) * time.Second
+	if time.Since(session.LastActivity) >= writeInterval {
+		now := time.Now()
+		if err := s.store.Sessions.TouchActivity(session.ID, now); err != nil {
+			s.logger.Error("Failed to update session activity", "error", err, "session_id", session.ID)
+		}
+		session.LastActivity = now
+	}
 
 	return &SessionData{
-		UserID:       session.User.ID,
-		PCOUserID:    session.User.PCOUserID,
-		Email:        session.User.Email,
-		IsAdmin:      session.User.IsAdmin,
-		IsRememberMe: session.IsRememberMe,
-		ExpiresAt:    session.ExpiresAt,
+		SessionID:     session.ID,
+		UserID:        session.User.ID,
+		PCOUserID:     session.User.PCOUserID,
+		Email:         session.User.Email,
+		IsAdmin:       session.User.IsAdmin,
+		IsRememberMe:  session.IsRememberMe,
+		ExpiresAt:     session.ExpiresAt,
+		AuthMethod:    AuthMethodSession,
+		MedicalAccess: session.User.IsAdmin || session.User.MedicalAccess,
+		ContactAccess: session.User.IsAdmin || session.User.ContactAccess,
 	}, nil
 }
 
+// isIdle reports whether a session has exceeded the idle timeout.
+// Remember-me sessions are exempt since they are meant to outlive idle periods.
+func (s *AuthService) isIdle(session *models.Session) bool {
+	if session.IsRememberMe || s.config.Auth.SessionIdleTimeout <= 0 || session.LastActivity.IsZero() {
+		return false
+	}
+	idleTimeout := time.Duration(s.config.Auth.SessionIdleTimeout) * time.Second
+	return time.Since(session.LastActivity) > idleTimeout
+}
+
+// CSRFToken derives the anti-CSRF token for a session. It is bound to the
+// session token, which cross-site pages cannot read, so nothing is stored.
+func (s *AuthService) CSRFToken(sessionToken string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.Auth.JWTSecret))
+	mac.Write([]byte("csrf:" + sessionToken))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateCSRFToken reports whether token is the CSRF token for the session
+func (s *AuthService) ValidateCSRFToken(sessionToken, token string) bool {
+	if sessionToken == "" || token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(s.CSRFToken(sessionToken)))
+}
+
 // CreateRememberMeSession creates a long-term session for "Remember Me" functionality
 func (s *AuthService) CreateRememberMeSession(user *models.User) (*models.Session, error) {
-	return s.CreateSession(user, true)
+	return s.CreateSession(user, true, SessionMetadata{})
 }
 
 // GenerateJWT generates a JWT token for a user
@@ -177,62 +291,170 @@ func (s *AuthService) ValidateJWT(tokenString string) (*Claims, error) {
 
 // RevokeSession revokes a session by deleting it from the database
 func (s *AuthService) RevokeSession(token string) error {
-	result := s.db.Where("token = ?", token).Delete(&models.Session{})
-	if result.Error != nil {
-		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	if err := s.store.Sessions.DeleteByToken(token); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
 	}
 	return nil
 }
 
 // RevokeAllUserSessions revokes all sessions for a specific user
 func (s *AuthService) RevokeAllUserSessions(userID uint) error {
-	result := s.db.Where("user_id = ?", userID).Delete(&models.Session{})
-	if result.Error != nil {
-		return fmt.Errorf("failed to revoke user sessions: %w", result.Error)
+	if err := s.store.Sessions.DeleteByUser(userID, 0); err != nil {
+		return fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+	return nil
+}
+
+// ListUserSessions returns the active sessions belonging to a user
+func (s *AuthService) ListUserSessions(userID uint) ([]models.Session, error) {
+	filter := s.activeSessions()
+	filter.UserID = userID
+
+	sessions, err := s.store.Sessions.List(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// ListAllSessions returns the active sessions of every user
+func (s *AuthService) ListAllSessions() ([]models.Session, error) {
+	filter := s.activeSessions()
+	filter.WithUser = true
+
+	sessions, err := s.store.Sessions.List(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// GetSessionByID retrieves a session by ID
+func (s *AuthService) GetSessionByID(sessionID uint) (*models.Session, error) {
+	session, err := s.store.Sessions.GetByID(sessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("session not found")
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+// RevokeSessionByID revokes a single session by ID
+func (s *AuthService) RevokeSessionByID(sessionID uint) error {
+	if err := s.store.Sessions.Delete(sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
 	}
 	return nil
 }
 
-// CleanupExpiredSessions removes expired sessions from the database
+// RevokeOtherUserSessions revokes every session of a user except the given one
+func (s *AuthService) RevokeOtherUserSessions(userID, keepSessionID uint) error {
+	if err := s.store.Sessions.DeleteByUser(userID, keepSessionID); err != nil {
+		return fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+	return nil
+}
+
+// activeSessions filters to sessions that are neither expired nor idle
+func (s *AuthService) activeSessions() repository.SessionFilter {
+	return repository.SessionFilter{
+		ActiveAt:   time.Now(),
+		IdleBefore: s.idleCutoff(),
+	}
+}
+
+// idleCutoff is the last-activity time before which a session counts as
+// idle, or zero when the idle timeout is disabled
+func (s *AuthService) idleCutoff() time.Time {
+	if s.config.Auth.SessionIdleTimeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-time.Duration(s.config.Auth.SessionIdleTimeout) * time.Second)
+}
+
+// CountActiveSessions returns the number of sessions that are neither expired nor idle
+func (s *AuthService) CountActiveSessions() (int64, error) {
+	count, err := s.store.Sessions.Count(s.activeSessions())
+	if err != nil {
+		return 0, fmt.Errorf("failed to count sessions: %w", err)
+	}
+	return count, nil
+}
+
+// CleanupExpiredSessions removes expired and idle sessions from the database
 func (s *AuthService) CleanupExpiredSessions() error {
-	result := s.db.Where("expires_at < ?", time.Now()).Delete(&models.Session{})
-	if result.Error != nil {
-		return fmt.Errorf("failed to cleanup expired sessions: %w", result.Error)
+	if _, err := s.store.Sessions.DeleteExpired(time.Now(), s.idleCutoff()); err != nil {
+		return fmt.Errorf("failed to cleanup expired sessions: %w", err)
 	}
 	return nil
 }
 
 // GetUserByID retrieves a user by ID
 func (s *AuthService) GetUserByID(userID uint) (*models.User, error) {
-	var user models.User
-	result := s.db.First(&user, userID)
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
+	user, err := s.store.Users.GetByID(userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return nil, fmt.Errorf("user not found")
 		}
-		return nil, fmt.Errorf("failed to get user: %w", result.Error)
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	return &user, nil
+	return user, nil
+}
+
+// SetUserAdmin grants or removes the admin role. Sessions pick up the change
+// on their next request because ValidateSession reads the role from the user.
+func (s *AuthService) SetUserAdmin(userID uint, isAdmin bool) error {
+	if err := s.store.Users.SetAdmin(userID, isAdmin); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to update user role: %w", err)
+	}
+	return nil
+}
+
+// SetUserMedicalAccess grants or removes a user's access to medical details.
+// Like the admin role, sessions pick it up on their next request.
+func (s *AuthService) SetUserMedicalAccess(userID uint, allowed bool) error {
+	if err := s.store.Users.SetMedicalAccess(userID, allowed); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to update medical access: %w", err)
+	}
+	return nil
+}
+
+// SetUserContactAccess grants or removes a user's access to parents'
+// contacts. Like the admin role, sessions pick it up on their next request.
+func (s *AuthService) SetUserContactAccess(userID uint, allowed bool) error {
+	if err := s.store.Users.SetContactAccess(userID, allowed); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("user not found")
+		}
+		return fmt.Errorf("failed to update contact access: %w", err)
+	}
+	return nil
 }
 
 // GetUserByPCOID retrieves a user by PCO user ID
 func (s *AuthService) GetUserByPCOID(pcoUserID string) (*models.User, error) {
-	var user models.User
-	result := s.db.Where("pco_user_id = ?", pcoUserID).First(&user)
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
+	user, err := s.store.Users.GetByPCOUserID(pcoUserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			return nil, fmt.Errorf("user not found")
 		}
-		return nil, fmt.Errorf("failed to get user: %w", result.Error)
+		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
-	return &user, nil
+	return user, nil
 }
 
 // UpdateUserLastActivity updates the last activity timestamp for a user
 func (s *AuthService) UpdateUserLastActivity(userID uint) error {
-	result := s.db.Model(&models.User{}).Where("id = ?", userID).Update("last_activity", time.Now())
-	if result.Error != nil {
-		return fmt.Errorf("failed to update user activity: %w", result.Error)
+	if err := s.store.Users.TouchLastActivity(userID, time.Now()); err != nil {
+		return fmt.Errorf("failed to update user activity: %w", err)
 	}
 	return nil
 }
@@ -264,13 +486,43 @@ func (s *AuthService) RefreshUserTokens(user *models.User) error {
 	user.TokenExpiry = time.Now().Add(time.Duration(authResp.ExpiresIn) * time.Second)
 	user.UpdatedAt = time.Now()
 
-	if err := s.db.Save(user).Error; err != nil {
+	if err := s.store.Users.Save(user); err != nil {
 		return fmt.Errorf("failed to save refreshed tokens: %w", err)
 	}
 
 	return nil
 }
 
+// AdminAccessToken returns the PCO access token of the most recently active
+// admin, refreshed if it's expiring, for background work with no user behind it
+func (s *AuthService) AdminAccessToken() (string, error) {
+	users, err := s.store.Users.List()
+	if err != nil {
+		return "", fmt.Errorf("failed to list users: %w", err)
+	}
+
+	var admin *models.User
+	for i := range users {
+		user := &users[i]
+		if !user.IsAdmin || !user.IsActive || user.AccessToken == "" {
+			continue
+		}
+		if admin == nil || user.LastActivity.After(admin.LastActivity) {
+			admin = user
+		}
+	}
+	if admin == nil {
+		return "", fmt.Errorf("no active admin has signed in with PCO")
+	}
+
+	if s.IsTokenExpiringSoon(admin) {
+		if err := s.RefreshUserTokens(admin); err != nil {
+			return "", fmt.Errorf("failed to refresh tokens of admin %d: %w", admin.ID, err)
+		}
+	}
+	return admin.AccessToken, nil
+}
+
 // ValidateUserAccess validates that a user has valid access to the system
 func (s *AuthService) ValidateUserAccess(user *models.User) error {
 	// Check if user is active
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/database"
+	"go_pco_arrivals/internal/utils"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	backupPrefix = "pco_arrivals_"
+	// encryptedBackupMagic starts every encrypted backup file
+	encryptedBackupMagic = "PCOBACKUP1\n"
+	sqliteHeader         = "SQLite format 3\x00"
+)
+
+// ErrBackupUnsupported is returned when the storage backend can't be backed up
+var ErrBackupUnsupported = errors.New("backups are not supported for this database")
+
+// BackupInfo describes a backup file in the backup directory
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+	Encrypted bool      `json:"encrypted"`
+}
+
+// BackupService writes point-in-time snapshots of the database to the backup
+// directory, optionally encrypted, and keeps the most recent ones
+type BackupService struct {
+	config config.BackupConfig
+	db     database.Database
+	key    []byte
+	logger *utils.Logger
+	mu     sync.Mutex
+}
+
+// NewBackupService returns a backup service for db. db may be nil in demo
+// mode, in which case every backup fails with ErrBackupUnsupported.
+func NewBackupService(cfg config.BackupConfig, db database.Database, logger *utils.Logger) (*BackupService, error) {
+	s := &BackupService{
+		config: cfg,
+		db:     db,
+		logger: logger.WithComponent("backup_service"),
+	}
+	if cfg.EncryptionKey != "" {
+		key, err := utils.ParseEncryptionKey(cfg.EncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BACKUP_ENCRYPTION_KEY: %w", err)
+		}
+		s.key = key
+	}
+	return s, nil
+}
+
+// Create takes a snapshot of the live database, then prunes old backups
+func (s *BackupService) Create() (*BackupInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.config.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	var name string
+	var write func(path string) error
+	switch db := s.db.(type) {
+	case *database.SQLiteDatabase:
+		name = backupPrefix + stamp + ".db"
+		write = func(path string) error { return snapshotSQLite(db.GetGormDB(), path) }
+	case *database.MongoDBDatabase:
+		name = backupPrefix + stamp + ".mongo.tar.gz"
+		write = func(path string) error { return exportMongo(db.GetMongoDB(), path) }
+	case *database.PostgresDatabase:
+		return nil, fmt.Errorf("%w: use pg_dump for PostgreSQL", ErrBackupUnsupported)
+	default:
+		return nil, fmt.Errorf("%w: demo mode keeps data in memory", ErrBackupUnsupported)
+	}
+
+	tmp := filepath.Join(s.config.Dir, name+".tmp")
+	defer os.Remove(tmp)
+	if err := write(tmp); err != nil {
+		return nil, err
+	}
+
+	if s.key != nil {
+		if err := encryptFile(s.key, tmp); err != nil {
+			return nil, err
+		}
+		name += ".enc"
+	}
+
+	path := filepath.Join(s.config.Dir, name)
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("failed to store backup: %w", err)
+	}
+
+	info, err := backupInfo(path)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Backup created", "name", info.Name, "size", info.Size, "encrypted", info.Encrypted)
+
+	if err := s.prune(); err != nil {
+		s.logger.Error("Failed to prune old backups", "error", err)
+	}
+	return info, nil
+}
+
+// List returns the backups in the backup directory, newest first
+func (s *BackupService) List() ([]BackupInfo, error) {
+	entries, err := os.ReadDir(s.config.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []BackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	backups := []BackupInfo{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isBackupName(entry.Name()) {
+			continue
+		}
+		info, err := backupInfo(filepath.Join(s.config.Dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, *info)
+	}
+
+	// Names embed a UTC timestamp, so they sort chronologically
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+// Path returns the location of the named backup, rejecting anything that
+// isn't a plain backup file name
+func (s *BackupService) Path(name string) (string, error) {
+	if name != filepath.Base(name) || !isBackupName(name) {
+		return "", fmt.Errorf("invalid backup name %q", name)
+	}
+	path := filepath.Join(s.config.Dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("backup %s not found: %w", name, err)
+	}
+	return path, nil
+}
+
+// prune deletes all but the newest Retention backups. A retention of zero
+// keeps everything.
+func (s *BackupService) prune() error {
+	if s.config.Retention <= 0 {
+		return nil
+	}
+	backups, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups[min(s.config.Retention, len(backups)):] {
+		if err := os.Remove(filepath.Join(s.config.Dir, backup.Name)); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", backup.Name, err)
+		}
+		s.logger.Info("Removed old backup", "name", backup.Name)
+	}
+	return nil
+}
+
+// Restore replaces the contents of the database with a backup file. The
+// server must be stopped first: SQLite restores swap the database file.
+func (s *BackupService) Restore(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	if bytes.HasPrefix(data, []byte(encryptedBackupMagic)) {
+		if s.key == nil {
+			return fmt.Errorf("backup is encrypted but BACKUP_ENCRYPTION_KEY is not set")
+		}
+		if data, err = utils.DecryptBytes(s.key, data[len(encryptedBackupMagic):]); err != nil {
+			return fmt.Errorf("failed to decrypt backup: %w", err)
+		}
+	}
+
+	switch db := s.db.(type) {
+	case *database.SQLiteDatabase:
+		return restoreSQLite(db, data)
+	case *database.MongoDBDatabase:
+		return importMongo(db.GetMongoDB(), data)
+	case *database.PostgresDatabase:
+		return fmt.Errorf("%w: use pg_restore for PostgreSQL", ErrBackupUnsupported)
+	default:
+		return ErrBackupUnsupported
+	}
+}
+
+// snapshotSQLite writes a consistent copy of a live database with VACUUM
+// INTO, which reads inside a single transaction and doesn't block writers
+func snapshotSQLite(db *gorm.DB, path string) error {
+	if err := db.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
+
+// restoreSQLite checks the backup is a healthy SQLite database and moves it
+// over the live database file
+func restoreSQLite(db *database.SQLiteDatabase, data []byte) error {
+	if !bytes.HasPrefix(data, []byte(sqliteHeader)) {
+		return fmt.Errorf("backup is not a SQLite database")
+	}
+
+	var files []struct {
+		Name string
+		File string
+	}
+	if err := db.GetGormDB().Raw("PRAGMA database_list").Scan(&files).Error; err != nil {
+		return fmt.Errorf("failed to locate database file: %w", err)
+	}
+	var target string
+	for _, f := range files {
+		if f.Name == "main" {
+			target = f.File
+		}
+	}
+	if target == "" {
+		return fmt.Errorf("database is not stored in a file")
+	}
+
+	tmp := target + ".restore"
+	defer os.Remove(tmp)
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write restored database: %w", err)
+	}
+	if err := checkSQLiteIntegrity(tmp); err != nil {
+		return err
+	}
+
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(target + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", target+suffix, err)
+		}
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	return nil
+}
+
+func checkSQLiteIntegrity(path string) error {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return fmt.Errorf("failed to open restored database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	var result string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil {
+		return fmt.Errorf("failed to check restored database: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("restored database failed integrity check: %s", result)
+	}
+	return nil
+}
+
+// encryptFile seals the file at path in place
+func encryptFile(key []byte, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	sealed, err := utils.EncryptBytes(key, data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt backup: %w", err)
+	}
+	if err := os.WriteFile(path, append([]byte(encryptedBackupMagic), sealed...), 0o600); err != nil {
+		return fmt.Errorf("failed to write encrypted backup: %w", err)
+	}
+	return nil
+}
+
+func isBackupName(name string) bool {
+	return strings.HasPrefix(name, backupPrefix) && !strings.HasSuffix(name, ".tmp")
+}
+
+func backupInfo(path string) (*BackupInfo, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	return &BackupInfo{
+		Name:      stat.Name(),
+		Size:      stat.Size(),
+		CreatedAt: stat.ModTime(),
+		Encrypted: strings.HasSuffix(stat.Name(), ".enc"),
+	}, nil
+}
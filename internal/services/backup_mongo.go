@@ -0,0 +1,218 @@
+package services
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go_pco_arrivals/internal/database"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// mongoBackupTimeout bounds a whole export or import
+const mongoBackupTimeout = 30 * time.Minute
+
+// mongoMetadata mirrors the <collection>.metadata.json files mongodump writes
+type mongoMetadata struct {
+	CollectionName string     `bson:"collectionName"`
+	Type           string     `bson:"type"`
+	Options        bson.M     `bson:"options"`
+	Indexes        []bson.Raw `bson:"indexes"`
+}
+
+// exportMongo writes every collection to a gzipped tar in the layout
+// mongodump uses: <db>/<collection>.bson holds the documents back to back
+// and <db>/<collection>.metadata.json the indexes. Unpacked, it can be loaded
+// with `mongorestore --dir`.
+func exportMongo(db *database.MongoDB, file string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoBackupTimeout)
+	defer cancel()
+
+	out, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	archive := tar.NewWriter(gz)
+
+	names, err := db.Database().ListCollectionNames(ctx, bson.M{"type": "collection"})
+	if err != nil {
+		return fmt.Errorf("failed to list collections: %w", err)
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, "system.") {
+			continue
+		}
+		if err := exportCollection(ctx, archive, db.Database().Collection(name), db.Name()); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	return out.Close()
+}
+
+func exportCollection(ctx context.Context, archive *tar.Writer, collection *mongo.Collection, dbName string) error {
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", collection.Name(), err)
+	}
+	defer cursor.Close(ctx)
+
+	var documents bytes.Buffer
+	for cursor.Next(ctx) {
+		documents.Write(cursor.Current)
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", collection.Name(), err)
+	}
+
+	indexCursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list indexes on %s: %w", collection.Name(), err)
+	}
+	metadata := mongoMetadata{CollectionName: collection.Name(), Type: "collection", Options: bson.M{}}
+	if err := indexCursor.All(ctx, &metadata.Indexes); err != nil {
+		return fmt.Errorf("failed to list indexes on %s: %w", collection.Name(), err)
+	}
+	metadataJSON, err := bson.MarshalExtJSON(metadata, true, false)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata for %s: %w", collection.Name(), err)
+	}
+
+	base := path.Join(dbName, collection.Name())
+	if err := writeTarFile(archive, base+".bson", documents.Bytes()); err != nil {
+		return err
+	}
+	return writeTarFile(archive, base+".metadata.json", metadataJSON)
+}
+
+func writeTarFile(archive *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: time.Now()}
+	if err := archive.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := archive.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// importMongo replaces every collection found in an exportMongo archive,
+// recreating its indexes. Collections missing from the archive are left alone.
+func importMongo(db *database.MongoDB, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoBackupTimeout)
+	defer cancel()
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("backup is not a MongoDB export: %w", err)
+	}
+	archive := tar.NewReader(gz)
+
+	documents := map[string][]byte{}
+	metadata := map[string]mongoMetadata{}
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read backup: %w", err)
+		}
+		content, err := io.ReadAll(archive)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", header.Name, err)
+		}
+
+		file := path.Base(header.Name)
+		switch {
+		case strings.HasSuffix(file, ".metadata.json"):
+			var meta mongoMetadata
+			if err := bson.UnmarshalExtJSON(content, true, &meta); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", header.Name, err)
+			}
+			metadata[strings.TrimSuffix(file, ".metadata.json")] = meta
+		case strings.HasSuffix(file, ".bson"):
+			documents[strings.TrimSuffix(file, ".bson")] = content
+		}
+	}
+
+	for name, content := range documents {
+		if err := importCollection(ctx, db.Database().Collection(name), content, metadata[name].Indexes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importCollection(ctx context.Context, collection *mongo.Collection, content []byte, indexes []bson.Raw) error {
+	if err := collection.Drop(ctx); err != nil {
+		return fmt.Errorf("failed to drop %s: %w", collection.Name(), err)
+	}
+
+	var batch []interface{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := collection.InsertMany(ctx, batch); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", collection.Name(), err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+	for len(content) > 0 {
+		document, rest, ok := bsoncore.ReadDocument(content)
+		if !ok {
+			return fmt.Errorf("corrupt document in %s", collection.Name())
+		}
+		batch = append(batch, bson.Raw(document))
+		content = rest
+		if len(batch) == 1000 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	for _, spec := range indexes {
+		var index struct {
+			Name   string `bson:"name"`
+			Key    bson.D `bson:"key"`
+			Unique bool   `bson:"unique"`
+		}
+		if err := bson.Unmarshal(spec, &index); err != nil {
+			return fmt.Errorf("failed to parse index on %s: %w", collection.Name(), err)
+		}
+		if index.Name == "_id_" {
+			continue
+		}
+		model := mongo.IndexModel{Keys: index.Key, Options: options.Index().SetName(index.Name).SetUnique(index.Unique)}
+		if _, err := collection.Indexes().CreateOne(ctx, model); err != nil {
+			return fmt.Errorf("failed to create index %s on %s: %w", index.Name, collection.Name(), err)
+		}
+	}
+	return nil
+}
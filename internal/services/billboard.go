@@ -1,27 +1,32 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"go_pco_arrivals/internal/config"
 	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
 	"go_pco_arrivals/internal/utils"
-
-	"gorm.io/gorm"
 )
 
 type BillboardService struct {
-	config *config.Config
-	db     *gorm.DB
-	logger *utils.Logger
-	pco    *PCOService
-	ws     interface{} // Will be WebSocketService when implemented
+	config    *config.Config
+	store     *repository.Store
+	logger    *utils.Logger
+	pco       *PCOService
+	rollups   *RollupService
+	guardians *GuardianService
+	ws        *WebSocketHub
 }
 
+// BillboardState is what a billboard shows. A billboard for a folder shows
+// the check-ins of every location in it, listed in LocationIDs.
 type BillboardState struct {
 	LocationID     string           `json:"location_id"`
 	LocationName   string           `json:"location_name"`
+	LocationIDs    []string         `json:"location_ids,omitempty"`
 	LastUpdated    time.Time        `json:"last_updated"`
 	TotalCheckIns  int              `json:"total_check_ins"`
 	RecentCheckIns []CheckInDisplay `json:"recent_check_ins"`
@@ -45,29 +50,79 @@ type RealTimeUpdate struct {
 	Timestamp  time.Time       `json:"timestamp"`
 }
 
-func NewBillboardService(config *config.Config, db *gorm.DB, logger *utils.Logger, pco *PCOService, ws interface{}) *BillboardService {
+func NewBillboardService(config *config.Config, store *repository.Store, logger *utils.Logger, pco *PCOService, rollups *RollupService, guardians *GuardianService, ws *WebSocketHub) *BillboardService {
 	return &BillboardService{
-		config: config,
-		db:     db,
-		logger: logger,
-		pco:    pco,
-		ws:     ws,
+		config:    config,
+		store:     store,
+		logger:    logger,
+		pco:       pco,
+		rollups:   rollups,
+		guardians: guardians,
+		ws:        ws,
+	}
+}
+
+// Launch shows the pickup codes for an event on the billboard at a location.
+// Active billboards at clearLocation, or everywhere when it is empty, are
+// cleared first and returned with the new state.
+func (s *BillboardService) Launch(event *models.Event, locationID, createdBy, clearLocation string) ([]models.BillboardState, *models.BillboardState, error) {
+	locationName := locationID
+	if location, err := s.store.Locations.GetByPCOID(locationID); err == nil {
+		locationName = location.Label()
+	} else if event.LocationID == locationID && event.LocationName != "" {
+		locationName = event.LocationName
+	}
+
+	securityCodes, err := s.store.SecurityCodes.ListActive()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch security codes: %w", err)
+	}
+	var codes []string
+	for _, code := range securityCodes {
+		codes = append(codes, code.Code)
+	}
+
+	// Loaded before clearing so the audit log records what was replaced
+	previous, err := s.store.BillboardStates.ListActive(clearLocation)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load active billboards: %w", err)
+	}
+	if _, err := s.store.BillboardStates.Deactivate(clearLocation); err != nil {
+		return nil, nil, fmt.Errorf("failed to clear billboards: %w", err)
+	}
+
+	now := time.Now()
+	state := &models.BillboardState{
+		EventID:       event.ID,
+		EventName:     event.Name,
+		Date:          now,
+		LocationID:    locationID,
+		LocationName:  locationName,
+		SecurityCodes: codes,
+		IsActive:      true,
+		LastUpdated:   now,
+		CreatedBy:     createdBy,
 	}
+	if err := s.store.BillboardStates.Create(state); err != nil {
+		return nil, nil, fmt.Errorf("failed to create billboard state: %w", err)
+	}
+	return previous, state, nil
 }
 
 // GetBillboardState retrieves the current billboard state for a location
 func (s *BillboardService) GetBillboardState(locationID string) (*BillboardState, error) {
 	// Get location info
-	var location models.Location
-	if err := s.db.Where("pco_location_id = ?", locationID).First(&location).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	location, err := s.store.Locations.GetByPCOID(locationID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			// Create location if it doesn't exist
-			location = models.Location{
+			location = &models.Location{
 				PCOLocationID: locationID,
+				Kind:          models.LocationKindLocation,
 				CreatedAt:     time.Now(),
 				UpdatedAt:     time.Now(),
 			}
-			if err := s.db.Create(&location).Error; err != nil {
+			if err := s.store.Locations.Create(location); err != nil {
 				return nil, fmt.Errorf("failed to create location: %w", err)
 			}
 		} else {
@@ -89,12 +144,18 @@ func (s *BillboardService) GetBillboardState(locationID string) (*BillboardState
 
 	state := &BillboardState{
 		LocationID:     locationID,
-		LocationName:   location.Name,
+		LocationName:   location.Label(),
 		LastUpdated:    time.Now(),
 		TotalCheckIns:  totalCheckIns,
 		RecentCheckIns: recentCheckIns,
 		IsOnline:       true, // TODO: Implement actual online status check
 	}
+	if location.IsFolder() {
+		state.LocationIDs, err = coveredLocationIDs(s.store, locationID)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Save state to database
 	if err := s.SaveBillboardState(state); err != nil {
@@ -104,17 +165,20 @@ func (s *BillboardService) GetBillboardState(locationID string) (*BillboardState
 	return state, nil
 }
 
-// GetRecentCheckIns retrieves recent check-ins for a location
+// GetRecentCheckIns retrieves recent check-ins for a location, or for every
+// location in a folder
 func (s *BillboardService) GetRecentCheckIns(locationID string, limit int) ([]CheckInDisplay, error) {
-	var checkIns []models.CheckIn
-	result := s.db.Where("location_id = ? AND check_in_time >= ?",
-		locationID, time.Now().AddDate(0, 0, -1)). // Last 24 hours
-		Order("check_in_time DESC").
-		Limit(limit).
-		Find(&checkIns)
-
-	if result.Error != nil {
-		return nil, fmt.Errorf("failed to get recent check-ins: %w", result.Error)
+	covered, err := coveredLocationIDs(s.store, locationID)
+	if err != nil {
+		return nil, err
+	}
+	checkIns, err := s.store.CheckIns.List(repository.CheckInFilter{
+		LocationIDs: covered,
+		Since:       time.Now().AddDate(0, 0, -1), // Last 24 hours
+		Limit:       limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent check-ins: %w", err)
 	}
 
 	displayCheckIns := make([]CheckInDisplay, len(checkIns))
@@ -132,44 +196,33 @@ func (s *BillboardService) GetRecentCheckIns(locationID string, limit int) ([]Ch
 	return displayCheckIns, nil
 }
 
-// GetTodayCheckInCount gets the total number of check-ins for today
+// GetTodayCheckInCount gets the total number of check-ins for today at a
+// location, or at every location in a folder
 func (s *BillboardService) GetTodayCheckInCount(locationID string) (int, error) {
 	startOfDay := time.Now().Truncate(24 * time.Hour)
 	endOfDay := startOfDay.Add(24 * time.Hour)
 
-	var count int64
-	result := s.db.Model(&models.CheckIn{}).
-		Where("location_id = ? AND check_in_time >= ? AND check_in_time < ?",
-			locationID, startOfDay, endOfDay).
-		Count(&count)
-
-	if result.Error != nil {
-		return 0, fmt.Errorf("failed to count today's check-ins: %w", result.Error)
+	covered, err := coveredLocationIDs(s.store, locationID)
+	if err != nil {
+		return 0, err
+	}
+	count, err := s.store.CheckIns.Count(repository.CheckInFilter{
+		LocationIDs: covered,
+		Since:       startOfDay,
+		Until:       endOfDay,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count today's check-ins: %w", err)
 	}
 
 	return int(count), nil
 }
 
-// ProcessNewCheckIn processes a new check-in and broadcasts updates
-func (s *BillboardService) ProcessNewCheckIn(checkIn *models.CheckIn) error {
-	// TODO: Broadcast to location-specific room when WebSocket service is ready
-	// displayCheckIn := CheckInDisplay{
-	// 	ID:           checkIn.PCOCheckInID,
-	// 	PersonName:   checkIn.PersonName,
-	// 	CheckInTime:  checkIn.CheckInTime,
-	// 	LocationName: checkIn.LocationName,
-	// 	Notes:        checkIn.Notes,
-	// 	TimeAgo:      s.formatTimeAgo(checkIn.CheckInTime),
-	// }
-	// update := RealTimeUpdate{
-	// 	Type:       "new_check_in",
-	// 	LocationID: checkIn.LocationID,
-	// 	CheckIn:    &displayCheckIn,
-	// 	Timestamp:  time.Now(),
-	// }
-	// if err := s.ws.BroadcastLocationUpdate(checkIn.LocationID, update); err != nil {
-	// 	s.logger.Error("Failed to broadcast check-in update", "error", err, "location_id", checkIn.LocationID)
-	// }
+// ProcessNewCheckIn broadcasts a new check-in to the billboards of its
+// location and of the folders above it, and updates its billboard state.
+// parents maps each location to its folder.
+func (s *BillboardService) ProcessNewCheckIn(checkIn *models.CheckIn, parents map[string]string) error {
+	s.broadcastCheckIn("new_check_in", checkIn, parents)
 
 	// Update billboard state
 	if _, err := s.GetBillboardState(checkIn.LocationID); err != nil {
@@ -179,53 +232,107 @@ func (s *BillboardService) ProcessNewCheckIn(checkIn *models.CheckIn) error {
 	return nil
 }
 
-// SyncPCOCheckIns syncs check-ins from PCO and processes them
-func (s *BillboardService) SyncPCOCheckIns(accessToken string, locationID string) error {
-	// Get check-ins from the last hour
-	since := time.Now().Add(-1 * time.Hour)
+// ProcessCheckOut tells the billboards of a check-in's location and of the
+// folders above it that the person has left, so they can remove the entry
+func (s *BillboardService) ProcessCheckOut(checkIn *models.CheckIn, parents map[string]string) error {
+	s.broadcastCheckIn("check_out", checkIn, parents)
+
+	if _, err := s.GetBillboardState(checkIn.LocationID); err != nil {
+		s.logger.Error("Failed to update billboard state", "error", err, "location_id", checkIn.LocationID)
+	}
+
+	return nil
+}
+
+// broadcastCheckIn sends a check-in message to its location and every
+// folder above it
+func (s *BillboardService) broadcastCheckIn(messageType string, checkIn *models.CheckIn, parents map[string]string) {
+	if s.ws == nil {
+		return
+	}
+
+	displayCheckIn := CheckInDisplay{
+		ID:           checkIn.PCOCheckInID,
+		PersonName:   checkIn.PersonName,
+		CheckInTime:  checkIn.CheckInTime,
+		LocationName: checkIn.LocationName,
+		Notes:        checkIn.Notes,
+		TimeAgo:      s.formatTimeAgo(checkIn.CheckInTime),
+	}
+	// A folder can't be its own ancestor; stop at the first repeat
+	seen := map[string]bool{}
+	for locationID := checkIn.LocationID; locationID != "" && !seen[locationID]; locationID = parents[locationID] {
+		seen[locationID] = true
+		s.ws.BroadcastToLocation(locationID, messageType, RealTimeUpdate{
+			Type:       messageType,
+			LocationID: locationID,
+			CheckIn:    &displayCheckIn,
+			Timestamp:  time.Now(),
+		})
+	}
+}
+
+// SyncPCOCheckIns syncs check-ins from PCO, for every location in a folder,
+// with the guardians of the children checked in, and processes the ones that
+// were created or changed
+func (s *BillboardService) SyncPCOCheckIns(accessToken string, locationID string) (*repository.UpsertResult, error) {
+	// Go back as far as check-ins count towards occupancy, so the check-outs
+	// of everyone still in a room are picked up
+	since := time.Now().Add(-occupancyWindow)
 
-	pcoCheckIns, err := s.pco.GetCheckIns(accessToken, locationID, since)
+	covered, err := coveredLocationIDs(s.store, locationID)
 	if err != nil {
-		return fmt.Errorf("failed to get PCO check-ins: %w", err)
-	}
-
-	// Process each check-in
-	for _, pcoCheckIn := range pcoCheckIns {
-		// Check if check-in already exists
-		var existingCheckIn models.CheckIn
-		result := s.db.Where("pco_check_in_id = ?", pcoCheckIn.ID).First(&existingCheckIn)
-
-		if result.Error != nil {
-			if result.Error == gorm.ErrRecordNotFound {
-				// Create new check-in
-				newCheckIn := models.CheckIn{
-					PCOCheckInID: pcoCheckIn.ID,
-					PersonID:     pcoCheckIn.PersonID,
-					PersonName:   pcoCheckIn.PersonName,
-					LocationID:   pcoCheckIn.LocationID,
-					LocationName: pcoCheckIn.LocationName,
-					CheckInTime:  pcoCheckIn.CheckedInAt,
-					Notes:        pcoCheckIn.Notes,
-					Status:       "active",
-					CreatedAt:    time.Now(),
-					UpdatedAt:    time.Now(),
-				}
-
-				if err := s.db.Create(&newCheckIn).Error; err != nil {
-					s.logger.Error("Failed to create check-in", "error", err, "check_in_id", pcoCheckIn.ID)
-					continue
-				}
-
-				// Process the new check-in
-				if err := s.ProcessNewCheckIn(&newCheckIn); err != nil {
-					s.logger.Error("Failed to process new check-in", "error", err, "check_in_id", pcoCheckIn.ID)
-				}
-			} else {
-				s.logger.Error("Failed to query check-in", "error", result.Error, "check_in_id", pcoCheckIn.ID)
-			}
+		return nil, err
+	}
+	var pcoCheckIns []PCOCheckIn
+	for _, id := range covered {
+		checkIns, err := s.pco.GetCheckIns(accessToken, id, since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get PCO check-ins: %w", err)
 		}
+		pcoCheckIns = append(pcoCheckIns, checkIns...)
+	}
+	s.guardians.Fill(accessToken, pcoCheckIns)
+
+	result, err := s.pco.SyncCheckIns(pcoCheckIns)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.processSyncResult(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// processSyncResult broadcasts the arrivals and check-outs of a sync. Created
+// check-ins are new arrivals; updated ones are only sent once they are
+// checked out, so other changes don't show a child arriving again.
+func (s *BillboardService) processSyncResult(result *repository.UpsertResult) error {
+	locations, err := s.store.Locations.List()
+	if err != nil {
+		return fmt.Errorf("failed to list locations: %w", err)
+	}
+	parents := make(map[string]string, len(locations))
+	for _, location := range locations {
+		parents[location.PCOLocationID] = location.ParentID
 	}
 
+	for i := range result.Changed {
+		checkIn := &result.Changed[i]
+		switch {
+		case checkIn.CheckedOutAt != nil:
+			err = s.ProcessCheckOut(checkIn, parents)
+		case result.Inserted[checkIn.PCOCheckInID]:
+			err = s.ProcessNewCheckIn(checkIn, parents)
+		default:
+			continue
+		}
+		if err != nil {
+			s.logger.Error("Failed to process check-in", "error", err, "check_in_id", checkIn.PCOCheckInID)
+		}
+	}
 	return nil
 }
 
@@ -241,17 +348,16 @@ func (s *BillboardService) SaveBillboardState(state *BillboardState) error {
 	}
 
 	// Upsert the state
-	var existing models.BillboardState
-	result := s.db.Where("location_id = ?", state.LocationID).First(&existing)
+	existing, err := s.store.BillboardStates.GetByLocation(state.LocationID)
 
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			// Create new state
-			if err := s.db.Create(&billboardState).Error; err != nil {
+			if err := s.store.BillboardStates.Create(&billboardState); err != nil {
 				return fmt.Errorf("failed to create billboard state: %w", err)
 			}
 		} else {
-			return fmt.Errorf("failed to query billboard state: %w", result.Error)
+			return fmt.Errorf("failed to query billboard state: %w", err)
 		}
 	} else {
 		// Update existing state
@@ -259,7 +365,7 @@ func (s *BillboardService) SaveBillboardState(state *BillboardState) error {
 		existing.IsActive = state.IsOnline
 		existing.UpdatedAt = time.Now()
 
-		if err := s.db.Save(&existing).Error; err != nil {
+		if err := s.store.BillboardStates.Save(existing); err != nil {
 			return fmt.Errorf("failed to update billboard state: %w", err)
 		}
 	}
@@ -267,31 +373,20 @@ func (s *BillboardService) SaveBillboardState(state *BillboardState) error {
 	return nil
 }
 
-// GetLocations retrieves all available locations
+// GetLocations retrieves the locations a billboard can be shown for, leaving
+// out hidden and deactivated ones
 func (s *BillboardService) GetLocations() ([]models.Location, error) {
-	var locations []models.Location
-	result := s.db.Find(&locations)
-	if result.Error != nil {
-		return nil, fmt.Errorf("failed to get locations: %w", result.Error)
-	}
-	return locations, nil
-}
-
-// AddLocation adds a new location to the system
-func (s *BillboardService) AddLocation(pcoLocationID, name string) error {
-	location := models.Location{
-		PCOLocationID: pcoLocationID,
-		Name:          name,
-		IsActive:      true,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+	locations, err := s.store.Locations.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get locations: %w", err)
 	}
-
-	if err := s.db.Create(&location).Error; err != nil {
-		return fmt.Errorf("failed to create location: %w", err)
+	available := make([]models.Location, 0, len(locations))
+	for _, l := range locations {
+		if l.IsActive && !l.Hidden {
+			available = append(available, l)
+		}
 	}
-
-	return nil
+	return available, nil
 }
 
 // GetLocationBillboard gets the billboard for a specific location
@@ -326,63 +421,69 @@ func (s *BillboardService) formatTimeAgo(t time.Time) string {
 	}
 }
 
-// CleanupOldCheckIns removes check-ins older than the configured retention period
+// CleanupOldCheckIns removes check-ins older than the configured retention
+// period. The cutoff falls on a local day boundary so a rollup day is either
+// fully rebuildable or left as it is.
 func (s *BillboardService) CleanupOldCheckIns() error {
-	retentionDays := 30 // Default to 30 days - TODO: add to config
+	cutoffDate := addDays(dayStart(time.Now()), -CheckInRetentionDays)
 
-	cutoffDate := time.Now().AddDate(0, 0, -retentionDays)
-
-	result := s.db.Where("check_in_time < ?", cutoffDate).Delete(&models.CheckIn{})
-	if result.Error != nil {
-		return fmt.Errorf("failed to cleanup old check-ins: %w", result.Error)
+	deleted, err := s.store.CheckIns.DeleteBefore(cutoffDate)
+	if err != nil {
+		return fmt.Errorf("failed to cleanup old check-ins: %w", err)
 	}
 
-	s.logger.Info("Cleaned up old check-ins", "deleted_count", result.RowsAffected, "cutoff_date", cutoffDate)
+	s.logger.Info("Cleaned up old check-ins", "deleted_count", deleted, "cutoff_date", cutoffDate)
 	return nil
 }
 
-// GetCheckInStats gets statistics for check-ins
+// GetCheckInStats gets check-in statistics from the attendance rollups, summed
+// over the locations of a folder
 func (s *BillboardService) GetCheckInStats(locationID string, days int) (map[string]interface{}, error) {
 	if days <= 0 {
 		days = 7 // Default to 7 days
 	}
 
-	startDate := time.Now().AddDate(0, 0, -days)
-
-	var stats struct {
-		TotalCheckIns  int64 `json:"total_check_ins"`
-		TodayCheckIns  int64 `json:"today_check_ins"`
-		WeeklyCheckIns int64 `json:"weekly_check_ins"`
+	covered, err := coveredLocationIDs(s.store, locationID)
+	if err != nil {
+		return nil, err
 	}
-
-	// Total check-ins for the period
-	if err := s.db.Model(&models.CheckIn{}).
-		Where("location_id = ? AND check_in_time >= ?", locationID, startDate).
-		Count(&stats.TotalCheckIns).Error; err != nil {
+	now := time.Now()
+	total, err := s.coveredTotals(covered, now.AddDate(0, 0, -days), now)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get total check-ins: %w", err)
 	}
 
-	// Today's check-ins
-	todayStart := time.Now().Truncate(24 * time.Hour)
-	if err := s.db.Model(&models.CheckIn{}).
-		Where("location_id = ? AND check_in_time >= ?", locationID, todayStart).
-		Count(&stats.TodayCheckIns).Error; err != nil {
+	today, err := s.coveredTotals(covered, now, now)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get today's check-ins: %w", err)
 	}
 
 	// This week's check-ins
-	weekStart := time.Now().AddDate(0, 0, -int(time.Now().Weekday()))
-	if err := s.db.Model(&models.CheckIn{}).
-		Where("location_id = ? AND check_in_time >= ?", locationID, weekStart).
-		Count(&stats.WeeklyCheckIns).Error; err != nil {
+	weekly, err := s.coveredTotals(covered, now.AddDate(0, 0, -int(now.Weekday())), now)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get weekly check-ins: %w", err)
 	}
 
 	return map[string]interface{}{
-		"total_check_ins":  stats.TotalCheckIns,
-		"today_check_ins":  stats.TodayCheckIns,
-		"weekly_check_ins": stats.WeeklyCheckIns,
-		"period_days":      days,
-		"location_id":      locationID,
+		"total_check_ins":     total.CheckIns,
+		"today_check_ins":     today.CheckIns,
+		"weekly_check_ins":    weekly.CheckIns,
+		"first_time_visitors": total.FirstTimeVisitors,
+		"period_days":         days,
+		"location_id":         locationID,
 	}, nil
 }
+
+// coveredTotals sums the attendance totals of several locations
+func (s *BillboardService) coveredTotals(locationIDs []string, since, until time.Time) (*AttendanceTotals, error) {
+	sum := &AttendanceTotals{}
+	for _, id := range locationIDs {
+		totals, err := s.rollups.Totals(id, since, until)
+		if err != nil {
+			return nil, err
+		}
+		sum.CheckIns += totals.CheckIns
+		sum.FirstTimeVisitors += totals.FirstTimeVisitors
+	}
+	return sum, nil
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/types"
+	"go_pco_arrivals/internal/utils"
+
+	fasthttpws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// listenToLocation connects a billboard client for a location to the hub and
+// returns its connection once the hub has registered it
+func listenToLocation(t *testing.T, hub *WebSocketHub, locationID string) *fasthttpws.Conn {
+	t.Helper()
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/ws/:locationId", websocket.New(func(c *websocket.Conn) {
+		client := &types.WebSocketClient{Conn: c, ID: utils.GenerateID(), LocationID: c.Params("locationId")}
+		hub.Register(client)
+		defer hub.Unregister(client)
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+
+	conn, _, err := fasthttpws.DefaultDialer.Dial("ws://"+listener.Addr().String()+"/ws/"+locationID, nil)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	for deadline := time.Now().Add(2 * time.Second); hub.GetStats()["total_clients"] != 1; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("client was never registered")
+		}
+	}
+	return conn
+}
+
+// nextUpdate reads the next billboard message, or fails after a short wait
+func nextUpdate(t *testing.T, conn *fasthttpws.Conn) RealTimeUpdate {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var message struct {
+		Type string         `json:"type"`
+		Data RealTimeUpdate `json:"data"`
+	}
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	if message.Type != message.Data.Type {
+		t.Errorf("message type %q doesn't match update type %q", message.Type, message.Data.Type)
+	}
+	return message.Data
+}
+
+// expectNoUpdate fails if a billboard message arrives within a short wait.
+// The timeout leaves the connection unusable, so it must be the last read.
+func expectNoUpdate(t *testing.T, conn *fasthttpws.Conn) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	var message json.RawMessage
+	if err := conn.ReadJSON(&message); err == nil {
+		t.Fatalf("unexpected message %s", message)
+	}
+}
+
+func TestSyncBroadcastsArrivalsAndCheckOuts(t *testing.T) {
+	store := repository.NewMemoryStore()
+	hub := NewWebSocketHub()
+	billboard := NewBillboardService(&config.Config{}, store, utils.NewLogger(), nil, nil, nil, hub)
+	conn := listenToLocation(t, hub, "loc-1")
+
+	checkIn := models.CheckIn{
+		PCOCheckInID: "ci-1",
+		PersonID:     "person-1",
+		PersonName:   "Ada Child",
+		LocationID:   "loc-1",
+		LocationName: "Nursery",
+		SecurityCode: "ABC",
+		CheckInTime:  time.Now().Add(-time.Hour),
+		EventID:      "evt-1",
+	}
+	sync := func(checkIn models.CheckIn) {
+		t.Helper()
+		result, err := store.CheckIns.Upsert([]models.CheckIn{checkIn})
+		if err != nil {
+			t.Fatalf("failed to upsert check-in: %v", err)
+		}
+		if err := billboard.processSyncResult(result); err != nil {
+			t.Fatalf("failed to process sync: %v", err)
+		}
+	}
+
+	sync(checkIn)
+
+	// A change that isn't a check-out is not an arrival
+	checkIn.Notes = "Peanut allergy"
+	sync(checkIn)
+
+	out := time.Now()
+	checkIn.CheckedOutAt = &out
+	checkIn.Status = models.CheckInStatusCheckedOut
+	sync(checkIn)
+
+	// Unchanged check-ins are not sent again
+	sync(checkIn)
+
+	update := nextUpdate(t, conn)
+	if update.Type != "new_check_in" || update.CheckIn == nil || update.CheckIn.ID != "ci-1" {
+		t.Fatalf("created check-in: got %+v, want new_check_in for ci-1", update)
+	}
+	update = nextUpdate(t, conn)
+	if update.Type != "check_out" || update.CheckIn == nil || update.CheckIn.ID != "ci-1" {
+		t.Fatalf("checked-out check-in: got %+v, want check_out for ci-1", update)
+	}
+	expectNoUpdate(t, conn)
+}
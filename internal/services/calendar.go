@@ -0,0 +1,245 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/utils"
+)
+
+const (
+	// calendarTokenPrefix identifies calendar feed tokens
+	calendarTokenPrefix = "pcoc_"
+	// calendarPast and calendarAhead bound the occurrences in a feed
+	calendarPast  = 7 * 24 * time.Hour
+	calendarAhead = 90 * 24 * time.Hour
+	// calendarUIDDomain makes feed UIDs globally unique
+	calendarUIDDomain = "pco-arrivals"
+)
+
+// ErrInvalidCalendarToken is returned for unknown or revoked calendar tokens
+var ErrInvalidCalendarToken = errors.New("invalid calendar token")
+
+// RotateCalendarToken issues a new calendar feed token for a user, replacing
+// any previous one. The token is returned only once; only its hash is stored.
+func (s *AuthService) RotateCalendarToken(userID uint) (string, error) {
+	secret, err := utils.GenerateSecureToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate calendar token: %w", err)
+	}
+	token := calendarTokenPrefix + strings.TrimRight(secret, "=")
+
+	if err := s.store.Users.SetCalendarToken(userID, hashAPIKey(token)); err != nil {
+		return "", fmt.Errorf("failed to save calendar token: %w", err)
+	}
+	return token, nil
+}
+
+// RevokeCalendarToken disables a user's calendar feed
+func (s *AuthService) RevokeCalendarToken(userID uint) error {
+	if err := s.store.Users.SetCalendarToken(userID, ""); err != nil {
+		return fmt.Errorf("failed to revoke calendar token: %w", err)
+	}
+	return nil
+}
+
+// UserForCalendarToken returns the active user a calendar token belongs to
+func (s *AuthService) UserForCalendarToken(token string) (*models.User, error) {
+	if !strings.HasPrefix(token, calendarTokenPrefix) {
+		return nil, ErrInvalidCalendarToken
+	}
+	user, err := s.store.Users.GetByCalendarToken(hashAPIKey(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidCalendarToken
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if !user.IsActive {
+		return nil, ErrInvalidCalendarToken
+	}
+	return user, nil
+}
+
+// Calendar renders the events around now as an iCalendar feed, with a
+// second entry for each billboard the scheduler will launch. With a
+// locationID, only events held there are included.
+//
+// UIDs are derived from PCO periods and event keys rather than times, so
+// an edited event replaces its entry instead of adding another.
+func (s *EventService) Calendar(locationID string, now time.Time) ([]byte, error) {
+	name := "Check-in events"
+	if locationID != "" {
+		location, err := s.store.Locations.GetByPCOID(locationID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get location: %w", err)
+		}
+		name += " - " + location.Label()
+	}
+
+	occurrences, err := listOccurrences(s.store, now.Add(-calendarPast), now.Add(calendarAhead))
+	if err != nil {
+		return nil, err
+	}
+
+	cal := &icalWriter{}
+	cal.line("BEGIN", "VCALENDAR")
+	cal.line("VERSION", "2.0")
+	cal.line("PRODID", "-//PCO Arrivals//Check-in events//EN")
+	cal.line("CALSCALE", "GREGORIAN")
+	cal.line("METHOD", "PUBLISH")
+	cal.text("X-WR-CALNAME", name)
+	cal.line("REFRESH-INTERVAL;VALUE=DURATION", "PT15M")
+	cal.line("X-PUBLISHED-TTL", "PT15M")
+
+	stamp := now.UTC()
+	for _, occurrence := range occurrences {
+		if locationID != "" && !occurrence.heldAt(locationID) {
+			continue
+		}
+		uid := occurrenceUID(occurrence)
+		modified := occurrence.Event.UpdatedAt
+		if occurrence.Period != nil && occurrence.Period.UpdatedAt.After(modified) {
+			modified = occurrence.Period.UpdatedAt
+		}
+
+		var where []string
+		for _, l := range occurrence.Locations {
+			where = append(where, l.Label())
+		}
+		var details []string
+		if occurrence.Event.Description != "" {
+			details = append(details, occurrence.Event.Description)
+		}
+		if len(occurrence.Times) > 0 {
+			var times []string
+			for _, t := range occurrence.Times {
+				times = append(times, t.StartsAt.In(time.Local).Format("3:04 PM"))
+			}
+			details = append(details, "Check-in times: "+strings.Join(times, ", "))
+		}
+
+		cal.event(icalEvent{
+			UID:         "event-" + uid,
+			Stamp:       stamp,
+			Modified:    modified,
+			StartsAt:    occurrence.StartsAt,
+			EndsAt:      occurrence.EndsAt,
+			Summary:     occurrence.Event.Name,
+			Description: strings.Join(details, "\n"),
+			Location:    strings.Join(where, ", "),
+			Category:    "Check-in",
+		})
+
+		if occurrence.Event.AutoLaunch && occurrence.Event.LocationID != "" {
+			cal.event(icalEvent{
+				UID:         "billboard-" + uid,
+				Stamp:       stamp,
+				Modified:    modified,
+				StartsAt:    occurrence.StartsAt,
+				EndsAt:      occurrence.EndsAt,
+				Summary:     "Billboard: " + occurrence.Event.Name,
+				Description: "The billboard is launched automatically when the event starts and cleared when it ends.",
+				Location:    occurrence.Event.LocationName,
+				Category:    "Billboard",
+			})
+		}
+	}
+
+	cal.line("END", "VCALENDAR")
+	return []byte(cal.String()), nil
+}
+
+// occurrenceUID identifies an occurrence across feed refreshes: by PCO
+// period, by event for one-off events, and by event and local date for
+// repeats of a recurring event
+func occurrenceUID(occurrence EventOccurrence) string {
+	var id string
+	switch {
+	case occurrence.Period != nil:
+		id = "period-" + occurrence.Period.PCOEventPeriodID
+	case occurrence.Event.RRule != "":
+		id = occurrence.Event.PCOEventID + "-" + occurrence.StartsAt.In(time.Local).Format("20060102")
+	default:
+		id = occurrence.Event.PCOEventID
+	}
+	return id + "@" + calendarUIDDomain
+}
+
+// icalEvent is a VEVENT of a feed
+type icalEvent struct {
+	UID         string
+	Stamp       time.Time
+	Modified    time.Time
+	StartsAt    time.Time
+	EndsAt      time.Time
+	Summary     string
+	Description string
+	Location    string
+	Category    string
+}
+
+// icalWriter builds iCalendar (RFC 5545) text with CRLF line endings and
+// long lines folded
+type icalWriter struct {
+	strings.Builder
+}
+
+func (w *icalWriter) event(e icalEvent) {
+	w.line("BEGIN", "VEVENT")
+	w.text("UID", e.UID)
+	w.line("DTSTAMP", icalTime(e.Stamp))
+	if !e.Modified.IsZero() {
+		w.line("LAST-MODIFIED", icalTime(e.Modified))
+	}
+	w.line("DTSTART", icalTime(e.StartsAt))
+	if e.EndsAt.After(e.StartsAt) {
+		w.line("DTEND", icalTime(e.EndsAt))
+	}
+	w.text("SUMMARY", e.Summary)
+	if e.Description != "" {
+		w.text("DESCRIPTION", e.Description)
+	}
+	if e.Location != "" {
+		w.text("LOCATION", e.Location)
+	}
+	w.text("CATEGORIES", e.Category)
+	w.line("STATUS", "CONFIRMED")
+	w.line("TRANSP", "TRANSPARENT")
+	w.line("END", "VEVENT")
+}
+
+// text writes a property with a TEXT value, escaped
+func (w *icalWriter) text(name, value string) {
+	w.line(name, icalEscaper.Replace(value))
+}
+
+// line writes a property, folding it at 75 octets without splitting a UTF-8
+// character
+func (w *icalWriter) line(name, value string) {
+	content := name + ":" + value
+	limit := 75
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && content[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(content[:cut])
+		w.WriteString("\r\n ")
+		content = content[cut:]
+		// Continuation lines start with a space
+		limit = 74
+	}
+	w.WriteString(content)
+	w.WriteString("\r\n")
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icalTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
@@ -0,0 +1,687 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/utils"
+)
+
+var (
+	// ErrEventReadOnly is returned when changing an event synced from PCO
+	ErrEventReadOnly = errors.New("events synced from Planning Center are read-only")
+	// ErrEventInUse is returned when deleting an event an active billboard
+	// is showing
+	ErrEventInUse = errors.New("event is shown on an active billboard")
+)
+
+const (
+	// eventSyncInterval is how old synced events may get before a request
+	// triggers a fresh sync
+	eventSyncInterval = 15 * time.Minute
+	// eventSyncRetry spaces out retries after a failed sync
+	eventSyncRetry = time.Minute
+	// eventPeriodsPerSync is how many recent periods are fetched per event
+	eventPeriodsPerSync = 10
+	// eventCheckInLead is how early before an event check-ins count towards it
+	eventCheckInLead = time.Hour
+)
+
+// EventSyncStatus reports how fresh the local copy of PCO events is
+type EventSyncStatus struct {
+	LastAttempt time.Time `json:"last_attempt"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// EventSyncResult counts what a sync wrote
+type EventSyncResult struct {
+	Events    int `json:"events"`
+	Periods   int `json:"periods"`
+	Times     int `json:"times"`
+	Locations int `json:"locations"`
+}
+
+// EventOccurrence is one time an event happens. Period is nil except for
+// synced PCO periods.
+type EventOccurrence struct {
+	Event     models.Event
+	Period    *models.EventPeriod
+	Times     []models.EventTime
+	Locations []models.Location
+	StartsAt  time.Time
+	EndsAt    time.Time
+}
+
+// EventInput holds the editable fields of a local event. Nil fields are left
+// unchanged on update.
+type EventInput struct {
+	Name        *string    `json:"name"`
+	Description *string    `json:"description"`
+	StartTime   *time.Time `json:"start_time"`
+	EndTime     *time.Time `json:"end_time"`
+	LocationID  *string    `json:"location_id"`
+	RRule       *string    `json:"rrule"`
+	ExDates     *[]string  `json:"exdates"`
+	AutoLaunch  *bool      `json:"auto_launch"`
+	IsActive    *bool      `json:"is_active"`
+}
+
+// EventService keeps a local copy of PCO Check-Ins events, their periods and
+// times, and answers event queries from it
+type EventService struct {
+	store  *repository.Store
+	pco    *PCOService
+	logger *utils.Logger
+	// syncing serialises syncs; mu guards status
+	syncing sync.Mutex
+	mu      sync.RWMutex
+	status  EventSyncStatus
+}
+
+// NewEventService returns an event service. pco may be nil in demo mode, in
+// which case only local events are served.
+func NewEventService(store *repository.Store, pco *PCOService, logger *utils.Logger) *EventService {
+	return &EventService{
+		store:  store,
+		pco:    pco,
+		logger: logger.WithComponent("event_service"),
+	}
+}
+
+// Status returns the outcome of the latest sync
+func (s *EventService) Status() EventSyncStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// Stale reports whether the latest sync failed, so local events may be out
+// of date
+func (s *EventService) Stale() bool {
+	status := s.Status()
+	return status.LastError != ""
+}
+
+// SyncIfStale starts a sync in the background when the local copy is older
+// than eventSyncInterval, so callers serve stored events without waiting on
+// PCO. Nothing is started while another sync is running.
+func (s *EventService) SyncIfStale(accessToken string) {
+	if s.pco == nil {
+		return
+	}
+	status := s.Status()
+	now := time.Now()
+	if now.Sub(status.LastSuccess) < eventSyncInterval {
+		return
+	}
+	if status.LastError != "" && now.Sub(status.LastAttempt) < eventSyncRetry {
+		return
+	}
+	if !s.syncing.TryLock() {
+		return
+	}
+
+	go func() {
+		defer s.syncing.Unlock()
+		if _, err := s.sync(accessToken); err != nil {
+			s.logger.Warn("Event sync failed, serving stored events", "error", err)
+		}
+	}()
+}
+
+// Sync fetches events, their recent periods and times, and their locations
+// from PCO and stores them
+func (s *EventService) Sync(accessToken string) (*EventSyncResult, error) {
+	if s.pco == nil {
+		return nil, errors.New("PCO sync is disabled")
+	}
+	s.syncing.Lock()
+	defer s.syncing.Unlock()
+	return s.sync(accessToken)
+}
+
+func (s *EventService) sync(accessToken string) (*EventSyncResult, error) {
+	started := time.Now()
+	result, err := s.syncEvents(accessToken)
+
+	s.mu.Lock()
+	s.status.LastAttempt = started
+	if err != nil {
+		s.status.LastError = err.Error()
+	} else {
+		s.status.LastSuccess = started
+		s.status.LastError = ""
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("Synced PCO events", "events", result.Events, "periods", result.Periods, "times", result.Times, "locations", result.Locations)
+	return result, nil
+}
+
+func (s *EventService) syncEvents(accessToken string) (*EventSyncResult, error) {
+	pcoEvents, err := s.pco.GetEvents(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &EventSyncResult{}
+	seen := make(map[string]bool, len(pcoEvents))
+	for _, pcoEvent := range pcoEvents {
+		periods, err := s.pco.GetEventPeriods(accessToken, pcoEvent.ID, eventPeriodsPerSync)
+		if err != nil {
+			return nil, err
+		}
+		locations, err := s.pco.GetEventLocations(accessToken, pcoEvent.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		event, err := s.saveEvent(pcoEvent, periods, locations)
+		if err != nil {
+			return nil, err
+		}
+		seen[event.PCOEventID] = true
+		result.Events++
+
+		for _, l := range locations {
+			if err := s.saveLocation(l, pcoEvent.ID); err != nil {
+				return nil, err
+			}
+			result.Locations++
+		}
+
+		for _, p := range periods {
+			period := &models.EventPeriod{
+				PCOEventPeriodID: p.ID,
+				EventID:          event.ID,
+				StartsAt:         p.StartsAt.UTC(),
+				EndsAt:           p.EndsAt.UTC(),
+				RegularCount:     p.RegularCount,
+				GuestCount:       p.GuestCount,
+				VolunteerCount:   p.VolunteerCount,
+				Note:             p.Note,
+			}
+			if err := s.store.Events.SavePeriod(period); err != nil {
+				return nil, fmt.Errorf("failed to save event period %s: %w", p.ID, err)
+			}
+			result.Periods++
+
+			for _, t := range p.Times {
+				eventTime := &models.EventTime{
+					PCOEventTimeID: t.ID,
+					EventPeriodID:  period.ID,
+					EventID:        event.ID,
+					Name:           t.Name,
+					StartsAt:       t.StartsAt.UTC(),
+					ShowsAt:        t.ShowsAt.UTC(),
+					HidesAt:        t.HidesAt.UTC(),
+				}
+				if err := s.store.Events.SaveTime(eventTime); err != nil {
+					return nil, fmt.Errorf("failed to save event time %s: %w", t.ID, err)
+				}
+				result.Times++
+			}
+		}
+	}
+
+	// Events no longer listed have been archived in PCO
+	stored, err := s.store.Events.List(repository.EventFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+	for _, event := range stored {
+		if event.Source != models.EventSourcePCO || seen[event.PCOEventID] || !event.IsActive {
+			continue
+		}
+		event.IsActive = false
+		if err := s.store.Events.Save(&event); err != nil {
+			return nil, fmt.Errorf("failed to deactivate event %s: %w", event.PCOEventID, err)
+		}
+	}
+	return result, nil
+}
+
+// saveEvent creates or updates the local copy of a PCO event. Its date and
+// times come from the latest period, and it is tied to a location only when
+// PCO offers exactly one outside of folders.
+func (s *EventService) saveEvent(pcoEvent PCOEvent, periods []PCOEventPeriod, locations []PCOLocation) (*models.Event, error) {
+	event, err := s.store.Events.GetByPCOID(pcoEvent.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		event = &models.Event{PCOEventID: pcoEvent.ID, Source: models.EventSourcePCO, CreatedBy: models.EventSourcePCO}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get event %s: %w", pcoEvent.ID, err)
+	}
+
+	event.Name = pcoEvent.Name
+	event.Frequency = pcoEvent.Frequency
+	event.IsActive = pcoEvent.ArchivedAt == nil
+	if len(periods) > 0 {
+		latest := periods[0]
+		for _, p := range periods[1:] {
+			if p.StartsAt.After(latest.StartsAt) {
+				latest = p
+			}
+		}
+		event.Date = latest.StartsAt.UTC()
+		event.StartTime = latest.StartsAt.UTC()
+		event.EndTime = latest.EndsAt.UTC()
+	}
+	event.LocationID, event.LocationName = "", ""
+	var checkInLocations []PCOLocation
+	for _, l := range locations {
+		if l.Kind != models.LocationKindFolder {
+			checkInLocations = append(checkInLocations, l)
+		}
+	}
+	if len(checkInLocations) == 1 {
+		event.LocationID = checkInLocations[0].ID
+		event.LocationName = checkInLocations[0].Name
+	}
+
+	if event.ID == 0 {
+		err = s.store.Events.Create(event)
+	} else {
+		err = s.store.Events.Save(event)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save event %s: %w", pcoEvent.ID, err)
+	}
+	return event, nil
+}
+
+// saveLocation creates or updates a location or folder, with its place in
+// the tree, and links it to its event
+func (s *EventService) saveLocation(l PCOLocation, pcoEventID string) error {
+	location, err := s.store.Locations.GetByPCOID(l.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		location = &models.Location{PCOLocationID: l.ID, IsActive: true}
+	} else if err != nil {
+		return fmt.Errorf("failed to get location %s: %w", l.ID, err)
+	}
+
+	applyPCOLocation(location, l, pcoEventID)
+	if location.ID == 0 {
+		err = s.store.Locations.Create(location)
+	} else {
+		err = s.store.Locations.Save(location)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save location %s: %w", l.ID, err)
+	}
+	return nil
+}
+
+// EventsOn returns the active events on the local day of date, in start
+// time order
+func (s *EventService) EventsOn(date time.Time) ([]EventOccurrence, error) {
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	return listOccurrences(s.store, from, from.AddDate(0, 0, 1))
+}
+
+// listOccurrences returns the active events starting in [from, to): one per
+// synced PCO period, one per one-off event and one per repeat of a
+// recurring event, in start time order. Recurrence is expanded in local time.
+func listOccurrences(store *repository.Store, from, to time.Time) ([]EventOccurrence, error) {
+	periods, err := store.Events.ListPeriods(repository.EventPeriodFilter{From: from, To: to})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event periods: %w", err)
+	}
+	periodIDs := make([]uint, len(periods))
+	for i, p := range periods {
+		periodIDs[i] = p.ID
+	}
+	times, err := store.Events.ListTimes(periodIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event times: %w", err)
+	}
+	timesByPeriod := make(map[uint][]models.EventTime)
+	for _, t := range times {
+		timesByPeriod[t.EventPeriodID] = append(timesByPeriod[t.EventPeriodID], t)
+	}
+
+	locations, err := store.Locations.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", err)
+	}
+
+	events := make(map[uint]*models.Event)
+	var occurrences []EventOccurrence
+	for i := range periods {
+		period := &periods[i]
+		event, ok := events[period.EventID]
+		if !ok {
+			event, err = store.Events.GetByID(period.EventID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get event %d: %w", period.EventID, err)
+			}
+			events[period.EventID] = event
+		}
+		if !event.IsActive {
+			continue
+		}
+		occurrences = append(occurrences, EventOccurrence{
+			Event:     *event,
+			Period:    period,
+			Times:     timesByPeriod[period.ID],
+			Locations: eventLocations(*event, locations),
+			StartsAt:  period.StartsAt,
+			EndsAt:    period.EndsAt,
+		})
+	}
+
+	// Events without periods fall back to their own start time
+	dated, err := store.Events.List(repository.EventFilter{From: from, To: to})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+	for _, event := range dated {
+		if _, ok := events[event.ID]; ok || !event.IsActive || event.RRule != "" {
+			continue
+		}
+		occurrences = append(occurrences, EventOccurrence{
+			Event:     event,
+			Locations: eventLocations(event, locations),
+			StartsAt:  event.StartTime,
+			EndsAt:    event.EndTime,
+		})
+	}
+
+	recurring, err := store.Events.List(repository.EventFilter{Recurring: true, To: to})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recurring events: %w", err)
+	}
+	for _, event := range recurring {
+		if !event.IsActive {
+			continue
+		}
+		rule, err := utils.ParseRRule(event.RRule)
+		if err != nil {
+			// Rules are validated when saved, so this is a damaged row;
+			// skip it rather than hide every other event
+			continue
+		}
+		skipped := make(map[string]bool, len(event.ExDates))
+		for _, date := range event.ExDates {
+			skipped[date] = true
+		}
+		duration := event.EndTime.Sub(event.StartTime)
+		for _, start := range rule.Between(event.StartTime.In(time.Local), from, to) {
+			if skipped[start.Format("2006-01-02")] {
+				continue
+			}
+			occurrences = append(occurrences, EventOccurrence{
+				Event:     event,
+				Locations: eventLocations(event, locations),
+				StartsAt:  start.UTC(),
+				EndsAt:    start.Add(duration).UTC(),
+			})
+		}
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		return occurrences[i].StartsAt.Before(occurrences[j].StartsAt)
+	})
+	return occurrences, nil
+}
+
+// CreateLocal creates an event that isn't in PCO
+func (s *EventService) CreateLocal(input EventInput, createdBy string) (*models.Event, error) {
+	if input.Name == nil || input.StartTime == nil || input.EndTime == nil {
+		return nil, fmt.Errorf("%w: name, start_time and end_time are required", utils.ErrInvalidInput)
+	}
+
+	event := &models.Event{
+		PCOEventID: "local-" + utils.GenerateID(),
+		Source:     models.EventSourceLocal,
+		IsActive:   true,
+		CreatedBy:  createdBy,
+	}
+	if err := s.applyInput(event, input); err != nil {
+		return nil, err
+	}
+	if err := s.store.Events.Create(event); err != nil {
+		return nil, fmt.Errorf("failed to create event: %w", err)
+	}
+	return event, nil
+}
+
+// UpdateLocal changes a local event and returns it as it was before and after
+func (s *EventService) UpdateLocal(pcoEventID string, input EventInput) (*models.Event, *models.Event, error) {
+	event, err := s.localEvent(pcoEventID)
+	if err != nil {
+		return nil, nil, err
+	}
+	before := *event
+
+	if err := s.applyInput(event, input); err != nil {
+		return nil, nil, err
+	}
+	if err := s.store.Events.Save(event); err != nil {
+		return nil, nil, fmt.Errorf("failed to save event: %w", err)
+	}
+	return &before, event, nil
+}
+
+// DeleteLocal removes a local event that no active billboard is showing and
+// returns it
+func (s *EventService) DeleteLocal(pcoEventID string) (*models.Event, error) {
+	event, err := s.localEvent(pcoEventID)
+	if err != nil {
+		return nil, err
+	}
+
+	states, err := s.store.BillboardStates.ListActive("")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list billboards: %w", err)
+	}
+	for _, state := range states {
+		if state.EventID == event.ID {
+			return nil, ErrEventInUse
+		}
+	}
+
+	if err := s.store.Events.Delete(event.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete event: %w", err)
+	}
+	return event, nil
+}
+
+// localEvent loads an event that may be changed here
+func (s *EventService) localEvent(pcoEventID string) (*models.Event, error) {
+	event, err := s.store.Events.GetByPCOID(pcoEventID)
+	if err != nil {
+		return nil, err
+	}
+	if event.Source != models.EventSourceLocal {
+		return nil, ErrEventReadOnly
+	}
+	return event, nil
+}
+
+// applyInput copies the set fields of input onto event and validates the
+// result
+func (s *EventService) applyInput(event *models.Event, input EventInput) error {
+	if input.Name != nil {
+		event.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Description != nil {
+		event.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.StartTime != nil {
+		event.StartTime = input.StartTime.UTC()
+		event.Date = event.StartTime
+	}
+	if input.EndTime != nil {
+		event.EndTime = input.EndTime.UTC()
+	}
+	if input.IsActive != nil {
+		event.IsActive = *input.IsActive
+	}
+	if input.LocationID != nil {
+		event.LocationID, event.LocationName = *input.LocationID, ""
+		if event.LocationID != "" {
+			location, err := s.store.Locations.GetByPCOID(event.LocationID)
+			if errors.Is(err, repository.ErrNotFound) {
+				return fmt.Errorf("%w: unknown location %q", utils.ErrInvalidInput, event.LocationID)
+			} else if err != nil {
+				return fmt.Errorf("failed to get location: %w", err)
+			}
+			event.LocationName = location.Name
+		}
+	}
+
+	if input.RRule != nil {
+		event.RRule = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(*input.RRule), "RRULE:"))
+	}
+	if input.ExDates != nil {
+		dates := make([]string, 0, len(*input.ExDates))
+		seen := map[string]bool{}
+		for _, date := range *input.ExDates {
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				return fmt.Errorf("%w: exdates must be dates in YYYY-MM-DD format", utils.ErrInvalidInput)
+			}
+			if !seen[date] {
+				seen[date] = true
+				dates = append(dates, date)
+			}
+		}
+		sort.Strings(dates)
+		event.ExDates = dates
+	}
+	if input.AutoLaunch != nil {
+		event.AutoLaunch = *input.AutoLaunch
+	}
+
+	switch {
+	case event.Name == "":
+		return fmt.Errorf("%w: name is required", utils.ErrInvalidInput)
+	case len(event.Name) > 200:
+		return fmt.Errorf("%w: name must be at most 200 characters", utils.ErrInvalidInput)
+	case event.StartTime.IsZero() || event.EndTime.IsZero():
+		return fmt.Errorf("%w: start_time and end_time are required", utils.ErrInvalidInput)
+	case !event.EndTime.After(event.StartTime):
+		return fmt.Errorf("%w: end_time must be after start_time", utils.ErrInvalidInput)
+	case event.AutoLaunch && event.LocationID == "":
+		return fmt.Errorf("%w: auto_launch needs a location_id", utils.ErrInvalidInput)
+	case len(event.ExDates) > 0 && event.RRule == "":
+		return fmt.Errorf("%w: exdates need an rrule", utils.ErrInvalidInput)
+	}
+	if event.RRule != "" {
+		if _, err := utils.ParseRRule(event.RRule); err != nil {
+			return err
+		}
+		if event.EndTime.Sub(event.StartTime) > 24*time.Hour {
+			return fmt.Errorf("%w: a recurring event can last at most 24 hours", utils.ErrInvalidInput)
+		}
+	}
+	return nil
+}
+
+// attributeEvents fills in the event of check-ins that have none from the
+// occurrence at their location they fall in. When occurrences overlap the
+// one that started last wins.
+func attributeEvents(store *repository.Store, checkIns []models.CheckIn) error {
+	var from, to time.Time
+	for _, checkIn := range checkIns {
+		if checkIn.EventID != "" {
+			continue
+		}
+		if from.IsZero() || checkIn.CheckInTime.Before(from) {
+			from = checkIn.CheckInTime
+		}
+		if checkIn.CheckInTime.After(to) {
+			to = checkIn.CheckInTime
+		}
+	}
+	if from.IsZero() {
+		return nil
+	}
+
+	// Occurrences last at most a day, so any that covers a check-in
+	// started within a day before it
+	occurrences, err := listOccurrences(store, from.Add(-24*time.Hour), to.Add(eventCheckInLead+time.Nanosecond))
+	if err != nil {
+		return err
+	}
+	for i := range checkIns {
+		checkIn := &checkIns[i]
+		if checkIn.EventID != "" {
+			continue
+		}
+		var match *EventOccurrence
+		for j := range occurrences {
+			o := &occurrences[j]
+			if checkIn.CheckInTime.Before(o.StartsAt.Add(-eventCheckInLead)) || !checkIn.CheckInTime.Before(o.EndsAt) {
+				continue
+			}
+			if !o.heldAt(checkIn.LocationID) {
+				continue
+			}
+			if match == nil || o.StartsAt.After(match.StartsAt) {
+				match = o
+			}
+		}
+		if match != nil {
+			checkIn.EventID = match.Event.PCOEventID
+			checkIn.EventName = match.Event.Name
+		}
+	}
+	return nil
+}
+
+// heldAt reports whether the occurrence takes check-ins at a location
+func (o *EventOccurrence) heldAt(locationID string) bool {
+	if locationID == "" {
+		return false
+	}
+	if o.Event.LocationID == locationID {
+		return true
+	}
+	for _, l := range o.Locations {
+		if l.PCOLocationID == locationID {
+			return true
+		}
+	}
+	return false
+}
+
+// Occurrence describes a single event with its locations, for endpoints
+// that aren't tied to a day
+func (s *EventService) Occurrence(event models.Event) (*EventOccurrence, error) {
+	locations, err := s.store.Locations.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", err)
+	}
+	return &EventOccurrence{
+		Event:     event,
+		Locations: eventLocations(event, locations),
+		StartsAt:  event.StartTime,
+		EndsAt:    event.EndTime,
+	}, nil
+}
+
+// eventLocations picks the locations children check in to for an event:
+// those PCO links to it, or else the one it is held at, or every location
+// in the folder it is held in
+func eventLocations(event models.Event, locations []models.Location) []models.Location {
+	var linked []models.Location
+	for _, l := range locations {
+		if event.Source == models.EventSourcePCO && l.PCOEventID == event.PCOEventID && !l.IsFolder() {
+			linked = append(linked, l)
+		}
+	}
+	if len(linked) > 0 || event.LocationID == "" {
+		return linked
+	}
+	return coveredLocations(locations, event.LocationID)
+}
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// PDF page layout, in points. Pages are US Letter, turned to landscape when
+// a table is too wide to fit upright.
+const (
+	pdfPageWidth   = 612
+	pdfPageHeight  = 792
+	pdfMargin      = 36
+	pdfFontSize    = 9
+	pdfRowHeight   = 16
+	pdfCellPadding = 4
+	pdfMinColumn   = 30
+	// pdfFooterHeight is kept clear at the bottom of each page for the page
+	// number
+	pdfFooterHeight = 24
+)
+
+// WritePDF writes the table as a PDF document headed by the organization,
+// the table's title and the period it covers. Text is set in the PDF's
+// built-in Helvetica, so no fonts are embedded, and the header row is
+// repeated on every page.
+func (t *Table) WritePDF(w io.Writer, organization, period string) error {
+	columns := len(t.Columns)
+	header := make([]string, columns)
+	copy(header, t.Columns)
+	rows := make([][]string, len(t.Rows))
+	numeric := make([]bool, columns)
+	for i := range numeric {
+		numeric[i] = len(t.Rows) > 0
+	}
+	for i, row := range t.Rows {
+		rows[i] = make([]string, columns)
+		for j := 0; j < columns; j++ {
+			var cell interface{}
+			if j < len(row) {
+				cell = row[j]
+			}
+			rows[i][j] = cellText(cell)
+			switch cell.(type) {
+			case int, int64, float64, nil:
+			default:
+				numeric[j] = false
+			}
+		}
+	}
+
+	// Size columns to their widest text, rounded up so it isn't cut short,
+	// then fit them to the page
+	widths := make([]float64, columns)
+	for j := range widths {
+		widths[j] = math.Ceil(pdfTextWidth(header[j], true, pdfFontSize)) + 2*pdfCellPadding
+		for _, row := range rows {
+			if width := math.Ceil(pdfTextWidth(row[j], false, pdfFontSize)) + 2*pdfCellPadding; width > widths[j] {
+				widths[j] = width
+			}
+		}
+		if widths[j] < pdfMinColumn {
+			widths[j] = pdfMinColumn
+		}
+	}
+	pageWidth, pageHeight := float64(pdfPageWidth), float64(pdfPageHeight)
+	if totalWidth(widths) > pageWidth-2*pdfMargin {
+		pageWidth, pageHeight = pageHeight, pageWidth
+	}
+	fitColumns(widths, pageWidth-2*pdfMargin)
+
+	// The first page also holds the heading
+	var headingLines []pdfLine
+	if organization != "" {
+		headingLines = append(headingLines, pdfLine{organization, true, 16})
+	}
+	headingLines = append(headingLines, pdfLine{t.Title, true, 13})
+	if period != "" {
+		headingLines = append(headingLines, pdfLine{period, false, 10})
+	}
+	headingHeight := 8.0
+	for _, line := range headingLines {
+		headingHeight += line.size * 1.4
+	}
+	bodyHeight := pageHeight - 2*pdfMargin - pdfFooterHeight - pdfRowHeight
+	firstRows := int((bodyHeight - headingHeight) / pdfRowHeight)
+	perPage := int(bodyHeight / pdfRowHeight)
+	if firstRows < 1 {
+		firstRows = 1
+	}
+
+	var pages [][][]string
+	remaining := rows
+	for first := true; first || len(remaining) > 0; first = false {
+		n := perPage
+		if first {
+			n = firstRows
+		}
+		if n > len(remaining) {
+			n = len(remaining)
+		}
+		pages = append(pages, remaining[:n])
+		remaining = remaining[n:]
+	}
+
+	generated := "Generated " + time.Now().Format("2006-01-02 15:04")
+	doc := &pdfDocument{}
+	var contents []string
+	for number, pageRows := range pages {
+		page := &strings.Builder{}
+		y := pageHeight - pdfMargin
+		if number == 0 {
+			for _, line := range headingLines {
+				y -= line.size * 1.4
+				pdfText(page, pdfMargin, y+line.size*0.3, line.text, line.bold, line.size)
+			}
+			y -= 8
+		}
+
+		pdfTableRow(page, y, widths, header, numeric, true, 0.85)
+		y -= pdfRowHeight
+		for i, row := range pageRows {
+			shade := 1.0
+			if i%2 == 1 {
+				shade = 0.95
+			}
+			pdfTableRow(page, y, widths, row, numeric, false, shade)
+			y -= pdfRowHeight
+		}
+		if len(rows) == 0 {
+			pdfText(page, pdfMargin+pdfCellPadding, y-pdfRowHeight+5, "Nothing to report for this period", false, pdfFontSize)
+			y -= pdfRowHeight
+		}
+		fmt.Fprintf(page, "0.5 w %.2f %.2f m %.2f %.2f l S\n", float64(pdfMargin), y, pdfMargin+totalWidth(widths), y)
+
+		page.WriteString("0.4 g\n")
+		pdfText(page, pdfMargin, pdfMargin, generated, false, 8)
+		pageLabel := fmt.Sprintf("Page %d of %d", number+1, len(pages))
+		pdfText(page, pageWidth-pdfMargin-pdfTextWidth(pageLabel, false, 8), pdfMargin, pageLabel, false, 8)
+		page.WriteString("0 g\n")
+		contents = append(contents, page.String())
+	}
+
+	return doc.write(w, t.Title, pageWidth, pageHeight, contents)
+}
+
+type pdfLine struct {
+	text string
+	bold bool
+	size float64
+}
+
+// fitColumns shrinks the widest columns until the table fits the width,
+// leaving columns narrower than an even share as they are
+func fitColumns(widths []float64, available float64) {
+	for totalWidth(widths) > available {
+		fixed, flexible := 0.0, 0.0
+		share := available / float64(len(widths))
+		for _, width := range widths {
+			if width <= share {
+				fixed += width
+			} else {
+				flexible += width
+			}
+		}
+		if flexible == 0 {
+			return
+		}
+		scale := (available - fixed) / flexible
+		for j, width := range widths {
+			if width > share {
+				widths[j] = width * scale
+				if widths[j] < pdfMinColumn {
+					widths[j] = pdfMinColumn
+				}
+			}
+		}
+		if scale >= 1 {
+			return
+		}
+	}
+}
+
+// pdfTableRow draws one row of cells with its top at y, filled with a gray
+// shade (1 is white); numeric columns are right-aligned and text too wide
+// for its column is cut short
+func pdfTableRow(page *strings.Builder, y float64, widths []float64, cells []string, numeric []bool, bold bool, shade float64) {
+	if shade < 1 {
+		fmt.Fprintf(page, "%.2f g %.2f %.2f %.2f %.2f re f 0 g\n", shade, float64(pdfMargin), y-pdfRowHeight, totalWidth(widths), float64(pdfRowHeight))
+	}
+	x := float64(pdfMargin)
+	for j, width := range widths {
+		text := pdfTruncate(cells[j], bold, width-2*pdfCellPadding)
+		textX := x + pdfCellPadding
+		if numeric[j] {
+			textX = x + width - pdfCellPadding - pdfTextWidth(text, bold, pdfFontSize)
+		}
+		pdfText(page, textX, y-pdfRowHeight+5, text, bold, pdfFontSize)
+		x += width
+	}
+}
+
+// pdfText sets a line of text with its baseline at x, y
+func pdfText(page *strings.Builder, x, y float64, text string, bold bool, size float64) {
+	if text == "" {
+		return
+	}
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(winAnsi(text)))
+}
+
+// pdfTruncate cuts text to fit a width, ending it with an ellipsis
+func pdfTruncate(text string, bold bool, width float64) string {
+	if pdfTextWidth(text, bold, pdfFontSize) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdfTextWidth(string(runes)+"…", bold, pdfFontSize) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+func totalWidth(widths []float64) float64 {
+	total := 0.0
+	for _, width := range widths {
+		total += width
+	}
+	return total
+}
+
+// pdfDocument assembles the objects of a PDF file and their cross-reference
+// table
+type pdfDocument struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// object appends the next numbered object
+func (d *pdfDocument) object(body string) {
+	d.offsets = append(d.offsets, d.buf.Len())
+	fmt.Fprintf(&d.buf, "%d 0 obj\n%s\nendobj\n", len(d.offsets), body)
+}
+
+// write lays out the catalog, page tree, fonts and info as objects 1 to 5,
+// then a content stream and page object for each page
+func (d *pdfDocument) write(w io.Writer, title string, width, height float64, contents []string) error {
+	d.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	const firstPage = 6
+	kids := make([]string, len(contents))
+	for i := range contents {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i+1)
+	}
+	d.object("<< /Type /Catalog /Pages 2 0 R >>")
+	d.object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(contents)))
+	d.object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	d.object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	d.object(fmt.Sprintf("<< /Title (%s) /Producer (PCO Arrivals) /CreationDate (D:%s) >>", pdfEscape(winAnsi(title)), time.Now().UTC().Format("20060102150405Z")))
+
+	for i, content := range contents {
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write([]byte(content)); err != nil {
+			return fmt.Errorf("failed to compress page %d: %w", i+1, err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("failed to compress page %d: %w", i+1, err)
+		}
+		d.object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), compressed.Bytes()))
+		d.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			width, height, firstPage+2*i))
+	}
+
+	xref := d.buf.Len()
+	fmt.Fprintf(&d.buf, "xref\n0 %d\n0000000000 65535 f \n", len(d.offsets)+1)
+	for _, offset := range d.offsets {
+		fmt.Fprintf(&d.buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&d.buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(d.offsets)+1, xref)
+
+	if _, err := w.Write(d.buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write PDF: %w", err)
+	}
+	return nil
+}
+
+// winAnsiExtras are the characters outside Latin-1 that WinAnsiEncoding has
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e, '‘': 0x91,
+	'’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98,
+	'™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// winAnsi encodes text for the built-in fonts; characters they can't show
+// become question marks
+func winAnsi(text string) []byte {
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			encoded = append(encoded, ' ')
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			encoded = append(encoded, byte(r))
+		default:
+			if b, ok := winAnsiExtras[r]; ok {
+				encoded = append(encoded, b)
+			} else {
+				encoded = append(encoded, '?')
+			}
+		}
+	}
+	return encoded
+}
+
+// pdfEscape writes encoded text as the body of a PDF string
+func pdfEscape(text []byte) string {
+	var b strings.Builder
+	for _, c := range text {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// pdfTextWidth measures text in points using the Helvetica metrics
+func pdfTextWidth(text string, bold bool, size float64) float64 {
+	widths := &helveticaWidths
+	if bold {
+		widths = &helveticaBoldWidths
+	}
+	units := 0
+	for _, c := range winAnsi(text) {
+		switch {
+		case c >= 0x20 && c < 0x7f:
+			units += widths[c-0x20]
+		case c == 0x85 || c == 0x97 || c == 0x89:
+			units += 1000
+		default:
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// helveticaWidths and helveticaBoldWidths are the advance widths, in
+// thousandths of the font size, of the printable ASCII characters
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
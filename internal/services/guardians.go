@@ -0,0 +1,151 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/utils"
+)
+
+// Guardian is the primary contact of a person's PCO household, who is
+// reached about them at pickup
+type Guardian struct {
+	PersonID string `json:"person_id"`
+	Name     string `json:"name"`
+	Phone    string `json:"phone"`
+	Email    string `json:"email"`
+}
+
+// guardianEntry is a cached lookup; guardian is nil for people without one
+type guardianEntry struct {
+	guardian *Guardian
+	expires  time.Time
+}
+
+// GuardianService resolves the guardians of checked-in children from PCO
+// households, caching each person's guardian and each contact's details
+type GuardianService struct {
+	pco    *PCOService
+	ttl    time.Duration
+	logger *utils.Logger
+
+	// mu guards the caches, keyed by PCO person ID
+	mu       sync.Mutex
+	children map[string]guardianEntry
+	contacts map[string]guardianEntry
+}
+
+func NewGuardianService(cfg *config.Config, pco *PCOService, logger *utils.Logger) *GuardianService {
+	return &GuardianService{
+		pco:      pco,
+		ttl:      time.Duration(cfg.PCO.GuardianTTL) * time.Second,
+		logger:   logger.WithComponent("guardian_service"),
+		children: make(map[string]guardianEntry),
+		contacts: make(map[string]guardianEntry),
+	}
+}
+
+// Guardian returns the primary contact of the first of a person's households
+// that someone else heads, or nil if the person heads all of theirs or has
+// none. Lookups, including those that find no one, are cached for the TTL.
+func (s *GuardianService) Guardian(accessToken, personID string) (*Guardian, error) {
+	now := time.Now()
+	if guardian, ok := s.cached(s.children, personID, now); ok {
+		return guardian, nil
+	}
+
+	households, err := s.pco.GetHouseholds(accessToken, personID)
+	if err != nil {
+		return nil, err
+	}
+	var guardian *Guardian
+	for _, household := range households {
+		if household.PrimaryContactID == "" || household.PrimaryContactID == personID {
+			continue
+		}
+		guardian, err = s.contact(accessToken, household.PrimaryContactID, household.PrimaryContactName, now)
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	s.store(s.children, personID, guardian, now)
+	return guardian, nil
+}
+
+// Fill sets the parent details of children's check-ins from their
+// guardians. Volunteers and one-time guests, who have no PCO household, are
+// skipped, and a failed lookup is logged and leaves the check-in's parent
+// blank so the stored one is kept.
+func (s *GuardianService) Fill(accessToken string, checkIns []PCOCheckIn) {
+	for i := range checkIns {
+		checkIn := &checkIns[i]
+		if checkIn.PersonID == "" || checkIn.OneTimeGuest || checkIn.Kind == models.CheckInKindVolunteer {
+			continue
+		}
+		guardian, err := s.Guardian(accessToken, checkIn.PersonID)
+		if err != nil {
+			s.logger.Error("Failed to resolve guardian", "error", err, "person_id", checkIn.PersonID)
+			continue
+		}
+		if guardian == nil {
+			continue
+		}
+		checkIn.ParentName = guardian.Name
+		checkIn.ParentPhone = guardian.Phone
+		checkIn.ParentEmail = guardian.Email
+	}
+}
+
+// contact returns a household's primary contact with their phone number and
+// email address, falling back to the household's name for them when PCO
+// doesn't return the person
+func (s *GuardianService) contact(accessToken, personID, name string, now time.Time) (*Guardian, error) {
+	if guardian, ok := s.cached(s.contacts, personID, now); ok {
+		return guardian, nil
+	}
+
+	guardian := &Guardian{PersonID: personID, Name: name}
+	contact, err := s.pco.GetContact(accessToken, personID)
+	if err != nil {
+		return nil, err
+	}
+	if contact != nil {
+		if contact.Name != "" {
+			guardian.Name = contact.Name
+		}
+		guardian.Phone = contact.Phone
+		guardian.Email = contact.Email
+	}
+
+	s.store(s.contacts, personID, guardian, now)
+	return guardian, nil
+}
+
+func (s *GuardianService) cached(cache map[string]guardianEntry, personID string, now time.Time) (*Guardian, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := cache[personID]
+	if !ok || now.After(entry.expires) {
+		return nil, false
+	}
+	return entry.guardian, true
+}
+
+// store caches a lookup, dropping expired entries so the cache doesn't grow
+// with every child ever checked in
+func (s *GuardianService) store(cache map[string]guardianEntry, personID string, guardian *Guardian, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, entry := range cache {
+		if now.After(entry.expires) {
+			delete(cache, id)
+		}
+	}
+	cache[personID] = guardianEntry{guardian: guardian, expires: now.Add(s.ttl)}
+}
@@ -0,0 +1,460 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/utils"
+)
+
+// locationSyncActor names location reconciliation in the audit log
+const locationSyncActor = "location_sync"
+
+// ErrNoPCOLocations is returned when PCO lists no locations at all, which
+// is taken as a failed request rather than a reason to deactivate them all
+var ErrNoPCOLocations = errors.New("PCO returned no locations")
+
+// ErrLocationSyncUnavailable is returned for reconciliations without PCO,
+// as in demo mode
+var ErrLocationSyncUnavailable = errors.New("location sync is not available without PCO")
+
+var locationColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// LocationNode is a location with the locations and folders inside it
+type LocationNode struct {
+	models.Location
+	Children []*LocationNode `json:"children"`
+}
+
+// LocationFieldChange is one field a reconciliation changed
+type LocationFieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// LocationChange is a location a reconciliation created, updated or
+// deactivated
+type LocationChange struct {
+	PCOLocationID string                `json:"pco_location_id"`
+	Name          string                `json:"name"`
+	Fields        []LocationFieldChange `json:"fields,omitempty"`
+}
+
+// LocationSyncReport is the diff a reconciliation applied, or would apply
+// on a dry run
+type LocationSyncReport struct {
+	StartedAt   time.Time        `json:"started_at"`
+	FinishedAt  time.Time        `json:"finished_at"`
+	DryRun      bool             `json:"dry_run"`
+	Created     []LocationChange `json:"created"`
+	Updated     []LocationChange `json:"updated"`
+	Deactivated []LocationChange `json:"deactivated"`
+	Unchanged   int              `json:"unchanged"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// Changed reports whether the reconciliation changed anything
+func (r *LocationSyncReport) Changed() bool {
+	return len(r.Created)+len(r.Updated)+len(r.Deactivated) > 0
+}
+
+// LocationOverrides holds the local-only fields of a location. Nil fields
+// are left unchanged.
+type LocationOverrides struct {
+	DisplayName *string `json:"display_name"`
+	Color       *string `json:"color"`
+	Hidden      *bool   `json:"hidden"`
+	Capacity    *int    `json:"capacity"`
+	Ratio       *int    `json:"volunteer_ratio"`
+}
+
+// LocationService keeps the locations in step with PCO and answers questions
+// about their tree
+type LocationService struct {
+	store  *repository.Store
+	pco    *PCOService
+	audit  *AuditService
+	logger *utils.Logger
+
+	// syncing serialises reconciliations; mu guards last
+	syncing sync.Mutex
+	mu      sync.RWMutex
+	last    *LocationSyncReport
+	stop    chan struct{}
+}
+
+// NewLocationService returns a location service. pco may be nil in demo
+// mode, in which case locations aren't reconciled.
+func NewLocationService(store *repository.Store, pco *PCOService, audit *AuditService, logger *utils.Logger) *LocationService {
+	return &LocationService{
+		store:  store,
+		pco:    pco,
+		audit:  audit,
+		logger: logger.WithComponent("location_service"),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Start reconciles locations every interval until Stop is called, with the
+// access token returned by token at the time
+func (s *LocationService) Start(interval time.Duration, token func() (string, error)) {
+	if s.pco == nil || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			s.runSync(token)
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	s.logger.Info("Location sync started", "interval", interval)
+}
+
+func (s *LocationService) Stop() {
+	close(s.stop)
+}
+
+// runSync runs one background reconciliation and audits what it changed
+func (s *LocationService) runSync(token func() (string, error)) {
+	accessToken, err := token()
+	if err != nil {
+		s.logger.Warn("Skipping location sync", "error", err)
+		return
+	}
+	report, err := s.Reconcile(accessToken, false)
+	if err != nil {
+		s.logger.Error("Location sync failed", "error", err)
+		return
+	}
+	if report.Changed() {
+		s.audit.Record(&models.AuditEvent{ActorName: locationSyncActor, AuthMethod: locationSyncActor, Action: models.AuditLocationSync, TargetType: "location", After: report})
+	}
+}
+
+// LastReport returns the report of the latest reconciliation that wasn't a
+// dry run, or nil if there has been none
+func (s *LocationService) LastReport() *LocationSyncReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.last
+}
+
+// Reconcile brings the locations in line with PCO: new ones are created,
+// changed ones updated and those PCO no longer lists deactivated. Local
+// overrides are kept. With dryRun, the report is built but nothing is
+// written.
+func (s *LocationService) Reconcile(accessToken string, dryRun bool) (*LocationSyncReport, error) {
+	if s.pco == nil {
+		return nil, ErrLocationSyncUnavailable
+	}
+	s.syncing.Lock()
+	defer s.syncing.Unlock()
+
+	report := &LocationSyncReport{StartedAt: time.Now(), DryRun: dryRun, Created: []LocationChange{}, Updated: []LocationChange{}, Deactivated: []LocationChange{}}
+	err := s.reconcile(accessToken, report)
+	report.FinishedAt = time.Now()
+	if err != nil {
+		report.Error = err.Error()
+	}
+	if !dryRun {
+		s.mu.Lock()
+		s.last = report
+		s.mu.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		s.logger.Info("Reconciled locations with PCO", "created", len(report.Created), "updated", len(report.Updated), "deactivated", len(report.Deactivated), "unchanged", report.Unchanged)
+	}
+	return report, nil
+}
+
+func (s *LocationService) reconcile(accessToken string, report *LocationSyncReport) error {
+	pcoLocations, err := s.pco.GetLocations(accessToken)
+	if err != nil {
+		return err
+	}
+	if len(pcoLocations) == 0 {
+		return ErrNoPCOLocations
+	}
+
+	stored, err := s.store.Locations.List()
+	if err != nil {
+		return fmt.Errorf("failed to list locations: %w", err)
+	}
+	existing := make(map[string]models.Location, len(stored))
+	for _, l := range stored {
+		existing[l.PCOLocationID] = l
+	}
+
+	seen := make(map[string]bool, len(pcoLocations))
+	for _, l := range pcoLocations {
+		seen[l.ID] = true
+		location, ok := existing[l.ID]
+		if !ok {
+			location = models.Location{PCOLocationID: l.ID, IsActive: true}
+		}
+		changes := applyPCOLocation(&location, l, l.EventID)
+		if ok && !location.IsActive {
+			location.IsActive = true
+			changes = append(changes, LocationFieldChange{Field: "is_active", Before: false, After: true})
+		}
+
+		switch {
+		case !ok:
+			report.Created = append(report.Created, LocationChange{PCOLocationID: l.ID, Name: l.Name})
+			if !report.DryRun {
+				if err := s.store.Locations.Create(&location); err != nil {
+					return fmt.Errorf("failed to create location %s: %w", l.ID, err)
+				}
+			}
+		case len(changes) > 0:
+			report.Updated = append(report.Updated, LocationChange{PCOLocationID: l.ID, Name: l.Name, Fields: changes})
+			if !report.DryRun {
+				if err := s.store.Locations.Save(&location); err != nil {
+					return fmt.Errorf("failed to save location %s: %w", l.ID, err)
+				}
+			}
+		default:
+			report.Unchanged++
+		}
+	}
+
+	for _, location := range stored {
+		if seen[location.PCOLocationID] || !location.IsActive {
+			continue
+		}
+		report.Deactivated = append(report.Deactivated, LocationChange{
+			PCOLocationID: location.PCOLocationID,
+			Name:          location.Name,
+			Fields:        []LocationFieldChange{{Field: "is_active", Before: true, After: false}},
+		})
+		if report.DryRun {
+			continue
+		}
+		location.IsActive = false
+		if err := s.store.Locations.Save(&location); err != nil {
+			return fmt.Errorf("failed to deactivate location %s: %w", location.PCOLocationID, err)
+		}
+	}
+	return nil
+}
+
+// UpdateOverrides sets the local-only fields of a location and returns it
+// before and after
+func (s *LocationService) UpdateOverrides(pcoLocationID string, overrides LocationOverrides) (*models.Location, *models.Location, error) {
+	location, err := s.store.Locations.GetByPCOID(pcoLocationID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get location: %w", err)
+	}
+	before := *location
+
+	if overrides.DisplayName != nil {
+		name := strings.TrimSpace(*overrides.DisplayName)
+		if len(name) > 100 {
+			return nil, nil, fmt.Errorf("%w: display_name must be at most 100 characters", utils.ErrInvalidInput)
+		}
+		location.DisplayName = name
+	}
+	if overrides.Color != nil {
+		if *overrides.Color != "" && !locationColorPattern.MatchString(*overrides.Color) {
+			return nil, nil, fmt.Errorf("%w: color must be a hex color such as #1e90ff", utils.ErrInvalidInput)
+		}
+		location.Color = strings.ToLower(*overrides.Color)
+	}
+	if overrides.Hidden != nil {
+		location.Hidden = *overrides.Hidden
+	}
+	if overrides.Capacity != nil {
+		if *overrides.Capacity < 0 {
+			return nil, nil, fmt.Errorf("%w: capacity can't be negative", utils.ErrInvalidInput)
+		}
+		location.Capacity = *overrides.Capacity
+	}
+	if overrides.Ratio != nil {
+		if *overrides.Ratio < 0 {
+			return nil, nil, fmt.Errorf("%w: volunteer_ratio can't be negative", utils.ErrInvalidInput)
+		}
+		location.Ratio = *overrides.Ratio
+	}
+
+	if err := s.store.Locations.Save(location); err != nil {
+		return nil, nil, fmt.Errorf("failed to save location: %w", err)
+	}
+	return &before, location, nil
+}
+
+// applyPCOLocation copies the synced fields of a PCO location onto a stored
+// one, leaving local overrides alone, and returns the fields that changed
+func applyPCOLocation(location *models.Location, l PCOLocation, pcoEventID string) []LocationFieldChange {
+	var changes []LocationFieldChange
+	set := func(field string, before, after interface{}, apply func()) {
+		if before != after {
+			changes = append(changes, LocationFieldChange{Field: field, Before: before, After: after})
+			apply()
+		}
+	}
+	maxOccupancy := 0
+	if l.MaxOccupancy != nil {
+		maxOccupancy = *l.MaxOccupancy
+	}
+
+	set("name", location.Name, l.Name, func() { location.Name = l.Name })
+	set("pco_event_id", location.PCOEventID, pcoEventID, func() { location.PCOEventID = pcoEventID })
+	set("kind", location.Kind, l.Kind, func() { location.Kind = l.Kind })
+	set("parent_id", location.ParentID, l.ParentID, func() { location.ParentID = l.ParentID })
+	set("position", location.Position, l.Position, func() { location.Position = l.Position })
+	set("age_min_months", optionalInt(location.AgeMinMonths), optionalInt(l.AgeMinInMonths), func() { location.AgeMinMonths = l.AgeMinInMonths })
+	set("age_max_months", optionalInt(location.AgeMaxMonths), optionalInt(l.AgeMaxInMonths), func() { location.AgeMaxMonths = l.AgeMaxInMonths })
+	set("grade_min", optionalInt(location.GradeMin), optionalInt(l.GradeMin), func() { location.GradeMin = l.GradeMin })
+	set("grade_max", optionalInt(location.GradeMax), optionalInt(l.GradeMax), func() { location.GradeMax = l.GradeMax })
+	set("max_occupancy", location.MaxOccupancy, maxOccupancy, func() { location.MaxOccupancy = maxOccupancy })
+	return changes
+}
+
+// optionalInt dereferences an optional number for comparison and reports,
+// keeping nil
+func optionalInt(n *int) interface{} {
+	if n == nil {
+		return nil
+	}
+	return *n
+}
+
+// Tree returns the locations as a forest, ordered by PCO position then
+// name. Locations whose parent isn't known are placed at the top.
+func (s *LocationService) Tree() ([]*LocationNode, error) {
+	locations, err := s.store.Locations.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", err)
+	}
+
+	nodes := make(map[string]*LocationNode, len(locations))
+	for _, l := range locations {
+		nodes[l.PCOLocationID] = &LocationNode{Location: l, Children: []*LocationNode{}}
+	}
+	roots := []*LocationNode{}
+	for _, l := range locations {
+		node := nodes[l.PCOLocationID]
+		parent, ok := nodes[l.ParentID]
+		// A location can't be its own ancestor; break cycles at the top
+		if !ok || l.ParentID == l.PCOLocationID || isDescendant(nodes, l.ParentID, l.PCOLocationID) {
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+
+	sortLocationNodes(roots)
+	return roots, nil
+}
+
+// Covered returns the IDs of the locations a billboard for pcoLocationID
+// shows check-ins from: the location itself, or every location in a folder
+func (s *LocationService) Covered(pcoLocationID string) ([]string, error) {
+	return coveredLocationIDs(s.store, pcoLocationID)
+}
+
+// coveredLocationIDs is Covered for services without a LocationService.
+// Unknown locations cover only themselves, as do empty folders, so the
+// result is never empty.
+func coveredLocationIDs(store *repository.Store, pcoLocationID string) ([]string, error) {
+	locations, err := store.Locations.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", err)
+	}
+	covered := coveredLocations(locations, pcoLocationID)
+	if len(covered) == 0 {
+		return []string{pcoLocationID}, nil
+	}
+	ids := make([]string, len(covered))
+	for i, l := range covered {
+		ids[i] = l.PCOLocationID
+	}
+	return ids, nil
+}
+
+// coveredLocations returns the location with pcoLocationID if it takes
+// check-ins, or for a folder every location below it at any depth
+func coveredLocations(locations []models.Location, pcoLocationID string) []models.Location {
+	children := make(map[string][]models.Location)
+	var target *models.Location
+	for i, l := range locations {
+		if l.PCOLocationID == pcoLocationID {
+			target = &locations[i]
+		}
+		if l.ParentID != "" && l.ParentID != l.PCOLocationID {
+			children[l.ParentID] = append(children[l.ParentID], l)
+		}
+	}
+	if target == nil {
+		return nil
+	}
+	if !target.IsFolder() {
+		return []models.Location{*target}
+	}
+
+	var covered []models.Location
+	visited := map[string]bool{pcoLocationID: true}
+	queue := []string{pcoLocationID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, child := range children[id] {
+			if visited[child.PCOLocationID] {
+				continue
+			}
+			visited[child.PCOLocationID] = true
+			if child.IsFolder() {
+				queue = append(queue, child.PCOLocationID)
+			} else {
+				covered = append(covered, child)
+			}
+		}
+	}
+	return covered
+}
+
+// isDescendant reports whether id is below ancestorID in the tree
+func isDescendant(nodes map[string]*LocationNode, id, ancestorID string) bool {
+	seen := map[string]bool{}
+	for id != "" && !seen[id] {
+		seen[id] = true
+		node, ok := nodes[id]
+		if !ok {
+			return false
+		}
+		if node.ParentID == ancestorID {
+			return true
+		}
+		id = node.ParentID
+	}
+	return false
+}
+
+func sortLocationNodes(nodes []*LocationNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Position != nodes[j].Position {
+			return nodes[i].Position < nodes[j].Position
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+	for _, node := range nodes {
+		sortLocationNodes(node.Children)
+	}
+}
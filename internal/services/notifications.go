@@ -1,42 +1,170 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
 	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
 	"go_pco_arrivals/internal/utils"
-
-	"gorm.io/gorm"
 )
 
+// notificationTTL is how long a pickup request stays on the billboard
+const notificationTTL = 30 * time.Minute
+
 type NotificationService struct {
-	db         *gorm.DB
+	store      *repository.Store
 	pcoService *PCOService
 	logger     *utils.Logger
 }
 
-func NewNotificationService(db *gorm.DB, pcoService *PCOService) *NotificationService {
+// PickupRequest describes a request to call a child for pickup
+type PickupRequest struct {
+	SecurityCode string `json:"security_code"`
+	ChildName    string `json:"child_name"`
+	LocationID   string `json:"location_id"`
+	PCOCheckInID string `json:"pco_check_in_id"`
+	Notes        string `json:"notes"`
+}
+
+func NewNotificationService(store *repository.Store, pcoService *PCOService) *NotificationService {
 	return &NotificationService{
-		db:         db,
+		store:      store,
 		pcoService: pcoService,
 		logger:     utils.NewLogger().WithComponent("notification_service"),
 	}
 }
 
+// CreatePickupRequest creates an active notification, filling in child and
+// location details from the matching check-in when one can be found
+func (s *NotificationService) CreatePickupRequest(request PickupRequest, createdBy string) (*models.Notification, error) {
+	request.SecurityCode = strings.ToUpper(strings.TrimSpace(request.SecurityCode))
+	if request.SecurityCode == "" && request.PCOCheckInID == "" {
+		return nil, fmt.Errorf("%w: security code or check-in ID is required", utils.ErrInvalidInput)
+	}
+
+	notification := &models.Notification{
+		PCOCheckInID: request.PCOCheckInID,
+		ChildName:    request.ChildName,
+		SecurityCode: request.SecurityCode,
+		LocationID:   request.LocationID,
+		Notes:        request.Notes,
+		Status:       "active",
+		ExpiresAt:    time.Now().Add(notificationTTL),
+		CreatedBy:    createdBy,
+	}
+
+	// Look up the check-in to fill in details the caller didn't provide
+	checkIn, err := s.findCheckIn(request)
+	if err == nil {
+		notification.PCOCheckInID = checkIn.PCOCheckInID
+		if notification.ChildName == "" {
+			notification.ChildName = checkIn.PersonName
+		}
+		if notification.SecurityCode == "" {
+			notification.SecurityCode = checkIn.SecurityCode
+		}
+		if notification.LocationID == "" {
+			notification.LocationID = checkIn.LocationID
+		}
+		notification.LocationName = checkIn.LocationName
+		notification.EventName = checkIn.EventName
+		notification.ParentName = checkIn.ParentName
+		notification.ParentPhone = checkIn.ParentPhone
+		notification.ParentEmail = checkIn.ParentEmail
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("failed to look up check-in: %w", err)
+	}
+
+	if notification.ChildName == "" {
+		return nil, fmt.Errorf("%w: no check-in found for security code %s, child name is required", utils.ErrInvalidInput, notification.SecurityCode)
+	}
+	if notification.PCOCheckInID == "" {
+		notification.PCOCheckInID = "manual-" + utils.GenerateID()
+	}
+
+	if err := s.CreateNotification(notification); err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// findCheckIn returns the check-in a pickup request refers to: the one with
+// its PCO ID, or else the latest in the past day with its security code
+func (s *NotificationService) findCheckIn(request PickupRequest) (*models.CheckIn, error) {
+	if request.PCOCheckInID != "" {
+		return s.store.CheckIns.GetByPCOID(request.PCOCheckInID)
+	}
+
+	checkIns, err := s.store.CheckIns.List(repository.CheckInFilter{
+		SecurityCode: request.SecurityCode,
+		LocationID:   request.LocationID,
+		Since:        time.Now().Add(-24 * time.Hour),
+		Limit:        1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(checkIns) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &checkIns[0], nil
+}
+
 func (s *NotificationService) CreateNotification(notification *models.Notification) error {
-	// Notification creation will be implemented later
+	// A check-in can only have one pickup request, so replace any earlier one
+	if err := s.store.Notifications.DeleteByCheckInID(notification.PCOCheckInID); err != nil {
+		return fmt.Errorf("failed to replace existing notification: %w", err)
+	}
+
+	if err := s.store.Notifications.Create(notification); err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
 	return nil
 }
 
 func (s *NotificationService) GetNotifications() ([]models.Notification, error) {
-	// Get notifications will be implemented later
-	return nil, nil
+	notifications, err := s.store.Notifications.List(repository.NotificationFilter{Status: "active", ActiveAt: time.Now()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notifications: %w", err)
+	}
+	return notifications, nil
+}
+
+// GetNotificationByCheckInID retrieves a notification by its check-in ID
+func (s *NotificationService) GetNotificationByCheckInID(pcoCheckInID string) (*models.Notification, error) {
+	notification, err := s.store.Notifications.GetByCheckInID(pcoCheckInID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, fmt.Errorf("notification not found")
+		}
+		return nil, fmt.Errorf("failed to get notification: %w", err)
+	}
+	return notification, nil
+}
+
+// CancelNotification marks a pickup request as cancelled
+func (s *NotificationService) CancelNotification(notification *models.Notification) error {
+	notification.Status = "cancelled"
+	if err := s.store.Notifications.UpdateStatus(notification.ID, "cancelled"); err != nil {
+		return fmt.Errorf("failed to cancel notification: %w", err)
+	}
+	return nil
 }
 
 func (s *NotificationService) DeleteNotification(id uint) error {
-	// Delete notification will be implemented later
+	if err := s.store.Notifications.Delete(id); err != nil {
+		return fmt.Errorf("failed to delete notification: %w", err)
+	}
 	return nil
 }
 
 func (s *NotificationService) CleanupExpiredNotifications() error {
-	// Cleanup expired notifications will be implemented later
+	if _, err := s.store.Notifications.ExpireActive(time.Now()); err != nil {
+		return fmt.Errorf("failed to expire notifications: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go_pco_arrivals/internal/config"
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/utils"
+)
+
+const (
+	// occupancyInterval is how often rooms are checked against their limits
+	occupancyInterval = time.Minute
+	// occupancyWindow bounds the check-ins counted as in a room, so people
+	// PCO never checked out don't count forever
+	occupancyWindow = 12 * time.Hour
+)
+
+// Room statuses, from least to most urgent
+const (
+	RoomStatusOK           = "ok"
+	RoomStatusNearCapacity = "near_capacity"
+	RoomStatusFull         = "full"
+)
+
+// Messages sent to admins when a room's status worsens
+const (
+	RoomEventNearCapacity = "room_near_capacity"
+	RoomEventFull         = "room_full"
+)
+
+// RoomOccupancy is who is in a location, or in every location in a folder,
+// against its limits. Occupancy counts children and volunteers, Capacity
+// and Ratio are zero when unset, and ChildLimit is the children the
+// volunteers present may look after under the ratio. Reasons names the
+// limits that are near or reached: "capacity" and "ratio".
+type RoomOccupancy struct {
+	LocationID   string   `json:"location_id"`
+	LocationName string   `json:"location_name"`
+	Children     int64    `json:"children"`
+	Volunteers   int64    `json:"volunteers"`
+	Occupancy    int64    `json:"occupancy"`
+	Capacity     int      `json:"capacity"`
+	Ratio        int      `json:"volunteer_ratio"`
+	ChildLimit   int64    `json:"child_limit,omitempty"`
+	Status       string   `json:"status"`
+	Reasons      []string `json:"reasons,omitempty"`
+}
+
+// RosterEntry is a person on a room's roster. The parent's phone number and
+// email address, MedicalAlert and the medical details are only filled in for
+// rosters that include them.
+type RosterEntry struct {
+	PCOCheckInID  string    `json:"pco_check_in_id"`
+	PersonID      string    `json:"person_id"`
+	PersonName    string    `json:"person_name"`
+	LocationID    string    `json:"location_id"`
+	LocationName  string    `json:"location_name"`
+	SecurityCode  string    `json:"security_code"`
+	Number        int       `json:"number"`
+	ParentName    string    `json:"parent_name,omitempty"`
+	ParentPhone   string    `json:"parent_phone,omitempty"`
+	ParentEmail   string    `json:"parent_email,omitempty"`
+	Notes         string    `json:"notes"`
+	FirstTime     bool      `json:"first_time"`
+	OneTimeGuest  bool      `json:"one_time_guest"`
+	CheckedInAt   time.Time `json:"checked_in_at"`
+	MinutesInRoom int       `json:"minutes_in_room"`
+
+	MedicalAlert          bool   `json:"medical_alert,omitempty"`
+	MedicalNotes          string `json:"medical_notes,omitempty"`
+	EmergencyContactName  string `json:"emergency_contact_name,omitempty"`
+	EmergencyContactPhone string `json:"emergency_contact_phone,omitempty"`
+}
+
+// Roster is who is in a location, or in every location in a folder, longest
+// there first. CheckedOut counts those who have already left, and
+// MedicalAlerts those present with medical notes when the roster includes
+// them.
+type Roster struct {
+	LocationID    string        `json:"location_id"`
+	LocationName  string        `json:"location_name"`
+	Children      []RosterEntry `json:"children"`
+	Volunteers    []RosterEntry `json:"volunteers"`
+	CheckedOut    int64         `json:"checked_out"`
+	MedicalAlerts int           `json:"medical_alerts,omitempty"`
+	GeneratedAt   time.Time     `json:"generated_at"`
+}
+
+// OccupancyService tracks live room occupancy from check-ins and check-outs
+// and alerts admins when a room nears or reaches its capacity or volunteer
+// ratio
+type OccupancyService struct {
+	store       *repository.Store
+	hub         *WebSocketHub
+	nearPercent int64
+	logger      *utils.Logger
+
+	// mu guards statuses, the last status of each room
+	mu       sync.Mutex
+	statuses map[string]string
+	stop     chan struct{}
+}
+
+func NewOccupancyService(cfg *config.Config, store *repository.Store, hub *WebSocketHub, logger *utils.Logger) *OccupancyService {
+	return &OccupancyService{
+		store:       store,
+		hub:         hub,
+		nearPercent: int64(cfg.Realtime.NearCapacityPercent),
+		logger:      logger.WithComponent("occupancy_service"),
+		statuses:    make(map[string]string),
+		stop:        make(chan struct{}),
+	}
+}
+
+// Start checks the rooms in the background until Stop is called
+func (s *OccupancyService) Start() {
+	go func() {
+		ticker := time.NewTicker(occupancyInterval)
+		defer ticker.Stop()
+		for {
+			if _, err := s.Check(time.Now()); err != nil {
+				s.logger.Error("Occupancy check failed", "error", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	s.logger.Info("Occupancy monitor started")
+}
+
+func (s *OccupancyService) Stop() {
+	close(s.stop)
+}
+
+// Rooms returns the occupancy of every active location and folder
+func (s *OccupancyService) Rooms(now time.Time) ([]RoomOccupancy, error) {
+	locations, err := s.store.Locations.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", err)
+	}
+
+	var rooms []RoomOccupancy
+	for _, location := range locations {
+		if !location.IsActive {
+			continue
+		}
+		room, err := s.occupancy(locations, location, now)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, *room)
+	}
+	return rooms, nil
+}
+
+// Room returns the occupancy of one location or folder
+func (s *OccupancyService) Room(pcoLocationID string, now time.Time) (*RoomOccupancy, error) {
+	location, err := s.store.Locations.GetByPCOID(pcoLocationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location: %w", err)
+	}
+	locations, err := s.store.Locations.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", err)
+	}
+	return s.occupancy(locations, *location, now)
+}
+
+// Roster lists the children and volunteers checked in at a location, or at
+// every location in a folder, and not yet checked out. With medical, it
+// includes their medical notes and emergency contacts and flags those with
+// allergies or other notes. With contacts, it includes their parents' phone
+// numbers and email addresses.
+func (s *OccupancyService) Roster(pcoLocationID string, now time.Time, medical, contacts bool) (*Roster, error) {
+	location, err := s.store.Locations.GetByPCOID(pcoLocationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get location: %w", err)
+	}
+	ids, err := coveredLocationIDs(s.store, pcoLocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	roster := &Roster{
+		LocationID:   location.PCOLocationID,
+		LocationName: location.Label(),
+		Children:     []RosterEntry{},
+		Volunteers:   []RosterEntry{},
+		GeneratedAt:  now,
+	}
+
+	filter := repository.CheckInFilter{LocationIDs: ids, Since: now.Add(-occupancyWindow)}
+	checkIns, err := s.store.CheckIns.List(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list check-ins: %w", err)
+	}
+	// Listed most recent first; the roster starts with who has been in
+	// longest
+	for i := len(checkIns) - 1; i >= 0; i-- {
+		checkIn := checkIns[i]
+		if checkIn.CheckedOutAt != nil {
+			roster.CheckedOut++
+			continue
+		}
+		entry := RosterEntry{
+			PCOCheckInID:  checkIn.PCOCheckInID,
+			PersonID:      checkIn.PersonID,
+			PersonName:    checkIn.PersonName,
+			LocationID:    checkIn.LocationID,
+			LocationName:  checkIn.LocationName,
+			SecurityCode:  checkIn.SecurityCode,
+			Number:        checkIn.Number,
+			ParentName:    checkIn.ParentName,
+			Notes:         checkIn.Notes,
+			FirstTime:     checkIn.FirstTime,
+			OneTimeGuest:  checkIn.OneTimeGuest,
+			CheckedInAt:   checkIn.CheckInTime,
+			MinutesInRoom: int(now.Sub(checkIn.CheckInTime).Minutes()),
+		}
+		if contacts {
+			entry.ParentPhone = checkIn.ParentPhone
+			entry.ParentEmail = checkIn.ParentEmail
+		}
+		if medical {
+			entry.MedicalAlert = checkIn.HasMedicalNotes()
+			entry.MedicalNotes = checkIn.MedicalNotes
+			entry.EmergencyContactName = checkIn.EmergencyContactName
+			entry.EmergencyContactPhone = checkIn.EmergencyContactPhone
+			if entry.MedicalAlert {
+				roster.MedicalAlerts++
+			}
+		}
+		if checkIn.Kind == models.CheckInKindVolunteer {
+			roster.Volunteers = append(roster.Volunteers, entry)
+		} else {
+			roster.Children = append(roster.Children, entry)
+		}
+	}
+	return roster, nil
+}
+
+// Check works out every room's occupancy and sends admins room_near_capacity
+// or room_full when a room's status changes to one of them
+func (s *OccupancyService) Check(now time.Time) ([]RoomOccupancy, error) {
+	rooms, err := s.Rooms(now)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, room := range rooms {
+		previous := s.statuses[room.LocationID]
+		s.statuses[room.LocationID] = room.Status
+		if room.Status == previous || room.Status == RoomStatusOK {
+			continue
+		}
+
+		event := RoomEventNearCapacity
+		if room.Status == RoomStatusFull {
+			event = RoomEventFull
+		}
+		s.logger.Warn("Room limit alert", "event", event, "location_id", room.LocationID, "occupancy", room.Occupancy, "capacity", room.Capacity, "children", room.Children, "volunteers", room.Volunteers)
+		if s.hub != nil {
+			s.hub.BroadcastToAdmins(event, room)
+		}
+	}
+	return rooms, nil
+}
+
+// occupancy counts the people checked in and not yet checked out at a
+// location, or at every location in a folder, and compares them with its
+// limits
+func (s *OccupancyService) occupancy(locations []models.Location, location models.Location, now time.Time) (*RoomOccupancy, error) {
+	room := &RoomOccupancy{
+		LocationID:   location.PCOLocationID,
+		LocationName: location.Label(),
+		Capacity:     location.CapacityLimit(),
+		Ratio:        location.Ratio,
+		Status:       RoomStatusOK,
+	}
+
+	var ids []string
+	for _, l := range coveredLocations(locations, location.PCOLocationID) {
+		ids = append(ids, l.PCOLocationID)
+	}
+	// An empty folder has no one in it, and an empty filter would match
+	// every location
+	if len(ids) > 0 {
+		filter := repository.CheckInFilter{LocationIDs: ids, Since: now.Add(-occupancyWindow), Present: true}
+		present, err := s.store.CheckIns.Count(filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count check-ins at %s: %w", location.PCOLocationID, err)
+		}
+		filter.Kind = models.CheckInKindVolunteer
+		volunteers, err := s.store.CheckIns.Count(filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count volunteers at %s: %w", location.PCOLocationID, err)
+		}
+		room.Occupancy = present
+		room.Volunteers = volunteers
+		room.Children = present - volunteers
+	}
+
+	if room.Capacity > 0 {
+		s.flag(room, "capacity", room.Occupancy, int64(room.Capacity))
+	}
+	// Rooms without children are never over their ratio, even with no
+	// volunteers yet
+	if room.Ratio > 0 && room.Children > 0 {
+		room.ChildLimit = room.Volunteers * int64(room.Ratio)
+		s.flag(room, "ratio", room.Children, room.ChildLimit)
+	}
+	return room, nil
+}
+
+// flag raises the room's status when count is near or at limit
+func (s *OccupancyService) flag(room *RoomOccupancy, reason string, count, limit int64) {
+	status := RoomStatusOK
+	switch {
+	case count >= limit:
+		status = RoomStatusFull
+	case count*100 >= limit*s.nearPercent:
+		status = RoomStatusNearCapacity
+	}
+	if status == RoomStatusOK {
+		return
+	}
+	room.Reasons = append(room.Reasons, reason)
+	if status == RoomStatusFull || room.Status == RoomStatusOK {
+		room.Status = status
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,15 +12,15 @@ import (
 
 	"go_pco_arrivals/internal/config"
 	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
 	"go_pco_arrivals/internal/utils"
-
-	"gorm.io/gorm"
 )
 
 type PCOService struct {
-	config *config.Config
-	db     *gorm.DB
-	logger *utils.Logger
+	config  *config.Config
+	store   *repository.Store
+	logger  *utils.Logger
+	rollups *RollupService
 }
 
 type PCOUser struct {
@@ -38,11 +39,52 @@ type PCOCheckIn struct {
 	LocationName string    `json:"location_name"`
 	CheckedInAt  time.Time `json:"checked_in_at"`
 	Notes        string    `json:"notes"`
+	// Kind is Regular, Guest or Volunteer
+	Kind         string     `json:"kind"`
+	CheckedOutAt *time.Time `json:"checked_out_at"`
+	FirstTime    bool       `json:"first_time"`
+	OneTimeGuest bool       `json:"one_time_guest"`
+	Number       int        `json:"number"`
+	// MedicalNotes and the emergency contact are sensitive: handlers Redact
+	// them for callers without medical access
+	MedicalNotes          string `json:"medical_notes,omitempty"`
+	EmergencyContactName  string `json:"emergency_contact_name,omitempty"`
+	EmergencyContactPhone string `json:"emergency_contact_phone,omitempty"`
+	// The parent is filled in from PCO households by GuardianService.Fill.
+	// Their phone number and email address are sensitive too: handlers
+	// RedactContact them for callers without contact access.
+	ParentName  string `json:"parent_name,omitempty"`
+	ParentPhone string `json:"parent_phone,omitempty"`
+	ParentEmail string `json:"parent_email,omitempty"`
+}
+
+// Redact removes the check-in's medical notes and emergency contact
+func (c *PCOCheckIn) Redact() {
+	c.MedicalNotes = ""
+	c.EmergencyContactName = ""
+	c.EmergencyContactPhone = ""
+}
+
+// RedactContact removes the parent's phone number and email address
+func (c *PCOCheckIn) RedactContact() {
+	c.ParentPhone = ""
+	c.ParentEmail = ""
 }
 
+// PCOLocation is a location or folder from the PCO Check-Ins API. ParentID
+// and EventID come from its relationships.
 type PCOLocation struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Kind           string `json:"kind"`
+	ParentID       string `json:"parent_id"`
+	EventID        string `json:"event_id"`
+	Position       int    `json:"position"`
+	AgeMinInMonths *int   `json:"age_min_in_months"`
+	AgeMaxInMonths *int   `json:"age_max_in_months"`
+	GradeMin       *int   `json:"grade_min"`
+	GradeMax       *int   `json:"grade_max"`
+	MaxOccupancy   *int   `json:"max_occupancy"`
 }
 
 type PCOAuthResponse struct {
@@ -53,11 +95,12 @@ type PCOAuthResponse struct {
 	Scope        string `json:"scope"`
 }
 
-func NewPCOService(config *config.Config, db *gorm.DB, logger *utils.Logger) *PCOService {
+func NewPCOService(config *config.Config, store *repository.Store, logger *utils.Logger, rollups *RollupService) *PCOService {
 	return &PCOService{
-		config: config,
-		db:     db,
-		logger: logger,
+		config:  config,
+		store:   store,
+		logger:  logger,
+		rollups: rollups,
 	}
 }
 
@@ -216,10 +259,18 @@ func (s *PCOService) GetCheckIns(accessToken string, locationID string, since ti
 
 	var response struct {
 		Data []struct {
-			ID          string    `json:"id"`
-			CheckedInAt time.Time `json:"checked_in_at"`
-			Notes       string    `json:"notes"`
-			Person      struct {
+			ID                    string     `json:"id"`
+			CheckedInAt           time.Time  `json:"checked_in_at"`
+			CheckedOutAt          *time.Time `json:"checked_out_at"`
+			Kind                  string     `json:"kind"`
+			Notes                 string     `json:"notes"`
+			FirstTime             bool       `json:"first_time"`
+			OneTimeGuest          bool       `json:"one_time_guest"`
+			Number                int        `json:"number"`
+			MedicalNotes          string     `json:"medical_notes"`
+			EmergencyContactName  string     `json:"emergency_contact_name"`
+			EmergencyContactPhone string     `json:"emergency_contact_phone_number"`
+			Person                struct {
 				ID        string `json:"id"`
 				FirstName string `json:"first_name"`
 				LastName  string `json:"last_name"`
@@ -238,49 +289,37 @@ func (s *PCOService) GetCheckIns(accessToken string, locationID string, since ti
 	checkIns := make([]PCOCheckIn, len(response.Data))
 	for i, item := range response.Data {
 		checkIns[i] = PCOCheckIn{
-			ID:           item.ID,
-			PersonID:     item.Person.ID,
-			PersonName:   item.Person.FirstName + " " + item.Person.LastName,
-			LocationID:   item.Location.ID,
-			LocationName: item.Location.Name,
-			CheckedInAt:  item.CheckedInAt,
-			Notes:        item.Notes,
+			ID:                    item.ID,
+			PersonID:              item.Person.ID,
+			PersonName:            item.Person.FirstName + " " + item.Person.LastName,
+			LocationID:            item.Location.ID,
+			LocationName:          item.Location.Name,
+			CheckedInAt:           item.CheckedInAt,
+			Notes:                 item.Notes,
+			Kind:                  item.Kind,
+			CheckedOutAt:          item.CheckedOutAt,
+			FirstTime:             item.FirstTime,
+			OneTimeGuest:          item.OneTimeGuest,
+			Number:                item.Number,
+			MedicalNotes:          item.MedicalNotes,
+			EmergencyContactName:  item.EmergencyContactName,
+			EmergencyContactPhone: item.EmergencyContactPhone,
 		}
 	}
 
 	return checkIns, nil
 }
 
-// GetLocations fetches available locations from PCO API
+// GetLocations fetches every location and folder from PCO Check-Ins
 func (s *PCOService) GetLocations(accessToken string) ([]PCOLocation, error) {
-	req, err := http.NewRequest("GET", s.config.PCO.BaseURL+"/check_ins/v2/locations", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create locations request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("X-PCO-API-Version", "2023-01-01")
+	params := url.Values{}
+	params.Set("per_page", "100")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	pages, err := s.getPCOPages(accessToken, "/check_ins/v2/locations", params, 0, "locations")
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch locations: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to fetch locations with status %d: %s", resp.StatusCode, string(body))
+		return nil, err
 	}
-
-	var response struct {
-		Data []PCOLocation `json:"data"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, fmt.Errorf("failed to decode locations response: %w", err)
-	}
-
-	return response.Data, nil
+	return decodePCOLocations(pages)
 }
 
 // ValidateUser checks if a user is authorized based on PCO user ID
@@ -295,13 +334,12 @@ func (s *PCOService) ValidateUser(pcoUserID string) bool {
 
 // CreateOrUpdateUser creates or updates a user in the database
 func (s *PCOService) CreateOrUpdateUser(pcoUser *PCOUser, accessToken string) (*models.User, error) {
-	var user models.User
-	result := s.db.Where("pco_user_id = ?", pcoUser.ID).First(&user)
+	user, err := s.store.Users.GetByPCOUserID(pcoUser.ID)
 
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			// Create new user
-			user = models.User{
+			user = &models.User{
 				PCOUserID: pcoUser.ID,
 				Name:      pcoUser.FirstName + " " + pcoUser.LastName,
 				Email:     pcoUser.Email,
@@ -311,11 +349,11 @@ func (s *PCOService) CreateOrUpdateUser(pcoUser *PCOUser, accessToken string) (*
 				UpdatedAt: time.Now(),
 			}
 
-			if err := s.db.Create(&user).Error; err != nil {
+			if err := s.store.Users.Create(user); err != nil {
 				return nil, fmt.Errorf("failed to create user: %w", err)
 			}
 		} else {
-			return nil, fmt.Errorf("failed to query user: %w", result.Error)
+			return nil, fmt.Errorf("failed to query user: %w", err)
 		}
 	} else {
 		// Update existing user
@@ -324,45 +362,67 @@ func (s *PCOService) CreateOrUpdateUser(pcoUser *PCOUser, accessToken string) (*
 		user.LastLogin = time.Now()
 		user.UpdatedAt = time.Now()
 
-		if err := s.db.Save(&user).Error; err != nil {
+		if err := s.store.Users.Save(user); err != nil {
 			return nil, fmt.Errorf("failed to update user: %w", err)
 		}
 	}
 
-	return &user, nil
-}
-
-// SyncCheckInsToDatabase syncs PCO check-ins to local database
-func (s *PCOService) SyncCheckInsToDatabase(checkIns []PCOCheckIn) error {
-	for _, checkIn := range checkIns {
-		var existingCheckIn models.CheckIn
-		result := s.db.Where("pco_check_in_id = ?", checkIn.ID).First(&existingCheckIn)
-
-		if result.Error != nil {
-			if result.Error == gorm.ErrRecordNotFound {
-				// Create new check-in
-				newCheckIn := models.CheckIn{
-					PCOCheckInID: checkIn.ID,
-					PersonID:     checkIn.PersonID,
-					PersonName:   checkIn.PersonName,
-					LocationID:   checkIn.LocationID,
-					LocationName: checkIn.LocationName,
-					CheckInTime:  checkIn.CheckedInAt,
-					Notes:        checkIn.Notes,
-					CreatedAt:    time.Now(),
-					UpdatedAt:    time.Now(),
-				}
+	return user, nil
+}
 
-				if err := s.db.Create(&newCheckIn).Error; err != nil {
-					s.logger.Error("Failed to create check-in", "error", err, "check_in_id", checkIn.ID)
-				}
-			} else {
-				s.logger.Error("Failed to query check-in", "error", result.Error, "check_in_id", checkIn.ID)
-			}
+// SyncCheckIns upserts PCO check-ins into the local database in one batch
+// and updates the attendance rollups for the rows that changed. Rows that
+// failed are counted and logged in the result rather than aborting the sync.
+func (s *PCOService) SyncCheckIns(checkIns []PCOCheckIn) (*repository.UpsertResult, error) {
+	rows := make([]models.CheckIn, len(checkIns))
+	for i, checkIn := range checkIns {
+		status := models.CheckInStatusActive
+		if checkIn.CheckedOutAt != nil {
+			status = models.CheckInStatusCheckedOut
+		}
+		rows[i] = models.CheckIn{
+			PCOCheckInID:          checkIn.ID,
+			PersonID:              checkIn.PersonID,
+			PersonName:            checkIn.PersonName,
+			LocationID:            checkIn.LocationID,
+			LocationName:          checkIn.LocationName,
+			CheckInTime:           checkIn.CheckedInAt,
+			CheckedOutAt:          checkIn.CheckedOutAt,
+			Notes:                 checkIn.Notes,
+			Status:                status,
+			Kind:                  checkIn.Kind,
+			FirstTime:             checkIn.FirstTime,
+			OneTimeGuest:          checkIn.OneTimeGuest,
+			Number:                checkIn.Number,
+			MedicalNotes:          checkIn.MedicalNotes,
+			EmergencyContactName:  checkIn.EmergencyContactName,
+			EmergencyContactPhone: checkIn.EmergencyContactPhone,
+			ParentName:            checkIn.ParentName,
+			ParentPhone:           checkIn.ParentPhone,
+			ParentEmail:           checkIn.ParentEmail,
 		}
 	}
 
-	return nil
+	// Group check-ins under the event they were made for, including local
+	// and recurring events PCO doesn't know about
+	if err := attributeEvents(s.store, rows); err != nil {
+		s.logger.Error("Failed to match check-ins to events", "error", err)
+	}
+
+	result, err := s.store.CheckIns.Upsert(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upsert check-ins: %w", err)
+	}
+	for _, err := range result.Errors {
+		s.logger.Error("Failed to sync check-in", "error", err)
+	}
+
+	// The check-ins are saved either way; `rollups rebuild` repairs the
+	// rollups if this fails
+	if err := s.rollups.Record(result.Changed); err != nil {
+		s.logger.Error("Failed to update attendance rollups", "error", err)
+	}
+	return result, nil
 }
 
 // RefreshAccessToken refreshes an expired access token
@@ -400,210 +460,330 @@ func (s *PCOService) RefreshAccessToken(refreshToken string) (*PCOAuthResponse,
 	return &authResponse, nil
 }
 
-// PCOEvent represents an event from the PCO Check-ins API
+// PCOEvent is an event from the PCO Check-Ins API
 type PCOEvent struct {
-	ID           string    `json:"id"`
-	Name         string    `json:"name"`
-	Date         time.Time `json:"date"`
-	StartTime    time.Time `json:"start_time"`
-	EndTime      time.Time `json:"end_time"`
-	LocationID   string    `json:"location_id"`
-	LocationName string    `json:"location_name"`
-	Description  string    `json:"description"`
-	IsActive     bool      `json:"is_active"`
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Frequency  string     `json:"frequency"`
+	ArchivedAt *time.Time `json:"archived_at"`
 }
 
-// GetEvents retrieves events from PCO Check-ins API for a specific date
-func (s *PCOService) GetEvents(accessToken string, date time.Time) ([]PCOEvent, error) {
-	// Format date for PCO API (YYYY-MM-DD)
-	dateStr := date.Format("2006-01-02")
+// PCOEventPeriod is one occurrence of a PCO event, with its check-in times
+type PCOEventPeriod struct {
+	ID             string         `json:"id"`
+	StartsAt       time.Time      `json:"starts_at"`
+	EndsAt         time.Time      `json:"ends_at"`
+	RegularCount   int            `json:"regular_count"`
+	GuestCount     int            `json:"guest_count"`
+	VolunteerCount int            `json:"volunteer_count"`
+	Note           string         `json:"note"`
+	Times          []PCOEventTime `json:"times"`
+}
 
-	// Try different PCO API endpoints for events
-	// First, try the check-ins API events endpoint (using underscore like GetLocations)
-	url := fmt.Sprintf("%s/check_ins/v2/events?where[date]=%s", s.config.PCO.BaseURL, dateStr)
+// PCOEventTime is a check-in time within a PCO event period
+type PCOEventTime struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	StartsAt time.Time `json:"starts_at"`
+	ShowsAt  time.Time `json:"shows_at"`
+	HidesAt  time.Time `json:"hides_at"`
+}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create events request: %w", err)
-	}
+// pcoResource is a JSON:API resource object as returned by PCO
+type pcoResource struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Attributes    json.RawMessage `json:"attributes"`
+	Relationships map[string]struct {
+		Data json.RawMessage `json:"data"`
+	} `json:"relationships"`
+}
 
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	req.Header.Set("X-PCO-API-Version", "2023-01-01")
+// pcoPage is one page of a JSON:API list response
+type pcoPage struct {
+	Data     []pcoResource `json:"data"`
+	Included []pcoResource `json:"included"`
+	Links    struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
 
+// getPCOPages fetches a PCO list endpoint, following next links until
+// maxPages pages have been read. A maxPages of 0 reads every page.
+func (s *PCOService) getPCOPages(accessToken, path string, params url.Values, maxPages int, what string) ([]pcoPage, error) {
+	next := fmt.Sprintf("%s%s?%s", s.config.PCO.BaseURL, path, params.Encode())
 	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch events: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Log the response for debugging
-	body, _ := io.ReadAll(resp.Body)
-	s.logger.Info("PCO Events API response",
-		"status", resp.Status,
-		"url", url,
-		"body_length", len(body),
-		"body_preview", string(body[:min(200, len(body))]))
-
-	// If the first endpoint fails, try alternative endpoints
-	if resp.StatusCode != http.StatusOK {
-		s.logger.Warn("First events endpoint failed, trying alternative", "status", resp.Status)
 
-		// Try alternative endpoint: /check_ins/v2/events (without date filter)
-		altUrl := fmt.Sprintf("%s/check_ins/v2/events", s.config.PCO.BaseURL)
-		req, err = http.NewRequest("GET", altUrl, nil)
+	var pages []pcoPage
+	for next != "" && (maxPages == 0 || len(pages) < maxPages) {
+		req, err := http.NewRequest("GET", next, nil)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create alternative events request: %w", err)
+			return nil, fmt.Errorf("failed to create %s request: %w", what, err)
 		}
-
 		req.Header.Set("Authorization", "Bearer "+accessToken)
 		req.Header.Set("X-PCO-API-Version", "2023-01-01")
 
-		resp, err = client.Do(req)
+		resp, err := client.Do(req)
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch events from alternative endpoint: %w", err)
+			return nil, fmt.Errorf("failed to fetch %s: %w", what, err)
 		}
-		defer resp.Body.Close()
-
-		body, _ = io.ReadAll(resp.Body)
-		s.logger.Info("PCO Alternative Events API response",
-			"status", resp.Status,
-			"url", altUrl,
-			"body_length", len(body),
-			"body_preview", string(body[:min(200, len(body))]))
-
 		if resp.StatusCode != http.StatusOK {
-			// If both fail, return empty events list for now
-			s.logger.Warn("Both events endpoints failed, returning empty list", "status", resp.Status)
-			return []PCOEvent{}, nil
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to fetch %s with status %d: %s", what, resp.StatusCode, string(body))
 		}
-	}
 
-	// Parse PCO API response
-	var response struct {
-		Data []struct {
-			ID         string `json:"id"`
-			Type       string `json:"type"`
-			Attributes struct {
-				Name        string `json:"name"`
-				Frequency   string `json:"frequency"`
-				Description string `json:"description"`
-			} `json:"attributes"`
-			Relationships struct {
-				Location struct {
-					Data struct {
-						ID   string `json:"id"`
-						Type string `json:"type"`
-					} `json:"data"`
-				} `json:"location"`
-			} `json:"relationships"`
-		} `json:"data"`
-		Included []struct {
-			ID         string `json:"id"`
-			Type       string `json:"type"`
-			Attributes struct {
-				Name string `json:"name"`
-			} `json:"attributes"`
-		} `json:"included"`
+		var page pcoPage
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s response: %w", what, err)
+		}
+		pages = append(pages, page)
+		next = page.Links.Next
 	}
+	return pages, nil
+}
 
-	if err := json.Unmarshal(body, &response); err != nil {
-		s.logger.Warn("Failed to parse events response, returning empty list", "error", err)
-		return []PCOEvent{}, nil
+// GetEvents fetches every unarchived event from PCO Check-Ins
+func (s *PCOService) GetEvents(accessToken string) ([]PCOEvent, error) {
+	params := url.Values{}
+	params.Set("filter", "not_archived")
+	params.Set("per_page", "100")
+
+	pages, err := s.getPCOPages(accessToken, "/check_ins/v2/events", params, 0, "events")
+	if err != nil {
+		return nil, err
 	}
 
-	// Create a map of location IDs to names from included data
-	locationMap := make(map[string]string)
-	for _, included := range response.Included {
-		if included.Type == "Location" {
-			locationMap[included.ID] = included.Attributes.Name
+	events := []PCOEvent{}
+	for _, page := range pages {
+		for _, resource := range page.Data {
+			var event PCOEvent
+			if err := json.Unmarshal(resource.Attributes, &event); err != nil {
+				return nil, fmt.Errorf("failed to decode event %s: %w", resource.ID, err)
+			}
+			event.ID = resource.ID
+			events = append(events, event)
 		}
 	}
+	return events, nil
+}
 
-	var events []PCOEvent
-	for _, eventData := range response.Data {
-		if eventData.Type != "Event" {
-			continue
-		}
+// GetEventPeriods fetches the most recent periods of an event, newest first,
+// with their event times
+func (s *PCOService) GetEventPeriods(accessToken, eventID string, limit int) ([]PCOEventPeriod, error) {
+	params := url.Values{}
+	params.Set("include", "event_times")
+	params.Set("order", "-starts_at")
+	params.Set("per_page", fmt.Sprint(limit))
+
+	path := fmt.Sprintf("/check_ins/v2/events/%s/event_periods", url.PathEscape(eventID))
+	pages, err := s.getPCOPages(accessToken, path, params, 1, "event periods")
+	if err != nil {
+		return nil, err
+	}
 
-		// For recurring events, we need to determine if this event occurs on the requested date
-		// For now, we'll include all events and let the frontend handle filtering
-		// In a production system, you'd want to implement proper recurring event logic
-
-		// Generate appropriate times based on event name and frequency
-		var startTime, endTime time.Time
-		eventName := strings.ToLower(eventData.Attributes.Name)
-
-		// Set default times based on event type
-		switch {
-		case strings.Contains(eventName, "sunday"):
-			startTime = time.Date(date.Year(), date.Month(), date.Day(), 9, 0, 0, 0, date.Location())
-			endTime = time.Date(date.Year(), date.Month(), date.Day(), 10, 30, 0, 0, date.Location())
-		case strings.Contains(eventName, "wednesday"):
-			startTime = time.Date(date.Year(), date.Month(), date.Day(), 18, 30, 0, 0, date.Location())
-			endTime = time.Date(date.Year(), date.Month(), date.Day(), 20, 0, 0, 0, date.Location())
-		case strings.Contains(eventName, "youth"):
-			startTime = time.Date(date.Year(), date.Month(), date.Day(), 18, 0, 0, 0, date.Location())
-			endTime = time.Date(date.Year(), date.Month(), date.Day(), 19, 30, 0, 0, date.Location())
-		case strings.Contains(eventName, "student"):
-			startTime = time.Date(date.Year(), date.Month(), date.Day(), 18, 0, 0, 0, date.Location())
-			endTime = time.Date(date.Year(), date.Month(), date.Day(), 19, 30, 0, 0, date.Location())
-		default:
-			// Default time for other events
-			startTime = time.Date(date.Year(), date.Month(), date.Day(), 19, 0, 0, 0, date.Location())
-			endTime = time.Date(date.Year(), date.Month(), date.Day(), 20, 30, 0, 0, date.Location())
+	periods := []PCOEventPeriod{}
+	for _, page := range pages {
+		times := make(map[string]PCOEventTime)
+		for _, resource := range page.Included {
+			if resource.Type != "EventTime" {
+				continue
+			}
+			var eventTime PCOEventTime
+			if err := json.Unmarshal(resource.Attributes, &eventTime); err != nil {
+				return nil, fmt.Errorf("failed to decode event time %s: %w", resource.ID, err)
+			}
+			eventTime.ID = resource.ID
+			times[resource.ID] = eventTime
 		}
 
-		locationID := eventData.Relationships.Location.Data.ID
-		locationName := locationMap[locationID]
-		if locationName == "" {
-			locationName = "Main Location" // Default location name
+		for _, resource := range page.Data {
+			var period PCOEventPeriod
+			if err := json.Unmarshal(resource.Attributes, &period); err != nil {
+				return nil, fmt.Errorf("failed to decode event period %s: %w", resource.ID, err)
+			}
+			period.ID = resource.ID
+
+			var linked []struct {
+				ID string `json:"id"`
+			}
+			if rel, ok := resource.Relationships["event_times"]; ok && len(rel.Data) > 0 {
+				if err := json.Unmarshal(rel.Data, &linked); err != nil {
+					return nil, fmt.Errorf("failed to decode event times of period %s: %w", resource.ID, err)
+				}
+			}
+			for _, link := range linked {
+				if eventTime, ok := times[link.ID]; ok {
+					period.Times = append(period.Times, eventTime)
+				}
+			}
+			periods = append(periods, period)
 		}
+	}
+	return periods, nil
+}
+
+// GetEventLocations fetches the locations children can check in to for an
+// event
+func (s *PCOService) GetEventLocations(accessToken, eventID string) ([]PCOLocation, error) {
+	params := url.Values{}
+	params.Set("per_page", "100")
+
+	path := fmt.Sprintf("/check_ins/v2/events/%s/locations", url.PathEscape(eventID))
+	pages, err := s.getPCOPages(accessToken, path, params, 0, "event locations")
+	if err != nil {
+		return nil, err
+	}
+
+	return decodePCOLocations(pages)
+}
 
-		event := PCOEvent{
-			ID:           eventData.ID,
-			Name:         eventData.Attributes.Name,
-			Date:         date,
-			StartTime:    startTime,
-			EndTime:      endTime,
-			LocationID:   locationID,
-			LocationName: locationName,
-			Description:  eventData.Attributes.Description,
-			IsActive:     true, // Assume active if returned by API
+// decodePCOLocations reads the locations from pages of a locations listing
+func decodePCOLocations(pages []pcoPage) ([]PCOLocation, error) {
+	locations := []PCOLocation{}
+	for _, page := range pages {
+		for _, resource := range page.Data {
+			var location PCOLocation
+			if err := json.Unmarshal(resource.Attributes, &location); err != nil {
+				return nil, fmt.Errorf("failed to decode location %s: %w", resource.ID, err)
+			}
+			location.ID = resource.ID
+			// PCO capitalises kinds, as "Folder" and "Location"
+			if strings.EqualFold(location.Kind, models.LocationKindFolder) {
+				location.Kind = models.LocationKindFolder
+			} else {
+				location.Kind = models.LocationKindLocation
+			}
+
+			for name, target := range map[string]*string{"parent": &location.ParentID, "event": &location.EventID} {
+				var linked struct {
+					ID string `json:"id"`
+				}
+				if rel, ok := resource.Relationships[name]; ok && len(rel.Data) > 0 {
+					if err := json.Unmarshal(rel.Data, &linked); err != nil {
+						return nil, fmt.Errorf("failed to decode %s of location %s: %w", name, resource.ID, err)
+					}
+				}
+				*target = linked.ID
+			}
+			locations = append(locations, location)
 		}
+	}
+	return locations, nil
+}
+
+// PCOHousehold is a household from the PCO People API
+type PCOHousehold struct {
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	PrimaryContactID   string `json:"primary_contact_id"`
+	PrimaryContactName string `json:"primary_contact_name"`
+}
+
+// PCOContact is a person from the PCO People API with their primary phone
+// number and email address
+type PCOContact struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Phone string `json:"phone"`
+	Email string `json:"email"`
+}
+
+// GetHouseholds fetches the households a person belongs to
+func (s *PCOService) GetHouseholds(accessToken, personID string) ([]PCOHousehold, error) {
+	params := url.Values{}
+	params.Set("per_page", "25")
 
-		events = append(events, event)
-	}
-
-	// If no events found, return some mock events for testing
-	if len(events) == 0 {
-		s.logger.Info("No events found in PCO API, returning mock events for testing")
-		mockEvents := []PCOEvent{
-			{
-				ID:           "mock-event-1",
-				Name:         "Sunday Service",
-				Date:         date,
-				StartTime:    time.Date(date.Year(), date.Month(), date.Day(), 9, 0, 0, 0, date.Location()),
-				EndTime:      time.Date(date.Year(), date.Month(), date.Day(), 10, 30, 0, 0, date.Location()),
-				LocationID:   "mock-location-1",
-				LocationName: "Main Auditorium",
-				Description:  "Sunday morning worship service",
-				IsActive:     true,
-			},
-			{
-				ID:           "mock-event-2",
-				Name:         "Youth Group",
-				Date:         date,
-				StartTime:    time.Date(date.Year(), date.Month(), date.Day(), 18, 0, 0, 0, date.Location()),
-				EndTime:      time.Date(date.Year(), date.Month(), date.Day(), 19, 30, 0, 0, date.Location()),
-				LocationID:   "mock-location-2",
-				LocationName: "Youth Room",
-				Description:  "Youth group meeting",
-				IsActive:     true,
-			},
+	path := fmt.Sprintf("/people/v2/people/%s/households", url.PathEscape(personID))
+	pages, err := s.getPCOPages(accessToken, path, params, 1, "households")
+	if err != nil {
+		return nil, err
+	}
+
+	households := []PCOHousehold{}
+	for _, page := range pages {
+		for _, resource := range page.Data {
+			var household PCOHousehold
+			if err := json.Unmarshal(resource.Attributes, &household); err != nil {
+				return nil, fmt.Errorf("failed to decode household %s: %w", resource.ID, err)
+			}
+			household.ID = resource.ID
+			households = append(households, household)
 		}
-		return mockEvents, nil
 	}
+	return households, nil
+}
 
-	return events, nil
+// GetContact fetches a person with their phone numbers and email addresses,
+// keeping the primary of each: the one marked primary, else a mobile number,
+// else the first. Blocked email addresses are skipped. It returns nil when
+// PCO has no such person.
+func (s *PCOService) GetContact(accessToken, personID string) (*PCOContact, error) {
+	params := url.Values{}
+	params.Set("where[id]", personID)
+	params.Set("include", "phone_numbers,emails")
+	params.Set("per_page", "1")
+
+	pages, err := s.getPCOPages(accessToken, "/people/v2/people", params, 1, "contact")
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 || len(pages[0].Data) == 0 {
+		return nil, nil
+	}
+
+	page := pages[0]
+	var person struct {
+		Name      string `json:"name"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+	}
+	if err := json.Unmarshal(page.Data[0].Attributes, &person); err != nil {
+		return nil, fmt.Errorf("failed to decode person %s: %w", personID, err)
+	}
+	contact := &PCOContact{ID: page.Data[0].ID, Name: person.Name}
+	if contact.Name == "" {
+		contact.Name = strings.TrimSpace(person.FirstName + " " + person.LastName)
+	}
+
+	var phonePrimary, emailPrimary bool
+	for _, resource := range page.Included {
+		switch resource.Type {
+		case "PhoneNumber":
+			var phone struct {
+				Number   string `json:"number"`
+				Location string `json:"location"`
+				Primary  bool   `json:"primary"`
+			}
+			if err := json.Unmarshal(resource.Attributes, &phone); err != nil {
+				return nil, fmt.Errorf("failed to decode phone number %s: %w", resource.ID, err)
+			}
+			if phone.Number == "" || phonePrimary {
+				continue
+			}
+			if phone.Primary || contact.Phone == "" || strings.EqualFold(phone.Location, "Mobile") {
+				contact.Phone = phone.Number
+				phonePrimary = phone.Primary
+			}
+		case "Email":
+			var email struct {
+				Address string `json:"address"`
+				Primary bool   `json:"primary"`
+				Blocked bool   `json:"blocked"`
+			}
+			if err := json.Unmarshal(resource.Attributes, &email); err != nil {
+				return nil, fmt.Errorf("failed to decode email %s: %w", resource.ID, err)
+			}
+			if email.Address == "" || email.Blocked || emailPrimary {
+				continue
+			}
+			if email.Primary || contact.Email == "" {
+				contact.Email = email.Address
+				emailPrimary = email.Primary
+			}
+		}
+	}
+	return contact, nil
 }
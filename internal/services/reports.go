@@ -0,0 +1,465 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/utils"
+)
+
+// FirstTimeGuest is a person who first came in a report's range, with the
+// location and event of their first visit and how many times they came
+type FirstTimeGuest struct {
+	PersonID     string    `json:"person_id"`
+	PersonName   string    `json:"person_name"`
+	Kind         string    `json:"kind"`
+	OneTimeGuest bool      `json:"one_time_guest"`
+	FirstVisitAt time.Time `json:"first_visit_at"`
+	LocationID   string    `json:"location_id"`
+	LocationName string    `json:"location_name"`
+	EventID      string    `json:"event_id"`
+	EventName    string    `json:"event_name"`
+	Visits       int       `json:"visits"`
+}
+
+// GuestHousehold is the first-time guests of one family, grouped by their
+// household's primary contact. Guests with no known contact are a household
+// of their own.
+type GuestHousehold struct {
+	ContactName  string           `json:"contact_name"`
+	ContactPhone string           `json:"contact_phone,omitempty"`
+	ContactEmail string           `json:"contact_email,omitempty"`
+	FirstVisitAt time.Time        `json:"first_visit_at"`
+	Guests       []FirstTimeGuest `json:"guests"`
+}
+
+// FirstTimeGuestReport lists the families who came for the first time
+// between From and To, earliest first
+type FirstTimeGuestReport struct {
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	Households  []GuestHousehold `json:"households"`
+	Guests      int              `json:"guests"`
+	Contacts    bool             `json:"contacts"`
+	GeneratedAt time.Time        `json:"generated_at"`
+}
+
+// ReportService builds reports from the local check-in history
+type ReportService struct {
+	store  *repository.Store
+	logger *utils.Logger
+}
+
+func NewReportService(store *repository.Store, logger *utils.Logger) *ReportService {
+	return &ReportService{
+		store:  store,
+		logger: logger.WithComponent("report_service"),
+	}
+}
+
+// guestVisits is what a report range holds about one person
+type guestVisits struct {
+	guest FirstTimeGuest
+	// flagged is set when PCO marked a check-in first_time, and visitor
+	// when one was a guest check-in
+	flagged bool
+	visitor bool
+	// parent is their latest check-in with a parent
+	parent models.CheckIn
+}
+
+// FirstTimeGuests reports the people who came for the first time between
+// from and to: those PCO flagged first_time, and guests with no check-in
+// before from. Volunteers are left out. Contact phone numbers and email
+// addresses are only included with contacts.
+func (s *ReportService) FirstTimeGuests(from, to time.Time, contacts bool) (*FirstTimeGuestReport, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("%w: to must be after from", utils.ErrInvalidInput)
+	}
+
+	checkIns, err := s.store.CheckIns.List(repository.CheckInFilter{Since: from, Until: to})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list check-ins: %w", err)
+	}
+
+	// Listed most recent first; walk them oldest first so each person's
+	// first visit is the one recorded
+	people := make(map[string]*guestVisits)
+	var order []string
+	for i := len(checkIns) - 1; i >= 0; i-- {
+		checkIn := checkIns[i]
+		if checkIn.Kind == models.CheckInKindVolunteer {
+			continue
+		}
+		key := guestKey(checkIn)
+		visits, ok := people[key]
+		if !ok {
+			visits = &guestVisits{guest: FirstTimeGuest{
+				PersonID:     checkIn.PersonID,
+				PersonName:   checkIn.PersonName,
+				Kind:         checkIn.Kind,
+				OneTimeGuest: checkIn.OneTimeGuest,
+				FirstVisitAt: checkIn.CheckInTime,
+				LocationID:   checkIn.LocationID,
+				LocationName: checkIn.LocationName,
+				EventID:      checkIn.EventID,
+				EventName:    checkIn.EventName,
+			}}
+			people[key] = visits
+			order = append(order, key)
+		}
+		visits.guest.Visits++
+		visits.flagged = visits.flagged || checkIn.FirstTime
+		visits.visitor = visits.visitor || checkIn.Kind == models.CheckInKindGuest || checkIn.OneTimeGuest
+		if checkIn.ParentName != "" {
+			visits.parent = checkIn
+		}
+	}
+
+	report := &FirstTimeGuestReport{
+		From:        from,
+		To:          to,
+		Households:  []GuestHousehold{},
+		Contacts:    contacts,
+		GeneratedAt: time.Now(),
+	}
+	// People are in order of their first visit, so households are too
+	households := make(map[string]int)
+	for _, key := range order {
+		visits := people[key]
+		first, err := s.firstTime(visits, from)
+		if err != nil {
+			return nil, err
+		}
+		if !first {
+			continue
+		}
+
+		parent := visits.parent
+		householdKey := "person:" + key
+		if parent.ParentName != "" {
+			householdKey = strings.ToLower(parent.ParentName + "|" + parent.ParentPhone + "|" + parent.ParentEmail)
+		}
+		index, ok := households[householdKey]
+		if !ok {
+			household := GuestHousehold{ContactName: parent.ParentName, FirstVisitAt: visits.guest.FirstVisitAt}
+			if contacts {
+				household.ContactPhone = parent.ParentPhone
+				household.ContactEmail = parent.ParentEmail
+			}
+			index = len(report.Households)
+			households[householdKey] = index
+			report.Households = append(report.Households, household)
+		}
+		report.Households[index].Guests = append(report.Households[index].Guests, visits.guest)
+		report.Guests++
+	}
+	return report, nil
+}
+
+// firstTime reports whether a person came for the first time in the range:
+// PCO flagged one of their check-ins first_time, or they checked in as a
+// guest and have no local check-in before it
+func (s *ReportService) firstTime(visits *guestVisits, from time.Time) (bool, error) {
+	if visits.flagged {
+		return true, nil
+	}
+	if !visits.visitor {
+		return false, nil
+	}
+	// One-time guests have no PCO person to look back through
+	if visits.guest.PersonID == "" {
+		return true, nil
+	}
+	earlier, err := s.store.CheckIns.Count(repository.CheckInFilter{PersonID: visits.guest.PersonID, Until: from})
+	if err != nil {
+		return false, fmt.Errorf("failed to count earlier check-ins of %s: %w", visits.guest.PersonID, err)
+	}
+	return earlier == 0, nil
+}
+
+// guestKey identifies a person across check-ins; one-time guests have no PCO
+// person, so their name stands in
+func guestKey(checkIn models.CheckIn) string {
+	if checkIn.PersonID != "" {
+		return checkIn.PersonID
+	}
+	return "name:" + strings.ToLower(strings.TrimSpace(checkIn.PersonName))
+}
+
+// Table flattens the report to one row per guest, for export
+func (r *FirstTimeGuestReport) Table() *Table {
+	table := &Table{Title: "First-time guests"}
+	table.Columns = []string{"Household contact"}
+	if r.Contacts {
+		table.Columns = append(table.Columns, "Contact phone", "Contact email")
+	}
+	table.Columns = append(table.Columns, "Guest", "Kind", "One-time guest", "First visit", "Location", "Event", "Visits")
+
+	for _, household := range r.Households {
+		for _, guest := range household.Guests {
+			row := []interface{}{household.ContactName}
+			if r.Contacts {
+				row = append(row, household.ContactPhone, household.ContactEmail)
+			}
+			row = append(row, guest.PersonName, guest.Kind, guest.OneTimeGuest, guest.FirstVisitAt, guest.LocationName, guest.EventName, guest.Visits)
+			table.AddRow(row...)
+		}
+	}
+	return table
+}
+
+// Attendance report groupings
+const (
+	AttendanceByWeek     = "week"
+	AttendanceByEvent    = "event"
+	AttendanceByLocation = "location"
+	AttendanceByAgeGroup = "age_group"
+)
+
+// AttendanceRow is the attendance of one week, event, location or age
+// group. Key is the week's first day, the PCO event or location ID, or the
+// age group's label.
+type AttendanceRow struct {
+	Key               string `json:"key"`
+	Label             string `json:"label"`
+	Days              int    `json:"days"`
+	CheckIns          int64  `json:"check_ins"`
+	UniquePeople      int64  `json:"unique_people"`
+	FirstTimeVisitors int64  `json:"first_time_visitors"`
+}
+
+// AttendanceReport is the attendance between From and To grouped By week,
+// event, location or age group, with the totals over all of them
+type AttendanceReport struct {
+	From        time.Time       `json:"from"`
+	To          time.Time       `json:"to"`
+	By          string          `json:"by"`
+	Rows        []AttendanceRow `json:"rows"`
+	Totals      AttendanceRow   `json:"totals"`
+	GeneratedAt time.Time       `json:"generated_at"`
+}
+
+// Attendance reports the attendance on the days from from until to, grouped
+// by week (starting on Sunday), event, location or age group. It is read
+// from the daily rollups, so it covers days whose check-ins have been purged;
+// like them, days are local and people are counted once per location, event
+// and day. Weeks without attendance are included; other groups are busiest
+// first, except age groups, which are youngest first.
+func (s *ReportService) Attendance(from, to time.Time, by string) (*AttendanceReport, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("%w: to must be after from", utils.ErrInvalidInput)
+	}
+	switch by {
+	case AttendanceByWeek, AttendanceByEvent, AttendanceByLocation, AttendanceByAgeGroup:
+	default:
+		return nil, fmt.Errorf("%w: by must be week, event, location or age_group", utils.ErrInvalidInput)
+	}
+
+	rollups, err := s.store.Rollups.List(repository.RollupFilter{Period: models.RollupDay, Since: dayStart(from), Until: to.UTC()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rollups: %w", err)
+	}
+	locations := map[string]models.Location{}
+	if by == AttendanceByLocation || by == AttendanceByAgeGroup {
+		list, err := s.store.Locations.List()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list locations: %w", err)
+		}
+		for _, location := range list {
+			locations[location.PCOLocationID] = location
+		}
+	}
+
+	report := &AttendanceReport{
+		From:        from,
+		To:          to,
+		By:          by,
+		Rows:        []AttendanceRow{},
+		Totals:      AttendanceRow{Label: "Total"},
+		GeneratedAt: time.Now(),
+	}
+	rows := map[string]*AttendanceRow{}
+	// ages orders age groups, youngest first
+	ages := map[string]int{}
+	days := map[string]map[time.Time]bool{}
+	var order []string
+	group := func(key, label string) *AttendanceRow {
+		row, ok := rows[key]
+		if !ok {
+			row = &AttendanceRow{Key: key, Label: label}
+			rows[key] = row
+			days[key] = map[time.Time]bool{}
+			order = append(order, key)
+		}
+		return row
+	}
+	if by == AttendanceByWeek {
+		for week := weekStart(from); week.Before(to); week = addDays(week, 7) {
+			group(dayDate(week), weekLabel(week))
+		}
+	}
+
+	totalDays := map[time.Time]bool{}
+	for _, rollup := range rollups {
+		var row *AttendanceRow
+		switch by {
+		case AttendanceByWeek:
+			week := weekStart(rollup.PeriodStart)
+			row = group(dayDate(week), weekLabel(week))
+		case AttendanceByEvent:
+			row = group(rollup.EventID, "")
+		case AttendanceByLocation:
+			label := rollup.LocationName
+			if location, ok := locations[rollup.LocationID]; ok {
+				label = location.Label()
+			}
+			row = group(rollup.LocationID, label)
+		case AttendanceByAgeGroup:
+			label, age := "Unspecified", math.MaxInt32
+			if location, ok := locations[rollup.LocationID]; ok {
+				label, age = ageGroup(&location)
+			}
+			ages[label] = age
+			row = group(label, label)
+		}
+		day := rollup.PeriodStart.UTC()
+		days[row.Key][day] = true
+		totalDays[day] = true
+		row.CheckIns += rollup.CheckIns
+		row.UniquePeople += rollup.UniquePeople
+		row.FirstTimeVisitors += rollup.FirstTimeVisitors
+		report.Totals.CheckIns += rollup.CheckIns
+		report.Totals.UniquePeople += rollup.UniquePeople
+		report.Totals.FirstTimeVisitors += rollup.FirstTimeVisitors
+	}
+	report.Totals.Days = len(totalDays)
+
+	for _, key := range order {
+		row := rows[key]
+		row.Days = len(days[key])
+		if by == AttendanceByEvent {
+			row.Label = s.eventName(key)
+		}
+		report.Rows = append(report.Rows, *row)
+	}
+	switch by {
+	case AttendanceByEvent, AttendanceByLocation:
+		sort.SliceStable(report.Rows, func(i, j int) bool { return report.Rows[i].CheckIns > report.Rows[j].CheckIns })
+	case AttendanceByAgeGroup:
+		sort.SliceStable(report.Rows, func(i, j int) bool { return ages[report.Rows[i].Key] < ages[report.Rows[j].Key] })
+	}
+	return report, nil
+}
+
+// eventName names an event for a report, falling back to its PCO ID
+func (s *ReportService) eventName(eventID string) string {
+	if eventID == "" {
+		return "No event"
+	}
+	if event, err := s.store.Events.GetByPCOID(eventID); err == nil && event.Name != "" {
+		return event.Name
+	}
+	return eventID
+}
+
+// Table flattens the report to one row per group and a total, for export
+func (r *AttendanceReport) Table() *Table {
+	heading := map[string]string{
+		AttendanceByWeek:     "Week",
+		AttendanceByEvent:    "Event",
+		AttendanceByLocation: "Location",
+		AttendanceByAgeGroup: "Age group",
+	}[r.By]
+	table := &Table{
+		Title:   "Attendance by " + strings.ToLower(heading),
+		Columns: []string{heading, "Days", "Check-ins", "Unique people", "First-time visitors"},
+	}
+	for _, row := range r.Rows {
+		table.AddRow(row.Label, row.Days, row.CheckIns, row.UniquePeople, row.FirstTimeVisitors)
+	}
+	table.AddRow(r.Totals.Label, r.Totals.Days, r.Totals.CheckIns, r.Totals.UniquePeople, r.Totals.FirstTimeVisitors)
+	return table
+}
+
+// weekStart returns the Sunday starting the local week t falls in
+func weekStart(t time.Time) time.Time {
+	day := dayStart(t)
+	return addDays(day, -int(day.In(time.Local).Weekday()))
+}
+
+func weekLabel(week time.Time) string {
+	return week.In(time.Local).Format("Jan 2") + " – " + addDays(week, 6).In(time.Local).Format("Jan 2, 2006")
+}
+
+// ageGroup names the ages or grades a location is for, with a rank that
+// orders groups youngest first: ages before grades, and locations with
+// neither last
+func ageGroup(location *models.Location) (string, int) {
+	switch {
+	case location.GradeMin != nil || location.GradeMax != nil:
+		low, high := location.GradeMin, location.GradeMax
+		rank := 10000
+		if low != nil {
+			rank += (*low + 10) * 100
+		}
+		switch {
+		case low == nil:
+			return "Grades up to " + gradeName(*high), rank
+		case high == nil:
+			return "Grades " + gradeName(*low) + "+", rank
+		case *low == *high:
+			return "Grade " + gradeName(*low), rank
+		default:
+			return "Grades " + gradeName(*low) + "–" + gradeName(*high), rank
+		}
+	case location.AgeMinMonths != nil || location.AgeMaxMonths != nil:
+		low, high := location.AgeMinMonths, location.AgeMaxMonths
+		rank := 0
+		if low != nil {
+			rank = *low
+		}
+		switch {
+		case low == nil:
+			return "Ages up to " + ageName(*high), rank
+		case high == nil:
+			return "Ages " + ageName(*low) + "+", rank
+		case *low%12 == 0 && *high%12 == 0:
+			return fmt.Sprintf("Ages %d–%d years", *low/12, *high/12), rank
+		default:
+			return fmt.Sprintf("Ages %d–%d months", *low, *high), rank
+		}
+	default:
+		return "Unspecified", math.MaxInt32
+	}
+}
+
+// gradeName names a PCO grade, where 0 is kindergarten and below it pre-K
+func gradeName(grade int) string {
+	switch {
+	case grade < 0:
+		return "Pre-K"
+	case grade == 0:
+		return "K"
+	default:
+		return strconv.Itoa(grade)
+	}
+}
+
+// ageName names an age in months, in years when it is a whole number of them
+func ageName(months int) string {
+	switch {
+	case months == 12:
+		return "1 year"
+	case months > 0 && months%12 == 0:
+		return fmt.Sprintf("%d years", months/12)
+	default:
+		return fmt.Sprintf("%d months", months)
+	}
+}
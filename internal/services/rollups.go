@@ -0,0 +1,408 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/utils"
+)
+
+// CheckInRetentionDays is how long raw check-ins are kept. The rollups keep
+// their attendance figures after that.
+const CheckInRetentionDays = 30
+
+// DailyAttendance is one day of attendance at a location, summed over events
+type DailyAttendance struct {
+	Date              string `json:"date"`
+	Count             int64  `json:"count"`
+	UniquePeople      int64  `json:"unique_people"`
+	FirstTimeVisitors int64  `json:"first_time_visitors"`
+}
+
+// AttendanceTotals sums the daily rollups over a range
+type AttendanceTotals struct {
+	CheckIns          int64 `json:"check_ins"`
+	FirstTimeVisitors int64 `json:"first_time_visitors"`
+}
+
+// EventAttendance sums the daily rollups of one event over a range. Check-ins
+// not matched to any event have an empty EventID.
+type EventAttendance struct {
+	EventID           string `json:"event_id"`
+	EventName         string `json:"event_name"`
+	Days              int    `json:"days"`
+	CheckIns          int64  `json:"check_ins"`
+	FirstTimeVisitors int64  `json:"first_time_visitors"`
+}
+
+// RollupService maintains the hourly and daily attendance rollups and answers
+// analytics queries from them. Buckets are whole local hours and days, stored
+// by the UTC instant they start at. Each touched day is rebuilt from its raw
+// check-ins, so re-syncing is idempotent.
+type RollupService struct {
+	store  *repository.Store
+	logger *utils.Logger
+	// mu serialises rebuilds so two syncs can't interleave a day's rollups
+	mu sync.Mutex
+}
+
+func NewRollupService(store *repository.Store, logger *utils.Logger) *RollupService {
+	return &RollupService{
+		store:  store,
+		logger: logger.WithComponent("rollup_service"),
+	}
+}
+
+// Record updates the rollups for the days the given check-ins fall on
+func (s *RollupService) Record(checkIns []models.CheckIn) error {
+	if len(checkIns) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	days := map[time.Time]bool{}
+	for _, checkIn := range checkIns {
+		days[dayStart(checkIn.CheckInTime)] = true
+	}
+
+	moved, err := s.store.Visitors.Record(visitorsFrom(checkIns))
+	if err != nil {
+		return fmt.Errorf("failed to record visitors: %w", err)
+	}
+	// People whose first visit moved earlier stop being first-timers on
+	// their old day
+	for _, visitor := range moved {
+		days[dayStart(visitor.FirstCheckInAt)] = true
+	}
+
+	ordered := make([]time.Time, 0, len(days))
+	for day := range days {
+		ordered = append(ordered, day)
+	}
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Before(ordered[j]) })
+	for _, day := range ordered {
+		checkIns, err := s.dayCheckIns(day)
+		if err != nil {
+			return err
+		}
+		if err := s.rebuildDay(day, checkIns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebuild recomputes visitors and rollups for every day from since to today
+// and returns the number of days rebuilt. Days without raw check-ins are
+// skipped, so history whose check-ins were purged is kept.
+func (s *RollupService) Rebuild(since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rebuilt := 0
+	today := dayStart(time.Now())
+	for day := dayStart(since); !day.After(today); day = addDays(day, 1) {
+		checkIns, err := s.dayCheckIns(day)
+		if err != nil {
+			return rebuilt, err
+		}
+		if len(checkIns) == 0 {
+			continue
+		}
+		if _, err := s.store.Visitors.Record(visitorsFrom(checkIns)); err != nil {
+			return rebuilt, fmt.Errorf("failed to record visitors: %w", err)
+		}
+		if err := s.rebuildDay(day, checkIns); err != nil {
+			return rebuilt, err
+		}
+		rebuilt++
+	}
+	s.logger.Info("Rebuilt attendance rollups", "since", dayDate(dayStart(since)), "days", rebuilt)
+	return rebuilt, nil
+}
+
+// dayCheckIns returns the raw check-ins of one local day
+func (s *RollupService) dayCheckIns(day time.Time) ([]models.CheckIn, error) {
+	checkIns, err := s.store.CheckIns.List(repository.CheckInFilter{Since: day, Until: addDays(day, 1)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load check-ins for %s: %w", dayDate(day), err)
+	}
+	return checkIns, nil
+}
+
+// rebuildDay replaces the rollups of one local day with figures computed from
+// its raw check-ins. A day with none left is skipped rather than emptied,
+// since its check-ins have been purged.
+func (s *RollupService) rebuildDay(day time.Time, checkIns []models.CheckIn) error {
+	if len(checkIns) == 0 {
+		return nil
+	}
+	end := addDays(day, 1)
+	visitors, err := s.store.Visitors.List(repository.VisitorFilter{Since: day, Until: end})
+	if err != nil {
+		return fmt.Errorf("failed to load visitors for %s: %w", dayDate(day), err)
+	}
+
+	type bucketKey struct {
+		period     string
+		start      time.Time
+		locationID string
+		eventID    string
+	}
+	buckets := map[bucketKey]*models.AttendanceRollup{}
+	people := map[bucketKey]map[string]bool{}
+	bucket := func(period string, start time.Time, locationID, locationName, eventID string) bucketKey {
+		key := bucketKey{period, start, locationID, eventID}
+		if _, ok := buckets[key]; !ok {
+			buckets[key] = &models.AttendanceRollup{Period: period, PeriodStart: start, LocationID: locationID, LocationName: locationName, EventID: eventID}
+			people[key] = map[string]bool{}
+		}
+		return key
+	}
+
+	for _, checkIn := range checkIns {
+		for _, key := range []bucketKey{
+			bucket(models.RollupDay, day, checkIn.LocationID, checkIn.LocationName, checkIn.EventID),
+			bucket(models.RollupHour, hourStart(checkIn.CheckInTime), checkIn.LocationID, checkIn.LocationName, checkIn.EventID),
+		} {
+			buckets[key].CheckIns++
+			person := checkIn.PersonID
+			if person == "" {
+				person = checkIn.PCOCheckInID
+			}
+			if !people[key][person] {
+				people[key][person] = true
+				buckets[key].UniquePeople++
+			}
+		}
+	}
+	for _, visitor := range visitors {
+		for _, key := range []bucketKey{
+			bucket(models.RollupDay, day, visitor.LocationID, visitor.LocationName, visitor.EventID),
+			bucket(models.RollupHour, hourStart(visitor.FirstCheckInAt), visitor.LocationID, visitor.LocationName, visitor.EventID),
+		} {
+			buckets[key].FirstTimeVisitors++
+		}
+	}
+
+	rollups := make([]models.AttendanceRollup, 0, len(buckets))
+	for _, rollup := range buckets {
+		rollups = append(rollups, *rollup)
+	}
+	if err := s.store.Rollups.Replace(day, end, rollups); err != nil {
+		return fmt.Errorf("failed to store rollups for %s: %w", dayDate(day), err)
+	}
+	return nil
+}
+
+// Daily returns per-day attendance at a location over whole days from since
+// to until, in date order. People are counted once per event.
+func (s *RollupService) Daily(locationID string, since, until time.Time) ([]DailyAttendance, error) {
+	rollups, err := s.store.Rollups.List(repository.RollupFilter{
+		Period:     models.RollupDay,
+		LocationID: locationID,
+		Since:      dayStart(since),
+		Until:      rangeEnd(until),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	daily := []DailyAttendance{}
+	for _, rollup := range rollups {
+		date := dayDate(rollup.PeriodStart)
+		if len(daily) == 0 || daily[len(daily)-1].Date != date {
+			daily = append(daily, DailyAttendance{Date: date})
+		}
+		day := &daily[len(daily)-1]
+		day.Count += rollup.CheckIns
+		day.UniquePeople += rollup.UniquePeople
+		day.FirstTimeVisitors += rollup.FirstTimeVisitors
+	}
+	return daily, nil
+}
+
+// Totals sums attendance at a location over whole days from since to until.
+// An empty locationID covers every location and zero times leave the range
+// open.
+func (s *RollupService) Totals(locationID string, since, until time.Time) (*AttendanceTotals, error) {
+	filter := repository.RollupFilter{Period: models.RollupDay, LocationID: locationID}
+	if !since.IsZero() {
+		filter.Since = dayStart(since)
+	}
+	if !until.IsZero() {
+		filter.Until = rangeEnd(until)
+	}
+	rollups, err := s.store.Rollups.List(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := &AttendanceTotals{}
+	for _, rollup := range rollups {
+		totals.CheckIns += rollup.CheckIns
+		totals.FirstTimeVisitors += rollup.FirstTimeVisitors
+	}
+	return totals, nil
+}
+
+// LocationTotals sums a location's attendance over a range and on its last day
+type LocationTotals struct {
+	Period  AttendanceTotals `json:"period"`
+	LastDay AttendanceTotals `json:"last_day"`
+}
+
+// TotalsByLocation sums attendance at every location over whole days from
+// since to until, and on until's day, keyed by location ID, in a single query
+func (s *RollupService) TotalsByLocation(since, until time.Time) (map[string]*LocationTotals, error) {
+	rollups, err := s.store.Rollups.List(repository.RollupFilter{
+		Period: models.RollupDay,
+		Since:  dayStart(since),
+		Until:  rangeEnd(until),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	lastDay := dayStart(until)
+	totals := map[string]*LocationTotals{}
+	for _, rollup := range rollups {
+		location, ok := totals[rollup.LocationID]
+		if !ok {
+			location = &LocationTotals{}
+			totals[rollup.LocationID] = location
+		}
+		location.Period.CheckIns += rollup.CheckIns
+		location.Period.FirstTimeVisitors += rollup.FirstTimeVisitors
+		if rollup.PeriodStart.Equal(lastDay) {
+			location.LastDay.CheckIns += rollup.CheckIns
+			location.LastDay.FirstTimeVisitors += rollup.FirstTimeVisitors
+		}
+	}
+	return totals, nil
+}
+
+// ByEvent sums attendance at a location per event over whole days from since
+// to until, busiest first
+func (s *RollupService) ByEvent(locationID string, since, until time.Time) ([]EventAttendance, error) {
+	rollups, err := s.store.Rollups.List(repository.RollupFilter{
+		Period:     models.RollupDay,
+		LocationID: locationID,
+		Since:      dayStart(since),
+		Until:      rangeEnd(until),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byEvent := map[string]*EventAttendance{}
+	var order []string
+	for _, rollup := range rollups {
+		attendance, ok := byEvent[rollup.EventID]
+		if !ok {
+			attendance = &EventAttendance{EventID: rollup.EventID}
+			byEvent[rollup.EventID] = attendance
+			order = append(order, rollup.EventID)
+		}
+		attendance.Days++
+		attendance.CheckIns += rollup.CheckIns
+		attendance.FirstTimeVisitors += rollup.FirstTimeVisitors
+	}
+
+	events := make([]EventAttendance, 0, len(order))
+	for _, eventID := range order {
+		attendance := byEvent[eventID]
+		if eventID != "" {
+			if event, err := s.store.Events.GetByPCOID(eventID); err == nil {
+				attendance.EventName = event.Name
+			}
+		}
+		events = append(events, *attendance)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CheckIns > events[j].CheckIns })
+	return events, nil
+}
+
+// PeakHours returns the busiest local hours of the day at a location from
+// since to until, busiest first, at most limit of them
+func (s *RollupService) PeakHours(locationID string, since, until time.Time, limit int) ([]repository.HourlyCount, error) {
+	rollups, err := s.store.Rollups.List(repository.RollupFilter{
+		Period:     models.RollupHour,
+		LocationID: locationID,
+		Since:      hourStart(since),
+		Until:      until.UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[int]int64{}
+	for _, rollup := range rollups {
+		counts[rollup.PeriodStart.In(time.Local).Hour()] += rollup.CheckIns
+	}
+	hours := make([]repository.HourlyCount, 0, len(counts))
+	for hour, count := range counts {
+		hours = append(hours, repository.HourlyCount{Hour: hour, Count: count})
+	}
+	sort.Slice(hours, func(i, j int) bool {
+		if hours[i].Count != hours[j].Count {
+			return hours[i].Count > hours[j].Count
+		}
+		return hours[i].Hour < hours[j].Hour
+	})
+	if limit > 0 && len(hours) > limit {
+		hours = hours[:limit]
+	}
+	return hours, nil
+}
+
+// visitorsFrom turns check-ins into candidate first visits
+func visitorsFrom(checkIns []models.CheckIn) []models.Visitor {
+	visitors := make([]models.Visitor, 0, len(checkIns))
+	for _, checkIn := range checkIns {
+		visitors = append(visitors, models.Visitor{
+			PersonID:       checkIn.PersonID,
+			PersonName:     checkIn.PersonName,
+			FirstCheckInAt: checkIn.CheckInTime,
+			LocationID:     checkIn.LocationID,
+			LocationName:   checkIn.LocationName,
+			EventID:        checkIn.EventID,
+		})
+	}
+	return visitors
+}
+
+// dayStart returns local midnight of the day t falls on, in UTC so stored
+// and queried times compare alike
+func dayStart(t time.Time) time.Time {
+	t = t.In(time.Local)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local).UTC()
+}
+
+// addDays moves a day start by whole local days, which are not always 24
+// hours long
+func addDays(day time.Time, days int) time.Time {
+	return dayStart(day.In(time.Local).AddDate(0, 0, days))
+}
+
+// dayDate formats the local date of a day start
+func dayDate(day time.Time) string {
+	return day.In(time.Local).Format("2006-01-02")
+}
+
+// rangeEnd is the exclusive end of a range of whole days that includes until
+func rangeEnd(until time.Time) time.Time {
+	return addDays(dayStart(until), 1)
+}
+
+// hourStart returns the start of the local hour t falls in, in UTC
+func hourStart(t time.Time) time.Time {
+	t = t.In(time.Local)
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local).UTC()
+}
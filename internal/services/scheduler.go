@@ -0,0 +1,146 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go_pco_arrivals/internal/models"
+	"go_pco_arrivals/internal/repository"
+	"go_pco_arrivals/internal/utils"
+)
+
+// schedulerInterval is how often the scheduler looks for occurrences that
+// start or end
+const schedulerInterval = time.Minute
+
+// schedulerActor names the scheduler in the audit log and on billboards
+const schedulerActor = "scheduler"
+
+// scheduledLaunch is a billboard the scheduler launched and will clear
+type scheduledLaunch struct {
+	eventID    uint
+	locationID string
+	endsAt     time.Time
+}
+
+// BillboardScheduler launches the billboard of auto_launch events at their
+// location when an occurrence starts, one-off or recurring, and clears it
+// when the occurrence ends unless something else was launched meanwhile
+type BillboardScheduler struct {
+	store     *repository.Store
+	billboard *BillboardService
+	audit     *AuditService
+	logger    *utils.Logger
+
+	mu sync.Mutex
+	// launched is keyed by event and occurrence start
+	launched map[string]scheduledLaunch
+	stop     chan struct{}
+}
+
+func NewBillboardScheduler(store *repository.Store, billboard *BillboardService, audit *AuditService, logger *utils.Logger) *BillboardScheduler {
+	return &BillboardScheduler{
+		store:     store,
+		billboard: billboard,
+		audit:     audit,
+		logger:    logger.WithComponent("billboard_scheduler"),
+		launched:  make(map[string]scheduledLaunch),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start runs the scheduler in the background until Stop is called
+func (s *BillboardScheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.Run(time.Now()); err != nil {
+				s.logger.Error("Billboard scheduler run failed", "error", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	s.logger.Info("Billboard scheduler started")
+}
+
+func (s *BillboardScheduler) Stop() {
+	close(s.stop)
+	s.logger.Info("Billboard scheduler stopped")
+}
+
+// Run launches the billboards of occurrences in progress at now and clears
+// those of occurrences that have ended
+func (s *BillboardScheduler) Run(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, launch := range s.launched {
+		if now.Before(launch.endsAt) {
+			continue
+		}
+		if err := s.clear(launch); err != nil {
+			return err
+		}
+		delete(s.launched, key)
+	}
+
+	// Occurrences last at most a day
+	occurrences, err := listOccurrences(s.store, now.Add(-24*time.Hour), now.Add(time.Nanosecond))
+	if err != nil {
+		return err
+	}
+	for _, occurrence := range occurrences {
+		event := occurrence.Event
+		if !event.AutoLaunch || event.LocationID == "" || !now.Before(occurrence.EndsAt) {
+			continue
+		}
+		key := fmt.Sprintf("%s@%d", event.PCOEventID, occurrence.StartsAt.Unix())
+		if _, ok := s.launched[key]; ok {
+			continue
+		}
+
+		previous, state, err := s.billboard.Launch(&event, event.LocationID, schedulerActor, event.LocationID)
+		if err != nil {
+			return fmt.Errorf("failed to launch billboard for %s: %w", event.Name, err)
+		}
+		s.launched[key] = scheduledLaunch{eventID: event.ID, locationID: event.LocationID, endsAt: occurrence.EndsAt}
+		s.logger.Info("Launched scheduled billboard", "event", event.Name, "location_id", event.LocationID, "starts_at", occurrence.StartsAt)
+
+		audit := &models.AuditEvent{ActorName: schedulerActor, AuthMethod: schedulerActor, Action: models.AuditBillboardLaunch, TargetType: "location", TargetID: event.LocationID, After: state}
+		if len(previous) > 0 {
+			audit.Before = previous
+		}
+		s.audit.Record(audit)
+	}
+	return nil
+}
+
+// clear deactivates a scheduled billboard if it is still showing its event
+func (s *BillboardScheduler) clear(launch scheduledLaunch) error {
+	states, err := s.store.BillboardStates.ListActive(launch.locationID)
+	if err != nil {
+		return fmt.Errorf("failed to list billboards: %w", err)
+	}
+	showing := false
+	for _, state := range states {
+		if state.EventID == launch.eventID {
+			showing = true
+		}
+	}
+	if !showing {
+		return nil
+	}
+
+	if _, err := s.store.BillboardStates.Deactivate(launch.locationID); err != nil {
+		return fmt.Errorf("failed to clear billboard: %w", err)
+	}
+	s.logger.Info("Cleared scheduled billboard", "location_id", launch.locationID)
+	s.audit.Record(&models.AuditEvent{ActorName: schedulerActor, AuthMethod: schedulerActor, Action: models.AuditBillboardClear, TargetType: "location", TargetID: launch.locationID, Before: states})
+	return nil
+}
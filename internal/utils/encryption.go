@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks values produced by TokenCipher so that legacy
+// plaintext values can still be read while they are being migrated.
+const encryptedPrefix = "enc:v1:"
+
+// TokenCipher performs envelope encryption of secrets stored at rest.
+// Every value is sealed with its own random data key, and the data key is
+// sealed with one of the configured key encryption keys. The key id is stored
+// alongside the value so older keys can still decrypt after a rotation.
+type TokenCipher struct {
+	keys     map[string][]byte
+	activeID string
+}
+
+// NewTokenCipher parses a key specification of the form
+// "id1:base64key,id2:base64key". Keys must decode to 16, 24 or 32 bytes.
+// The active key is used for new encryptions; it defaults to the first key.
+// An empty specification yields a disabled cipher that passes values through.
+func NewTokenCipher(keySpec, activeID string) (*TokenCipher, error) {
+	c := &TokenCipher{keys: make(map[string][]byte)}
+
+	var firstID string
+	for _, entry := range strings.Split(keySpec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid encryption key entry %q, expected id:base64key", entry)
+		}
+
+		id := parts[0]
+		key, err := ParseEncryptionKey(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+
+		if _, exists := c.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate encryption key id %q", id)
+		}
+		c.keys[id] = key
+		if firstID == "" {
+			firstID = id
+		}
+	}
+
+	if activeID == "" {
+		activeID = firstID
+	}
+	if activeID != "" {
+		if _, ok := c.keys[activeID]; !ok {
+			return nil, fmt.Errorf("active encryption key %q is not configured", activeID)
+		}
+	}
+	c.activeID = activeID
+
+	return c, nil
+}
+
+// Enabled reports whether at least one key is configured
+func (c *TokenCipher) Enabled() bool {
+	return c != nil && c.activeID != ""
+}
+
+// ActiveKeyID returns the id of the key used for new encryptions
+func (c *TokenCipher) ActiveKeyID() string {
+	if c == nil {
+		return ""
+	}
+	return c.activeID
+}
+
+// Encrypt seals a plaintext value. Empty values are stored as-is, and values
+// pass through unchanged when no key is configured.
+func (c *TokenCipher) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" || !c.Enabled() {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	sealedValue, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt value: %w", err)
+	}
+
+	sealedKey, err := seal(c.keys[c.activeID], dataKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	return encryptedPrefix + c.activeID + ":" +
+		base64.RawStdEncoding.EncodeToString(sealedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(sealedValue), nil
+}
+
+// Decrypt opens a value produced by Encrypt. Values without the encrypted
+// prefix are treated as legacy plaintext and returned unchanged.
+func (c *TokenCipher) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	keyID, sealedKey, sealedValue, err := parseEncrypted(value)
+	if err != nil {
+		return "", err
+	}
+
+	if c == nil {
+		return "", fmt.Errorf("value is encrypted with key %q but no encryption keys are configured", keyID)
+	}
+	kek, ok := c.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("value is encrypted with unknown key %q", keyID)
+	}
+
+	dataKey, err := open(kek, sealedKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	plaintext, err := open(dataKey, sealedValue)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether a stored value should be re-encrypted,
+// either because it is still plaintext or because it uses a non-active key
+func (c *TokenCipher) NeedsRotation(value string) bool {
+	if value == "" || !c.Enabled() {
+		return false
+	}
+	if !IsEncrypted(value) {
+		return true
+	}
+	keyID, _, _, err := parseEncrypted(value)
+	return err != nil || keyID != c.activeID
+}
+
+// IsEncrypted reports whether a stored value was produced by TokenCipher
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// ParseEncryptionKey decodes a base64 key, which must be 16, 24 or 32 bytes
+func ParseEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("key must be 16, 24 or 32 bytes, got %d", len(key))
+	}
+}
+
+// EncryptBytes seals data with AES-GCM under key
+func EncryptBytes(key, data []byte) ([]byte, error) {
+	return seal(key, data)
+}
+
+// DecryptBytes opens data produced by EncryptBytes
+func DecryptBytes(key, data []byte) ([]byte, error) {
+	return open(key, data)
+}
+
+// GenerateEncryptionKey returns a random 32-byte key encoded for use in a key specification
+func GenerateEncryptionKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+func parseEncrypted(value string) (keyID string, sealedKey, sealedValue []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, fmt.Errorf("malformed encrypted value")
+	}
+
+	sealedKey, err = base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("malformed encrypted data key: %w", err)
+	}
+	sealedValue, err = base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("malformed encrypted payload: %w", err)
+	}
+
+	return parts[0], sealedKey, sealedValue, nil
+}
+
+// seal encrypts data with AES-GCM and prepends the random nonce
+func seal(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// open reverses seal
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
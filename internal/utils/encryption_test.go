@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, keySpec, activeID string) *TokenCipher {
+	t.Helper()
+	c, err := NewTokenCipher(keySpec, activeID)
+	if err != nil {
+		t.Fatalf("NewTokenCipher: %v", err)
+	}
+	return c
+}
+
+func testKey(t *testing.T) string {
+	t.Helper()
+	key, err := GenerateEncryptionKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func TestTokenCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, "k1:"+testKey(t), "")
+
+	sealed, err := c.Encrypt("pco-access-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !IsEncrypted(sealed) || strings.Contains(sealed, "pco-access-token") {
+		t.Fatalf("got %q, want an encrypted value", sealed)
+	}
+	if got, err := c.Decrypt(sealed); err != nil || got != "pco-access-token" {
+		t.Fatalf("Decrypt: got %q, %v", got, err)
+	}
+	if c.NeedsRotation(sealed) {
+		t.Errorf("value sealed with the active key needs rotation")
+	}
+}
+
+func TestTokenCipherRotation(t *testing.T) {
+	oldKey := testKey(t)
+	sealed, err := newTestCipher(t, "k1:"+oldKey, "").Encrypt("pco-access-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	rotated := newTestCipher(t, "k2:"+testKey(t)+",k1:"+oldKey, "k2")
+	if got, err := rotated.Decrypt(sealed); err != nil || got != "pco-access-token" {
+		t.Fatalf("Decrypt with the old key: got %q, %v", got, err)
+	}
+	if !rotated.NeedsRotation(sealed) {
+		t.Errorf("value sealed with the old key doesn't need rotation")
+	}
+
+	resealed, err := rotated.Encrypt("pco-access-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !strings.HasPrefix(resealed, encryptedPrefix+"k2:") {
+		t.Errorf("got %q, want it sealed with k2", resealed)
+	}
+}
+
+func TestTokenCipherUnknownKey(t *testing.T) {
+	sealed, err := newTestCipher(t, "k1:"+testKey(t), "").Encrypt("pco-access-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if _, err := newTestCipher(t, "k2:"+testKey(t), "").Decrypt(sealed); err == nil {
+		t.Fatalf("Decrypt with an unknown key id succeeded")
+	}
+	if _, err := (*TokenCipher)(nil).Decrypt(sealed); err == nil {
+		t.Fatalf("Decrypt without keys succeeded")
+	}
+}
+
+func TestTokenCipherRejectsDamagedValues(t *testing.T) {
+	c := newTestCipher(t, "k1:"+testKey(t), "")
+	sealed, err := c.Encrypt("pco-access-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	parts := strings.Split(sealed, ":")
+	payload, err := base64.RawStdEncoding.DecodeString(parts[len(parts)-1])
+	if err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	payload[len(payload)-1] ^= 0xff
+	tampered := strings.Join(parts[:len(parts)-1], ":") + ":" + base64.RawStdEncoding.EncodeToString(payload)
+
+	for name, value := range map[string]string{
+		"tampered payload":  tampered,
+		"too short":         encryptedPrefix + "k1:AA:AA",
+		"empty parts":       encryptedPrefix + "k1::",
+		"missing parts":     encryptedPrefix + "k1",
+		"invalid base64":    encryptedPrefix + "k1:!!:!!",
+		"truncated payload": sealed[:len(sealed)-10],
+	} {
+		if got, err := c.Decrypt(value); err == nil {
+			t.Errorf("%s: got %q, want an error", name, got)
+		}
+	}
+}
+
+func TestTokenCipherPlaintextPassesThrough(t *testing.T) {
+	c := newTestCipher(t, "k1:"+testKey(t), "")
+	if got, err := c.Decrypt("legacy-token"); err != nil || got != "legacy-token" {
+		t.Fatalf("Decrypt of plaintext: got %q, %v", got, err)
+	}
+	if !c.NeedsRotation("legacy-token") {
+		t.Errorf("plaintext value doesn't need rotation")
+	}
+
+	disabled := newTestCipher(t, "", "")
+	if got, err := disabled.Encrypt("legacy-token"); err != nil || got != "legacy-token" {
+		t.Fatalf("Encrypt without keys: got %q, %v", got, err)
+	}
+}
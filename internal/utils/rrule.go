@@ -0,0 +1,355 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRRulePeriods bounds expansion of a rule, about 270 years of days
+const maxRRulePeriods = 100000
+
+// RRule is a parsed iCalendar (RFC 5545) recurrence rule. The common subset
+// is supported: FREQ of DAILY, WEEKLY, MONTHLY or YEARLY with INTERVAL,
+// COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	ByDay      []RRuleWeekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+
+	// until is the exclusive end of the rule, zero when it has none
+	until time.Time
+	// untilDate is set when UNTIL is a date, whose end depends on the zone
+	// the rule is expanded in
+	untilDate string
+}
+
+// RRuleWeekday is a BYDAY entry such as MO, 2SU or -1FR. N is zero for
+// every such weekday in the period.
+type RRuleWeekday struct {
+	Weekday time.Weekday
+	N       int
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// ParseRRule parses a rule such as "FREQ=WEEKLY;BYDAY=WE". An "RRULE:"
+// prefix is allowed.
+func ParseRRule(value string) (*RRule, error) {
+	value = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "RRULE:")
+	if value == "" {
+		return nil, fmt.Errorf("%w: empty recurrence rule", ErrInvalidInput)
+	}
+
+	rule := &RRule{Interval: 1, WeekStart: time.Monday}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("%w: malformed recurrence rule part %q", ErrInvalidInput, part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%w: %s given twice in recurrence rule", ErrInvalidInput, key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch val {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				rule.Freq = val
+			default:
+				err = fmt.Errorf("unsupported FREQ %s", val)
+			}
+		case "INTERVAL":
+			rule.Interval, err = positiveInt(val, "INTERVAL")
+		case "COUNT":
+			rule.Count, err = positiveInt(val, "COUNT")
+		case "UNTIL":
+			err = rule.parseUntil(val)
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := rruleWeekdays[day[max(len(day)-2, 0):]]
+				if !ok {
+					err = fmt.Errorf("invalid BYDAY %s", day)
+					break
+				}
+				entry := RRuleWeekday{Weekday: weekday}
+				if prefix := day[:len(day)-2]; prefix != "" {
+					entry.N, err = strconv.Atoi(prefix)
+					if err != nil || entry.N == 0 || entry.N < -5 || entry.N > 5 {
+						err = fmt.Errorf("invalid BYDAY %s", day)
+						break
+					}
+				}
+				rule.ByDay = append(rule.ByDay, entry)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(val, ",") {
+				n, convErr := strconv.Atoi(day)
+				if convErr != nil || n == 0 || n < -31 || n > 31 {
+					err = fmt.Errorf("invalid BYMONTHDAY %s", day)
+					break
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(val, ",") {
+				n, convErr := strconv.Atoi(month)
+				if convErr != nil || n < 1 || n > 12 {
+					err = fmt.Errorf("invalid BYMONTH %s", month)
+					break
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			weekday, ok := rruleWeekdays[val]
+			if !ok {
+				err = fmt.Errorf("invalid WKST %s", val)
+			}
+			rule.WeekStart = weekday
+		default:
+			err = fmt.Errorf("unsupported recurrence rule part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+	}
+
+	switch {
+	case rule.Freq == "":
+		return nil, fmt.Errorf("%w: recurrence rule needs FREQ", ErrInvalidInput)
+	case rule.Count > 0 && (!rule.until.IsZero() || rule.untilDate != ""):
+		return nil, fmt.Errorf("%w: recurrence rule can't have both COUNT and UNTIL", ErrInvalidInput)
+	case rule.Freq == "WEEKLY" && len(rule.ByMonthDay) > 0:
+		return nil, fmt.Errorf("%w: BYMONTHDAY can't be used with FREQ=WEEKLY", ErrInvalidInput)
+	case rule.Freq == "YEARLY" && len(rule.ByDay) > 0 && len(rule.ByMonth) == 0:
+		return nil, fmt.Errorf("%w: BYDAY with FREQ=YEARLY needs BYMONTH", ErrInvalidInput)
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && (rule.Freq == "DAILY" || rule.Freq == "WEEKLY") {
+			return nil, fmt.Errorf("%w: numbered BYDAY needs FREQ=MONTHLY or YEARLY", ErrInvalidInput)
+		}
+	}
+	return rule, nil
+}
+
+func positiveInt(value, name string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s %s", name, value)
+	}
+	return n, nil
+}
+
+func (r *RRule) parseUntil(value string) error {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		r.until = t.Add(time.Nanosecond)
+		return nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, time.Local); err == nil {
+		r.until = t.Add(time.Nanosecond)
+		return nil
+	}
+	if _, err := time.Parse("20060102", value); err == nil {
+		r.untilDate = value
+		return nil
+	}
+	return fmt.Errorf("invalid UNTIL %s", value)
+}
+
+// Between returns the occurrences of the rule that start in [from, to), in
+// order. start is the first occurrence (DTSTART); later ones keep its wall
+// clock time in its location, across daylight saving changes.
+func (r *RRule) Between(start, from, to time.Time) []time.Time {
+	loc := start.Location()
+	until := r.until
+	if r.untilDate != "" {
+		day, _ := time.ParseInLocation("20060102", r.untilDate, loc)
+		until = day.AddDate(0, 0, 1)
+	}
+	hour, minute, second := start.Clock()
+
+	var occurrences []time.Time
+	count := 0
+	for k := 0; k < maxRRulePeriods; k++ {
+		periodStart, days := r.period(start, k)
+		if !periodStart.Before(to) || (!until.IsZero() && !periodStart.Before(until)) {
+			break
+		}
+		for _, day := range days {
+			t := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, second, start.Nanosecond(), loc)
+			if t.Before(start) {
+				continue
+			}
+			count++
+			if (r.Count > 0 && count > r.Count) || (!until.IsZero() && !t.Before(until)) || !t.Before(to) {
+				return occurrences
+			}
+			if !t.Before(from) {
+				occurrences = append(occurrences, t)
+			}
+		}
+	}
+	return occurrences
+}
+
+// period returns the start of the k-th period of the rule and the days in
+// it that match, in order
+func (r *RRule) period(start time.Time, k int) (time.Time, []time.Time) {
+	loc := start.Location()
+	step := k * r.Interval
+
+	switch r.Freq {
+	case "DAILY":
+		day := time.Date(start.Year(), start.Month(), start.Day()+step, 0, 0, 0, 0, loc)
+		return day, r.filter([]time.Time{day})
+	case "WEEKLY":
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		week := time.Date(start.Year(), start.Month(), start.Day()-offset+7*step, 0, 0, 0, 0, loc)
+		var days []time.Time
+		for i := 0; i < 7; i++ {
+			day := week.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && day.Weekday() != start.Weekday() {
+				continue
+			}
+			days = append(days, day)
+		}
+		return week, r.filter(days)
+	case "MONTHLY":
+		month := time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, loc)
+		return month, r.filter(r.monthDays(month, start))
+	default:
+		year := time.Date(start.Year()+step, time.January, 1, 0, 0, 0, 0, loc)
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{start.Month()}
+		}
+		sorted := append([]time.Month(nil), months...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		var days []time.Time
+		for _, m := range sorted {
+			days = append(days, r.monthDays(time.Date(year.Year(), m, 1, 0, 0, 0, 0, loc), start)...)
+		}
+		return year, r.filter(days)
+	}
+}
+
+// monthDays returns the days of a month picked by BYMONTHDAY and BYDAY, or
+// the day of the month of start when neither is given
+func (r *RRule) monthDays(month, start time.Time) []time.Time {
+	last := month.AddDate(0, 1, -1).Day()
+	picked := map[int]bool{}
+
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if start.Day() <= last {
+			picked[start.Day()] = true
+		}
+	}
+	for _, n := range r.ByMonthDay {
+		day := n
+		if n < 0 {
+			day = last + n + 1
+		}
+		if day >= 1 && day <= last {
+			picked[day] = true
+		}
+	}
+	if len(r.ByDay) > 0 {
+		byDay := map[int]bool{}
+		for _, entry := range r.ByDay {
+			var matches []int
+			for day := 1; day <= last; day++ {
+				if month.AddDate(0, 0, day-1).Weekday() == entry.Weekday {
+					matches = append(matches, day)
+				}
+			}
+			switch {
+			case entry.N == 0:
+				for _, day := range matches {
+					byDay[day] = true
+				}
+			case entry.N > 0 && entry.N <= len(matches):
+				byDay[matches[entry.N-1]] = true
+			case entry.N < 0 && -entry.N <= len(matches):
+				byDay[matches[len(matches)+entry.N]] = true
+			}
+		}
+		if len(r.ByMonthDay) > 0 {
+			for day := range picked {
+				if !byDay[day] {
+					delete(picked, day)
+				}
+			}
+		} else {
+			picked = byDay
+		}
+	}
+
+	days := make([]time.Time, 0, len(picked))
+	for day := 1; day <= last; day++ {
+		if picked[day] {
+			days = append(days, month.AddDate(0, 0, day-1))
+		}
+	}
+	return days
+}
+
+// filter drops days outside BYMONTH, and for daily and weekly rules those
+// not on a BYDAY weekday or BYMONTHDAY
+func (r *RRule) filter(days []time.Time) []time.Time {
+	kept := days[:0]
+	for _, day := range days {
+		if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, day.Month()) {
+			continue
+		}
+		if r.Freq == "DAILY" || r.Freq == "WEEKLY" {
+			if len(r.ByDay) > 0 && !containsWeekday(r.ByDay, day.Weekday()) {
+				continue
+			}
+			if len(r.ByMonthDay) > 0 && !matchesMonthDay(r.ByMonthDay, day) {
+				continue
+			}
+		}
+		kept = append(kept, day)
+	}
+	return kept
+}
+
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, m := range months {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func containsWeekday(days []RRuleWeekday, weekday time.Weekday) bool {
+	for _, d := range days {
+		if d.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesMonthDay(monthDays []int, day time.Time) bool {
+	last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+	for _, n := range monthDays {
+		if n == day.Day() || (n < 0 && last+n+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
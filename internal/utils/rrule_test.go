@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestRRuleBetween(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("failed to load time zone: %v", err)
+	}
+	at := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, newYork)
+	}
+
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		to    time.Time
+		want  []time.Time
+	}{
+		{
+			// Clocks go forward on 10 March 2024
+			name:  "weekly across daylight saving change",
+			rule:  "FREQ=WEEKLY;BYDAY=WE",
+			start: at(2024, time.March, 6, 9),
+			to:    at(2024, time.March, 21, 0),
+			want:  []time.Time{at(2024, time.March, 6, 9), at(2024, time.March, 13, 9), at(2024, time.March, 20, 9)},
+		},
+		{
+			name:  "interval with count",
+			rule:  "FREQ=DAILY;INTERVAL=2;COUNT=3",
+			start: at(2024, time.January, 1, 10),
+			to:    at(2025, time.January, 1, 0),
+			want:  []time.Time{at(2024, time.January, 1, 10), at(2024, time.January, 3, 10), at(2024, time.January, 5, 10)},
+		},
+		{
+			name:  "last Friday of the month",
+			rule:  "RRULE:FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			start: at(2024, time.January, 26, 19),
+			to:    at(2025, time.January, 1, 0),
+			want:  []time.Time{at(2024, time.January, 26, 19), at(2024, time.February, 23, 19), at(2024, time.March, 29, 19)},
+		},
+		{
+			name:  "31st skips short months",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=4",
+			start: at(2024, time.January, 31, 9),
+			to:    at(2025, time.January, 1, 0),
+			want:  []time.Time{at(2024, time.January, 31, 9), at(2024, time.March, 31, 9), at(2024, time.May, 31, 9), at(2024, time.July, 31, 9)},
+		},
+		{
+			name:  "until date includes that day",
+			rule:  "FREQ=WEEKLY;BYDAY=SU;UNTIL=20240317",
+			start: at(2024, time.March, 3, 18),
+			to:    at(2025, time.January, 1, 0),
+			want:  []time.Time{at(2024, time.March, 3, 18), at(2024, time.March, 10, 18), at(2024, time.March, 17, 18)},
+		},
+		{
+			name:  "yearly on 29 February",
+			rule:  "FREQ=YEARLY;COUNT=3",
+			start: at(2024, time.February, 29, 12),
+			to:    at(2040, time.January, 1, 0),
+			want:  []time.Time{at(2024, time.February, 29, 12), at(2028, time.February, 29, 12), at(2032, time.February, 29, 12)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+			}
+			got := rule.Between(tt.start, tt.start, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d occurrences %v, want %v", len(got), got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("occurrence %d: got %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseRRuleRejectsInvalidRules(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"BYDAY=MO",
+		"FREQ=HOURLY",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6FR",
+		"FREQ=DAILY;COUNT=3;UNTIL=20240317",
+	} {
+		if _, err := ParseRRule(rule); !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("ParseRRule(%q): got %v, want ErrInvalidInput", rule, err)
+		}
+	}
+}
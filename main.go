@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -13,17 +15,32 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/websocket/v2"
-	"gorm.io/gorm"
 
 	"go_pco_arrivals/internal/config"
 	"go_pco_arrivals/internal/database"
 	"go_pco_arrivals/internal/handlers"
 	"go_pco_arrivals/internal/middleware"
+	"go_pco_arrivals/internal/repository"
 	"go_pco_arrivals/internal/services"
 	"go_pco_arrivals/internal/utils"
 )
 
 func main() {
+	demo := flag.Bool("demo", false, "run with in-memory storage seeded with sample data")
+	flag.Parse()
+
+	// Dispatch maintenance subcommands before starting the server
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Arg(0), flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *demo {
+		setDemoEnvironment()
+	}
+
 	// Validate environment variables
 	if err := validateEnvironment(); err != nil {
 		log.Fatal("Environment validation failed:", err)
@@ -38,41 +55,75 @@ func main() {
 		appLogger.Fatal("Failed to load configuration", "error", err)
 	}
 
-	// Initialize database
-	db, err := database.Connect(cfg.Database)
-	if err != nil {
-		appLogger.Fatal("Failed to connect to database", "error", err)
-	}
-
-	// Run migrations
-	if err := db.Migrate(); err != nil {
-		appLogger.Fatal("Failed to run database migrations", "error", err)
+	// Configure encryption of PCO tokens at rest
+	if err := configureTokenEncryption(cfg, appLogger); err != nil {
+		appLogger.Fatal("Failed to configure token encryption", "error", err)
 	}
 
-	// Get the appropriate database connection for services
-	var gormDB *gorm.DB
-	if db.GetType() == database.SQLiteDB {
-		sqliteDB := db.(*database.SQLiteDatabase)
-		gormDB = sqliteDB.GetGormDB()
-		// Configure database connection pool for SQLite
-		if err := database.ConfigureConnectionPool(gormDB, cfg.Database.MaxOpenConns, cfg.Database.MaxIdleConns, time.Duration(cfg.Database.ConnMaxLifetime)*time.Second); err != nil {
-			appLogger.Fatal("Failed to configure database pool", "error", err)
-		}
+	// Initialize storage
+	var db database.Database
+	var store *repository.Store
+	if *demo {
+		appLogger.Warn("Demo mode: using in-memory storage, nothing will be saved")
+		store = repository.NewMemoryStore()
+	} else {
+		db, store = openDatabase(cfg, appLogger)
 	}
 
 	// Initialize logger
 	logger := utils.NewLogger()
 
 	// Initialize services
-	pcoService := services.NewPCOService(cfg, gormDB, logger)
-	authService := services.NewAuthService(cfg, gormDB, logger, pcoService)
-	notificationService := services.NewNotificationService(gormDB, pcoService)
-	billboardService := services.NewBillboardService(cfg, gormDB, logger, pcoService, nil) // TODO: Add WebSocket service
-
+	rollupService := services.NewRollupService(store, logger)
+	pcoService := services.NewPCOService(cfg, store, logger, rollupService)
+	authService := services.NewAuthService(cfg, store, logger, pcoService)
+	notificationService := services.NewNotificationService(store, pcoService)
+	auditService := services.NewAuditService(cfg, store, logger)
+	// Demo mode has no PCO account to sync events and locations from
+	eventPCO := pcoService
+	if *demo {
+		eventPCO = nil
+	}
+	eventService := services.NewEventService(store, eventPCO, logger)
+	locationService := services.NewLocationService(store, eventPCO, auditService, logger)
+	guardianService := services.NewGuardianService(cfg, pcoService, logger)
+	reportService := services.NewReportService(store, logger)
 	// Initialize WebSocket hub
 	wsHub := services.NewWebSocketHub()
 	go wsHub.Run()
 
+	billboardService := services.NewBillboardService(cfg, store, logger, pcoService, rollupService, guardianService, wsHub)
+	backupService, err := services.NewBackupService(cfg.Backup, db, logger)
+	if err != nil {
+		appLogger.Fatal("Failed to configure backups", "error", err)
+	}
+
+	if *demo {
+		admin, err := seedDemoData(store, time.Now())
+		if err != nil {
+			appLogger.Fatal("Failed to seed demo data", "error", err)
+		}
+		if _, err := rollupService.Rebuild(time.Now().AddDate(0, 0, -1)); err != nil {
+			appLogger.Fatal("Failed to build demo rollups", "error", err)
+		}
+		session, err := authService.CreateSession(admin, true, services.SessionMetadata{UserAgent: "demo"})
+		if err != nil {
+			appLogger.Fatal("Failed to create demo session", "error", err)
+		}
+		appLogger.Info("Demo data seeded, set the session_token cookie to sign in as the demo admin", "session_token", session.Token)
+	}
+
+	// Launch and clear the billboards of scheduled events
+	scheduler := services.NewBillboardScheduler(store, billboardService, auditService, logger)
+	scheduler.Start()
+
+	// Keep locations in step with PCO, using an admin's token
+	locationService.Start(time.Duration(cfg.Realtime.LocationSyncInterval)*time.Second, authService.AdminAccessToken)
+
+	// Alert admins when rooms near their capacity or volunteer ratio
+	occupancyService := services.NewOccupancyService(cfg, store, wsHub, logger)
+	occupancyService.Start()
+
 	// Initialize cleanup service
 	cleanupService := services.NewCleanupService(db, notificationService)
 	go cleanupService.Start()
@@ -94,7 +145,7 @@ func main() {
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     strings.Join(cfg.Server.CORSOrigins, ","),
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization," + middleware.CSRFHeader,
 		AllowCredentials: true,
 	}))
 	// TODO: Add proper logging middleware
@@ -105,32 +156,27 @@ func main() {
 		c.Locals("auth_service", authService)
 		return c.Next()
 	})
-
-	// Initialize handlers based on database type
-	var authHandler *handlers.AuthHandler
-	var apiHandler *handlers.APIHandler
-	var healthHandler *handlers.HealthHandler
-	var billboardHandler *handlers.BillboardHandler
-
-	if db.GetType() == database.SQLiteDB {
-		// SQLite handlers
-		authHandler = handlers.NewAuthHandler(cfg, gormDB, logger, authService, pcoService)
-		apiHandler = handlers.NewAPIHandler(gormDB, pcoService, notificationService, billboardService, wsHub, logger)
-		healthHandler = handlers.NewHealthHandler(gormDB)
-		billboardHandler = handlers.NewBillboardHandler(cfg, gormDB, logger, billboardService, pcoService)
-	} else if db.GetType() == database.MongoDBDB {
-		// MongoDB handlers - these need to be updated to handle nil GORM DB
-		authHandler = handlers.NewAuthHandler(cfg, nil, logger, authService, pcoService)
-		apiHandler = handlers.NewAPIHandler(nil, pcoService, notificationService, billboardService, wsHub, logger)
-		healthHandler = handlers.NewHealthHandler(nil)
-		billboardHandler = handlers.NewBillboardHandler(cfg, nil, logger, billboardService, pcoService)
-	}
-
+	app.Use(middleware.CSRFProtection())
+
+	// Initialize handlers
+	authHandler := handlers.NewAuthHandler(cfg, store, logger, authService, pcoService, auditService)
+	apiHandler := handlers.NewAPIHandler(store, pcoService, notificationService, billboardService, rollupService, eventService, occupancyService, wsHub, auditService, logger)
+	healthHandler := handlers.NewHealthHandler(store)
+	billboardHandler := handlers.NewBillboardHandler(cfg, store, logger, billboardService, pcoService, auditService)
+
+	sessionHandler := handlers.NewSessionHandler(authService, logger)
+	apiKeyHandler := handlers.NewAPIKeyHandler(cfg, authService, logger)
+	auditHandler := handlers.NewAuditHandler(auditService, logger)
+	userHandler := handlers.NewUserHandler(authService, auditService, logger)
+	backupHandler := handlers.NewBackupHandler(backupService, authService, auditService, logger)
 	staticHandler := handlers.NewStaticHandler()
 	websocketHandler := handlers.NewWebSocketHandler(wsHub, authService)
+	calendarHandler := handlers.NewCalendarHandler(authService, eventService, logger)
+	locationHandler := handlers.NewLocationHandler(locationService, authService, auditService, logger)
+	reportHandler := handlers.NewReportHandler(cfg, reportService, logger)
 
 	// Setup routes
-	setupRoutes(app, authHandler, apiHandler, staticHandler, websocketHandler, healthHandler, billboardHandler)
+	setupRoutes(app, authHandler, apiHandler, sessionHandler, apiKeyHandler, auditHandler, userHandler, backupHandler, staticHandler, websocketHandler, healthHandler, billboardHandler, calendarHandler, locationHandler, reportHandler)
 
 	// Start server
 	go func() {
@@ -152,14 +198,58 @@ func main() {
 	// Stop cleanup service
 	cleanupService.Stop()
 
+	// Stop billboard scheduler
+	scheduler.Stop()
+
+	// Stop location sync
+	locationService.Stop()
+
+	// Stop occupancy monitor
+	occupancyService.Stop()
+
 	// Close database connection
-	if err := db.Close(); err != nil {
-		appLogger.Error("Failed to close database connection", "error", err)
+	if db != nil {
+		if err := db.Close(); err != nil {
+			appLogger.Error("Failed to close database connection", "error", err)
+		}
 	}
 
 	appLogger.Info("Server stopped")
 }
 
+// openDatabase connects to the configured database, brings its schema up to
+// date and returns it with its repositories
+func openDatabase(cfg *config.Config, logger *utils.Logger) (database.Database, *repository.Store) {
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		logger.Fatal("Failed to connect to database", "error", err)
+	}
+
+	// Run migrations
+	if err := db.Migrate(); err != nil {
+		if errors.Is(err, database.ErrSchemaAhead) {
+			logger.Fatal("Refusing to start: the database was migrated by a newer release", "error", err)
+		}
+		logger.Fatal("Failed to run database migrations", "error", err)
+	}
+
+	if db.GetType() == database.SQLiteDB {
+		// Configure database connection pool for SQLite
+		gormDB := db.(*database.SQLiteDatabase).GetGormDB()
+		if err := database.ConfigureConnectionPool(gormDB, cfg.Database.MaxOpenConns, cfg.Database.MaxIdleConns, time.Duration(cfg.Database.ConnMaxLifetime)*time.Second); err != nil {
+			logger.Fatal("Failed to configure database pool", "error", err)
+		}
+	}
+
+	// Build the repositories for the configured backend
+	store, err := repository.New(db)
+	if err != nil {
+		logger.Fatal("Failed to initialize repositories", "error", err)
+	}
+
+	return db, store
+}
+
 func validateEnvironment() error {
 	required := []string{"PCO_CLIENT_ID", "PCO_CLIENT_SECRET", "PCO_REDIRECT_URI"}
 	for _, env := range required {
@@ -170,7 +260,7 @@ func validateEnvironment() error {
 	return nil
 }
 
-func setupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, apiHandler *handlers.APIHandler, staticHandler *handlers.StaticHandler, websocketHandler *handlers.WebSocketHandler, healthHandler *handlers.HealthHandler, billboardHandler *handlers.BillboardHandler) {
+func setupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, apiHandler *handlers.APIHandler, sessionHandler *handlers.SessionHandler, apiKeyHandler *handlers.APIKeyHandler, auditHandler *handlers.AuditHandler, userHandler *handlers.UserHandler, backupHandler *handlers.BackupHandler, staticHandler *handlers.StaticHandler, websocketHandler *handlers.WebSocketHandler, healthHandler *handlers.HealthHandler, billboardHandler *handlers.BillboardHandler, calendarHandler *handlers.CalendarHandler, locationHandler *handlers.LocationHandler, reportHandler *handlers.ReportHandler) {
 	// Health check
 	app.Get("/health", healthHandler.Health)
 	app.Get("/health/detailed", healthHandler.DetailedHealth)
@@ -184,14 +274,22 @@ func setupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, apiHandler *
 	auth.Post("/refresh", authHandler.RefreshToken)
 	auth.Get("/profile", authHandler.GetUserProfile)
 	auth.Put("/profile", authHandler.UpdateUserProfile)
+	auth.Post("/token", apiKeyHandler.IssueToken)
+
+	// The calendar feed authenticates with its URL token, so it is
+	// registered ahead of the /api auth middleware
+	app.Get("/api/calendar.ics", calendarHandler.GetCalendar)
 
 	// API routes
 	api := app.Group("/api", middleware.RequireAuth())
 	api.Get("/events", apiHandler.GetEvents)
 	api.Get("/events/:id", apiHandler.GetEvent)
 	api.Post("/events", middleware.RequireAdmin(), apiHandler.CreateEvent)
+	api.Post("/events/sync", middleware.RequireAdmin(), apiHandler.SyncEvents)
 	api.Put("/events/:id", middleware.RequireAdmin(), apiHandler.UpdateEvent)
 	api.Delete("/events/:id", middleware.RequireAdmin(), apiHandler.DeleteEvent)
+	api.Post("/calendar/token", calendarHandler.CreateCalendarToken)
+	api.Delete("/calendar/token", calendarHandler.DeleteCalendarToken)
 
 	api.Get("/notifications", apiHandler.GetNotifications)
 	api.Post("/notifications", apiHandler.CreateNotification)
@@ -207,15 +305,48 @@ func setupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, apiHandler *
 	api.Post("/billboard/clear", apiHandler.ClearBillboard)
 	api.Get("/billboard/stats/:locationId", apiHandler.GetCheckInStats)
 
+	// Session management
+	api.Get("/sessions", sessionHandler.ListMySessions)
+	api.Delete("/sessions", sessionHandler.RevokeMySessions)
+	api.Delete("/sessions/:id", sessionHandler.RevokeSession)
+	api.Get("/admin/sessions", middleware.RequireAdmin(), sessionHandler.ListAllSessions)
+	api.Delete("/admin/users/:userId/sessions", middleware.RequireAdmin(), sessionHandler.RevokeUserSessions)
+
+	// API key management (admin only)
+	api.Get("/api-keys", middleware.RequireAdmin(), apiKeyHandler.ListAPIKeys)
+	api.Post("/api-keys", middleware.RequireAdmin(), apiKeyHandler.CreateAPIKey)
+	api.Delete("/api-keys/:id", middleware.RequireAdmin(), apiKeyHandler.RevokeAPIKey)
+
+	// Audit log and user roles (admin only)
+	api.Get("/audit", middleware.RequireAdmin(), auditHandler.ListAuditEvents)
+	api.Put("/admin/users/:id/role", middleware.RequireAdmin(), userHandler.UpdateUserRole)
+	api.Put("/admin/users/:id/medical-access", middleware.RequireAdmin(), userHandler.UpdateMedicalAccess)
+	api.Put("/admin/users/:id/contact-access", middleware.RequireAdmin(), userHandler.UpdateContactAccess)
+
+	// Database backups (admin only)
+	api.Get("/admin/backups", middleware.RequireAdmin(), backupHandler.ListBackups)
+	api.Post("/admin/backups", middleware.RequireAdmin(), backupHandler.CreateBackup)
+	api.Get("/admin/backups/:name", middleware.RequireAdmin(), backupHandler.DownloadBackup)
+
 	api.Get("/check-ins", apiHandler.GetCheckIns)
 	api.Get("/check-ins/location/:locationId", apiHandler.GetCheckInsByLocation)
 	api.Get("/check-ins/event/:eventId", apiHandler.GetCheckInsByEvent)
 
 	api.Get("/locations", apiHandler.GetLocations)
+	// Before /locations/:id, which would match it
+	api.Get("/locations/overview", apiHandler.GetLocationsOverview)
 	api.Get("/locations/:id", apiHandler.GetLocation)
 	api.Get("/locations/:locationId/status", apiHandler.GetLocationStatus)
+	api.Get("/locations/:locationId/roster", apiHandler.GetLocationRoster)
 	api.Get("/locations/:locationId/analytics", apiHandler.GetLocationAnalytics)
-	api.Get("/locations/overview", apiHandler.GetLocationsOverview)
+	api.Get("/admin/locations", middleware.RequireAdmin(), locationHandler.GetLocationTree)
+	api.Get("/admin/locations/sync", middleware.RequireAdmin(), locationHandler.GetSyncReport)
+	api.Post("/admin/locations/sync", middleware.RequireAdmin(), locationHandler.SyncLocations)
+	api.Put("/admin/locations/:id", middleware.RequireAdmin(), locationHandler.UpdateLocation)
+
+	// Reports
+	api.Get("/reports/first-time-guests", reportHandler.GetFirstTimeGuests)
+	api.Get("/reports/attendance", reportHandler.GetAttendance)
 
 	// Test endpoint for WebSocket broadcasts (development only)
 	app.Get("/test/websocket", apiHandler.TestWebSocketBroadcast)
@@ -227,7 +358,6 @@ func setupRoutes(app *fiber.App, authHandler *handlers.AuthHandler, apiHandler *
 	billboard.Get("/stats/:locationID", billboardHandler.GetCheckInStats)
 	billboard.Post("/sync/:locationID", billboardHandler.SyncPCOCheckIns)
 	billboard.Get("/locations", billboardHandler.GetLocations)
-	billboard.Post("/locations", billboardHandler.AddLocation)
 	billboard.Get("/location/:locationID", billboardHandler.GetLocationBillboard)
 	billboard.Post("/cleanup", billboardHandler.CleanupOldData)
 	billboard.Get("/status", billboardHandler.GetSystemStatus)
@@ -42,18 +42,14 @@ log "${GREEN}Starting backup: $BACKUP_NAME${NC}"
 TEMP_DIR="/tmp/$BACKUP_NAME"
 mkdir -p "$TEMP_DIR"
 
-# Copy database files
-if [ -f "$DATA_DIR/pco_billboard.db" ]; then
+# Snapshot the database. The app's backup command is safe while the server
+# is running; copying the SQLite file directly is not.
+if [ -x "/app/main" ]; then
     log "Backing up database..."
-    cp "$DATA_DIR/pco_billboard.db" "$TEMP_DIR/"
-    
-    # Create SQL dump for better portability
-    if command -v sqlite3 >/dev/null 2>&1; then
-        log "Creating SQL dump..."
-        sqlite3 "$DATA_DIR/pco_billboard.db" ".dump" > "$TEMP_DIR/pco_billboard.sql"
-    fi
+    SNAPSHOT=$(BACKUP_DIR="$TEMP_DIR" BACKUP_RETENTION=0 /app/main backup) || error_exit "Database backup failed"
+    log "Database snapshot: $(basename "$SNAPSHOT")"
 else
-    log "${YELLOW}Warning: Database file not found${NC}"
+    log "${YELLOW}Warning: /app/main not found, skipping database backup${NC}"
 fi
 
 # Copy configuration files (if they exist)
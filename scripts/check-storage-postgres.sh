@@ -0,0 +1,67 @@
+#!/bin/bash
+
+# PCO Arrivals Dashboard - PostgreSQL Storage Check
+# This script starts a throwaway PostgreSQL cluster from the local
+# initdb/pg_ctl binaries (no container needed), runs the repository
+# conformance suite against it and removes the cluster afterwards.
+#
+# Usage: scripts/check-storage-postgres.sh
+#   PG_BIN   directory containing initdb and pg_ctl (default: from PATH)
+#   PG_PORT  port for the temporary server (default: 54329)
+
+set -e
+
+PG_PORT=${PG_PORT:-54329}
+if [ -n "$PG_BIN" ]; then
+    PATH="$PG_BIN:$PATH"
+fi
+
+# Colors for output
+RED='\033[0;31m'
+GREEN='\033[0;32m'
+YELLOW='\033[1;33m'
+NC='\033[0m' # No Color
+
+# Logging function
+log() {
+    echo -e "[$(date +'%Y-%m-%d %H:%M:%S')] $1"
+}
+
+# Error handling
+error_exit() {
+    log "${RED}ERROR: $1${NC}" >&2
+    exit 1
+}
+
+command -v initdb >/dev/null 2>&1 || error_exit "initdb not found, install PostgreSQL or set PG_BIN"
+command -v pg_ctl >/dev/null 2>&1 || error_exit "pg_ctl not found, install PostgreSQL or set PG_BIN"
+[ "$(id -u)" -ne 0 ] || error_exit "PostgreSQL refuses to run as root, run this script as a regular user"
+
+if [ ! -f "go.mod" ]; then
+    error_exit "Please run this script from the project root directory"
+fi
+
+DATA_DIR=$(mktemp -d)
+
+cleanup() {
+    pg_ctl -D "$DATA_DIR" -m immediate stop >/dev/null 2>&1 || true
+    rm -rf "$DATA_DIR"
+}
+trap cleanup EXIT
+
+log "${YELLOW}Initializing temporary cluster in $DATA_DIR${NC}"
+initdb -D "$DATA_DIR" -U postgres -A trust >/dev/null
+
+log "${YELLOW}Starting PostgreSQL on port $PG_PORT${NC}"
+pg_ctl -D "$DATA_DIR" -l "$DATA_DIR/server.log" -w \
+    -o "-p $PG_PORT -k $DATA_DIR -c listen_addresses=''" start >/dev/null
+
+log "${YELLOW}Running conformance suite${NC}"
+DATABASE_TYPE=postgres \
+DATABASE_URL="host=$DATA_DIR port=$PG_PORT user=postgres dbname=postgres sslmode=disable" \
+PCO_CLIENT_ID=${PCO_CLIENT_ID:-check-storage} \
+PCO_CLIENT_SECRET=${PCO_CLIENT_SECRET:-check-storage} \
+PCO_REDIRECT_URI=${PCO_REDIRECT_URI:-http://localhost/auth/callback} \
+    go run . check-storage
+
+log "${GREEN}PostgreSQL storage passed the conformance suite${NC}"